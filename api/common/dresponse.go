@@ -38,6 +38,22 @@ func NewBadRequestResponse() DefaultResponse {
 	}
 }
 
+//NewUnauthorizedResponse default unauthorized error response
+func NewUnauthorizedResponse() DefaultResponse {
+	return DefaultResponse{
+		401,
+		"Unauthorized",
+	}
+}
+
+//NewForbiddenResponse default forbidden error response
+func NewForbiddenResponse() DefaultResponse {
+	return DefaultResponse{
+		403,
+		"Forbidden",
+	}
+}
+
 //NewConflictResponse default not found error response
 func NewConflictResponse() DefaultResponse {
 	return DefaultResponse{
@@ -45,3 +61,26 @@ func NewConflictResponse() DefaultResponse {
 		"Data Has Been Modified",
 	}
 }
+
+//FieldError one invalid request field
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+//ValidationErrorResponse payload listing every invalid request field
+type ValidationErrorResponse struct {
+	Code    int          `json:"code"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors"`
+}
+
+//NewValidationErrorResponse default unprocessable entity response
+func NewValidationErrorResponse(errors []FieldError) ValidationErrorResponse {
+	return ValidationErrorResponse{
+		422,
+		"Unprocessable Entity",
+		errors,
+	}
+}
@@ -27,8 +27,24 @@ func TestDresponse(t *testing.T) {
 		assert.Equal(t, BadRequest.Message, "Bad Request")
 	})
 
+	t.Run("func NewUnauthorizedResponse()", func(t *testing.T) {
+		Unauthorized := NewUnauthorizedResponse()
+		assert.Equal(t, Unauthorized.Message, "Unauthorized")
+	})
+
+	t.Run("func NewForbiddenResponse()", func(t *testing.T) {
+		Forbidden := NewForbiddenResponse()
+		assert.Equal(t, Forbidden.Message, "Forbidden")
+	})
+
 	t.Run("func NewConflictResponse()", func(t *testing.T) {
 		Conflict := NewConflictResponse()
 		assert.Equal(t, Conflict.Message, "Data Has Been Modified")
 	})
+
+	t.Run("func NewValidationErrorResponse()", func(t *testing.T) {
+		Validation := NewValidationErrorResponse([]FieldError{{Field: "title", Rule: "required"}})
+		assert.Equal(t, Validation.Message, "Unprocessable Entity")
+		assert.Equal(t, Validation.Errors[0].Field, "title")
+	})
 }
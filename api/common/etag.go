@@ -0,0 +1,60 @@
+package common
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+// headers of conditional requests, RFC 7232
+const (
+	HeaderETag    = "ETag"
+	HeaderIfMatch = "If-Match"
+)
+
+//ETag entity tag of a record version
+func ETag(version uint) string {
+	return strconv.Quote(strconv.FormatUint(uint64(version), 10))
+}
+
+//SetETag send the version of the returned record as ETag header
+func SetETag(c echo.Context, version uint) {
+	c.Response().Header().Set(HeaderETag, ETag(version))
+}
+
+//IfMatch version required by the If-Match header. Writes must be
+//conditional, a missing header is a precondition required problem. "*"
+//asks for an unconditional write, zero then accepts any version
+func IfMatch(c echo.Context) (uint, error) {
+	header := strings.TrimSpace(c.Request().Header.Get(HeaderIfMatch))
+	if header == "" {
+		return 0, NewPreconditionRequiredProblem()
+	}
+	if header == "*" {
+		return 0, nil
+	}
+
+	// weak tags never match, RFC 7232 asks for a strong comparison
+	value, err := strconv.Unquote(header)
+	if err != nil || strings.HasPrefix(header, "W/") {
+		return 0, NewPreconditionFailedProblem("If-Match must be a single entity tag returned by this api")
+	}
+
+	version, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || version == 0 {
+		return 0, NewPreconditionFailedProblem("If-Match does not match the current entity tag")
+	}
+	return uint(version), nil
+}
+
+//NewPreconditionRequiredProblem write without If-Match problem
+func NewPreconditionRequiredProblem() *Problem {
+	return NewProblem(http.StatusPreconditionRequired, "precondition_required", "send the ETag of the record in If-Match, or * to overwrite any version")
+}
+
+//NewPreconditionFailedProblem If-Match did not hold problem
+func NewPreconditionFailedProblem(detail string) *Problem {
+	return NewProblem(http.StatusPreconditionFailed, "precondition_failed", detail)
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIfMatch(t *testing.T) {
+	cases := []struct {
+		header  string
+		version uint
+		fails   bool
+	}{
+		{"*", 0, false},
+		{`"3"`, 3, false},
+		{`W/"3"`, 0, true},
+		{"3", 0, true},
+		{`"abc"`, 0, true},
+	}
+
+	for _, c := range cases {
+		t.Run("If-Match "+c.header, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPut, "/", nil)
+			if c.header != "" {
+				req.Header.Set(HeaderIfMatch, c.header)
+			}
+			context := echo.New().NewContext(req, httptest.NewRecorder())
+
+			version, err := IfMatch(context)
+			assert.Equal(t, c.version, version)
+			if c.fails {
+				assert.Equal(t, http.StatusPreconditionFailed, ToProblem(err).Status)
+			} else {
+				assert.Nil(t, err)
+			}
+		})
+	}
+
+	t.Run("If-Match missing", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/", nil)
+		context := echo.New().NewContext(req, httptest.NewRecorder())
+
+		_, err := IfMatch(context)
+		assert.Equal(t, http.StatusPreconditionRequired, ToProblem(err).Status)
+		assert.Equal(t, "precondition_required", ToProblem(err).Code)
+	})
+
+	t.Run("ETag round trip", func(t *testing.T) {
+		assert.Equal(t, `"7"`, ETag(7))
+	})
+}
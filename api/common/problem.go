@@ -0,0 +1,157 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+//MIMEApplicationProblemJSON content type of Problem responses, RFC 7807
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+//Problem error payload following RFC 7807, Code is a stable machine
+//readable error code and Type a URI reference derived from it
+type Problem struct {
+	Type     string       `json:"type"`
+	Title    string       `json:"title"`
+	Status   int          `json:"status"`
+	Detail   string       `json:"detail,omitempty"`
+	Instance string       `json:"instance,omitempty"`
+	Code     string       `json:"code"`
+	Errors   []FieldError `json:"errors,omitempty"`
+}
+
+//NewProblem problem with the given status, error code and detail
+func NewProblem(status int, code, detail string) *Problem {
+	return &Problem{
+		Type:   "/problems/" + strings.ReplaceAll(code, "_", "-"),
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+func (p *Problem) Error() string {
+	if p.Detail == "" {
+		return fmt.Sprintf("%v: %v", p.Code, p.Title)
+	}
+	return fmt.Sprintf("%v: %v", p.Code, p.Detail)
+}
+
+//NewBadRequestProblem malformed request problem
+func NewBadRequestProblem(detail string) *Problem {
+	return NewProblem(http.StatusBadRequest, "bad_request", detail)
+}
+
+//NewValidationProblem problem listing every invalid request field
+func NewValidationProblem(errors []FieldError) *Problem {
+	problem := NewProblem(http.StatusUnprocessableEntity, "validation_failed", "the request has invalid fields")
+	problem.Errors = errors
+	return problem
+}
+
+//NewUnauthorizedProblem missing or bad credentials problem
+func NewUnauthorizedProblem(code, detail string) *Problem {
+	return NewProblem(http.StatusUnauthorized, code, detail)
+}
+
+//NewForbiddenProblem not allowed for the caller problem
+func NewForbiddenProblem(detail string) *Problem {
+	return NewProblem(http.StatusForbidden, "forbidden", detail)
+}
+
+//NewNotFoundProblem missing resource problem
+func NewNotFoundProblem(detail string) *Problem {
+	return NewProblem(http.StatusNotFound, "not_found", detail)
+}
+
+//NewInternalServerErrorProblem unexpected failure problem, the cause is
+//logged but never sent to the client
+func NewInternalServerErrorProblem() *Problem {
+	return NewProblem(http.StatusInternalServerError, "internal_error", "")
+}
+
+// knownProblem status and code answering a registered error
+type knownProblem struct {
+	err    error
+	status int
+	code   string
+}
+
+var problemLock = &sync.RWMutex{}
+var knownProblems = []knownProblem{}
+
+//RegisterProblem answer err, and every error wrapping it, with status
+//and code. The package mapping the model errors is api/problems
+func RegisterProblem(err error, status int, code string) {
+	problemLock.Lock()
+	defer problemLock.Unlock()
+
+	if err == nil {
+		panic("common: RegisterProblem err is nil")
+	}
+	knownProblems = append(knownProblems, knownProblem{err, status, code})
+}
+
+//ToProblem map any error to a problem: problems pass through, echo errors
+//keep their status and registered errors get their own status and code
+func ToProblem(err error) *Problem {
+	var problem *Problem
+	if errors.As(err, &problem) {
+		return problem
+	}
+
+	problemLock.RLock()
+	defer problemLock.RUnlock()
+	for _, known := range knownProblems {
+		if errors.Is(err, known.err) {
+			return NewProblem(known.status, known.code, err.Error())
+		}
+	}
+
+	var httpError *echo.HTTPError
+	if errors.As(err, &httpError) {
+		code := strings.ToLower(strings.ReplaceAll(http.StatusText(httpError.Code), " ", "_"))
+		if code == "" {
+			code = "http_error"
+		}
+		return NewProblem(httpError.Code, code, fmt.Sprint(httpError.Message))
+	}
+
+	return NewInternalServerErrorProblem()
+}
+
+//ErrorResponse write err as an application/problem+json document
+func ErrorResponse(c echo.Context, err error) error {
+	problem := *ToProblem(err)
+	if problem.Instance == "" {
+		problem.Instance = c.Request().URL.Path
+	}
+
+	if problem.Status >= http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationProblemJSON)
+	if c.Request().Method == http.MethodHead {
+		return c.NoContent(problem.Status)
+	}
+	return c.JSON(problem.Status, problem)
+}
+
+//HTTPErrorHandler echo error handler answering every error, including
+//the ones returned by handlers and middlewares, with a problem document
+func HTTPErrorHandler(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	if err := ErrorResponse(c, err); err != nil {
+		c.Logger().Error(err)
+	}
+}
@@ -0,0 +1,63 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblem(t *testing.T) {
+	t.Run("func NewNotFoundProblem()", func(t *testing.T) {
+		NotFound := NewNotFoundProblem("book 1 does not exist")
+		assert.Equal(t, 404, NotFound.Status)
+		assert.Equal(t, "Not Found", NotFound.Title)
+		assert.Equal(t, "not_found", NotFound.Code)
+		assert.Equal(t, "/problems/not-found", NotFound.Type)
+	})
+
+	t.Run("func ToProblem() registered error", func(t *testing.T) {
+		errOutOfPaper := errors.New("out of paper")
+		RegisterProblem(errOutOfPaper, http.StatusServiceUnavailable, "out_of_paper")
+
+		problem := ToProblem(fmt.Errorf("print: %w", errOutOfPaper))
+		assert.Equal(t, 503, problem.Status)
+		assert.Equal(t, "out_of_paper", problem.Code)
+	})
+
+	t.Run("func ToProblem() echo error", func(t *testing.T) {
+		problem := ToProblem(echo.ErrMethodNotAllowed)
+		assert.Equal(t, 405, problem.Status)
+		assert.Equal(t, "method_not_allowed", problem.Code)
+	})
+
+	t.Run("func ToProblem() unknown error", func(t *testing.T) {
+		problem := ToProblem(errors.New("connection refused"))
+		assert.Equal(t, 500, problem.Status)
+		assert.Empty(t, problem.Detail)
+	})
+}
+
+func TestHTTPErrorHandler(t *testing.T) {
+	e := echo.New()
+	e.HTTPErrorHandler = HTTPErrorHandler
+
+	req := httptest.NewRequest(http.MethodGet, "/books/1/unknown", nil)
+	res := httptest.NewRecorder()
+	e.ServeHTTP(res, req)
+
+	var response Problem
+	json.Unmarshal(res.Body.Bytes(), &response)
+
+	t.Run("unknown route", func(t *testing.T) {
+		assert.Equal(t, 404, res.Code)
+		assert.Equal(t, "application/problem+json", res.Header().Get("Content-Type"))
+		assert.Equal(t, "not_found", response.Code)
+		assert.Equal(t, "/books/1/unknown", response.Instance)
+	})
+}
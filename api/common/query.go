@@ -0,0 +1,123 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+//HeaderTotalCount number of records matching a list request
+const HeaderTotalCount = "X-Total-Count"
+
+//QueryFilter query string parameter filtering a list
+type QueryFilter struct {
+	Param string
+	Field string
+	Op    string
+	Time  bool
+}
+
+//ParseQuery read limit, offset, cursor, sort and the given filters of a
+//list request, sort is a comma separated list of fields, - sorts
+//descending
+func ParseQuery(c echo.Context, filters []QueryFilter) (models.Query, error) {
+	var query models.Query
+	var err error
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil || query.Limit < 1 {
+			return query, NewBadRequestProblem("limit must be a positive integer")
+		}
+	}
+
+	if offset := c.QueryParam("offset"); offset != "" {
+		if query.Offset, err = strconv.Atoi(offset); err != nil || query.Offset < 0 {
+			return query, NewBadRequestProblem("offset must be a non negative integer")
+		}
+	}
+
+	query.Cursor = c.QueryParam("cursor")
+
+	if sort := c.QueryParam("sort"); sort != "" {
+		for _, field := range strings.Split(sort, ",") {
+			field = strings.TrimSpace(field)
+			desc := strings.HasPrefix(field, "-")
+			query.Sort = append(query.Sort, models.Sort{Field: strings.TrimPrefix(field, "-"), Desc: desc})
+		}
+	}
+
+	for _, filter := range filters {
+		param := c.QueryParam(filter.Param)
+		if param == "" {
+			continue
+		}
+
+		var value interface{} = param
+		if filter.Time {
+			if value, err = parseTime(param); err != nil {
+				return query, NewBadRequestProblem(fmt.Sprintf("%v must be a RFC 3339 time or a date", filter.Param))
+			}
+		}
+		query.Filters = append(query.Filters, models.Filter{Field: filter.Field, Op: filter.Op, Value: value})
+	}
+
+	return query, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", value, time.Local)
+}
+
+//SetPageHeaders send the total count and RFC 8288 Link headers of a
+//list page. Requests paging by offset and lists without cursors get
+//offset links, all others cursor links
+func SetPageHeaders(c echo.Context, page models.Page) {
+	header := c.Response().Header()
+	header.Set(HeaderTotalCount, strconv.FormatInt(page.Total, 10))
+
+	link := func(rel string, set map[string]string) {
+		params := c.Request().URL.Query()
+		params.Del("offset")
+		params.Del("cursor")
+		params.Set("limit", strconv.Itoa(page.Limit))
+		for key, value := range set {
+			params.Set(key, value)
+		}
+
+		target := url.URL{Path: c.Request().URL.Path, RawQuery: params.Encode()}
+		header.Add("Link", fmt.Sprintf(`<%v>; rel="%v"`, target.String(), rel))
+	}
+
+	link("first", nil)
+
+	if c.QueryParam("offset") == "" && (c.QueryParam("cursor") != "" || page.NextCursor != "") {
+		if page.NextCursor != "" {
+			link("next", map[string]string{"cursor": page.NextCursor})
+		}
+		return
+	}
+
+	if next := page.Offset + page.Limit; int64(next) < page.Total {
+		link("next", map[string]string{"offset": strconv.Itoa(next)})
+	}
+	if page.Offset > 0 {
+		prev := page.Offset - page.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		link("prev", map[string]string{"offset": strconv.Itoa(prev)})
+	}
+	if page.Total > 0 {
+		last := int((page.Total - 1) / int64(page.Limit) * int64(page.Limit))
+		link("last", map[string]string{"offset": strconv.Itoa(last)})
+	}
+}
@@ -0,0 +1,137 @@
+package author
+
+import (
+	"net/http"
+	"strconv"
+
+	"project-api/api/common"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	authorModel models.AuthorModel
+}
+
+func NewController(authorModel models.AuthorModel) *Controller {
+	return &Controller{
+		authorModel,
+	}
+}
+
+// authorFilters query string parameters filtering GET /authors
+var authorFilters = []common.QueryFilter{
+	{Param: "name", Field: "name", Op: models.FilterContains},
+	{Param: "created_from", Field: "created_at", Op: models.FilterFrom, Time: true},
+	{Param: "created_before", Field: "created_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllAuthorController(c echo.Context) error {
+	query, err := common.ParseQuery(c, authorFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	author, page, err := controller.authorModel.GetAllAuthor(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, author)
+}
+
+func (controller *Controller) GetAuthorController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	author, err := controller.authorModel.GetAuthor(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, author.Version)
+	return c.JSON(http.StatusOK, NewGetAuthorResponse(author))
+}
+
+func (controller *Controller) PostAuthorController(c echo.Context) error {
+	// bind request value
+	var authorRequest PostAuthorRequest
+
+	if err := c.Bind(&authorRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	authorRequest.Normalize()
+	if err := c.Validate(&authorRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	author, err := controller.authorModel.InsertAuthor(models.Author{Name: authorRequest.Name})
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// the id is needed to link the author to books
+	common.SetETag(c, author.Version)
+	return c.JSON(http.StatusOK, NewGetAuthorResponse(author))
+}
+
+func (controller *Controller) EditAuthorController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// bind request value
+	var authorRequest EditAuthorRequest
+	if err := c.Bind(&authorRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	authorRequest.Normalize()
+	if err := c.Validate(&authorRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	author, err := controller.authorModel.EditAuthor(models.Author{Name: authorRequest.Name, Version: version}, id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, author.Version)
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+func (controller *Controller) DeleteAuthorController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if _, err := controller.authorModel.DeleteAuthor(id, version); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
@@ -0,0 +1,160 @@
+package author
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"project-api/api/common"
+	"project-api/api/validation"
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.Book{}, &models.Author{}, &models.BookAuthor{})
+		db.AutoMigrate(&models.Book{}, &models.Author{}, &models.BookAuthor{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "books")
+		models.DropMongoCollection(db, "authors")
+	}
+}
+
+func TestAuthorController(t *testing.T) {
+	// create controller
+	authorController := NewController(storage.AuthorModel)
+
+	request := func(method string, id uint, body map[string]string, header http.Header) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		for key := range header {
+			req.Header.Set(key, header.Get(key))
+		}
+		context := e.NewContext(req, res)
+
+		if id == 0 {
+			context.SetPath("/authors")
+		} else {
+			context.SetPath("/authors/:id")
+			context.SetParamNames("id")
+			context.SetParamValues(fmt.Sprint(id))
+		}
+
+		switch {
+		case method == http.MethodPost:
+			authorController.PostAuthorController(context)
+		case method == http.MethodPut:
+			authorController.EditAuthorController(context)
+		case method == http.MethodDelete:
+			authorController.DeleteAuthorController(context)
+		case id == 0:
+			authorController.GetAllAuthorController(context)
+		default:
+			authorController.GetAuthorController(context)
+		}
+		return res
+	}
+
+	var created GetAuthorResponse
+
+	t.Run("POST /authors", func(t *testing.T) {
+		res := request(http.MethodPost, 0, map[string]string{"name": " J.K.  Rowling "}, nil)
+		json.Unmarshal(res.Body.Bytes(), &created)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"1"`, res.Header().Get(common.HeaderETag))
+		assert.NotZero(t, created.ID)
+		assert.Equal(t, "J. K. Rowling", created.Name)
+	})
+
+	t.Run("POST /authors another spelling", func(t *testing.T) {
+		res := request(http.MethodPost, 0, map[string]string{"name": "j k rowling"}, nil)
+		assert.Equal(t, 409, res.Code)
+
+		res = request(http.MethodPost, 0, map[string]string{"name": "  "}, nil)
+		assert.Equal(t, 422, res.Code)
+	})
+
+	t.Run("GET /authors", func(t *testing.T) {
+		res := request(http.MethodGet, 0, nil, nil)
+
+		var authors []models.Author
+		json.Unmarshal(res.Body.Bytes(), &authors)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "1", res.Header().Get(common.HeaderTotalCount))
+		assert.Equal(t, "J. K. Rowling", authors[0].Name)
+	})
+
+	t.Run("PUT /authors/:id", func(t *testing.T) {
+		res := request(http.MethodPut, created.ID, map[string]string{"name": "Joanne Rowling"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+
+		res = request(http.MethodPut, created.ID, map[string]string{"name": "Robert Galbraith"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 412, res.Code)
+
+		var response GetAuthorResponse
+		res = request(http.MethodGet, created.ID, nil, nil)
+		json.Unmarshal(res.Body.Bytes(), &response)
+		assert.Equal(t, "Joanne Rowling", response.Name)
+	})
+
+	t.Run("DELETE /authors/:id still credited", func(t *testing.T) {
+		book, err := storage.BookModel.InsertBook(models.Book{
+			Title:   "Harry Potter",
+			Authors: []models.Contributor{{AuthorID: created.ID, Role: models.ContributorAuthor}},
+		})
+		assert.NoError(t, err)
+
+		res := request(http.MethodDelete, created.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 409, res.Code)
+
+		// once the book is gone, so is the last credit
+		_, err = storage.BookModel.DeleteBook(int(book.ID), 0)
+		assert.NoError(t, err)
+
+		res = request(http.MethodDelete, created.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+
+		res = request(http.MethodGet, created.ID, nil, nil)
+		assert.Equal(t, 404, res.Code)
+	})
+
+	t.Run("POST /authors a deleted name", func(t *testing.T) {
+		res := request(http.MethodPost, 0, map[string]string{"name": "Joanne Rowling"}, nil)
+		assert.Equal(t, 200, res.Code)
+	})
+}
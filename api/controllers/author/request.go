@@ -0,0 +1,19 @@
+package author
+
+import "project-api/models"
+
+type PostAuthorRequest struct {
+	Name string `json:"name" form:"name" validate:"required,max=255"`
+}
+
+type EditAuthorRequest struct {
+	Name string `json:"name" form:"name" validate:"required,max=255"`
+}
+
+func (r *PostAuthorRequest) Normalize() {
+	r.Name = models.NormalizeName(r.Name)
+}
+
+func (r *EditAuthorRequest) Normalize() {
+	r.Name = models.NormalizeName(r.Name)
+}
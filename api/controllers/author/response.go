@@ -0,0 +1,15 @@
+package author
+
+import "project-api/models"
+
+type GetAuthorResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func NewGetAuthorResponse(author models.Author) GetAuthorResponse {
+	return GetAuthorResponse{
+		ID:   author.ID,
+		Name: author.Name,
+	}
+}
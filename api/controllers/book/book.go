@@ -1,53 +1,230 @@
 package book
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"project-api/api/common"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/isbn"
+	"project-api/metadata"
 	"project-api/models"
+	"project-api/search"
 
 	echo "github.com/labstack/echo/v4"
 )
 
 type Controller struct {
-	bookModel models.BookModel
+	bookModel      models.BookModel
+	authorModel    models.AuthorModel
+	publisherModel models.PublisherModel
+	workModel      models.WorkModel
+	categoryModel  models.CategoryModel
+	index          *search.Index
+	provider       metadata.Provider
 }
 
-func NewController(bookModel models.BookModel) *Controller {
+// NewController provider may be nil, books are then never enriched
+func NewController(bookModel models.BookModel, authorModel models.AuthorModel, publisherModel models.PublisherModel,
+	workModel models.WorkModel, categoryModel models.CategoryModel, index *search.Index, provider metadata.Provider) *Controller {
 	return &Controller{
 		bookModel,
+		authorModel,
+		publisherModel,
+		workModel,
+		categoryModel,
+		index,
+		provider,
 	}
 }
 
+// bookFilters query string parameters filtering GET /books
+var bookFilters = []common.QueryFilter{
+	{Param: "author", Field: "author", Op: models.FilterEqual},
+	{Param: "publisher", Field: "publisher", Op: models.FilterEqual},
+	{Param: "title", Field: "title", Op: models.FilterContains},
+	{Param: "year", Field: "year", Op: models.FilterEqual},
+	{Param: "language", Field: "language", Op: models.FilterEqual},
+	{Param: "author_id", Field: "author_id", Op: models.FilterEqual},
+	{Param: "publisher_id", Field: "publisher_id", Op: models.FilterEqual},
+	{Param: "work_id", Field: "work_id", Op: models.FilterEqual},
+	{Param: "tag", Field: "tag", Op: models.FilterEqual},
+	// the category and everything below it
+	{Param: "category_id", Field: "category_id", Op: models.FilterEqual},
+	{Param: "created_from", Field: "created_at", Op: models.FilterFrom, Time: true},
+	{Param: "created_before", Field: "created_at", Op: models.FilterBefore, Time: true},
+}
+
 func (controller *Controller) GetAllBookController(c echo.Context) error {
-	book, err := controller.bookModel.GetAllBook()
+	query, err := common.ParseQuery(c, bookFilters)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, err)
 	}
 
+	book, page, err := controller.bookModel.GetAllBook(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
 	return c.JSON(http.StatusOK, book)
 }
 
+func (controller *Controller) GetBookFacetsController(c echo.Context) error {
+	query, err := common.ParseQuery(c, bookFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// facets=author,year picks the facets, all of them by default
+	var fields []string
+	if facets := c.QueryParam("facets"); facets != "" {
+		for _, field := range strings.Split(facets, ",") {
+			fields = append(fields, strings.TrimSpace(field))
+		}
+	}
+
+	facets, err := controller.bookModel.GetBookFacets(query, fields, query.Limit)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	response := make(map[string][]FacetValueResponse, len(facets))
+	for field, values := range facets {
+		response[field] = make([]FacetValueResponse, 0, len(values))
+		for _, value := range values {
+			response[field] = append(response[field], FacetValueResponse{Value: value.Value, Count: value.Count})
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (controller *Controller) SearchBookController(c echo.Context) error {
+	var searchRequest SearchBookRequest
+
+	if err := c.Bind(&searchRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed query string"))
+	}
+
+	if err := c.Validate(&searchRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	// only limit and offset apply, hits are ordered by relevance
+	query, err := common.ParseQuery(c, nil)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+	if query.Limit == 0 {
+		query.Limit = models.DefaultLimit
+	}
+	if query.Limit > models.MaxLimit {
+		query.Limit = models.MaxLimit
+	}
+
+	hits, total := controller.index.Search(searchRequest.Query, query.Limit, query.Offset)
+
+	response := make([]SearchBookResponse, 0, len(hits))
+	for _, hit := range hits {
+		response = append(response, SearchBookResponse{
+			ID:        hit.Book.ID,
+			Title:     hit.Book.Title,
+			Author:    hit.Book.Author,
+			Publisher: hit.Book.Publisher,
+			Score:     hit.Score,
+		})
+	}
+
+	common.SetPageHeaders(c, models.Page{Total: int64(total), Limit: query.Limit, Offset: query.Offset})
+	return c.JSON(http.StatusOK, response)
+}
+
+func (controller *Controller) SuggestBookController(c echo.Context) error {
+	var suggestRequest SuggestBookRequest
+
+	if err := c.Bind(&suggestRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed query string"))
+	}
+
+	if err := c.Validate(&suggestRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	query, err := common.ParseQuery(c, nil)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	suggestions := controller.index.Suggest(suggestRequest.Field, suggestRequest.Prefix, query.Limit)
+
+	response := make([]SuggestBookResponse, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		response = append(response, SuggestBookResponse{
+			Value: suggestion.Value,
+			Count: suggestion.Count,
+		})
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
 func (controller *Controller) GetBookController(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
 	}
 
 	book, err := controller.bookModel.GetBook(id)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, err)
 	}
 
-	response := GetBookResponse{
-		Title:     book.Title,
-		Author:    book.Author,
-		Publisher: book.Publisher,
+	common.SetETag(c, book.Version)
+	return c.JSON(http.StatusOK, NewGetBookResponse(book))
+}
+
+func (controller *Controller) GetBookEditionsController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
 	}
 
-	return c.JSON(http.StatusOK, response)
+	query, err := common.ParseQuery(c, bookFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	book, page, err := controller.bookModel.GetBookEditions(id, query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, book)
+}
+
+func (controller *Controller) GetBookByISBNController(c echo.Context) error {
+	isbn13, err := isbn.Parse(c.Param("isbn"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("isbn must be a valid ISBN-10 or ISBN-13"))
+	}
+
+	book, err := controller.bookModel.GetBookByISBN(isbn13)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, book.Version)
+	return c.JSON(http.StatusOK, NewGetBookResponse(book))
 }
 
 func (controller *Controller) PostBookController(c echo.Context) error {
@@ -55,19 +232,35 @@ func (controller *Controller) PostBookController(c echo.Context) error {
 	var bookRequest PostBookRequest
 
 	if err := c.Bind(&bookRequest); err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	bookRequest.Normalize()
+	if err := controller.enrichRequest(c, &bookRequest); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if err := c.Validate(&bookRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	isbn13, err := canonicalISBN(bookRequest.ISBN)
+	if err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
-	book := models.Book{
-		Title:     bookRequest.Title,
-		Author:    bookRequest.Author,
-		Publisher: bookRequest.Publisher,
+	book := bookRequest.book(isbn13)
+	if err := controller.link(&book, bookRequest.Authors, bookRequest.PublisherID, bookRequest.WorkID); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+	if book.Categories, err = controller.categories(bookRequest.CategoryIDs); err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
-	_, err := controller.bookModel.InsertBook(book)
+	_, err = controller.bookModel.InsertBook(book)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, common.NewInternalServerErrorResponse())
+		return common.ErrorResponse(c, err)
 	}
 
 	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
@@ -77,38 +270,364 @@ func (controller *Controller) EditBookController(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
 	// bind request value
 	var bookRequest EditBookRequest
 	if err := c.Bind(&bookRequest); err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
 	}
 
-	book := models.Book{
-		Title:     bookRequest.Title,
-		Author:    bookRequest.Author,
-		Publisher: bookRequest.Publisher,
+	bookRequest.Normalize()
+	if err := c.Validate(&bookRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
 	}
 
-	if _, err := controller.bookModel.EditBook(book, id); err != nil {
-		return c.JSON(http.StatusNotFound, common.NewBadRequestResponse())
+	isbn13, err := canonicalISBN(bookRequest.ISBN)
+	if err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
+	// the request replaces the whole book, links included
+	book := bookRequest.book(isbn13, version)
+	if err := controller.link(&book, bookRequest.Authors, bookRequest.PublisherID, bookRequest.WorkID); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	book, err = controller.bookModel.EditBook(book, id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, book.Version)
+
 	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
 }
 
+func (controller *Controller) EnrichBookController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	strategy, err := metadata.ParseStrategy(c.QueryParam("strategy"))
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("strategy must be fill or overwrite"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if controller.provider == nil {
+		return common.ErrorResponse(c, common.NewProblem(http.StatusServiceUnavailable, "enrichment_disabled", "no metadata provider is configured"))
+	}
+
+	book, err := controller.bookModel.GetBook(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if book.ISBN == nil {
+		return common.ErrorResponse(c, common.NewProblem(http.StatusUnprocessableEntity, "isbn_required", "only books with an isbn can be enriched"))
+	}
+
+	meta, err := controller.provider.Lookup(c.Request().Context(), *book.ISBN)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// without If-Match the edit still only applies to the version read
+	// above, a concurrent edit is a conflict rather than overwritten
+	if version == 0 {
+		version = book.Version
+	}
+
+	book = metadata.Merge(book, meta, strategy)
+	book.Version = version
+	if err := controller.link(&book, nil, 0, 0); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	book, err = controller.bookModel.EditBook(book, id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, book.Version)
+	return c.JSON(http.StatusOK, NewGetBookResponse(book))
+}
+
+func (controller *Controller) TagBookController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// bind request value
+	var tagRequest TagBookRequest
+	if err := c.Bind(&tagRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	tagRequest.Normalize()
+	if err := c.Validate(&tagRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	book, err := controller.bookModel.AttachBook(id, version, tagRequest.Tags, nil)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, book.Version)
+	return c.JSON(http.StatusOK, NewGetBookResponse(book))
+}
+
+func (controller *Controller) UntagBookController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// detaching a tag the book does not carry changes nothing
+	book, err := controller.bookModel.DetachBook(id, version, []string{models.NormalizeTag(c.Param("tag"))}, nil)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, book.Version)
+	return c.JSON(http.StatusOK, NewGetBookResponse(book))
+}
+
+func (controller *Controller) CategorizeBookController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// bind request value
+	var categorizeRequest CategorizeBookRequest
+	if err := c.Bind(&categorizeRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	if err := c.Validate(&categorizeRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	if _, err := controller.categories(categorizeRequest.CategoryIDs); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	book, err := controller.bookModel.AttachBook(id, version, nil, categorizeRequest.CategoryIDs)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, book.Version)
+	return c.JSON(http.StatusOK, NewGetBookResponse(book))
+}
+
+func (controller *Controller) UncategorizeBookController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	categoryID, err := strconv.ParseUint(c.Param("category_id"), 10, 32)
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("category_id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	book, err := controller.bookModel.DetachBook(id, version, nil, []uint{uint(categoryID)})
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, book.Version)
+	return c.JSON(http.StatusOK, NewGetBookResponse(book))
+}
+
 func (controller *Controller) DeleteBookController(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
 	}
 
-	if _, err := controller.bookModel.DeleteBook(id); err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if _, err := controller.bookModel.DeleteBook(id, version); err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
 	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
 }
+
+// enrichRequest fill the blanks of a request carrying an isbn but no
+// title from the catalogue. An unknown isbn leaves the request as is
+// for the validation to report the missing title
+func (controller *Controller) enrichRequest(c echo.Context, bookRequest *PostBookRequest) error {
+	if controller.provider == nil || bookRequest.Title != "" || bookRequest.ISBN == "" {
+		return nil
+	}
+
+	isbn13, err := isbn.Parse(bookRequest.ISBN)
+	if err != nil {
+		return nil
+	}
+
+	meta, err := controller.provider.Lookup(c.Request().Context(), isbn13)
+	if errors.Is(err, metadata.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	book := metadata.Merge(bookRequest.book(nil), meta, metadata.FillEmpty)
+	bookRequest.Title = book.Title
+	bookRequest.Author = book.Author
+	bookRequest.Publisher = book.Publisher
+	bookRequest.Year = book.Year
+	bookRequest.CoverURL = book.CoverURL
+	bookRequest.Normalize()
+	return nil
+}
+
+// categories the requested categories, every one of them has to exist
+func (controller *Controller) categories(categoryIDs []uint) ([]models.Category, error) {
+	categories := make([]models.Category, 0, len(categoryIDs))
+	for _, categoryID := range categoryIDs {
+		category, err := controller.categoryModel.GetCategory(int(categoryID))
+		if errors.Is(err, models.ErrNotFound) {
+			return nil, common.NewValidationProblem([]common.FieldError{{
+				Field:   "category_ids",
+				Rule:    "exists",
+				Message: fmt.Sprintf("category %v does not exist", categoryID),
+			}})
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		categories = append(categories, category)
+	}
+	return categories, nil
+}
+
+// link point the book at the requested authors, publisher and work, or
+// at the authors and publisher its by-line and publisher name stand for
+// when none are given
+func (controller *Controller) link(book *models.Book, authors []ContributorRequest, publisherID, workID uint) error {
+	var credited []string
+	seen := make(map[ContributorRequest]bool, len(authors))
+	for _, contributor := range authors {
+		if seen[contributor] {
+			return common.NewValidationProblem([]common.FieldError{{
+				Field:   "authors",
+				Rule:    "unique",
+				Message: fmt.Sprintf("author %v is credited as %v twice", contributor.AuthorID, contributor.Role),
+			}})
+		}
+		seen[contributor] = true
+
+		author, err := controller.authorModel.GetAuthor(int(contributor.AuthorID))
+		if errors.Is(err, models.ErrNotFound) {
+			return common.NewValidationProblem([]common.FieldError{{
+				Field:   "authors",
+				Rule:    "exists",
+				Message: fmt.Sprintf("author %v does not exist", contributor.AuthorID),
+			}})
+		}
+		if err != nil {
+			return err
+		}
+
+		book.Authors = append(book.Authors, models.Contributor{AuthorID: author.ID, Name: author.Name, Role: contributor.Role})
+		if contributor.Role == models.ContributorAuthor {
+			credited = append(credited, author.Name)
+		}
+	}
+	if book.Author == "" {
+		book.Author = strings.Join(credited, ", ")
+	}
+
+	if publisherID != 0 {
+		publisher, err := controller.publisherModel.GetPublisher(int(publisherID))
+		if errors.Is(err, models.ErrNotFound) {
+			return common.NewValidationProblem([]common.FieldError{{
+				Field:   "publisher_id",
+				Rule:    "exists",
+				Message: fmt.Sprintf("publisher %v does not exist", publisherID),
+			}})
+		}
+		if err != nil {
+			return err
+		}
+
+		book.PublisherID = &publisher.ID
+		if book.Publisher == "" {
+			book.Publisher = publisher.Name
+		}
+	}
+
+	if workID != 0 {
+		work, err := controller.workModel.GetWork(int(workID))
+		if errors.Is(err, models.ErrNotFound) {
+			return common.NewValidationProblem([]common.FieldError{{
+				Field:   "work_id",
+				Rule:    "exists",
+				Message: fmt.Sprintf("work %v does not exist", workID),
+			}})
+		}
+		if err != nil {
+			return err
+		}
+
+		book.WorkID = &work.ID
+	}
+
+	linked, err := models.LinkContributors(controller.authorModel, controller.publisherModel, *book)
+	if err != nil {
+		return err
+	}
+	*book = linked
+	return nil
+}
@@ -7,29 +7,83 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"project-api/api/common"
+	"project-api/api/validation"
 	"project-api/config"
+	"project-api/metadata"
 	"project-api/models"
+	"project-api/search"
 	"project-api/util"
 
 	echo "github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
+var storage *util.Storage
+var index *search.Index
+var provider metadata.Provider
+
+// catalogue stub answering like Open Library, 9780262033848 is down
+func catalogue() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("bibkeys") {
+		case "ISBN:9780140328721":
+			fmt.Fprint(w, `{"ISBN:9780140328721": {
+				"title": "Fantastic Mr Fox",
+				"authors": [{"name": "Roald Dahl"}],
+				"publishers": [{"name": "Puffin"}],
+				"publish_date": "October 1, 1988",
+				"cover": {"medium": "https://covers.example/b/id/8739161-M.jpg"}
+			}}`)
+		case "ISBN:9780262033848":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+}
+
 func TestMain(m *testing.M) {
 	setup()
 	os.Exit(m.Run())
 }
 
 func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
 	// create database connection
 	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
+	storage = util.DatabaseConnection(config)
 
 	// cleaning data before testing
-	db.Migrator().DropTable(&models.Book{})
-	db.AutoMigrate(&models.Book{})
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.Book{}, &models.Author{}, &models.Publisher{}, &models.BookAuthor{}, &models.Work{},
+			&models.Category{}, &models.BookTag{}, &models.BookCategory{}, &models.Copy{})
+		db.AutoMigrate(&models.Book{}, &models.Author{}, &models.Publisher{}, &models.BookAuthor{}, &models.Work{},
+			&models.Category{}, &models.BookTag{}, &models.BookCategory{}, &models.Copy{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "books")
+		models.DropMongoCollection(db, "authors")
+		models.DropMongoCollection(db, "publishers")
+		models.DropMongoCollection(db, "works")
+		models.DropMongoCollection(db, "categories")
+		models.DropMongoCollection(db, "copies")
+	}
+
+	// keep the search index in sync like the server does
+	index = search.NewIndex()
+	storage.BookModel = search.NewIndexedBookModel(storage.BookModel, index)
+
+	// look books up in a local catalogue stub, it lives as long as the tests
+	provider = metadata.NewCachedProvider(metadata.NewOpenLibrary(catalogue().URL, nil), time.Hour)
 
 	// preparate dummy data
 	var newBook models.Book
@@ -38,7 +92,7 @@ func setup() {
 	newBook.Publisher = "Alterra"
 
 	// dummy data with model
-	bookModel := models.NewBookModel(db)
+	bookModel := storage.BookModel
 	_, err := bookModel.InsertBook(newBook)
 	if err != nil {
 		fmt.Println(err)
@@ -46,14 +100,12 @@ func setup() {
 }
 
 func TestGetAllBookController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	bookModel := models.NewBookModel(db)
-	bookController := NewController(bookModel)
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	res := httptest.NewRecorder()
 	context := e.NewContext(req, res)
@@ -84,14 +136,12 @@ func TestGetAllBookController(t *testing.T) {
 }
 
 func TestGetBookController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	bookModel := models.NewBookModel(db)
-	bookController := NewController(bookModel)
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	res := httptest.NewRecorder()
 	context := e.NewContext(req, res)
@@ -123,11 +173,8 @@ func TestGetBookController(t *testing.T) {
 }
 
 func TestPostBookController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	bookModel := models.NewBookModel(db)
-	bookController := NewController(bookModel)
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
 
 	// input controller
 	reqBody, _ := json.Marshal(map[string]string{
@@ -138,6 +185,7 @@ func TestPostBookController(t *testing.T) {
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
 	res := httptest.NewRecorder()
 	req.Header.Set("Content-Type", "application/json")
@@ -162,25 +210,74 @@ func TestPostBookController(t *testing.T) {
 	})
 }
 
+func TestPostBookValidation(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	post := func(body map[string]string) (*httptest.ResponseRecorder, common.Problem) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		context := e.NewContext(req, res)
+		context.SetPath("/books")
+
+		bookController.PostBookController(context)
+
+		var response common.Problem
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	t.Run("POST /books without title", func(t *testing.T) {
+		res, response := post(map[string]string{"title": "   ", "author": "Alterra"})
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "application/problem+json", res.Header().Get("Content-Type"))
+		assert.Equal(t, "validation_failed", response.Code)
+		assert.Equal(t, 1, len(response.Errors))
+		assert.Equal(t, "title", response.Errors[0].Field)
+		assert.Equal(t, "required", response.Errors[0].Rule)
+	})
+
+	t.Run("POST /books too long", func(t *testing.T) {
+		res, response := post(map[string]string{"title": "Alfabet", "publisher": strings.Repeat("a", 256)})
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "publisher", response.Errors[0].Field)
+		assert.Equal(t, "max", response.Errors[0].Rule)
+	})
+
+	t.Run("POST /books normalized author", func(t *testing.T) {
+		res, _ := post(map[string]string{"title": " Harry  Potter ", "author": "J.K.  Rowling"})
+		assert.Equal(t, 200, res.Code)
+
+		books, _, _ := storage.BookModel.GetAllBook(models.Query{Sort: []models.Sort{{Field: "id", Desc: true}}})
+		last := books[0]
+		assert.Equal(t, "Harry Potter", last.Title)
+		assert.Equal(t, "J. K. Rowling", last.Author)
+	})
+}
+
 func TestEditBookController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	bookModel := models.NewBookModel(db)
-	bookController := NewController(bookModel)
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
 
 	// input controller
 	reqBody, _ := json.Marshal(map[string]string{
-		"name":     "Alfabet",
-		"email":    "Alterra",
-		"password": "Alterra",
+		"title":     "Alfabet",
+		"author":    "Alterra",
+		"publisher": "Alterra",
 	})
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(reqBody))
 	res := httptest.NewRecorder()
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(common.HeaderIfMatch, "*")
 	context := e.NewContext(req, res)
 	context.SetPath("/books/:id")
 	context.SetParamNames("id")
@@ -205,17 +302,16 @@ func TestEditBookController(t *testing.T) {
 }
 
 func TestDeleteBookController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	bookModel := models.NewBookModel(db)
-	bookController := NewController(bookModel)
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodPut, "/", nil)
 	res := httptest.NewRecorder()
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(common.HeaderIfMatch, "*")
 	context := e.NewContext(req, res)
 	context.SetPath("/books/:id")
 	context.SetParamNames("id")
@@ -238,3 +334,967 @@ func TestDeleteBookController(t *testing.T) {
 		assert.Equal(t, "Successful Operation", response.Message)
 	})
 }
+
+func TestMissingBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	handlers := map[string]echo.HandlerFunc{
+		http.MethodGet:    bookController.GetBookController,
+		http.MethodPut:    bookController.EditBookController,
+		http.MethodDelete: bookController.DeleteBookController,
+	}
+
+	for method, handler := range handlers {
+		reqBody, _ := json.Marshal(map[string]string{
+			"title": "Alfabet",
+		})
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(common.HeaderIfMatch, "*")
+		context := e.NewContext(req, res)
+		context.SetPath("/books/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("999")
+
+		handler(context)
+
+		var response common.Problem
+		json.Unmarshal(res.Body.Bytes(), &response)
+
+		t.Run(method+" /books/:id missing", func(t *testing.T) {
+			assert.Equal(t, 404, res.Code)
+			assert.Equal(t, "not_found", response.Code)
+		})
+	}
+}
+
+func TestConditionalBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	book, err := storage.BookModel.InsertBook(models.Book{Title: "Versioned", Author: "Alterra"})
+	assert.Nil(t, err)
+	id := fmt.Sprint(book.ID)
+
+	request := func(method, ifMatch string, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(map[string]string{
+			"title": "Versioned Edit",
+		})
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		if ifMatch != "" {
+			req.Header.Set(common.HeaderIfMatch, ifMatch)
+		}
+		context := e.NewContext(req, res)
+		context.SetPath("/books/:id")
+		context.SetParamNames("id")
+		context.SetParamValues(id)
+
+		handler(context)
+		return res
+	}
+
+	t.Run("GET /books/:id sends the version as ETag", func(t *testing.T) {
+		res := request(http.MethodGet, "", bookController.GetBookController)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"1"`, res.Header().Get(common.HeaderETag))
+	})
+
+	t.Run("PUT /books/:id with the current ETag", func(t *testing.T) {
+		res := request(http.MethodPut, `"1"`, bookController.EditBookController)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+	})
+
+	t.Run("PUT /books/:id with a stale ETag", func(t *testing.T) {
+		res := request(http.MethodPut, `"1"`, bookController.EditBookController)
+
+		var response common.Problem
+		json.Unmarshal(res.Body.Bytes(), &response)
+
+		assert.Equal(t, 412, res.Code)
+		assert.Equal(t, "precondition_failed", response.Code)
+	})
+
+	t.Run("PUT /books/:id with a malformed If-Match", func(t *testing.T) {
+		res := request(http.MethodPut, `W/"2"`, bookController.EditBookController)
+		assert.Equal(t, 412, res.Code)
+	})
+
+	t.Run("DELETE /books/:id with a stale ETag", func(t *testing.T) {
+		res := request(http.MethodDelete, `"1"`, bookController.DeleteBookController)
+		assert.Equal(t, 412, res.Code)
+	})
+
+	t.Run("DELETE /books/:id with the current ETag", func(t *testing.T) {
+		res := request(http.MethodDelete, `"2"`, bookController.DeleteBookController)
+		assert.Equal(t, 200, res.Code)
+	})
+}
+
+func TestDeleteBookFreesISBN(t *testing.T) {
+	isbn := "9780140449136"
+	book, err := storage.BookModel.InsertBook(models.Book{Title: "Withdrawn", ISBN: &isbn})
+	assert.NoError(t, err)
+
+	_, err = storage.BookModel.DeleteBook(int(book.ID), book.Version)
+	assert.NoError(t, err)
+
+	t.Run("the isbn can be catalogued again", func(t *testing.T) {
+		again, err := storage.BookModel.InsertBook(models.Book{Title: "Withdrawn", ISBN: &isbn})
+		assert.NoError(t, err)
+
+		found, err := storage.BookModel.GetBookByISBN(isbn)
+		assert.NoError(t, err)
+		assert.Equal(t, again.ID, found.ID)
+	})
+}
+
+func TestDeleteBookDropsLinks(t *testing.T) {
+	author, _ := storage.AuthorModel.InsertAuthor(models.Author{Name: "Withdrawn Author"})
+	category, _ := storage.CategoryModel.InsertCategory(models.Category{Code: "WDN", Name: "Withdrawn"})
+	book, err := storage.BookModel.InsertBook(models.Book{
+		Title:      "Withdrawn",
+		Authors:    []models.Contributor{{AuthorID: author.ID, Role: models.ContributorAuthor}},
+		Tags:       []string{"withdrawn"},
+		Categories: []models.Category{category},
+	})
+	assert.NoError(t, err)
+
+	_, err = storage.BookModel.DeleteBook(int(book.ID), book.Version)
+	assert.NoError(t, err)
+
+	t.Run("the links of a deleted book are gone", func(t *testing.T) {
+		if storage.DB == nil {
+			t.Skip("mongo keeps the links in the deleted book")
+		}
+
+		var authors, tags, categories int64
+		storage.DB.Model(&models.BookAuthor{}).Where("book_id = ?", book.ID).Count(&authors)
+		storage.DB.Model(&models.BookTag{}).Where("book_id = ?", book.ID).Count(&tags)
+		storage.DB.Model(&models.BookCategory{}).Where("book_id = ?", book.ID).Count(&categories)
+		assert.Equal(t, []int64{0, 0, 0}, []int64{authors, tags, categories})
+	})
+
+	t.Run("a deleted book is not counted in the tag facet", func(t *testing.T) {
+		facets, err := storage.BookModel.GetBookFacets(models.Query{}, []string{"tag"}, 10)
+		assert.NoError(t, err)
+		for _, value := range facets["tag"] {
+			assert.NotEqual(t, "withdrawn", value.Value)
+		}
+	})
+}
+
+func TestPagedBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	for _, title := range []string{"Delta", "Alpha", "Echo", "Charlie", "Bravo"} {
+		_, err := storage.BookModel.InsertBook(models.Book{Title: title, Author: "Pager", Publisher: "Paging Press"})
+		assert.Nil(t, err)
+	}
+
+	list := func(target string) (*httptest.ResponseRecorder, []string) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books")
+
+		bookController.GetAllBookController(context)
+
+		var books []models.Book
+		json.Unmarshal(res.Body.Bytes(), &books)
+
+		titles := []string{}
+		for _, book := range books {
+			titles = append(titles, book.Title)
+		}
+		return res, titles
+	}
+
+	// next link of a response, relative to /books
+	next := func(res *httptest.ResponseRecorder) string {
+		for _, link := range res.Header().Values("Link") {
+			if strings.HasSuffix(link, `rel="next"`) {
+				return strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`)
+			}
+		}
+		return ""
+	}
+
+	t.Run("GET /books cursor pages sorted by title", func(t *testing.T) {
+		res, titles := list("/books?publisher=Paging+Press&sort=title&limit=2")
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "5", res.Header().Get(common.HeaderTotalCount))
+		assert.Equal(t, []string{"Alpha", "Bravo"}, titles)
+
+		res, titles = list(next(res))
+		assert.Equal(t, []string{"Charlie", "Delta"}, titles)
+
+		res, titles = list(next(res))
+		assert.Equal(t, []string{"Echo"}, titles)
+		assert.Equal(t, "", next(res))
+	})
+
+	t.Run("GET /books cursor pages sorted by creation", func(t *testing.T) {
+		res, titles := list("/books?publisher=Paging+Press&sort=-created_at&limit=3")
+		assert.Equal(t, []string{"Bravo", "Charlie", "Echo"}, titles)
+
+		_, titles = list(next(res))
+		assert.Equal(t, []string{"Alpha", "Delta"}, titles)
+	})
+
+	t.Run("GET /books offset pages sorted descending", func(t *testing.T) {
+		res, titles := list("/books?publisher=Paging+Press&sort=-title&limit=2&offset=2")
+		assert.Equal(t, []string{"Charlie", "Bravo"}, titles)
+		assert.Contains(t, next(res), "offset=4")
+		assert.Contains(t, res.Header().Values("Link"), `</books?limit=2&offset=0&publisher=Paging+Press&sort=-title>; rel="prev"`)
+	})
+
+	t.Run("GET /books filtered", func(t *testing.T) {
+		_, titles := list("/books?publisher=Paging+Press&title=HARL")
+		assert.Equal(t, []string{"Charlie"}, titles)
+
+		_, titles = list("/books?author=Pager&created_before=2000-01-01")
+		assert.Equal(t, []string{}, titles)
+	})
+
+	t.Run("GET /books invalid query", func(t *testing.T) {
+		res, _ := list("/books?sort=isbn")
+		assert.Equal(t, 400, res.Code)
+
+		res, _ = list("/books?sort=title&cursor=broken")
+		assert.Equal(t, 400, res.Code)
+
+		res, _ = list("/books?limit=zero")
+		assert.Equal(t, 400, res.Code)
+	})
+}
+
+func TestSearchBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	_, err := storage.BookModel.InsertBook(models.Book{Title: "Laskar Pelangi", Author: "Andrea Hirata", Publisher: "Bentang"})
+	assert.Nil(t, err)
+
+	search := func(target string) (*httptest.ResponseRecorder, []SearchBookResponse) {
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books/search")
+
+		bookController.SearchBookController(context)
+
+		var response []SearchBookResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	t.Run("GET /books/search", func(t *testing.T) {
+		res, response := search("/books/search?q=pelangi+hirata")
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "1", res.Header().Get(common.HeaderTotalCount))
+		assert.Equal(t, "Laskar Pelangi", response[0].Title)
+		assert.True(t, response[0].Score > 0)
+	})
+
+	t.Run("GET /books/search with a typo", func(t *testing.T) {
+		_, response := search("/books/search?q=pelagni")
+		assert.Equal(t, 1, len(response))
+	})
+
+	t.Run("GET /books/search without q", func(t *testing.T) {
+		res, _ := search("/books/search")
+		assert.Equal(t, 422, res.Code)
+	})
+}
+
+func TestSuggestBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	suggest := func(target string) (*httptest.ResponseRecorder, []SuggestBookResponse) {
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books/suggest")
+
+		bookController.SuggestBookController(context)
+
+		var response []SuggestBookResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	t.Run("GET /books/suggest", func(t *testing.T) {
+		res, response := suggest("/books/suggest?field=publisher&prefix=pag")
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, []SuggestBookResponse{{Value: "Paging Press", Count: 5}}, response)
+	})
+
+	t.Run("GET /books/suggest unknown field", func(t *testing.T) {
+		res, _ := suggest("/books/suggest?field=isbn&prefix=97")
+		assert.Equal(t, 422, res.Code)
+	})
+}
+
+func TestBookFacetsController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	for _, book := range []models.Book{
+		{Title: "Bumi", Author: "Tere Liye", Publisher: "Facet House", Year: 2014, Language: "id"},
+		{Title: "Bulan", Author: "Tere Liye", Publisher: "Facet House", Year: 2015, Language: "id"},
+		{Title: "Matahari", Author: "Tere Liye", Publisher: "Facet House", Year: 2016, Language: "id"},
+		{Title: "Moon", Author: "Tere Liye", Publisher: "Facet House", Year: 2015, Language: "en"},
+		{Title: "Ronggeng", Author: "Ahmad Tohari", Publisher: "Facet House", Year: 2015},
+	} {
+		_, err := storage.BookModel.InsertBook(book)
+		assert.Nil(t, err)
+	}
+
+	facets := func(target string) (*httptest.ResponseRecorder, map[string][]FacetValueResponse) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books/facets")
+
+		bookController.GetBookFacetsController(context)
+
+		var response map[string][]FacetValueResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	t.Run("GET /books/facets", func(t *testing.T) {
+		res, response := facets("/books/facets?publisher=Facet+House")
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, []FacetValueResponse{{"Tere Liye", 4}, {"Ahmad Tohari", 1}}, response["author"])
+		assert.Equal(t, []FacetValueResponse{{float64(2015), 3}, {float64(2014), 1}, {float64(2016), 1}}, response["year"])
+		assert.Equal(t, []FacetValueResponse{{"id", 3}, {"en", 1}}, response["language"])
+		assert.Equal(t, []FacetValueResponse{{"Facet House", 5}}, response["publisher"])
+	})
+
+	t.Run("GET /books/facets respects the filters", func(t *testing.T) {
+		_, response := facets("/books/facets?publisher=Facet+House&year=2015&facets=language")
+		assert.Equal(t, []FacetValueResponse{{"en", 1}, {"id", 1}}, response["language"])
+		assert.Nil(t, response["author"])
+	})
+
+	t.Run("GET /books/facets unknown facet", func(t *testing.T) {
+		res, _ := facets("/books/facets?facets=title")
+		assert.Equal(t, 400, res.Code)
+	})
+}
+
+func TestISBNBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	post := func(body map[string]string) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		context := e.NewContext(req, res)
+		context.SetPath("/books")
+
+		bookController.PostBookController(context)
+		return res
+	}
+
+	lookup := func(value string) (*httptest.ResponseRecorder, GetBookResponse) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books/isbn/:isbn")
+		context.SetParamNames("isbn")
+		context.SetParamValues(value)
+
+		bookController.GetBookByISBNController(context)
+
+		var response GetBookResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	t.Run("POST /books with an ISBN-10", func(t *testing.T) {
+		res := post(map[string]string{"title": "Data Reduction", "isbn": "0-306-40615-2"})
+		assert.Equal(t, 200, res.Code)
+	})
+
+	t.Run("GET /books/isbn/:isbn in either form", func(t *testing.T) {
+		for _, value := range []string{"9780306406157", "978-0-306-40615-7", "0306406152"} {
+			res, response := lookup(value)
+			assert.Equal(t, 200, res.Code)
+			assert.Equal(t, "Data Reduction", response.Title)
+			assert.Equal(t, "978-0-306-40615-7", response.ISBN13)
+			assert.Equal(t, "0-306-40615-2", response.ISBN10)
+		}
+	})
+
+	t.Run("POST /books with a taken ISBN", func(t *testing.T) {
+		res := post(map[string]string{"title": "Data Reduction Again", "isbn": "9780306406157"})
+		assert.Equal(t, 409, res.Code)
+	})
+
+	t.Run("POST /books with a bad check digit", func(t *testing.T) {
+		res := post(map[string]string{"title": "Typo", "isbn": "0-306-40615-3"})
+
+		var response common.Problem
+		json.Unmarshal(res.Body.Bytes(), &response)
+
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "isbn", response.Errors[0].Field)
+	})
+
+	t.Run("GET /books/isbn/:isbn unknown or invalid", func(t *testing.T) {
+		res, _ := lookup("9781402894626")
+		assert.Equal(t, 404, res.Code)
+
+		res, _ = lookup("12345")
+		assert.Equal(t, 400, res.Code)
+	})
+}
+
+func TestEnrichBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	post := func(body map[string]string) (*httptest.ResponseRecorder, common.Problem) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		context := e.NewContext(req, res)
+		context.SetPath("/books")
+
+		bookController.PostBookController(context)
+
+		var response common.Problem
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	enrich := func(controller *Controller, id uint, query string) (*httptest.ResponseRecorder, []byte) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/?"+query, nil)
+		req.Header.Set(common.HeaderIfMatch, "*")
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books/enrich/:id")
+		context.SetParamNames("id")
+		context.SetParamValues(fmt.Sprint(id))
+
+		controller.EnrichBookController(context)
+		return res, res.Body.Bytes()
+	}
+
+	t.Run("POST /books with only an ISBN", func(t *testing.T) {
+		res, _ := post(map[string]string{"isbn": "0-14-032872-6"})
+		assert.Equal(t, 200, res.Code)
+
+		book, err := storage.BookModel.GetBookByISBN("9780140328721")
+		assert.NoError(t, err)
+		assert.Equal(t, "Fantastic Mr Fox", book.Title)
+		assert.Equal(t, "Roald Dahl", book.Author)
+		assert.Equal(t, "Puffin", book.Publisher)
+		assert.Equal(t, uint(1988), book.Year)
+		assert.Equal(t, "https://covers.example/b/id/8739161-M.jpg", book.CoverURL)
+	})
+
+	t.Run("POST /books with an unknown ISBN and no title", func(t *testing.T) {
+		res, response := post(map[string]string{"isbn": "9781402894626"})
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "title", response.Errors[0].Field)
+	})
+
+	t.Run("POST /books while the catalogue is down", func(t *testing.T) {
+		res, response := post(map[string]string{"isbn": "9780262033848"})
+		assert.Equal(t, 502, res.Code)
+		assert.Equal(t, "upstream_error", response.Code)
+	})
+
+	// the same book trimmed down by hand, the catalogue only fills the blanks
+	book, err := storage.BookModel.GetBookByISBN("9780140328721")
+	assert.NoError(t, err)
+	book, err = storage.BookModel.EditBook(models.Book{Title: "Mr Fox", Author: "R. Dahl", ISBN: book.ISBN}, int(book.ID))
+	assert.NoError(t, err)
+
+	t.Run("POST /books/enrich/:id fills the blanks", func(t *testing.T) {
+		res, body := enrich(bookController, book.ID, "")
+
+		var response GetBookResponse
+		json.Unmarshal(body, &response)
+
+		assert.Equal(t, 200, res.Code)
+		assert.NotEmpty(t, res.Header().Get(common.HeaderETag))
+		assert.Equal(t, "Mr Fox", response.Title)
+		assert.Equal(t, "R. Dahl", response.Author)
+		assert.Equal(t, "Puffin", response.Publisher)
+		assert.Equal(t, uint(1988), response.Year)
+	})
+
+	t.Run("POST /books/enrich/:id?strategy=overwrite", func(t *testing.T) {
+		res, body := enrich(bookController, book.ID, "strategy=overwrite")
+
+		var response GetBookResponse
+		json.Unmarshal(body, &response)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "Fantastic Mr Fox", response.Title)
+		assert.Equal(t, "Roald Dahl", response.Author)
+
+		res, _ = enrich(bookController, book.ID, "strategy=replace")
+		assert.Equal(t, 400, res.Code)
+	})
+
+	t.Run("POST /books/enrich/:id without ISBN or provider", func(t *testing.T) {
+		plain, _ := storage.BookModel.InsertBook(models.Book{Title: "No ISBN"})
+
+		res, body := enrich(bookController, plain.ID, "")
+		var response common.Problem
+		json.Unmarshal(body, &response)
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "isbn_required", response.Code)
+
+		res, body = enrich(NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, nil), book.ID, "")
+		json.Unmarshal(body, &response)
+		assert.Equal(t, 503, res.Code)
+		assert.Equal(t, "enrichment_disabled", response.Code)
+
+		res, _ = enrich(bookController, 99999, "")
+		assert.Equal(t, 404, res.Code)
+	})
+}
+
+func TestContributorBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	post := func(body map[string]interface{}) (*httptest.ResponseRecorder, common.Problem) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		context := e.NewContext(req, res)
+		context.SetPath("/books")
+
+		bookController.PostBookController(context)
+
+		var response common.Problem
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	list := func(query string) []models.Book {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books")
+
+		bookController.GetAllBookController(context)
+
+		var books []models.Book
+		json.Unmarshal(res.Body.Bytes(), &books)
+		return books
+	}
+
+	get := func(id uint) GetBookResponse {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books/:id")
+		context.SetParamNames("id")
+		context.SetParamValues(fmt.Sprint(id))
+
+		bookController.GetBookController(context)
+
+		var response GetBookResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return response
+	}
+
+	t.Run("POST /books links the by-line and publisher", func(t *testing.T) {
+		res, _ := post(map[string]interface{}{"title": "Good Omens", "author": "Neil Gaiman & Terry Pratchett", "publisher": "Gollancz"})
+		assert.Equal(t, 200, res.Code)
+
+		res, _ = post(map[string]interface{}{"title": "Neverwhere", "author": "neil gaiman", "publisher": "BBC Books"})
+		assert.Equal(t, 200, res.Code)
+
+		gaiman, err := storage.AuthorModel.EnsureAuthor("Neil Gaiman")
+		assert.NoError(t, err)
+
+		books := list(fmt.Sprintf("author_id=%v&sort=title", gaiman.ID))
+		assert.Equal(t, 2, len(books))
+		assert.Equal(t, "Good Omens", books[0].Title)
+		assert.Equal(t, "Neverwhere", books[1].Title)
+
+		response := get(books[0].ID)
+		assert.Equal(t, []ContributorResponse{
+			{ID: gaiman.ID, Name: "Neil Gaiman", Role: "author"},
+			{ID: books[0].Authors[1].AuthorID, Name: "Terry Pratchett", Role: "author"},
+		}, response.Authors)
+		assert.NotZero(t, response.PublisherID)
+		assert.Equal(t, 1, len(list(fmt.Sprintf("publisher_id=%v", response.PublisherID))))
+	})
+
+	t.Run("POST /books with linked authors", func(t *testing.T) {
+		translator, _ := storage.AuthorModel.InsertAuthor(models.Author{Name: "Edith Grossman"})
+		author, _ := storage.AuthorModel.InsertAuthor(models.Author{Name: "Gabriel García Márquez"})
+		publisher, _ := storage.PublisherModel.InsertPublisher(models.Publisher{Name: "Harper & Row"})
+
+		res, _ := post(map[string]interface{}{
+			"title": "Love in the Time of Cholera",
+			"authors": []map[string]interface{}{
+				{"author_id": author.ID},
+				{"author_id": translator.ID, "role": "translator"},
+			},
+			"publisher_id": publisher.ID,
+		})
+		assert.Equal(t, 200, res.Code)
+
+		books := list(fmt.Sprintf("author_id=%v", translator.ID))
+		assert.Equal(t, 1, len(books))
+
+		response := get(books[0].ID)
+		assert.Equal(t, "Gabriel García Márquez", response.Author)
+		assert.Equal(t, "Harper & Row", response.Publisher)
+		assert.Equal(t, "translator", response.Authors[1].Role)
+	})
+
+	t.Run("POST /books with unknown links", func(t *testing.T) {
+		res, response := post(map[string]interface{}{"title": "Nobody", "authors": []map[string]interface{}{{"author_id": 9999}}})
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "authors", response.Errors[0].Field)
+
+		res, response = post(map[string]interface{}{"title": "Nobody", "publisher_id": 9999})
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "publisher_id", response.Errors[0].Field)
+
+		res, response = post(map[string]interface{}{"title": "Nobody", "authors": []map[string]interface{}{{"author_id": 1, "role": "illustrator"}}})
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "oneof", response.Errors[0].Rule)
+	})
+
+	t.Run("POST /books crediting an author twice in one role", func(t *testing.T) {
+		author, _ := storage.AuthorModel.InsertAuthor(models.Author{Name: "Ursula K. Le Guin"})
+
+		res, response := post(map[string]interface{}{"title": "Tao Te Ching", "authors": []map[string]interface{}{
+			{"author_id": author.ID},
+			{"author_id": author.ID, "role": " Author "},
+		}})
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "authors", response.Errors[0].Field)
+		assert.Equal(t, "unique", response.Errors[0].Rule)
+
+		// one author in two roles is fine
+		res, _ = post(map[string]interface{}{"title": "Tao Te Ching", "authors": []map[string]interface{}{
+			{"author_id": author.ID},
+			{"author_id": author.ID, "role": "translator"},
+		}})
+		assert.Equal(t, 200, res.Code)
+	})
+}
+
+func TestEditionsBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	post := func(body map[string]interface{}) (*httptest.ResponseRecorder, common.Problem) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		context := e.NewContext(req, res)
+		context.SetPath("/books")
+
+		bookController.PostBookController(context)
+
+		var response common.Problem
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	editions := func(id uint, query string) (*httptest.ResponseRecorder, []models.Book) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books/:id/editions")
+		context.SetParamNames("id")
+		context.SetParamValues(fmt.Sprint(id))
+
+		bookController.GetBookEditionsController(context)
+
+		var books []models.Book
+		json.Unmarshal(res.Body.Bytes(), &books)
+		return res, books
+	}
+
+	work, err := storage.WorkModel.InsertWork(models.Work{Title: "One Hundred Years of Solitude"})
+	assert.NoError(t, err)
+
+	t.Run("POST /books as editions of a work", func(t *testing.T) {
+		for _, body := range []map[string]interface{}{
+			{"title": "Cien años de soledad", "language": "es", "year": 1967, "work_id": work.ID},
+			{"title": "One Hundred Years of Solitude", "language": "en", "year": 1970, "work_id": work.ID},
+			{"title": "Cent ans de solitude", "language": "fr", "year": 1968, "work_id": work.ID},
+		} {
+			res, _ := post(body)
+			assert.Equal(t, 200, res.Code)
+		}
+
+		res, response := post(map[string]interface{}{"title": "Nobody", "work_id": 9999})
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "work_id", response.Errors[0].Field)
+	})
+
+	t.Run("GET /books/:id/editions", func(t *testing.T) {
+		books, _, err := storage.BookModel.GetAllBook(models.Query{
+			Filters: []models.Filter{{Field: "work_id", Op: models.FilterEqual, Value: work.ID}},
+			Sort:    []models.Sort{{Field: "year"}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, len(books))
+
+		res, others := editions(books[0].ID, "sort=year")
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "2", res.Header().Get(common.HeaderTotalCount))
+		assert.Equal(t, "Cent ans de solitude", others[0].Title)
+		assert.Equal(t, "One Hundred Years of Solitude", others[1].Title)
+
+		// the usual filters still apply
+		_, others = editions(books[0].ID, "language=en")
+		assert.Equal(t, 1, len(others))
+	})
+
+	t.Run("GET /books/:id/editions without a work", func(t *testing.T) {
+		book, err := storage.BookModel.InsertBook(models.Book{Title: "Chronicle of a Death Foretold"})
+		assert.NoError(t, err)
+
+		res, others := editions(book.ID, "")
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 0, len(others))
+
+		res, _ = editions(9999, "")
+		assert.Equal(t, 404, res.Code)
+	})
+}
+
+func TestClassifyBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	request := func(method, path string, names []string, values []string, body map[string]interface{}, header http.Header) (*httptest.ResponseRecorder, GetBookResponse) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		for key := range header {
+			req.Header.Set(key, header.Get(key))
+		}
+		context := e.NewContext(req, res)
+		context.SetPath(path)
+		context.SetParamNames(names...)
+		context.SetParamValues(values...)
+
+		switch path {
+		case "/books":
+			bookController.PostBookController(context)
+		case "/books/:id/tags":
+			bookController.TagBookController(context)
+		case "/books/:id/tags/:tag":
+			bookController.UntagBookController(context)
+		case "/books/:id/categories":
+			bookController.CategorizeBookController(context)
+		case "/books/:id/categories/:category_id":
+			bookController.UncategorizeBookController(context)
+		}
+
+		var response GetBookResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	list := func(query string) []models.Book {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/?"+query, nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books")
+
+		bookController.GetAllBookController(context)
+
+		var books []models.Book
+		json.Unmarshal(res.Body.Bytes(), &books)
+		return books
+	}
+
+	science, _ := storage.CategoryModel.InsertCategory(models.Category{Name: "Science", Code: "500"})
+	astronomy, _ := storage.CategoryModel.InsertCategory(models.Category{Name: "Astronomy", Code: "520", ParentID: &science.ID})
+	history, _ := storage.CategoryModel.InsertCategory(models.Category{Name: "History", Code: "900"})
+
+	t.Run("POST /books with tags and categories", func(t *testing.T) {
+		res, _ := request(http.MethodPost, "/books", nil, nil, map[string]interface{}{
+			"title":        "Cosmos",
+			"tags":         []string{" Popular  Science", "space", "popular science"},
+			"category_ids": []uint{astronomy.ID},
+		}, nil)
+		assert.Equal(t, 200, res.Code)
+
+		res, _ = request(http.MethodPost, "/books", nil, nil, map[string]interface{}{
+			"title":        "A Brief History of Time",
+			"tags":         []string{"popular science"},
+			"category_ids": []uint{science.ID},
+		}, nil)
+		assert.Equal(t, 200, res.Code)
+
+		var problem common.Problem
+		res, _ = request(http.MethodPost, "/books", nil, nil, map[string]interface{}{"title": "Nowhere", "category_ids": []uint{9999}}, nil)
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "category_ids", problem.Errors[0].Field)
+	})
+
+	t.Run("GET /books by tag and category", func(t *testing.T) {
+		books := list("tag=popular%20science&sort=title")
+		assert.Equal(t, 2, len(books))
+		assert.Equal(t, []string{"popular science", "space"}, books[1].Tags)
+
+		// a category takes in everything below it
+		assert.Equal(t, 2, len(list(fmt.Sprintf("category_id=%v", science.ID))))
+		assert.Equal(t, 1, len(list(fmt.Sprintf("category_id=%v", astronomy.ID))))
+		assert.Equal(t, 0, len(list(fmt.Sprintf("category_id=%v", history.ID))))
+		assert.Equal(t, 0, len(list("category_id=9999")))
+	})
+
+	t.Run("GET /books/facets counts the tags", func(t *testing.T) {
+		facets, err := storage.BookModel.GetBookFacets(models.Query{
+			Filters: []models.Filter{{Field: "category_id", Op: models.FilterEqual, Value: science.ID}},
+		}, []string{"tag"}, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, []models.FacetValue{
+			{Value: "popular science", Count: 2},
+			{Value: "space", Count: 1},
+		}, facets["tag"])
+	})
+
+	books := list("tag=space")
+	id := fmt.Sprint(books[0].ID)
+
+	t.Run("POST /books/:id/tags", func(t *testing.T) {
+		res, response := request(http.MethodPost, "/books/:id/tags", []string{"id"}, []string{id}, map[string]interface{}{"tags": []string{"Astronomy", "space"}}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+		assert.Equal(t, []string{"astronomy", "popular science", "space"}, response.Tags)
+
+		res, _ = request(http.MethodPost, "/books/:id/tags", []string{"id"}, []string{id}, map[string]interface{}{"tags": []string{"late"}}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 412, res.Code)
+
+		res, _ = request(http.MethodPost, "/books/:id/tags", []string{"id"}, []string{id}, map[string]interface{}{"tags": []string{" "}}, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 422, res.Code)
+	})
+
+	t.Run("DELETE /books/:id/tags/:tag", func(t *testing.T) {
+		res, response := request(http.MethodDelete, "/books/:id/tags/:tag", []string{"id", "tag"}, []string{id, "Space"}, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, []string{"astronomy", "popular science"}, response.Tags)
+	})
+
+	t.Run("POST /books/:id/categories", func(t *testing.T) {
+		res, response := request(http.MethodPost, "/books/:id/categories", []string{"id"}, []string{id}, map[string]interface{}{"category_ids": []uint{history.ID, astronomy.ID}}, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, []CategoryResponse{
+			{ID: astronomy.ID, Name: "Astronomy", Code: "520"},
+			{ID: history.ID, Name: "History", Code: "900"},
+		}, response.Categories)
+
+		res, _ = request(http.MethodPost, "/books/:id/categories", []string{"id"}, []string{id}, map[string]interface{}{"category_ids": []uint{9999}}, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 422, res.Code)
+	})
+
+	t.Run("DELETE /books/:id/categories/:category_id", func(t *testing.T) {
+		res, response := request(http.MethodDelete, "/books/:id/categories/:category_id", []string{"id", "category_id"}, []string{id, fmt.Sprint(astronomy.ID)}, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 1, len(response.Categories))
+		assert.Equal(t, 1, len(list(fmt.Sprintf("category_id=%v", science.ID))))
+	})
+}
+
+func TestAvailabilityBookController(t *testing.T) {
+	// create controller
+	bookController := NewController(storage.BookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+
+	request := func(method string, id uint) (*httptest.ResponseRecorder, GetBookResponse) {
+		e := echo.New()
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set(common.HeaderIfMatch, "*")
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books/:id")
+		context.SetParamNames("id")
+		context.SetParamValues(fmt.Sprint(id))
+
+		if method == http.MethodDelete {
+			bookController.DeleteBookController(context)
+		} else {
+			bookController.GetBookController(context)
+		}
+
+		var response GetBookResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	book, _ := storage.BookModel.InsertBook(models.Book{Title: "Middlemarch"})
+	for _, status := range []string{models.CopyAvailable, models.CopyAvailable, models.CopyOnLoan, models.CopyInRepair} {
+		_, err := storage.CopyModel.InsertCopy(models.Copy{BookID: book.ID, Status: status}, models.LoanPolicy{})
+		assert.NoError(t, err)
+	}
+
+	t.Run("GET /books/:id counts the copies", func(t *testing.T) {
+		res, response := request(http.MethodGet, book.ID)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, CopiesResponse{Total: 4, Available: 2, OnLoan: 1, InRepair: 1}, response.Copies)
+	})
+
+	t.Run("DELETE /books/:id with copies left", func(t *testing.T) {
+		res, _ := request(http.MethodDelete, book.ID)
+		assert.Equal(t, 409, res.Code)
+	})
+}
@@ -1,13 +1,152 @@
 package book
 
+import (
+	"strings"
+
+	"project-api/isbn"
+	"project-api/models"
+)
+
 type PostBookRequest struct {
-	Title     string `json:"title" form:"title"`
-	Author    string `json:"author" form:"author"`
-	Publisher string `json:"publisher" form:"publisher"`
+	Title     string `json:"title" form:"title" validate:"required,max=255"`
+	Author    string `json:"author" form:"author" validate:"max=255"`
+	Publisher string `json:"publisher" form:"publisher" validate:"max=255"`
+	Year      uint   `json:"year" form:"year" validate:"omitempty,min=1000,max=9999"`
+	Language  string `json:"language" form:"language" validate:"omitempty,len=2,alpha"`
+	ISBN      string `json:"isbn" form:"isbn" validate:"omitempty,isbn"`
+	CoverURL  string `json:"cover_url" form:"cover_url" validate:"omitempty,url,max=512"`
+
+	// Authors and PublisherID link the book, Author and Publisher are
+	// linked by name when they are not given
+	Authors     []ContributorRequest `json:"authors" form:"-" validate:"omitempty,max=50,dive"`
+	PublisherID uint                 `json:"publisher_id" form:"publisher_id"`
+
+	// WorkID the work the book is an edition of
+	WorkID uint `json:"work_id" form:"work_id"`
+
+	// Tags and CategoryIDs the book starts out with, later on they are
+	// attached and detached on their own
+	Tags        []string `json:"tags" form:"-" validate:"omitempty,max=50,dive,max=64"`
+	CategoryIDs []uint   `json:"category_ids" form:"-" validate:"omitempty,max=20"`
 }
 
 type EditBookRequest struct {
-	Title     string `json:"title" form:"title"`
-	Author    string `json:"author" form:"author"`
-	Publisher string `json:"publisher" form:"publisher"`
+	Title     string `json:"title" form:"title" validate:"required,max=255"`
+	Author    string `json:"author" form:"author" validate:"max=255"`
+	Publisher string `json:"publisher" form:"publisher" validate:"max=255"`
+	Year      uint   `json:"year" form:"year" validate:"omitempty,min=1000,max=9999"`
+	Language  string `json:"language" form:"language" validate:"omitempty,len=2,alpha"`
+	ISBN      string `json:"isbn" form:"isbn" validate:"omitempty,isbn"`
+	CoverURL  string `json:"cover_url" form:"cover_url" validate:"omitempty,url,max=512"`
+
+	// Authors and PublisherID link the book, Author and Publisher are
+	// linked by name when they are not given
+	Authors     []ContributorRequest `json:"authors" form:"-" validate:"omitempty,max=50,dive"`
+	PublisherID uint                 `json:"publisher_id" form:"publisher_id"`
+
+	// WorkID the work the book is an edition of
+	WorkID uint `json:"work_id" form:"work_id"`
+}
+
+type ContributorRequest struct {
+	AuthorID uint   `json:"author_id" validate:"required"`
+	Role     string `json:"role" validate:"omitempty,oneof=author editor translator"`
+}
+
+type TagBookRequest struct {
+	Tags []string `json:"tags" form:"-" validate:"required,max=50,dive,max=64"`
+}
+
+type CategorizeBookRequest struct {
+	CategoryIDs []uint `json:"category_ids" form:"-" validate:"required,max=20"`
+}
+
+type SearchBookRequest struct {
+	Query string `json:"q" query:"q" validate:"required,max=255"`
+}
+
+type SuggestBookRequest struct {
+	Field  string `json:"field" query:"field" validate:"required,oneof=title author publisher"`
+	Prefix string `json:"prefix" query:"prefix" validate:"required,max=100"`
+}
+
+func (r *PostBookRequest) Normalize() {
+	r.Title = normalizeSpace(r.Title)
+	r.Author = models.NormalizeName(r.Author)
+	r.Publisher = normalizeSpace(r.Publisher)
+	r.Language = strings.ToLower(strings.TrimSpace(r.Language))
+	r.ISBN = isbn.Clean(r.ISBN)
+	r.CoverURL = strings.TrimSpace(r.CoverURL)
+	r.Tags = models.NormalizeTags(r.Tags)
+	normalizeContributors(r.Authors)
+}
+
+func (r *EditBookRequest) Normalize() {
+	r.Title = normalizeSpace(r.Title)
+	r.Author = models.NormalizeName(r.Author)
+	r.Publisher = normalizeSpace(r.Publisher)
+	r.Language = strings.ToLower(strings.TrimSpace(r.Language))
+	r.ISBN = isbn.Clean(r.ISBN)
+	r.CoverURL = strings.TrimSpace(r.CoverURL)
+	normalizeContributors(r.Authors)
+}
+
+func (r *TagBookRequest) Normalize() {
+	r.Tags = models.NormalizeTags(r.Tags)
+}
+
+// normalizeSpace trim and collapse runs of whitespace into one space
+func normalizeSpace(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// normalizeContributors credit as author unless another role is given
+func normalizeContributors(contributors []ContributorRequest) {
+	for i := range contributors {
+		contributors[i].Role = strings.ToLower(strings.TrimSpace(contributors[i].Role))
+		if contributors[i].Role == "" {
+			contributors[i].Role = models.ContributorAuthor
+		}
+	}
+}
+
+// book the requested book with its canonical isbn
+func (r *PostBookRequest) book(isbn13 *string) models.Book {
+	return models.Book{
+		Title:     r.Title,
+		Author:    r.Author,
+		Publisher: r.Publisher,
+		Year:      r.Year,
+		Language:  r.Language,
+		ISBN:      isbn13,
+		CoverURL:  r.CoverURL,
+		Tags:      r.Tags,
+	}
+}
+
+// book the requested book with its canonical isbn
+func (r *EditBookRequest) book(isbn13 *string, version uint) models.Book {
+	return models.Book{
+		Title:     r.Title,
+		Author:    r.Author,
+		Publisher: r.Publisher,
+		Year:      r.Year,
+		Language:  r.Language,
+		ISBN:      isbn13,
+		CoverURL:  r.CoverURL,
+		Version:   version,
+	}
+}
+
+// canonicalISBN ISBN-13 of a validated request isbn, nil without one
+func canonicalISBN(value string) (*string, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	isbn13, err := isbn.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	return &isbn13, nil
 }
@@ -1,7 +1,119 @@
 package book
 
+import (
+	"project-api/isbn"
+	"project-api/models"
+)
+
 type GetBookResponse struct {
 	Title     string `json:"title"`
 	Author    string `json:"author"`
 	Publisher string `json:"publisher"`
+	Year      uint   `json:"year,omitempty"`
+	Language  string `json:"language,omitempty"`
+	ISBN13    string `json:"isbn13,omitempty"`
+	ISBN10    string `json:"isbn10,omitempty"`
+	CoverURL  string `json:"cover_url,omitempty"`
+
+	PublisherID uint                  `json:"publisher_id,omitempty"`
+	Authors     []ContributorResponse `json:"authors"`
+	WorkID      uint                  `json:"work_id,omitempty"`
+
+	Tags       []string           `json:"tags"`
+	Categories []CategoryResponse `json:"categories"`
+
+	Copies CopiesResponse `json:"copies"`
+}
+
+type CopiesResponse struct {
+	Total     int64 `json:"total"`
+	Available int64 `json:"available"`
+	OnLoan    int64 `json:"on_loan"`
+	Lost      int64 `json:"lost"`
+	InRepair  int64 `json:"in_repair"`
+	OnHold    int64 `json:"on_hold"`
+}
+
+type CategoryResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Code string `json:"code,omitempty"`
+}
+
+type ContributorResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type SearchBookResponse struct {
+	ID        uint    `json:"id"`
+	Title     string  `json:"title"`
+	Author    string  `json:"author"`
+	Publisher string  `json:"publisher"`
+	Score     float64 `json:"score"`
+}
+
+type SuggestBookResponse struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+type FacetValueResponse struct {
+	Value interface{} `json:"value"`
+	Count int64       `json:"count"`
+}
+
+func NewGetBookResponse(book models.Book) GetBookResponse {
+	response := GetBookResponse{
+		Title:     book.Title,
+		Author:    book.Author,
+		Publisher: book.Publisher,
+		Year:      book.Year,
+		Language:  book.Language,
+		CoverURL:  book.CoverURL,
+		Authors:   make([]ContributorResponse, 0, len(book.Authors)),
+
+		Tags:       make([]string, 0, len(book.Tags)),
+		Categories: make([]CategoryResponse, 0, len(book.Categories)),
+
+		Copies: CopiesResponse{
+			Total:     book.Copies.Total,
+			Available: book.Copies.Available,
+			OnLoan:    book.Copies.OnLoan,
+			Lost:      book.Copies.Lost,
+			InRepair:  book.Copies.InRepair,
+			OnHold:    book.Copies.OnHold,
+		},
+	}
+
+	if book.PublisherID != nil {
+		response.PublisherID = *book.PublisherID
+	}
+	if book.WorkID != nil {
+		response.WorkID = *book.WorkID
+	}
+	for _, contributor := range book.Authors {
+		response.Authors = append(response.Authors, ContributorResponse{
+			ID:   contributor.AuthorID,
+			Name: contributor.Name,
+			Role: contributor.Role,
+		})
+	}
+	response.Tags = append(response.Tags, book.Tags...)
+	for _, category := range book.Categories {
+		response.Categories = append(response.Categories, CategoryResponse{
+			ID:   category.ID,
+			Name: category.Name,
+			Code: category.Code,
+		})
+	}
+
+	if book.ISBN != nil {
+		response.ISBN13 = isbn.Hyphenate(*book.ISBN)
+		if isbn10, err := isbn.To10(*book.ISBN); err == nil {
+			response.ISBN10 = isbn.Hyphenate(isbn10)
+		}
+	}
+	return response
 }
@@ -0,0 +1,248 @@
+package category
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"project-api/api/common"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	categoryModel models.CategoryModel
+}
+
+func NewController(categoryModel models.CategoryModel) *Controller {
+	return &Controller{
+		categoryModel,
+	}
+}
+
+// categoryFilters query string parameters filtering GET /categories
+var categoryFilters = []common.QueryFilter{
+	{Param: "name", Field: "name", Op: models.FilterContains},
+	{Param: "code", Field: "code", Op: models.FilterEqual},
+	{Param: "parent_id", Field: "parent_id", Op: models.FilterEqual},
+	{Param: "created_from", Field: "created_at", Op: models.FilterFrom, Time: true},
+	{Param: "created_before", Field: "created_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllCategoryController(c echo.Context) error {
+	query, err := common.ParseQuery(c, categoryFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	category, page, err := controller.categoryModel.GetAllCategory(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, category)
+}
+
+func (controller *Controller) GetCategoryController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	category, err := controller.categoryModel.GetCategory(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	ancestors, err := controller.ancestors(category)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	children, err := controller.children(category.ID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, category.Version)
+	return c.JSON(http.StatusOK, NewGetCategoryResponse(category, ancestors, children))
+}
+
+func (controller *Controller) PostCategoryController(c echo.Context) error {
+	// bind request value
+	var categoryRequest PostCategoryRequest
+
+	if err := c.Bind(&categoryRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	categoryRequest.Normalize()
+	if err := c.Validate(&categoryRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	parentID, err := controller.parent(categoryRequest.ParentID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	category, err := controller.categoryModel.InsertCategory(models.Category{
+		Name:     categoryRequest.Name,
+		Code:     categoryRequest.Code,
+		ParentID: parentID,
+	})
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// the id is needed to file books and subcategories under the category
+	common.SetETag(c, category.Version)
+	return c.JSON(http.StatusOK, NewGetCategoryResponse(category, nil, nil))
+}
+
+func (controller *Controller) EditCategoryController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// bind request value
+	var categoryRequest EditCategoryRequest
+	if err := c.Bind(&categoryRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	categoryRequest.Normalize()
+	if err := c.Validate(&categoryRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	parentID, err := controller.parent(categoryRequest.ParentID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// a new parent moves the category along with everything below it
+	category, err := controller.categoryModel.EditCategory(models.Category{
+		Name:     categoryRequest.Name,
+		Code:     categoryRequest.Code,
+		ParentID: parentID,
+		Version:  version,
+	}, id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, category.Version)
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+func (controller *Controller) DeleteCategoryController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if _, err := controller.categoryModel.DeleteCategory(id, version); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+// parent the id of the requested parent, nil for a root category
+func (controller *Controller) parent(parentID uint) (*uint, error) {
+	if parentID == 0 {
+		return nil, nil
+	}
+
+	parent, err := controller.categoryModel.GetCategory(int(parentID))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, common.NewValidationProblem([]common.FieldError{{
+			Field:   "parent_id",
+			Rule:    "exists",
+			Message: fmt.Sprintf("category %v does not exist", parentID),
+		}})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &parent.ID, nil
+}
+
+// ancestors the categories above category, root first
+func (controller *Controller) ancestors(category models.Category) ([]models.Category, error) {
+	ids := category.AncestorIDs()
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	query := models.Query{
+		Limit:   models.MaxLimit,
+		Filters: []models.Filter{{Field: "id", Op: models.FilterIn, Value: values}},
+	}
+
+	found, _, err := controller.categoryModel.GetAllCategory(query)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.Category, len(found))
+	for _, ancestor := range found {
+		byID[ancestor.ID] = ancestor
+	}
+	ancestors := make([]models.Category, 0, len(ids))
+	for _, id := range ids {
+		if ancestor, ok := byID[id]; ok {
+			ancestors = append(ancestors, ancestor)
+		}
+	}
+	return ancestors, nil
+}
+
+// children every category right below the category, by name
+func (controller *Controller) children(categoryID uint) ([]models.Category, error) {
+	query := models.Query{
+		Limit:   models.MaxLimit,
+		Filters: []models.Filter{{Field: "parent_id", Op: models.FilterEqual, Value: categoryID}},
+		Sort:    []models.Sort{{Field: "name"}},
+	}
+
+	var children []models.Category
+	for {
+		categories, page, err := controller.categoryModel.GetAllCategory(query)
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, categories...)
+
+		if page.NextCursor == "" {
+			return children, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
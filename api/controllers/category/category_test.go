@@ -0,0 +1,185 @@
+package category
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"project-api/api/common"
+	"project-api/api/validation"
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.Book{}, &models.Category{}, &models.BookCategory{})
+		db.AutoMigrate(&models.Book{}, &models.Category{}, &models.BookCategory{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "books")
+		models.DropMongoCollection(db, "categories")
+	}
+}
+
+func TestCategoryController(t *testing.T) {
+	// create controller
+	categoryController := NewController(storage.CategoryModel)
+
+	request := func(method string, id uint, body map[string]interface{}, header http.Header) (*httptest.ResponseRecorder, GetCategoryResponse) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		for key := range header {
+			req.Header.Set(key, header.Get(key))
+		}
+		context := e.NewContext(req, res)
+
+		if id == 0 {
+			context.SetPath("/categories")
+		} else {
+			context.SetPath("/categories/:id")
+			context.SetParamNames("id")
+			context.SetParamValues(fmt.Sprint(id))
+		}
+
+		switch {
+		case method == http.MethodPost:
+			categoryController.PostCategoryController(context)
+		case method == http.MethodPut:
+			categoryController.EditCategoryController(context)
+		case method == http.MethodDelete:
+			categoryController.DeleteCategoryController(context)
+		case id == 0:
+			categoryController.GetAllCategoryController(context)
+		default:
+			categoryController.GetCategoryController(context)
+		}
+
+		var response GetCategoryResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	var literature, english, fiction, poetry GetCategoryResponse
+
+	t.Run("POST /categories", func(t *testing.T) {
+		var res *httptest.ResponseRecorder
+		res, literature = request(http.MethodPost, 0, map[string]interface{}{"name": "Literature", "code": "800"}, nil)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"1"`, res.Header().Get(common.HeaderETag))
+		assert.Zero(t, literature.ParentID)
+
+		_, english = request(http.MethodPost, 0, map[string]interface{}{"name": "English literature", "code": "820", "parent_id": literature.ID}, nil)
+		_, fiction = request(http.MethodPost, 0, map[string]interface{}{"name": "English fiction", "code": "823", "parent_id": english.ID}, nil)
+		_, poetry = request(http.MethodPost, 0, map[string]interface{}{"name": "Poetry", "parent_id": literature.ID}, nil)
+		assert.Equal(t, english.ID, fiction.ParentID)
+
+		res, _ = request(http.MethodPost, 0, map[string]interface{}{"name": "Orphan", "parent_id": 9999}, nil)
+		assert.Equal(t, 422, res.Code)
+	})
+
+	t.Run("GET /categories/:id with its ancestors and children", func(t *testing.T) {
+		res, response := request(http.MethodGet, fiction.ID, nil, nil)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, []CategorySummaryResponse{
+			{ID: literature.ID, Name: "Literature", Code: "800"},
+			{ID: english.ID, Name: "English literature", Code: "820"},
+		}, response.Ancestors)
+		assert.Equal(t, []CategorySummaryResponse{}, response.Children)
+
+		_, response = request(http.MethodGet, literature.ID, nil, nil)
+		assert.Equal(t, 0, len(response.Ancestors))
+		assert.Equal(t, "English literature", response.Children[0].Name)
+		assert.Equal(t, "Poetry", response.Children[1].Name)
+	})
+
+	t.Run("PUT /categories/:id moves the subtree", func(t *testing.T) {
+		res, _ := request(http.MethodPut, english.ID, map[string]interface{}{"name": "English literature", "code": "820", "parent_id": poetry.ID}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+
+		_, response := request(http.MethodGet, fiction.ID, nil, nil)
+		assert.Equal(t, 3, len(response.Ancestors))
+		assert.Equal(t, poetry.ID, response.Ancestors[1].ID)
+	})
+
+	t.Run("PUT /categories/:id below itself", func(t *testing.T) {
+		var problem common.Problem
+		res, _ := request(http.MethodPut, english.ID, map[string]interface{}{"name": "English literature", "parent_id": fiction.ID}, http.Header{"If-Match": {"*"}})
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "category_cycle", problem.Code)
+
+		res, _ = request(http.MethodPut, english.ID, map[string]interface{}{"name": "English literature", "parent_id": english.ID}, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 422, res.Code)
+	})
+
+	t.Run("GET /categories", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/?parent_id=%v", literature.ID), nil)
+		res := httptest.NewRecorder()
+		categoryController.GetAllCategoryController(e.NewContext(req, res))
+
+		var categories []models.Category
+		json.Unmarshal(res.Body.Bytes(), &categories)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "1", res.Header().Get(common.HeaderTotalCount))
+		assert.Equal(t, "Poetry", categories[0].Name)
+	})
+
+	t.Run("DELETE /categories/:id still in use", func(t *testing.T) {
+		res, _ := request(http.MethodDelete, english.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 409, res.Code)
+
+		book, err := storage.BookModel.InsertBook(models.Book{Title: "Brideshead Revisited"})
+		assert.NoError(t, err)
+		_, err = storage.BookModel.AttachBook(int(book.ID), 0, nil, []uint{fiction.ID})
+		assert.NoError(t, err)
+
+		res, _ = request(http.MethodDelete, fiction.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 409, res.Code)
+
+		// once the book is gone, nothing uses it
+		_, err = storage.BookModel.DeleteBook(int(book.ID), 0)
+		assert.NoError(t, err)
+
+		res, _ = request(http.MethodDelete, fiction.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+
+		res, _ = request(http.MethodDelete, english.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+
+		res, _ = request(http.MethodGet, english.ID, nil, nil)
+		assert.Equal(t, 404, res.Code)
+	})
+}
@@ -0,0 +1,25 @@
+package category
+
+import "strings"
+
+type PostCategoryRequest struct {
+	Name     string `json:"name" form:"name" validate:"required,max=255"`
+	Code     string `json:"code" form:"code" validate:"max=32"`
+	ParentID uint   `json:"parent_id" form:"parent_id"`
+}
+
+type EditCategoryRequest struct {
+	Name     string `json:"name" form:"name" validate:"required,max=255"`
+	Code     string `json:"code" form:"code" validate:"max=32"`
+	ParentID uint   `json:"parent_id" form:"parent_id"`
+}
+
+func (r *PostCategoryRequest) Normalize() {
+	r.Name = strings.Join(strings.Fields(r.Name), " ")
+	r.Code = strings.TrimSpace(r.Code)
+}
+
+func (r *EditCategoryRequest) Normalize() {
+	r.Name = strings.Join(strings.Fields(r.Name), " ")
+	r.Code = strings.TrimSpace(r.Code)
+}
@@ -0,0 +1,47 @@
+package category
+
+import "project-api/models"
+
+type GetCategoryResponse struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Code     string `json:"code,omitempty"`
+	ParentID uint   `json:"parent_id,omitempty"`
+
+	// Ancestors from the root down, Children one level below
+	Ancestors []CategorySummaryResponse `json:"ancestors"`
+	Children  []CategorySummaryResponse `json:"children"`
+}
+
+type CategorySummaryResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Code string `json:"code,omitempty"`
+}
+
+func NewGetCategoryResponse(category models.Category, ancestors, children []models.Category) GetCategoryResponse {
+	response := GetCategoryResponse{
+		ID:        category.ID,
+		Name:      category.Name,
+		Code:      category.Code,
+		Ancestors: summaries(ancestors),
+		Children:  summaries(children),
+	}
+
+	if category.ParentID != nil {
+		response.ParentID = *category.ParentID
+	}
+	return response
+}
+
+func summaries(categories []models.Category) []CategorySummaryResponse {
+	response := make([]CategorySummaryResponse, 0, len(categories))
+	for _, category := range categories {
+		response = append(response, CategorySummaryResponse{
+			ID:   category.ID,
+			Name: category.Name,
+			Code: category.Code,
+		})
+	}
+	return response
+}
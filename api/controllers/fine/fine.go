@@ -0,0 +1,162 @@
+package fine
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"project-api/api/common"
+	"project-api/api/middlewares"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	fineModel models.FineModel
+	userModel models.UserModel
+	policy    models.LoanPolicy
+}
+
+func NewController(fineModel models.FineModel, userModel models.UserModel, policy models.LoanPolicy) *Controller {
+	return &Controller{
+		fineModel,
+		userModel,
+		policy,
+	}
+}
+
+// fineFilters query string parameters filtering GET /fines and
+// GET /users/:id/fines/entries
+var fineFilters = []common.QueryFilter{
+	{Param: "kind", Field: "kind", Op: models.FilterEqual},
+	{Param: "user_id", Field: "user_id", Op: models.FilterEqual},
+	{Param: "loan_id", Field: "loan_id", Op: models.FilterEqual},
+	{Param: "created_from", Field: "created_at", Op: models.FilterFrom, Time: true},
+	{Param: "created_before", Field: "created_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllFineController(c echo.Context) error {
+	query, err := common.ParseQuery(c, fineFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	entries, page, err := controller.fineModel.GetAllFineEntry(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, entries)
+}
+
+func (controller *Controller) GetUserFinesController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	balance, err := controller.fineModel.GetFineBalance(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, NewGetFineBalanceResponse(balance, controller.policy.Fines))
+}
+
+func (controller *Controller) GetUserFineEntriesController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	query, err := common.ParseQuery(c, fineFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// an unknown user is a 404, not an empty list
+	user, err := controller.userModel.Get(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+	query.Filters = append(query.Filters, models.Filter{Field: "user_id", Op: models.FilterEqual, Value: user.ID})
+
+	entries, page, err := controller.fineModel.GetAllFineEntry(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, entries)
+}
+
+func (controller *Controller) PayFineController(c echo.Context) error {
+	return controller.settle(c, models.FinePayment)
+}
+
+func (controller *Controller) WaiveFineController(c echo.Context) error {
+	return controller.settle(c, models.FineWaiver)
+}
+
+func (controller *Controller) AccrueFinesController(c echo.Context) error {
+	accrual, err := controller.fineModel.AccrueFines(controller.policy.Fines, time.Now())
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, AccrueFinesResponse{Loans: accrual.Loans, Amount: accrual.Amount})
+}
+
+// settle book a payment or a waiver on the balance of the user named by
+// the path, recorded by the staff member calling
+func (controller *Controller) settle(c echo.Context, kind string) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	// bind request value
+	var settleRequest SettleFineRequest
+
+	if err := c.Bind(&settleRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	settleRequest.Normalize()
+	if err := c.Validate(&settleRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	entry := models.FineEntry{
+		UserID: uint(id),
+		Kind:   kind,
+		Amount: settleRequest.Amount,
+		Note:   settleRequest.Note,
+	}
+	if staffId := uint(middlewares.ExtractTokenUserId(c)); staffId != 0 {
+		entry.RecordedBy = &staffId
+	}
+
+	entry, err = controller.fineModel.SettleFine(entry)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	balance, err := controller.fineModel.GetFineBalance(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, SettleFineResponse{
+		Entry:   NewGetFineEntryResponse(entry),
+		Balance: NewGetFineBalanceResponse(balance, controller.policy.Fines),
+	})
+}
@@ -0,0 +1,235 @@
+package fine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"project-api/api/common"
+	"project-api/api/validation"
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	"github.com/dgrijalva/jwt-go"
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+var policy = models.LoanPolicy{
+	Period: 14 * 24 * time.Hour,
+	Fines: models.FinePolicy{
+		FineRule:   models.FineRule{Rate: 10, Grace: 24 * time.Hour, Cap: 500},
+		MaxBalance: 1000,
+		Overrides:  []models.FineOverride{{Category: "REF", FineRule: models.FineRule{Rate: 100}}},
+	},
+}
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.User{}, &models.Book{}, &models.Category{}, &models.BookCategory{},
+			&models.Copy{}, &models.Loan{}, &models.Hold{}, &models.FineEntry{})
+		db.AutoMigrate(&models.User{}, &models.Book{}, &models.Category{}, &models.BookCategory{},
+			&models.Copy{}, &models.Loan{}, &models.Hold{}, &models.FineEntry{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "users")
+		models.DropMongoCollection(db, "books")
+		models.DropMongoCollection(db, "categories")
+		models.DropMongoCollection(db, "copies")
+		models.DropMongoCollection(db, "loans")
+		models.DropMongoCollection(db, "holds")
+		models.DropMongoCollection(db, "fines")
+	}
+}
+
+// overdue lend a new copy of the book to the user, due 16 and a half days ago
+func overdue(t *testing.T, book models.Book, user models.User) models.Loan {
+	copy, err := storage.CopyModel.InsertCopy(models.Copy{BookID: book.ID, Status: models.CopyAvailable}, policy)
+	assert.NoError(t, err)
+
+	loanedAt := time.Now().Add(-policy.Period - 16*24*time.Hour - 12*time.Hour)
+	loan, err := storage.LoanModel.CheckoutLoan(models.Loan{CopyID: copy.ID, UserID: user.ID, LoanedAt: loanedAt}, policy)
+	assert.NoError(t, err)
+	return loan
+}
+
+func TestFineController(t *testing.T) {
+	// create controller
+	fineController := NewController(storage.FineModel, storage.UserModel, policy)
+
+	request := func(method, path string, id uint, body map[string]interface{}, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		context := e.NewContext(req, res)
+		context.Set("user", &jwt.Token{
+			Valid:  true,
+			Claims: jwt.MapClaims{"userId": float64(1), "role": models.RoleLibrarian},
+		})
+
+		context.SetPath(path)
+		if id != 0 {
+			context.SetParamNames("id")
+			context.SetParamValues(fmt.Sprint(id))
+		}
+
+		handler(context)
+		return res
+	}
+
+	accrue := func() AccrueFinesResponse {
+		res := request(http.MethodPost, "/fines/accrue", 0, nil, fineController.AccrueFinesController)
+		assert.Equal(t, 200, res.Code)
+
+		var response AccrueFinesResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return response
+	}
+
+	borrower, err := storage.UserModel.Insert(models.User{Name: "late", Email: "late@alterra.id", Password: "password123"})
+	assert.NoError(t, err)
+
+	novel, _ := storage.BookModel.InsertBook(models.Book{Title: "Sanditon"})
+	reference, _ := storage.BookModel.InsertBook(models.Book{Title: "Oxford English Dictionary"})
+	root, _ := storage.CategoryModel.InsertCategory(models.Category{Name: "Reference", Code: "REF"})
+	dictionaries, _ := storage.CategoryModel.InsertCategory(models.Category{Name: "Dictionaries", ParentID: &root.ID})
+	reference, err = storage.BookModel.AttachBook(int(reference.ID), 0, nil, []uint{dictionaries.ID})
+	assert.NoError(t, err)
+
+	loan := overdue(t, novel, borrower)
+	overdue(t, reference, borrower)
+
+	t.Run("POST /fines/accrue", func(t *testing.T) {
+		// 16 started days after the grace period at 10, 17 at the 100 of
+		// the reference shelves which have no grace period
+		response := accrue()
+		assert.Equal(t, 2, response.Loans)
+		assert.Equal(t, int64(160+1700), response.Amount)
+
+		// nothing more the same day
+		response = accrue()
+		assert.Equal(t, 0, response.Loans)
+	})
+
+	t.Run("GET /users/:id/fines", func(t *testing.T) {
+		res := request(http.MethodGet, "/users/:id/fines", borrower.ID, nil, fineController.GetUserFinesController)
+
+		var response GetFineBalanceResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, int64(1860), response.Balance)
+		assert.True(t, response.Blocked)
+
+		res = request(http.MethodGet, "/users/:id/fines", 9999, nil, fineController.GetUserFinesController)
+		assert.Equal(t, 404, res.Code)
+	})
+
+	t.Run("checkout blocked", func(t *testing.T) {
+		copy, _ := storage.CopyModel.InsertCopy(models.Copy{BookID: novel.ID, Status: models.CopyAvailable}, policy)
+		_, err := storage.LoanModel.CheckoutLoan(models.Loan{CopyID: copy.ID, UserID: borrower.ID}, policy)
+		assert.Equal(t, models.ErrFinesOwed, err)
+	})
+
+	t.Run("POST /users/:id/fines/payments", func(t *testing.T) {
+		var problem common.Problem
+		res := request(http.MethodPost, "/users/:id/fines/payments", borrower.ID, map[string]interface{}{"amount": 2000}, fineController.PayFineController)
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "overpayment", problem.Code)
+
+		res = request(http.MethodPost, "/users/:id/fines/payments", borrower.ID, map[string]interface{}{"amount": 0}, fineController.PayFineController)
+		assert.Equal(t, 422, res.Code)
+
+		var response SettleFineResponse
+		res = request(http.MethodPost, "/users/:id/fines/payments", borrower.ID, map[string]interface{}{"amount": 700, "note": " cash "}, fineController.PayFineController)
+		json.Unmarshal(res.Body.Bytes(), &response)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, int64(-700), response.Entry.Amount)
+		assert.Equal(t, "cash", response.Entry.Note)
+		assert.Equal(t, uint(1), response.Entry.RecordedBy)
+		assert.Equal(t, int64(1160), response.Balance.Balance)
+		assert.True(t, response.Balance.Blocked)
+	})
+
+	t.Run("POST /users/:id/fines/waivers", func(t *testing.T) {
+		var response SettleFineResponse
+		res := request(http.MethodPost, "/users/:id/fines/waivers", borrower.ID, map[string]interface{}{"amount": 200}, fineController.WaiveFineController)
+		json.Unmarshal(res.Body.Bytes(), &response)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, models.FineWaiver, response.Entry.Kind)
+		assert.Equal(t, GetFineBalanceResponse{
+			UserID:     borrower.ID,
+			Balance:    960,
+			Charged:    1860,
+			Paid:       700,
+			Waived:     200,
+			MaxBalance: 1000,
+		}, response.Balance)
+
+		// owing less than the limit, borrowing again
+		copy, _ := storage.CopyModel.InsertCopy(models.Copy{BookID: novel.ID, Status: models.CopyAvailable}, policy)
+		_, err := storage.LoanModel.CheckoutLoan(models.Loan{CopyID: copy.ID, UserID: borrower.ID}, policy)
+		assert.NoError(t, err)
+	})
+
+	t.Run("returned loans are closed", func(t *testing.T) {
+		_, err := storage.LoanModel.ReturnLoan(int(loan.ID), 0, policy, time.Now())
+		assert.NoError(t, err)
+
+		assert.Equal(t, 0, accrue().Loans)
+
+		loan, _ = storage.LoanModel.GetLoan(int(loan.ID))
+		assert.Equal(t, int64(160), loan.Fined)
+		assert.True(t, loan.FinesClosed)
+	})
+
+	t.Run("GET /users/:id/fines/entries", func(t *testing.T) {
+		res := request(http.MethodGet, "/users/:id/fines/entries", borrower.ID, nil, fineController.GetUserFineEntriesController)
+
+		var entries []models.FineEntry
+		json.Unmarshal(res.Body.Bytes(), &entries)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 4, len(entries))
+		assert.Equal(t, loan.ID, *entries[0].LoanID)
+		assert.Equal(t, models.FineCharge, entries[0].Kind)
+	})
+
+	t.Run("GET /fines", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/fines?kind=charge", nil)
+		res := httptest.NewRecorder()
+		fineController.GetAllFineController(e.NewContext(req, res))
+
+		var entries []models.FineEntry
+		json.Unmarshal(res.Body.Bytes(), &entries)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 2, len(entries))
+	})
+}
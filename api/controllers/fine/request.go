@@ -0,0 +1,13 @@
+package fine
+
+import "strings"
+
+// SettleFineRequest amount paid or waived in cents, never more than owed
+type SettleFineRequest struct {
+	Amount int64  `json:"amount" form:"amount" validate:"required,gt=0"`
+	Note   string `json:"note" form:"note" validate:"max=255"`
+}
+
+func (r *SettleFineRequest) Normalize() {
+	r.Note = strings.TrimSpace(r.Note)
+}
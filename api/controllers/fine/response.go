@@ -0,0 +1,69 @@
+package fine
+
+import (
+	"time"
+
+	"project-api/models"
+)
+
+type GetFineEntryResponse struct {
+	ID         uint      `json:"id"`
+	UserID     uint      `json:"user_id"`
+	LoanID     uint      `json:"loan_id,omitempty"`
+	Kind       string    `json:"kind"`
+	Amount     int64     `json:"amount"`
+	Note       string    `json:"note,omitempty"`
+	RecordedBy uint      `json:"recorded_by,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type GetFineBalanceResponse struct {
+	UserID     uint  `json:"user_id"`
+	Balance    int64 `json:"balance"`
+	Charged    int64 `json:"charged"`
+	Paid       int64 `json:"paid"`
+	Waived     int64 `json:"waived"`
+	MaxBalance int64 `json:"max_balance,omitempty"`
+	Blocked    bool  `json:"blocked"`
+}
+
+type SettleFineResponse struct {
+	Entry   GetFineEntryResponse   `json:"entry"`
+	Balance GetFineBalanceResponse `json:"balance"`
+}
+
+type AccrueFinesResponse struct {
+	Loans  int   `json:"loans"`
+	Amount int64 `json:"amount"`
+}
+
+func NewGetFineEntryResponse(entry models.FineEntry) GetFineEntryResponse {
+	response := GetFineEntryResponse{
+		ID:        entry.ID,
+		UserID:    entry.UserID,
+		Kind:      entry.Kind,
+		Amount:    entry.Amount,
+		Note:      entry.Note,
+		CreatedAt: entry.CreatedAt,
+	}
+
+	if entry.LoanID != nil {
+		response.LoanID = *entry.LoanID
+	}
+	if entry.RecordedBy != nil {
+		response.RecordedBy = *entry.RecordedBy
+	}
+	return response
+}
+
+func NewGetFineBalanceResponse(balance models.FineBalance, policy models.FinePolicy) GetFineBalanceResponse {
+	return GetFineBalanceResponse{
+		UserID:     balance.UserID,
+		Balance:    balance.Balance,
+		Charged:    balance.Charged,
+		Paid:       balance.Paid,
+		Waived:     balance.Waived,
+		MaxBalance: policy.MaxBalance,
+		Blocked:    policy.MaxBalance > 0 && balance.Balance > policy.MaxBalance,
+	}
+}
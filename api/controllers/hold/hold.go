@@ -0,0 +1,228 @@
+package hold
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"project-api/api/common"
+	"project-api/api/middlewares"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	holdModel models.HoldModel
+	bookModel models.BookModel
+	userModel models.UserModel
+	policy    models.LoanPolicy
+}
+
+func NewController(holdModel models.HoldModel, bookModel models.BookModel, userModel models.UserModel, policy models.LoanPolicy) *Controller {
+	return &Controller{
+		holdModel,
+		bookModel,
+		userModel,
+		policy,
+	}
+}
+
+// holdFilters query string parameters filtering GET /holds and
+// GET /users/:id/holds
+var holdFilters = []common.QueryFilter{
+	{Param: "status", Field: "status", Op: models.FilterEqual},
+	{Param: "book_id", Field: "book_id", Op: models.FilterEqual},
+	{Param: "user_id", Field: "user_id", Op: models.FilterEqual},
+	{Param: "copy_id", Field: "copy_id", Op: models.FilterEqual},
+	{Param: "expires_before", Field: "expires_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllHoldController(c echo.Context) error {
+	query, err := common.ParseQuery(c, holdFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	holds, page, err := controller.holdModel.GetAllHold(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, holds)
+}
+
+func (controller *Controller) GetUserHoldsController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	query, err := common.ParseQuery(c, holdFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// an unknown user is a 404, not an empty list
+	user, err := controller.userModel.Get(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+	query.Filters = append(query.Filters, models.Filter{Field: "user_id", Op: models.FilterEqual, Value: user.ID})
+
+	holds, page, err := controller.holdModel.GetAllHold(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, holds)
+}
+
+func (controller *Controller) GetHoldController(c echo.Context) error {
+	hold, err := controller.hold(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, hold.Version)
+	return c.JSON(http.StatusOK, NewGetHoldResponse(hold))
+}
+
+func (controller *Controller) PostHoldController(c echo.Context) error {
+	// bind request value
+	var holdRequest PostHoldRequest
+
+	if err := c.Bind(&holdRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	if err := c.Validate(&holdRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	userID, err := controller.holder(c, holdRequest.UserID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	bookID, err := controller.book(holdRequest.BookID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	hold, err := controller.holdModel.PlaceHold(models.Hold{
+		BookID: bookID,
+		UserID: userID,
+	}, controller.policy)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, hold.Version)
+	return c.JSON(http.StatusOK, NewGetHoldResponse(hold))
+}
+
+func (controller *Controller) CancelHoldController(c echo.Context) error {
+	current, err := controller.hold(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// the copy of a ready hold goes to the next in line
+	if _, err := controller.holdModel.CancelHold(int(current.ID), version, controller.policy, time.Now()); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+func (controller *Controller) ExpireHoldsController(c echo.Context) error {
+	expired, err := controller.holdModel.ExpireHolds(controller.policy, time.Now())
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, ExpireHoldsResponse{Expired: expired})
+}
+
+// staff the token belongs to an admin or a librarian
+func staff(c echo.Context) bool {
+	switch middlewares.ExtractTokenRole(c) {
+	case models.RoleAdmin, models.RoleLibrarian:
+		return true
+	}
+	return false
+}
+
+// hold the hold named by the path, staff see every hold and users their
+// own only
+func (controller *Controller) hold(c echo.Context) (models.Hold, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return models.Hold{}, common.NewBadRequestProblem("id must be an integer")
+	}
+
+	hold, err := controller.holdModel.GetHold(id)
+	if err != nil {
+		return hold, err
+	}
+
+	if !staff(c) && int(hold.UserID) != middlewares.ExtractTokenUserId(c) {
+		return hold, common.NewForbiddenProblem("the hold belongs to another user")
+	}
+	return hold, nil
+}
+
+// holder the id of the user the hold is for, the caller unless staff
+// name someone else
+func (controller *Controller) holder(c echo.Context, userID uint) (uint, error) {
+	self := uint(middlewares.ExtractTokenUserId(c))
+	if userID == 0 || userID == self {
+		return self, nil
+	}
+	if !staff(c) {
+		return 0, common.NewForbiddenProblem("your role is not allowed to place holds for others")
+	}
+
+	user, err := controller.userModel.Get(int(userID))
+	if errors.Is(err, models.ErrNotFound) {
+		return 0, common.NewValidationProblem([]common.FieldError{{
+			Field:   "user_id",
+			Rule:    "exists",
+			Message: fmt.Sprintf("user %v does not exist", userID),
+		}})
+	}
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
+
+// book the id of the requested book
+func (controller *Controller) book(bookID uint) (uint, error) {
+	book, err := controller.bookModel.GetBook(int(bookID))
+	if errors.Is(err, models.ErrNotFound) {
+		return 0, common.NewValidationProblem([]common.FieldError{{
+			Field:   "book_id",
+			Rule:    "exists",
+			Message: fmt.Sprintf("book %v does not exist", bookID),
+		}})
+	}
+	if err != nil {
+		return 0, err
+	}
+	return book.ID, nil
+}
@@ -0,0 +1,322 @@
+package hold
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"project-api/api/common"
+	"project-api/api/validation"
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	"github.com/dgrijalva/jwt-go"
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+var policy = models.LoanPolicy{Period: 14 * 24 * time.Hour, MaxRenewals: 2, HoldPickup: 48 * time.Hour, MaxHolds: 3}
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.User{}, &models.Book{}, &models.Copy{}, &models.Loan{}, &models.Hold{})
+		db.AutoMigrate(&models.User{}, &models.Book{}, &models.Copy{}, &models.Loan{}, &models.Hold{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "users")
+		models.DropMongoCollection(db, "books")
+		models.DropMongoCollection(db, "copies")
+		models.DropMongoCollection(db, "loans")
+		models.DropMongoCollection(db, "holds")
+	}
+}
+
+// user insert a user for the test
+func user(t *testing.T, name string) models.User {
+	user, err := storage.UserModel.Insert(models.User{Name: name, Email: name + "@alterra.id", Password: "password123"})
+	assert.NoError(t, err)
+	return user
+}
+
+// lent insert a book with one copy per borrower, each copy lent out
+func lent(t *testing.T, title string, borrowers ...models.User) (models.Book, []models.Loan) {
+	book, err := storage.BookModel.InsertBook(models.Book{Title: title})
+	assert.NoError(t, err)
+
+	loans := make([]models.Loan, 0, len(borrowers))
+	for _, borrower := range borrowers {
+		copy, err := storage.CopyModel.InsertCopy(models.Copy{BookID: book.ID, Status: models.CopyAvailable}, policy)
+		assert.NoError(t, err)
+		loan, err := storage.LoanModel.CheckoutLoan(models.Loan{CopyID: copy.ID, UserID: borrower.ID}, policy)
+		assert.NoError(t, err)
+		loans = append(loans, loan)
+	}
+	return book, loans
+}
+
+func TestHoldController(t *testing.T) {
+	// create controller
+	holdController := NewController(storage.HoldModel, storage.BookModel, storage.UserModel, policy)
+
+	request := func(method string, id uint, body map[string]interface{}, userID uint, role string) (*httptest.ResponseRecorder, GetHoldResponse) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(common.HeaderIfMatch, "*")
+		context := e.NewContext(req, res)
+		context.Set("user", &jwt.Token{
+			Valid:  true,
+			Claims: jwt.MapClaims{"userId": float64(userID), "role": role},
+		})
+
+		if id == 0 {
+			context.SetPath("/holds")
+		} else {
+			context.SetPath("/holds/:id")
+			context.SetParamNames("id")
+			context.SetParamValues(fmt.Sprint(id))
+		}
+
+		switch {
+		case method == http.MethodPost:
+			holdController.PostHoldController(context)
+		case method == http.MethodDelete:
+			holdController.CancelHoldController(context)
+		default:
+			holdController.GetHoldController(context)
+		}
+
+		var response GetHoldResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	reader, first, second := user(t, "reader"), user(t, "first"), user(t, "second")
+	book, loans := lent(t, "Mansfield Park", reader)
+	copyID := loans[0].CopyID
+
+	var firstHold, secondHold GetHoldResponse
+
+	t.Run("POST /holds in queue order", func(t *testing.T) {
+		var res *httptest.ResponseRecorder
+		res, firstHold = request(http.MethodPost, 0, map[string]interface{}{"book_id": book.ID}, first.ID, models.RoleMember)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, models.HoldWaiting, firstHold.Status)
+		assert.Equal(t, 1, firstHold.Position)
+
+		res, secondHold = request(http.MethodPost, 0, map[string]interface{}{"book_id": book.ID, "user_id": second.ID}, 1, models.RoleLibrarian)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, second.ID, secondHold.UserID)
+		assert.Equal(t, 2, secondHold.Position)
+	})
+
+	t.Run("POST /holds refused", func(t *testing.T) {
+		res, _ := request(http.MethodPost, 0, map[string]interface{}{"book_id": book.ID}, first.ID, models.RoleMember)
+		assert.Equal(t, 409, res.Code)
+
+		res, _ = request(http.MethodPost, 0, map[string]interface{}{"book_id": book.ID, "user_id": reader.ID}, first.ID, models.RoleMember)
+		assert.Equal(t, 403, res.Code)
+
+		res, _ = request(http.MethodPost, 0, map[string]interface{}{"book_id": 9999}, first.ID, models.RoleMember)
+		assert.Equal(t, 422, res.Code)
+
+		// a book on the shelf is checked out, not held
+		shelved, _ := storage.BookModel.InsertBook(models.Book{Title: "Lady Susan"})
+		storage.CopyModel.InsertCopy(models.Copy{BookID: shelved.ID, Status: models.CopyAvailable}, policy)
+
+		var problem common.Problem
+		res, _ = request(http.MethodPost, 0, map[string]interface{}{"book_id": shelved.ID}, first.ID, models.RoleMember)
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 409, res.Code)
+		assert.Equal(t, "copy_available", problem.Code)
+	})
+
+	t.Run("renewing a held book", func(t *testing.T) {
+		_, err := storage.LoanModel.RenewLoan(int(loans[0].ID), 0, policy, time.Now())
+		assert.Equal(t, models.ErrHoldsWaiting, err)
+	})
+
+	t.Run("a returned copy goes to the first hold", func(t *testing.T) {
+		_, err := storage.LoanModel.ReturnLoan(int(loans[0].ID), 0, policy, time.Now())
+		assert.NoError(t, err)
+
+		res, hold := request(http.MethodGet, firstHold.ID, nil, first.ID, models.RoleMember)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, copyID, hold.CopyID)
+		assert.NotNil(t, hold.ExpiresAt)
+
+		_, hold = request(http.MethodGet, secondHold.ID, nil, second.ID, models.RoleMember)
+		assert.Equal(t, 1, hold.Position)
+
+		copy, _ := storage.CopyModel.GetCopy(int(copyID))
+		assert.Equal(t, models.CopyOnHold, copy.Status)
+
+		res, _ = request(http.MethodGet, firstHold.ID, nil, second.ID, models.RoleMember)
+		assert.Equal(t, 403, res.Code)
+	})
+
+	t.Run("only the holder checks the copy out", func(t *testing.T) {
+		_, err := storage.LoanModel.CheckoutLoan(models.Loan{CopyID: copyID, UserID: second.ID}, policy)
+		assert.Equal(t, models.ErrCopyUnavailable, err)
+
+		loan, err := storage.LoanModel.CheckoutLoan(models.Loan{CopyID: copyID, UserID: first.ID}, policy)
+		assert.NoError(t, err)
+		loans[0] = loan
+
+		hold, _ := storage.HoldModel.GetHold(int(firstHold.ID))
+		assert.Equal(t, models.HoldCollected, hold.Status)
+	})
+
+	t.Run("DELETE /holds/:id passes the copy on", func(t *testing.T) {
+		_, err := storage.LoanModel.ReturnLoan(int(loans[0].ID), 0, policy, time.Now())
+		assert.NoError(t, err)
+
+		hold, _ := storage.HoldModel.GetHold(int(secondHold.ID))
+		assert.Equal(t, models.HoldReady, hold.Status)
+
+		res, _ := request(http.MethodDelete, secondHold.ID, nil, second.ID, models.RoleMember)
+		assert.Equal(t, 200, res.Code)
+
+		// nobody else waits, back on the shelf
+		copy, _ := storage.CopyModel.GetCopy(int(copyID))
+		assert.Equal(t, models.CopyAvailable, copy.Status)
+
+		var problem common.Problem
+		res, _ = request(http.MethodDelete, secondHold.ID, nil, second.ID, models.RoleMember)
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 409, res.Code)
+		assert.Equal(t, "hold_closed", problem.Code)
+	})
+
+	t.Run("POST /holds/expire", func(t *testing.T) {
+		late := user(t, "late")
+		book, loans := lent(t, "Emma", reader)
+		hold, err := storage.HoldModel.PlaceHold(models.Hold{BookID: book.ID, UserID: late.ID}, policy)
+		assert.NoError(t, err)
+		_, err = storage.LoanModel.ReturnLoan(int(loans[0].ID), 0, policy, time.Now().Add(-3*24*time.Hour))
+		assert.NoError(t, err)
+
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		res := httptest.NewRecorder()
+		holdController.ExpireHoldsController(e.NewContext(req, res))
+
+		var response ExpireHoldsResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 1, response.Expired)
+
+		hold, _ = storage.HoldModel.GetHold(int(hold.ID))
+		assert.Equal(t, models.HoldExpired, hold.Status)
+
+		copy, _ := storage.CopyModel.GetCopy(int(loans[0].CopyID))
+		assert.Equal(t, models.CopyAvailable, copy.Status)
+	})
+
+	t.Run("GET /users/:id/holds", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/users/:id/holds")
+		context.SetParamNames("id")
+		context.SetParamValues(fmt.Sprint(second.ID))
+
+		holdController.GetUserHoldsController(context)
+
+		var holds []models.Hold
+		json.Unmarshal(res.Body.Bytes(), &holds)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 1, len(holds))
+		assert.Equal(t, models.HoldCancelled, holds[0].Status)
+	})
+}
+
+func TestConcurrentHoldQueue(t *testing.T) {
+	readers := []models.User{user(t, "racer1"), user(t, "racer2"), user(t, "racer3")}
+	book, loans := lent(t, "Persuasion", readers...)
+
+	waiting := make([]models.Hold, 0, 4)
+	for i := 0; i < cap(waiting); i++ {
+		hold, err := storage.HoldModel.PlaceHold(models.Hold{BookID: book.ID, UserID: user(t, fmt.Sprintf("waiter%v", i)).ID}, policy)
+		assert.NoError(t, err)
+		waiting = append(waiting, hold)
+	}
+
+	t.Run("returns and cancellations keep the queue whole", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for _, loan := range loans {
+			wg.Add(1)
+			go func(loanId int) {
+				defer wg.Done()
+				_, err := storage.LoanModel.ReturnLoan(loanId, 0, policy, time.Now())
+				assert.NoError(t, err)
+			}(int(loan.ID))
+		}
+		for _, hold := range waiting[:2] {
+			wg.Add(1)
+			go func(holdId int) {
+				defer wg.Done()
+				storage.HoldModel.CancelHold(holdId, 0, policy, time.Now())
+			}(int(hold.ID))
+		}
+		wg.Wait()
+
+		holds, _, err := storage.HoldModel.GetAllHold(models.Query{
+			Filters: []models.Filter{{Field: "book_id", Op: models.FilterEqual, Value: book.ID}},
+		})
+		assert.NoError(t, err)
+
+		ready := map[uint]bool{}
+		for _, hold := range holds {
+			if hold.Status == models.HoldReady {
+				assert.False(t, ready[*hold.CopyID], "copy set aside twice")
+				ready[*hold.CopyID] = true
+			}
+		}
+		// the two holds left are served, the third copy is back on the shelf
+		assert.Equal(t, 2, len(ready))
+
+		copies, _, err := storage.CopyModel.GetAllCopy(models.Query{
+			Filters: []models.Filter{{Field: "book_id", Op: models.FilterEqual, Value: book.ID}},
+		})
+		assert.NoError(t, err)
+		counts := map[string]int{}
+		for _, copy := range copies {
+			counts[copy.Status]++
+			if copy.Status == models.CopyOnHold {
+				assert.True(t, ready[copy.ID])
+			}
+		}
+		assert.Equal(t, map[string]int{models.CopyOnHold: 2, models.CopyAvailable: 1}, counts)
+	})
+}
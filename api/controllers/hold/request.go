@@ -0,0 +1,8 @@
+package hold
+
+// PostHoldRequest members hold books for themselves, staff may name the
+// user they place the hold for
+type PostHoldRequest struct {
+	BookID uint `json:"book_id" form:"book_id" validate:"required"`
+	UserID uint `json:"user_id" form:"user_id"`
+}
@@ -0,0 +1,41 @@
+package hold
+
+import (
+	"time"
+
+	"project-api/models"
+)
+
+type GetHoldResponse struct {
+	ID        uint       `json:"id"`
+	BookID    uint       `json:"book_id"`
+	UserID    uint       `json:"user_id"`
+	CopyID    uint       `json:"copy_id,omitempty"`
+	Status    string     `json:"status"`
+	Position  int        `json:"position,omitempty"`
+	QueuedAt  time.Time  `json:"queued_at"`
+	ReadyAt   *time.Time `json:"ready_at,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type ExpireHoldsResponse struct {
+	Expired int `json:"expired"`
+}
+
+func NewGetHoldResponse(hold models.Hold) GetHoldResponse {
+	response := GetHoldResponse{
+		ID:        hold.ID,
+		BookID:    hold.BookID,
+		UserID:    hold.UserID,
+		Status:    hold.Status,
+		Position:  hold.Position,
+		QueuedAt:  hold.QueuedAt,
+		ReadyAt:   hold.ReadyAt,
+		ExpiresAt: hold.ExpiresAt,
+	}
+
+	if hold.CopyID != nil {
+		response.CopyID = *hold.CopyID
+	}
+	return response
+}
@@ -0,0 +1,214 @@
+package inventory
+
+import (
+	"net/http"
+	"strconv"
+
+	"project-api/api/common"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	copyModel models.CopyModel
+	bookModel models.BookModel
+	policy    models.LoanPolicy
+}
+
+func NewController(copyModel models.CopyModel, bookModel models.BookModel, policy models.LoanPolicy) *Controller {
+	return &Controller{
+		copyModel,
+		bookModel,
+		policy,
+	}
+}
+
+// copyFilters query string parameters filtering GET /books/:id/copies
+var copyFilters = []common.QueryFilter{
+	{Param: "barcode", Field: "barcode", Op: models.FilterEqual},
+	{Param: "condition", Field: "condition", Op: models.FilterEqual},
+	{Param: "location", Field: "location", Op: models.FilterEqual},
+	{Param: "status", Field: "status", Op: models.FilterEqual},
+	{Param: "seen_from", Field: "last_seen_at", Op: models.FilterFrom, Time: true},
+	{Param: "seen_before", Field: "last_seen_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllCopyController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	query, err := common.ParseQuery(c, copyFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// an unknown book is a 404, not an empty list
+	book, err := controller.bookModel.GetBook(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+	query.Filters = append(query.Filters, models.Filter{Field: "book_id", Op: models.FilterEqual, Value: book.ID})
+
+	copies, page, err := controller.copyModel.GetAllCopy(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, copies)
+}
+
+func (controller *Controller) GetCopyController(c echo.Context) error {
+	copy, err := controller.bookCopy(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, copy.Version)
+	return c.JSON(http.StatusOK, NewGetCopyResponse(copy))
+}
+
+func (controller *Controller) PostCopyController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	// bind request value
+	var copyRequest PostCopyRequest
+
+	if err := c.Bind(&copyRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	copyRequest.Normalize()
+	if err := c.Validate(&copyRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	copy, err := controller.copyModel.InsertCopy(models.Copy{
+		BookID:    uint(id),
+		Barcode:   barcode(copyRequest.Barcode),
+		Condition: copyRequest.Condition,
+		Location:  copyRequest.Location,
+		Status:    copyRequest.Status,
+	}, controller.policy)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// the id is needed to edit or withdraw the copy
+	common.SetETag(c, copy.Version)
+	return c.JSON(http.StatusOK, NewGetCopyResponse(copy))
+}
+
+func (controller *Controller) EditCopyController(c echo.Context) error {
+	current, err := controller.bookCopy(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// bind request value
+	var copyRequest EditCopyRequest
+	if err := c.Bind(&copyRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	copyRequest.Normalize()
+	if err := c.Validate(&copyRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	// only a stocktake tells when a copy was last seen
+	copy, err := controller.copyModel.EditCopy(models.Copy{
+		Barcode:    barcode(copyRequest.Barcode),
+		Condition:  copyRequest.Condition,
+		Location:   copyRequest.Location,
+		Status:     copyRequest.Status,
+		LastSeenAt: current.LastSeenAt,
+		Version:    version,
+	}, int(current.ID), controller.policy)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, copy.Version)
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+func (controller *Controller) DeleteCopyController(c echo.Context) error {
+	current, err := controller.bookCopy(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if _, err := controller.copyModel.DeleteCopy(int(current.ID), version); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+func (controller *Controller) StocktakeController(c echo.Context) error {
+	// bind request value
+	var stocktakeRequest StocktakeRequest
+
+	if err := c.Bind(&stocktakeRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	stocktakeRequest.Normalize()
+	if err := c.Validate(&stocktakeRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	// without apply the stocktake is a dry run, nothing is written
+	stocktake, err := models.TakeStock(controller.copyModel, controller.policy, stocktakeRequest.Location, stocktakeRequest.Barcodes, stocktakeRequest.Apply)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, NewStocktakeResponse(stocktake))
+}
+
+// bookCopy the copy named by the path, which must belong to the book
+// named by the path as well
+func (controller *Controller) bookCopy(c echo.Context) (models.Copy, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return models.Copy{}, common.NewBadRequestProblem("id must be an integer")
+	}
+
+	copyID, err := strconv.Atoi(c.Param("copy_id"))
+	if err != nil {
+		return models.Copy{}, common.NewBadRequestProblem("copy_id must be an integer")
+	}
+
+	copy, err := controller.copyModel.GetCopy(copyID)
+	if err != nil {
+		return copy, err
+	}
+	if copy.BookID != uint(id) {
+		return copy, common.NewNotFoundProblem("book has no such copy")
+	}
+	return copy, nil
+}
@@ -0,0 +1,384 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"project-api/api/common"
+	"project-api/api/validation"
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+var policy = models.LoanPolicy{Period: 14 * 24 * time.Hour, HoldPickup: 3 * 24 * time.Hour}
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.User{}, &models.Book{}, &models.Copy{}, &models.Hold{})
+		db.AutoMigrate(&models.User{}, &models.Book{}, &models.Copy{}, &models.Hold{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "users")
+		models.DropMongoCollection(db, "books")
+		models.DropMongoCollection(db, "copies")
+		models.DropMongoCollection(db, "holds")
+	}
+}
+
+func TestCopyController(t *testing.T) {
+	// create controller
+	inventoryController := NewController(storage.CopyModel, storage.BookModel, policy)
+
+	request := func(method string, bookID, copyID uint, body map[string]interface{}, header http.Header) (*httptest.ResponseRecorder, GetCopyResponse) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		for key := range header {
+			req.Header.Set(key, header.Get(key))
+		}
+		context := e.NewContext(req, res)
+
+		if copyID == 0 {
+			context.SetPath("/books/:id/copies")
+			context.SetParamNames("id")
+			context.SetParamValues(fmt.Sprint(bookID))
+		} else {
+			context.SetPath("/books/:id/copies/:copy_id")
+			context.SetParamNames("id", "copy_id")
+			context.SetParamValues(fmt.Sprint(bookID), fmt.Sprint(copyID))
+		}
+
+		switch {
+		case method == http.MethodPost:
+			inventoryController.PostCopyController(context)
+		case method == http.MethodPut:
+			inventoryController.EditCopyController(context)
+		case method == http.MethodDelete:
+			inventoryController.DeleteCopyController(context)
+		case copyID == 0:
+			inventoryController.GetAllCopyController(context)
+		default:
+			inventoryController.GetCopyController(context)
+		}
+
+		var response GetCopyResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	book, _ := storage.BookModel.InsertBook(models.Book{Title: "Persuasion"})
+	other, _ := storage.BookModel.InsertBook(models.Book{Title: "Emma"})
+
+	var first, second GetCopyResponse
+
+	t.Run("POST /books/:id/copies", func(t *testing.T) {
+		var res *httptest.ResponseRecorder
+		res, first = request(http.MethodPost, book.ID, 0, map[string]interface{}{"barcode": " lib-0001 ", "location": "Stack  A"}, nil)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"1"`, res.Header().Get(common.HeaderETag))
+		assert.Equal(t, "LIB-0001", first.Barcode)
+		assert.Equal(t, "Stack A", first.Location)
+		assert.Equal(t, models.ConditionGood, first.Condition)
+		assert.Equal(t, models.CopyAvailable, first.Status)
+
+		_, second = request(http.MethodPost, book.ID, 0, map[string]interface{}{"barcode": "LIB-0002", "condition": "fair", "status": "in_repair"}, nil)
+		assert.Equal(t, models.CopyInRepair, second.Status)
+
+		copies, err := storage.BookModel.GetBook(int(book.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, models.CopyCounts{Total: 2, Available: 1, InRepair: 1}, copies.Copies)
+	})
+
+	t.Run("POST /books/:id/copies invalid", func(t *testing.T) {
+		res, _ := request(http.MethodPost, book.ID, 0, map[string]interface{}{"barcode": "LIB-0001"}, nil)
+		assert.Equal(t, 409, res.Code)
+
+		res, _ = request(http.MethodPost, book.ID, 0, map[string]interface{}{"status": "borrowed"}, nil)
+		assert.Equal(t, 422, res.Code)
+
+		// only a checkout puts a copy on loan
+		res, _ = request(http.MethodPost, book.ID, 0, map[string]interface{}{"status": "on_loan"}, nil)
+		assert.Equal(t, 422, res.Code)
+
+		res, _ = request(http.MethodPost, 9999, 0, map[string]interface{}{"barcode": "LIB-0003"}, nil)
+		assert.Equal(t, 404, res.Code)
+	})
+
+	t.Run("GET /books/:id/copies", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/?status=in_repair", nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/books/:id/copies")
+		context.SetParamNames("id")
+		context.SetParamValues(fmt.Sprint(book.ID))
+
+		inventoryController.GetAllCopyController(context)
+
+		var copies []models.Copy
+		json.Unmarshal(res.Body.Bytes(), &copies)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "1", res.Header().Get(common.HeaderTotalCount))
+		assert.Equal(t, second.ID, copies[0].ID)
+
+		res, _ = request(http.MethodGet, 9999, 0, nil, nil)
+		assert.Equal(t, 404, res.Code)
+	})
+
+	t.Run("GET /books/:id/copies/:copy_id of another book", func(t *testing.T) {
+		res, _ := request(http.MethodGet, other.ID, first.ID, nil, nil)
+		assert.Equal(t, 404, res.Code)
+	})
+
+	t.Run("PUT /books/:id/copies/:copy_id", func(t *testing.T) {
+		res, _ := request(http.MethodPut, book.ID, second.ID, map[string]interface{}{"barcode": "LIB-0002", "condition": "poor", "status": "lost"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+
+		res, _ = request(http.MethodPut, book.ID, second.ID, map[string]interface{}{"condition": "poor", "status": "in_repair"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 412, res.Code)
+
+		res, response := request(http.MethodGet, book.ID, second.ID, nil, nil)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, models.CopyLost, response.Status)
+		assert.Equal(t, "poor", response.Condition)
+	})
+
+	t.Run("PUT /books/:id/copies/:copy_id status of loans and holds", func(t *testing.T) {
+		res, _ := request(http.MethodPut, book.ID, first.ID, map[string]interface{}{"condition": "good", "status": "on_hold"}, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 422, res.Code)
+
+		onLoan, err := storage.CopyModel.InsertCopy(models.Copy{BookID: other.ID, Status: models.CopyOnLoan}, policy)
+		assert.NoError(t, err)
+
+		// the loan is returned, not the copy
+		res, _ = request(http.MethodPut, other.ID, onLoan.ID, map[string]interface{}{"condition": "good", "status": "available"}, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 409, res.Code)
+
+		// the other fields of a copy on loan can still be changed
+		copy, err := storage.CopyModel.EditCopy(models.Copy{Condition: models.ConditionFair, Status: models.CopyOnLoan}, int(onLoan.ID), policy)
+		assert.NoError(t, err)
+		assert.Equal(t, models.ConditionFair, copy.Condition)
+	})
+
+	t.Run("PUT /books/:id/copies/:copy_id back from repair serves the hold queue", func(t *testing.T) {
+		queued, _ := storage.BookModel.InsertBook(models.Book{Title: "Mansfield Park"})
+		repaired, err := storage.CopyModel.InsertCopy(models.Copy{BookID: queued.ID, Status: models.CopyInRepair}, policy)
+		assert.NoError(t, err)
+
+		user, err := storage.UserModel.Insert(models.User{Name: "waiting", Email: "waiting@alterra.id", Password: "password123"})
+		assert.NoError(t, err)
+		hold, err := storage.HoldModel.PlaceHold(models.Hold{BookID: queued.ID, UserID: user.ID}, policy)
+		assert.NoError(t, err)
+
+		res, _ := request(http.MethodPut, queued.ID, repaired.ID, map[string]interface{}{"condition": "good", "status": "available"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 200, res.Code)
+
+		copy, _ := storage.CopyModel.GetCopy(int(repaired.ID))
+		assert.Equal(t, models.CopyOnHold, copy.Status)
+		assert.Equal(t, `"3"`, res.Header().Get(common.HeaderETag))
+
+		hold, _ = storage.HoldModel.GetHold(int(hold.ID))
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, repaired.ID, *hold.CopyID)
+
+		t.Run("DELETE /books/:id/copies/:copy_id set aside for a hold", func(t *testing.T) {
+			res, _ := request(http.MethodDelete, queued.ID, repaired.ID, nil, http.Header{"If-Match": {"*"}})
+			assert.Equal(t, 409, res.Code)
+
+			hold, _ = storage.HoldModel.GetHold(int(hold.ID))
+			assert.Equal(t, models.HoldReady, hold.Status)
+		})
+	})
+
+	t.Run("POST /books/:id/copies serves the hold queue", func(t *testing.T) {
+		queued, _ := storage.BookModel.InsertBook(models.Book{Title: "Sense and Sensibility"})
+		user, err := storage.UserModel.Insert(models.User{Name: "first in line", Email: "first@alterra.id", Password: "password123"})
+		assert.NoError(t, err)
+		hold, err := storage.HoldModel.PlaceHold(models.Hold{BookID: queued.ID, UserID: user.ID}, policy)
+		assert.NoError(t, err)
+
+		res, added := request(http.MethodPost, queued.ID, 0, map[string]interface{}{"barcode": "LIB-0010"}, nil)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, models.CopyOnHold, added.Status)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+
+		hold, _ = storage.HoldModel.GetHold(int(hold.ID))
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, added.ID, *hold.CopyID)
+
+		// nobody else waits, the next copy is left on the shelf
+		res, added = request(http.MethodPost, queued.ID, 0, map[string]interface{}{"barcode": "LIB-0011"}, nil)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, models.CopyAvailable, added.Status)
+		assert.Equal(t, `"1"`, res.Header().Get(common.HeaderETag))
+	})
+
+	t.Run("DELETE /books/:id/copies/:copy_id frees the barcode", func(t *testing.T) {
+		res, _ := request(http.MethodDelete, book.ID, second.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+
+		res, _ = request(http.MethodGet, book.ID, second.ID, nil, nil)
+		assert.Equal(t, 404, res.Code)
+
+		res, _ = request(http.MethodPost, other.ID, 0, map[string]interface{}{"barcode": "LIB-0002"}, nil)
+		assert.Equal(t, 200, res.Code)
+	})
+}
+
+func TestStocktakeController(t *testing.T) {
+	// create controller
+	inventoryController := NewController(storage.CopyModel, storage.BookModel, policy)
+
+	stocktake := func(body map[string]interface{}) (*httptest.ResponseRecorder, StocktakeResponse) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		context := e.NewContext(req, res)
+		context.SetPath("/copies/stocktake")
+
+		inventoryController.StocktakeController(context)
+
+		var response StocktakeResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	book, _ := storage.BookModel.InsertBook(models.Book{Title: "Sanditon"})
+	insert := func(barcode, location, status string) models.Copy {
+		copy, err := storage.CopyModel.InsertCopy(models.Copy{BookID: book.ID, Barcode: &barcode, Location: location, Status: status}, policy)
+		assert.NoError(t, err)
+		return copy
+	}
+
+	shelved := insert("ST-1", "Room 2", models.CopyAvailable)
+	missing := insert("ST-2", "Room 2", models.CopyAvailable)
+	elsewhere := insert("ST-3", "Room 5", models.CopyAvailable)
+	lost := insert("ST-4", "Room 2", models.CopyLost)
+
+	scan := []string{"st-1", "ST-1", "ST-3", "ST-4", "ST-404"}
+
+	t.Run("POST /copies/stocktake dry run", func(t *testing.T) {
+		res, response := stocktake(map[string]interface{}{"location": "Room 2", "barcodes": scan})
+		assert.Equal(t, 200, res.Code)
+		assert.False(t, response.Applied)
+		assert.Equal(t, 4, response.Scanned)
+		assert.Equal(t, shelved.ID, response.Found[0].ID)
+		assert.Equal(t, missing.ID, response.Missing[0].ID)
+		assert.Equal(t, elsewhere.ID, response.Misplaced[0].ID)
+		assert.Equal(t, lost.ID, response.Unexpected[0].ID)
+		assert.Equal(t, []string{"ST-404"}, response.Unknown)
+
+		copy, _ := storage.CopyModel.GetCopy(int(missing.ID))
+		assert.Equal(t, models.CopyAvailable, copy.Status)
+	})
+
+	t.Run("POST /copies/stocktake applied", func(t *testing.T) {
+		res, response := stocktake(map[string]interface{}{"location": "Room 2", "barcodes": scan, "apply": true})
+		assert.Equal(t, 200, res.Code)
+		assert.True(t, response.Applied)
+
+		copy, _ := storage.CopyModel.GetCopy(int(shelved.ID))
+		assert.NotNil(t, copy.LastSeenAt)
+
+		copy, _ = storage.CopyModel.GetCopy(int(missing.ID))
+		assert.Equal(t, models.CopyLost, copy.Status)
+
+		copy, _ = storage.CopyModel.GetCopy(int(elsewhere.ID))
+		assert.Equal(t, "Room 2", copy.Location)
+
+		copy, _ = storage.CopyModel.GetCopy(int(lost.ID))
+		assert.Equal(t, models.CopyAvailable, copy.Status)
+
+		// the shelf now matches what the system expects
+		_, response = stocktake(map[string]interface{}{"location": "Room 2", "barcodes": []string{"ST-1", "ST-3", "ST-4"}})
+		assert.Equal(t, 3, len(response.Found))
+		assert.Equal(t, 0, len(response.Missing))
+	})
+
+	t.Run("stock changes skip copies changed meanwhile", func(t *testing.T) {
+		copy := insert("ST-5", "Room 7", models.CopyAvailable)
+		missing := models.StockChange{
+			CopyID:      copy.ID,
+			BookID:      copy.BookID,
+			Status:      models.CopyAvailable,
+			Location:    "Room 7",
+			NewStatus:   models.CopyLost,
+			NewLocation: "Room 7",
+		}
+
+		// moved between the reconcile and the apply
+		_, err := storage.CopyModel.EditCopy(models.Copy{Condition: models.ConditionGood, Location: "Room 8", Status: models.CopyAvailable}, int(copy.ID), policy)
+		assert.NoError(t, err)
+
+		_, err = storage.CopyModel.ApplyStockChange(missing, policy)
+		assert.ErrorIs(t, err, models.ErrConflict)
+
+		copy, _ = storage.CopyModel.GetCopy(int(copy.ID))
+		assert.Equal(t, models.CopyAvailable, copy.Status)
+		assert.Equal(t, "Room 8", copy.Location)
+	})
+
+	t.Run("POST /copies/stocktake found a lost copy somebody waits for", func(t *testing.T) {
+		queued, _ := storage.BookModel.InsertBook(models.Book{Title: "Lady Susan"})
+		barcode := "ST-6"
+		found, err := storage.CopyModel.InsertCopy(models.Copy{BookID: queued.ID, Barcode: &barcode, Location: "Room 9", Status: models.CopyLost}, policy)
+		assert.NoError(t, err)
+
+		user, err := storage.UserModel.Insert(models.User{Name: "patient", Email: "patient@alterra.id", Password: "password123"})
+		assert.NoError(t, err)
+		hold, err := storage.HoldModel.PlaceHold(models.Hold{BookID: queued.ID, UserID: user.ID}, policy)
+		assert.NoError(t, err)
+
+		res, response := stocktake(map[string]interface{}{"location": "Room 9", "barcodes": []string{"ST-6"}, "apply": true})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 0, len(response.Skipped))
+		if assert.Equal(t, 1, len(response.Unexpected)) {
+			assert.Equal(t, models.CopyOnHold, response.Unexpected[0].Status)
+		}
+
+		hold, _ = storage.HoldModel.GetHold(int(hold.ID))
+		assert.Equal(t, models.HoldReady, hold.Status)
+		assert.Equal(t, found.ID, *hold.CopyID)
+	})
+
+	t.Run("POST /copies/stocktake without barcodes", func(t *testing.T) {
+		res, _ := stocktake(map[string]interface{}{"location": "Room 2"})
+		assert.Equal(t, 422, res.Code)
+	})
+}
@@ -0,0 +1,65 @@
+package inventory
+
+import (
+	"strings"
+
+	"project-api/models"
+)
+
+type PostCopyRequest struct {
+	Barcode   string `json:"barcode" form:"barcode" validate:"max=32"`
+	Condition string `json:"condition" form:"condition" validate:"omitempty,oneof=new good fair poor damaged"`
+	Location  string `json:"location" form:"location" validate:"max=64"`
+	Status    string `json:"status" form:"status" validate:"omitempty,oneof=available lost in_repair"`
+}
+
+type EditCopyRequest struct {
+	Barcode   string `json:"barcode" form:"barcode" validate:"max=32"`
+	Condition string `json:"condition" form:"condition" validate:"required,oneof=new good fair poor damaged"`
+	Location  string `json:"location" form:"location" validate:"max=64"`
+	Status    string `json:"status" form:"status" validate:"required,oneof=available lost in_repair"`
+}
+
+type StocktakeRequest struct {
+	Location string   `json:"location" form:"location" validate:"max=64"`
+	Barcodes []string `json:"barcodes" form:"barcodes" validate:"required,max=10000,dive,max=32"`
+	Apply    bool     `json:"apply" form:"apply"`
+}
+
+// Normalize a new copy is in good condition and available unless told
+// otherwise
+func (r *PostCopyRequest) Normalize() {
+	r.Barcode = models.NormalizeBarcode(r.Barcode)
+	r.Condition = strings.ToLower(strings.TrimSpace(r.Condition))
+	r.Location = strings.Join(strings.Fields(r.Location), " ")
+	r.Status = strings.ToLower(strings.TrimSpace(r.Status))
+
+	if r.Condition == "" {
+		r.Condition = models.ConditionGood
+	}
+	if r.Status == "" {
+		r.Status = models.CopyAvailable
+	}
+}
+
+func (r *EditCopyRequest) Normalize() {
+	r.Barcode = models.NormalizeBarcode(r.Barcode)
+	r.Condition = strings.ToLower(strings.TrimSpace(r.Condition))
+	r.Location = strings.Join(strings.Fields(r.Location), " ")
+	r.Status = strings.ToLower(strings.TrimSpace(r.Status))
+}
+
+func (r *StocktakeRequest) Normalize() {
+	r.Location = strings.Join(strings.Fields(r.Location), " ")
+	for i, barcode := range r.Barcodes {
+		r.Barcodes[i] = models.NormalizeBarcode(barcode)
+	}
+}
+
+// barcode nil for a blank barcode, copies without a label share no value
+func barcode(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
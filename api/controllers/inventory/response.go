@@ -0,0 +1,68 @@
+package inventory
+
+import (
+	"time"
+
+	"project-api/models"
+)
+
+type GetCopyResponse struct {
+	ID         uint       `json:"id"`
+	BookID     uint       `json:"book_id"`
+	Barcode    string     `json:"barcode,omitempty"`
+	Condition  string     `json:"condition"`
+	Location   string     `json:"location,omitempty"`
+	Status     string     `json:"status"`
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+type StocktakeResponse struct {
+	Location string `json:"location,omitempty"`
+	Scanned  int    `json:"scanned"`
+	Applied  bool   `json:"applied"`
+
+	Found      []GetCopyResponse `json:"found"`
+	Missing    []GetCopyResponse `json:"missing"`
+	Misplaced  []GetCopyResponse `json:"misplaced"`
+	Unexpected []GetCopyResponse `json:"unexpected"`
+	Unknown    []string          `json:"unknown"`
+	Skipped    []GetCopyResponse `json:"skipped"`
+}
+
+func NewGetCopyResponse(copy models.Copy) GetCopyResponse {
+	response := GetCopyResponse{
+		ID:         copy.ID,
+		BookID:     copy.BookID,
+		Condition:  copy.Condition,
+		Location:   copy.Location,
+		Status:     copy.Status,
+		LastSeenAt: copy.LastSeenAt,
+	}
+
+	if copy.Barcode != nil {
+		response.Barcode = *copy.Barcode
+	}
+	return response
+}
+
+func NewStocktakeResponse(stocktake models.Stocktake) StocktakeResponse {
+	return StocktakeResponse{
+		Location:   stocktake.Location,
+		Scanned:    stocktake.Scanned,
+		Applied:    stocktake.Applied,
+		Found:      copies(stocktake.Found),
+		Missing:    copies(stocktake.Missing),
+		Misplaced:  copies(stocktake.Misplaced),
+		Unexpected: copies(stocktake.Unexpected),
+		Unknown:    append(make([]string, 0, len(stocktake.Unknown)), stocktake.Unknown...),
+		Skipped:    copies(stocktake.Skipped),
+	}
+}
+
+func copies(list []models.Copy) []GetCopyResponse {
+	response := make([]GetCopyResponse, 0, len(list))
+	for _, copy := range list {
+		response = append(response, NewGetCopyResponse(copy))
+	}
+	return response
+}
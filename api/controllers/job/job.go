@@ -0,0 +1,80 @@
+package job
+
+import (
+	"context"
+	"net/http"
+
+	"project-api/api/common"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/models"
+	"project-api/scheduler"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	scheduler *scheduler.Scheduler
+	jobModel  models.JobModel
+}
+
+func NewController(scheduler *scheduler.Scheduler, jobModel models.JobModel) *Controller {
+	return &Controller{
+		scheduler,
+		jobModel,
+	}
+}
+
+// jobRunFilters query string parameters filtering GET /jobs/runs
+var jobRunFilters = []common.QueryFilter{
+	{Param: "job", Field: "job", Op: models.FilterEqual},
+	{Param: "owner", Field: "owner", Op: models.FilterEqual},
+	{Param: "status", Field: "status", Op: models.FilterEqual},
+	{Param: "started_from", Field: "started_at", Op: models.FilterFrom, Time: true},
+	{Param: "started_before", Field: "started_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllJobController(c echo.Context) error {
+	jobs := controller.scheduler.Jobs()
+
+	response := make([]GetJobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		// the latest run, on whichever server it happened
+		runs, _, err := controller.jobModel.GetAllJobRun(models.Query{
+			Limit:   1,
+			Filters: []models.Filter{{Field: "job", Op: models.FilterEqual, Value: job.Name}},
+			Sort:    []models.Sort{{Field: "started_at", Desc: true}},
+		})
+		if err != nil {
+			return common.ErrorResponse(c, err)
+		}
+		response = append(response, NewGetJobResponse(job, runs))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (controller *Controller) GetAllJobRunController(c echo.Context) error {
+	query, err := common.ParseQuery(c, jobRunFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	runs, page, err := controller.jobModel.GetAllJobRun(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, runs)
+}
+
+func (controller *Controller) RunJobController(c echo.Context) error {
+	// the run goes on when the client hangs up, it is in the history
+	run, err := controller.scheduler.RunNow(context.Background(), c.Param("name"))
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, NewGetJobRunResponse(run))
+}
@@ -0,0 +1,150 @@
+package job
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"project-api/api/common"
+	"project-api/config"
+	"project-api/models"
+	"project-api/scheduler"
+	"project-api/util"
+
+	"github.com/dgrijalva/jwt-go"
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.JobRun{}, &models.JobLease{})
+		db.AutoMigrate(&models.JobRun{}, &models.JobLease{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "job_runs")
+		models.DropMongoCollection(db, "job_leases")
+	}
+}
+
+func TestJobController(t *testing.T) {
+	// create controller
+	jobs := scheduler.New(storage.JobModel, "test")
+	jobs.Add(scheduler.Job{
+		Name:     "report",
+		Schedule: scheduler.MustParse("0 6 * * *"),
+		Run: func(ctx context.Context) (string, error) {
+			return `{"loaned":3}`, nil
+		},
+	})
+	jobs.Add(scheduler.Job{
+		Name:     "holds",
+		Schedule: scheduler.MustParse("*/15 * * * *"),
+		Run: func(ctx context.Context) (string, error) {
+			return "", errors.New("database is gone")
+		},
+	})
+	jobController := NewController(jobs, storage.JobModel)
+
+	request := func(method, path, name, query string, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+		e := echo.New()
+		req := httptest.NewRequest(method, "/?"+query, nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.Set("user", &jwt.Token{
+			Valid:  true,
+			Claims: jwt.MapClaims{"userId": float64(1), "role": models.RoleAdmin},
+		})
+
+		context.SetPath(path)
+		if name != "" {
+			context.SetParamNames("name")
+			context.SetParamValues(name)
+		}
+
+		handler(context)
+		return res
+	}
+
+	t.Run("GET /jobs before any run", func(t *testing.T) {
+		res := request(http.MethodGet, "/jobs", "", "", jobController.GetAllJobController)
+		assert.Equal(t, 200, res.Code)
+
+		var response []GetJobResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		if assert.Len(t, response, 2) {
+			assert.Equal(t, "report", response[0].Name)
+			assert.Equal(t, "0 6 * * *", response[0].Schedule)
+			assert.Nil(t, response[0].LastRun)
+		}
+	})
+
+	t.Run("POST /jobs/:name/run", func(t *testing.T) {
+		res := request(http.MethodPost, "/jobs/:name/run", "report", "", jobController.RunJobController)
+		assert.Equal(t, 200, res.Code)
+
+		var response GetJobRunResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		assert.Equal(t, "report", response.Job)
+		assert.Equal(t, models.JobSucceeded, response.Status)
+		assert.Equal(t, `{"loaned":3}`, response.Output)
+
+		res = request(http.MethodPost, "/jobs/:name/run", "holds", "", jobController.RunJobController)
+		assert.Equal(t, 200, res.Code)
+		json.Unmarshal(res.Body.Bytes(), &response)
+		assert.Equal(t, models.JobFailed, response.Status)
+		assert.Equal(t, "database is gone", response.Error)
+	})
+
+	t.Run("POST /jobs/:name/run unknown job", func(t *testing.T) {
+		res := request(http.MethodPost, "/jobs/:name/run", "payroll", "", jobController.RunJobController)
+		assert.Equal(t, 404, res.Code)
+	})
+
+	t.Run("GET /jobs with the last run", func(t *testing.T) {
+		res := request(http.MethodGet, "/jobs", "", "", jobController.GetAllJobController)
+		assert.Equal(t, 200, res.Code)
+
+		var response []GetJobResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		if assert.Len(t, response, 2) && assert.NotNil(t, response[1].LastRun) {
+			assert.Equal(t, models.JobFailed, response[1].LastRun.Status)
+		}
+	})
+
+	t.Run("GET /jobs/runs", func(t *testing.T) {
+		res := request(http.MethodGet, "/jobs/runs", "", "status=failed", jobController.GetAllJobRunController)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "1", res.Header().Get(common.HeaderTotalCount))
+
+		var response []models.JobRun
+		json.Unmarshal(res.Body.Bytes(), &response)
+		if assert.Len(t, response, 1) {
+			assert.Equal(t, "holds", response[0].Job)
+		}
+
+		res = request(http.MethodGet, "/jobs/runs", "", "started_from=tomorrow", jobController.GetAllJobRunController)
+		assert.Equal(t, 400, res.Code)
+	})
+}
@@ -0,0 +1,59 @@
+package job
+
+import (
+	"time"
+
+	"project-api/models"
+	"project-api/scheduler"
+)
+
+type GetJobRunResponse struct {
+	ID          uint       `json:"id"`
+	Job         string     `json:"job"`
+	Owner       string     `json:"owner"`
+	Status      string     `json:"status"`
+	ScheduledAt time.Time  `json:"scheduled_at"`
+	StartedAt   time.Time  `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+	Output      string     `json:"output,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+type GetJobResponse struct {
+	Name     string             `json:"name"`
+	Schedule string             `json:"schedule"`
+	Jitter   string             `json:"jitter"`
+	Timeout  string             `json:"timeout"`
+	NextRun  time.Time          `json:"next_run"`
+	LastRun  *GetJobRunResponse `json:"last_run,omitempty"`
+}
+
+func NewGetJobRunResponse(run models.JobRun) GetJobRunResponse {
+	return GetJobRunResponse{
+		ID:          run.ID,
+		Job:         run.Job,
+		Owner:       run.Owner,
+		Status:      run.Status,
+		ScheduledAt: run.ScheduledAt,
+		StartedAt:   run.StartedAt,
+		FinishedAt:  run.FinishedAt,
+		Output:      run.Output,
+		Error:       run.Error,
+	}
+}
+
+func NewGetJobResponse(job scheduler.Status, runs []models.JobRun) GetJobResponse {
+	response := GetJobResponse{
+		Name:     job.Name,
+		Schedule: job.Schedule.String(),
+		Jitter:   job.Jitter.String(),
+		Timeout:  job.Timeout.String(),
+		NextRun:  job.Next,
+	}
+
+	if len(runs) > 0 {
+		last := NewGetJobRunResponse(runs[0])
+		response.LastRun = &last
+	}
+	return response
+}
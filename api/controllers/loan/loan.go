@@ -0,0 +1,260 @@
+package loan
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"project-api/api/common"
+	"project-api/api/middlewares"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	loanModel models.LoanModel
+	copyModel models.CopyModel
+	userModel models.UserModel
+	policy    models.LoanPolicy
+}
+
+func NewController(loanModel models.LoanModel, copyModel models.CopyModel, userModel models.UserModel, policy models.LoanPolicy) *Controller {
+	return &Controller{
+		loanModel,
+		copyModel,
+		userModel,
+		policy,
+	}
+}
+
+// loanFilters query string parameters filtering GET /loans and
+// GET /users/:id/loans
+var loanFilters = []common.QueryFilter{
+	{Param: "status", Field: "status", Op: models.FilterEqual},
+	{Param: "book_id", Field: "book_id", Op: models.FilterEqual},
+	{Param: "copy_id", Field: "copy_id", Op: models.FilterEqual},
+	{Param: "user_id", Field: "user_id", Op: models.FilterEqual},
+	{Param: "due_from", Field: "due_at", Op: models.FilterFrom, Time: true},
+	{Param: "due_before", Field: "due_at", Op: models.FilterBefore, Time: true},
+	{Param: "loaned_from", Field: "loaned_at", Op: models.FilterFrom, Time: true},
+	{Param: "loaned_before", Field: "loaned_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllLoanController(c echo.Context) error {
+	query, err := common.ParseQuery(c, loanFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	loans, page, err := controller.loanModel.GetAllLoan(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, loans)
+}
+
+func (controller *Controller) GetUserLoansController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	query, err := common.ParseQuery(c, loanFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// an unknown user is a 404, not an empty list
+	user, err := controller.userModel.Get(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+	query.Filters = append(query.Filters, models.Filter{Field: "user_id", Op: models.FilterEqual, Value: user.ID})
+
+	loans, page, err := controller.loanModel.GetAllLoan(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, loans)
+}
+
+func (controller *Controller) GetLoanController(c echo.Context) error {
+	loan, err := controller.loan(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, loan.Version)
+	return c.JSON(http.StatusOK, NewGetLoanResponse(loan, controller.policy, time.Now()))
+}
+
+func (controller *Controller) PostLoanController(c echo.Context) error {
+	// bind request value
+	var loanRequest PostLoanRequest
+
+	if err := c.Bind(&loanRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	loanRequest.Normalize()
+	if err := c.Validate(&loanRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	copyID, err := controller.copy(loanRequest.CopyID, loanRequest.Barcode)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	userID, err := controller.borrower(loanRequest.UserID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// the due date follows from the policy, not from the request
+	loan, err := controller.loanModel.CheckoutLoan(models.Loan{
+		CopyID: copyID,
+		UserID: userID,
+	}, controller.policy)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, loan.Version)
+	return c.JSON(http.StatusOK, NewGetLoanResponse(loan, controller.policy, time.Now()))
+}
+
+func (controller *Controller) RenewLoanController(c echo.Context) error {
+	current, err := controller.loan(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	now := time.Now()
+	loan, err := controller.loanModel.RenewLoan(int(current.ID), version, controller.policy, now)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, loan.Version)
+	return c.JSON(http.StatusOK, NewGetLoanResponse(loan, controller.policy, now))
+}
+
+func (controller *Controller) ReturnLoanController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	now := time.Now()
+	loan, err := controller.loanModel.ReturnLoan(id, version, controller.policy, now)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, loan.Version)
+	return c.JSON(http.StatusOK, NewGetLoanResponse(loan, controller.policy, now))
+}
+
+// loan the loan named by the path, staff see every loan and borrowers
+// their own only
+func (controller *Controller) loan(c echo.Context) (models.Loan, error) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return models.Loan{}, common.NewBadRequestProblem("id must be an integer")
+	}
+
+	loan, err := controller.loanModel.GetLoan(id)
+	if err != nil {
+		return loan, err
+	}
+
+	switch middlewares.ExtractTokenRole(c) {
+	case models.RoleAdmin, models.RoleLibrarian:
+		return loan, nil
+	}
+	if int(loan.UserID) != middlewares.ExtractTokenUserId(c) {
+		return loan, common.NewForbiddenProblem("the loan belongs to another user")
+	}
+	return loan, nil
+}
+
+// copy the id of the requested copy, by id or else by barcode
+func (controller *Controller) copy(copyID uint, barcode string) (uint, error) {
+	if copyID == 0 && barcode == "" {
+		return 0, common.NewValidationProblem([]common.FieldError{{
+			Field:   "copy_id",
+			Rule:    "required",
+			Message: "copy_id or barcode is required",
+		}})
+	}
+
+	if copyID != 0 {
+		copy, err := controller.copyModel.GetCopy(int(copyID))
+		if errors.Is(err, models.ErrNotFound) {
+			return 0, common.NewValidationProblem([]common.FieldError{{
+				Field:   "copy_id",
+				Rule:    "exists",
+				Message: fmt.Sprintf("copy %v does not exist", copyID),
+			}})
+		}
+		if err != nil {
+			return 0, err
+		}
+		return copy.ID, nil
+	}
+
+	copies, _, err := controller.copyModel.GetAllCopy(models.Query{
+		Limit:   1,
+		Filters: []models.Filter{{Field: "barcode", Op: models.FilterEqual, Value: barcode}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(copies) == 0 {
+		return 0, common.NewValidationProblem([]common.FieldError{{
+			Field:   "barcode",
+			Rule:    "exists",
+			Message: fmt.Sprintf("no copy carries barcode %v", barcode),
+		}})
+	}
+	return copies[0].ID, nil
+}
+
+// borrower the id of the requested borrower
+func (controller *Controller) borrower(userID uint) (uint, error) {
+	user, err := controller.userModel.Get(int(userID))
+	if errors.Is(err, models.ErrNotFound) {
+		return 0, common.NewValidationProblem([]common.FieldError{{
+			Field:   "user_id",
+			Rule:    "exists",
+			Message: fmt.Sprintf("user %v does not exist", userID),
+		}})
+	}
+	if err != nil {
+		return 0, err
+	}
+	return user.ID, nil
+}
@@ -0,0 +1,341 @@
+package loan
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"project-api/api/common"
+	"project-api/api/validation"
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	"github.com/dgrijalva/jwt-go"
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+var storage *util.Storage
+
+// policy short enough to run into every limit
+var policy = models.LoanPolicy{Period: 14 * 24 * time.Hour, MaxRenewals: 1, MaxActive: 2}
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.User{}, &models.Book{}, &models.Copy{}, &models.Loan{})
+		db.AutoMigrate(&models.User{}, &models.Book{}, &models.Copy{}, &models.Loan{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "users")
+		models.DropMongoCollection(db, "books")
+		models.DropMongoCollection(db, "copies")
+		models.DropMongoCollection(db, "loans")
+	}
+}
+
+func TestLoanController(t *testing.T) {
+	// create controller
+	loanController := NewController(storage.LoanModel, storage.CopyModel, storage.UserModel, policy)
+
+	request := func(method, path string, id uint, body map[string]interface{}, userID uint, role string) (*httptest.ResponseRecorder, GetLoanResponse) {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(common.HeaderIfMatch, "*")
+		context := e.NewContext(req, res)
+		context.SetPath(path)
+		if id != 0 {
+			context.SetParamNames("id")
+			context.SetParamValues(fmt.Sprint(id))
+		}
+		context.Set("user", &jwt.Token{
+			Valid:  true,
+			Claims: jwt.MapClaims{"userId": float64(userID), "role": role},
+		})
+
+		switch path {
+		case "/loans":
+			loanController.PostLoanController(context)
+		case "/loans/:id":
+			loanController.GetLoanController(context)
+		case "/loans/:id/renew":
+			loanController.RenewLoanController(context)
+		case "/loans/:id/return":
+			loanController.ReturnLoanController(context)
+		}
+
+		var response GetLoanResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	reader, _ := storage.UserModel.Insert(models.User{Name: "Reader", Email: "reader@alterra.id", Password: "password123"})
+	other, _ := storage.UserModel.Insert(models.User{Name: "Other", Email: "other@alterra.id", Password: "password123"})
+	book, _ := storage.BookModel.InsertBook(models.Book{Title: "Northanger Abbey"})
+
+	barcodes := []string{"LN-1", "LN-2", "LN-3"}
+	copies := make([]models.Copy, 0, len(barcodes))
+	for i := range barcodes {
+		copy, err := storage.CopyModel.InsertCopy(models.Copy{BookID: book.ID, Barcode: &barcodes[i], Status: models.CopyAvailable}, policy)
+		assert.NoError(t, err)
+		copies = append(copies, copy)
+	}
+
+	var loan GetLoanResponse
+
+	t.Run("POST /loans", func(t *testing.T) {
+		var res *httptest.ResponseRecorder
+		res, loan = request(http.MethodPost, "/loans", 0, map[string]interface{}{"barcode": "ln-1", "user_id": reader.ID}, 1, models.RoleLibrarian)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"1"`, res.Header().Get(common.HeaderETag))
+		assert.Equal(t, copies[0].ID, loan.CopyID)
+		assert.Equal(t, book.ID, loan.BookID)
+		assert.Equal(t, models.LoanActive, loan.Status)
+		assert.Equal(t, loan.LoanedAt.Add(policy.Period).Unix(), loan.DueAt.Unix())
+		assert.Equal(t, uint(1), loan.RenewalsLeft)
+
+		copy, _ := storage.CopyModel.GetCopy(int(copies[0].ID))
+		assert.Equal(t, models.CopyOnLoan, copy.Status)
+
+		_, err := storage.CopyModel.DeleteCopy(int(copy.ID), 0)
+		assert.Equal(t, models.ErrInUse, err)
+	})
+
+	t.Run("POST /loans of a copy already on loan", func(t *testing.T) {
+		var problem common.Problem
+		res, _ := request(http.MethodPost, "/loans", 0, map[string]interface{}{"copy_id": copies[0].ID, "user_id": other.ID}, 1, models.RoleLibrarian)
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 409, res.Code)
+		assert.Equal(t, "copy_unavailable", problem.Code)
+	})
+
+	t.Run("POST /loans invalid", func(t *testing.T) {
+		res, _ := request(http.MethodPost, "/loans", 0, map[string]interface{}{"user_id": reader.ID}, 1, models.RoleLibrarian)
+		assert.Equal(t, 422, res.Code)
+
+		res, _ = request(http.MethodPost, "/loans", 0, map[string]interface{}{"barcode": "LN-404", "user_id": reader.ID}, 1, models.RoleLibrarian)
+		assert.Equal(t, 422, res.Code)
+
+		res, _ = request(http.MethodPost, "/loans", 0, map[string]interface{}{"copy_id": copies[1].ID, "user_id": 9999}, 1, models.RoleLibrarian)
+		assert.Equal(t, 422, res.Code)
+	})
+
+	t.Run("POST /loans over the loan limit", func(t *testing.T) {
+		res, _ := request(http.MethodPost, "/loans", 0, map[string]interface{}{"copy_id": copies[1].ID, "user_id": reader.ID}, 1, models.RoleLibrarian)
+		assert.Equal(t, 200, res.Code)
+
+		var problem common.Problem
+		res, _ = request(http.MethodPost, "/loans", 0, map[string]interface{}{"copy_id": copies[2].ID, "user_id": reader.ID}, 1, models.RoleLibrarian)
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 409, res.Code)
+		assert.Equal(t, "loan_limit", problem.Code)
+
+		// the refused checkout left the copy on the shelf
+		copy, _ := storage.CopyModel.GetCopy(int(copies[2].ID))
+		assert.Equal(t, models.CopyAvailable, copy.Status)
+	})
+
+	t.Run("GET /loans/:id of another user", func(t *testing.T) {
+		res, _ := request(http.MethodGet, "/loans/:id", loan.ID, nil, other.ID, models.RoleMember)
+		assert.Equal(t, 403, res.Code)
+
+		res, response := request(http.MethodGet, "/loans/:id", loan.ID, nil, reader.ID, models.RoleMember)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, loan.ID, response.ID)
+	})
+
+	t.Run("POST /loans/:id/renew up to the limit", func(t *testing.T) {
+		res, renewed := request(http.MethodPost, "/loans/:id/renew", loan.ID, nil, reader.ID, models.RoleMember)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+		assert.Equal(t, uint(1), renewed.Renewals)
+		assert.Equal(t, uint(0), renewed.RenewalsLeft)
+		assert.False(t, renewed.DueAt.Before(loan.DueAt))
+
+		var problem common.Problem
+		res, _ = request(http.MethodPost, "/loans/:id/renew", loan.ID, nil, reader.ID, models.RoleMember)
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 409, res.Code)
+		assert.Equal(t, "renewal_limit", problem.Code)
+	})
+
+	t.Run("POST /loans/:id/return", func(t *testing.T) {
+		res, returned := request(http.MethodPost, "/loans/:id/return", loan.ID, nil, 1, models.RoleLibrarian)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, models.LoanReturned, returned.Status)
+		assert.NotNil(t, returned.ReturnedAt)
+		assert.False(t, returned.Overdue)
+
+		copy, _ := storage.CopyModel.GetCopy(int(copies[0].ID))
+		assert.Equal(t, models.CopyAvailable, copy.Status)
+
+		var problem common.Problem
+		res, _ = request(http.MethodPost, "/loans/:id/return", loan.ID, nil, 1, models.RoleLibrarian)
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 409, res.Code)
+		assert.Equal(t, "loan_returned", problem.Code)
+	})
+
+	t.Run("GET /users/:id/loans", func(t *testing.T) {
+		e := echo.New()
+		req := httptest.NewRequest(http.MethodGet, "/?status=active", nil)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath("/users/:id/loans")
+		context.SetParamNames("id")
+		context.SetParamValues(fmt.Sprint(reader.ID))
+
+		loanController.GetUserLoansController(context)
+
+		var loans []models.Loan
+		json.Unmarshal(res.Body.Bytes(), &loans)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "1", res.Header().Get(common.HeaderTotalCount))
+		assert.Equal(t, copies[1].ID, loans[0].CopyID)
+	})
+}
+
+func TestConcurrentCheckout(t *testing.T) {
+	book, _ := storage.BookModel.InsertBook(models.Book{Title: "Lady Susan"})
+	copy, _ := storage.CopyModel.InsertCopy(models.Copy{BookID: book.ID, Status: models.CopyAvailable}, policy)
+
+	borrowers := make([]models.User, 0, 4)
+	for i := 0; i < cap(borrowers); i++ {
+		user, err := storage.UserModel.Insert(models.User{Name: "Racer", Email: fmt.Sprintf("racer%v@alterra.id", i), Password: "password123"})
+		assert.NoError(t, err)
+		borrowers = append(borrowers, user)
+	}
+
+	t.Run("only one checkout of a copy succeeds", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make(chan error, len(borrowers))
+		for _, borrower := range borrowers {
+			wg.Add(1)
+			go func(userID uint) {
+				defer wg.Done()
+				_, err := storage.LoanModel.CheckoutLoan(models.Loan{CopyID: copy.ID, UserID: userID}, policy)
+				errs <- err
+			}(borrower.ID)
+		}
+		wg.Wait()
+		close(errs)
+
+		succeeded := 0
+		for err := range errs {
+			if err == nil {
+				succeeded++
+			} else {
+				assert.ErrorIs(t, err, models.ErrCopyUnavailable)
+			}
+		}
+		assert.Equal(t, 1, succeeded)
+
+		loans, _, err := storage.LoanModel.GetAllLoan(models.Query{
+			Filters: []models.Filter{{Field: "copy_id", Op: models.FilterEqual, Value: copy.ID}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(loans))
+	})
+
+	t.Run("one borrower checking out at once stays within the limit", func(t *testing.T) {
+		borrower, err := storage.UserModel.Insert(models.User{Name: "Greedy", Email: "greedy@alterra.id", Password: "password123"})
+		assert.NoError(t, err)
+
+		copies := make([]models.Copy, 0, policy.MaxActive+3)
+		for i := 0; i < cap(copies); i++ {
+			copy, err := storage.CopyModel.InsertCopy(models.Copy{BookID: book.ID, Status: models.CopyAvailable}, policy)
+			assert.NoError(t, err)
+			copies = append(copies, copy)
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, len(copies))
+		for _, copy := range copies {
+			wg.Add(1)
+			go func(copyID uint) {
+				defer wg.Done()
+				_, err := storage.LoanModel.CheckoutLoan(models.Loan{CopyID: copyID, UserID: borrower.ID}, policy)
+				errs <- err
+			}(copy.ID)
+		}
+		wg.Wait()
+		close(errs)
+
+		succeeded := 0
+		for err := range errs {
+			if err == nil {
+				succeeded++
+			} else {
+				assert.ErrorIs(t, err, models.ErrLoanLimit)
+			}
+		}
+		assert.Equal(t, policy.MaxActive, succeeded)
+
+		loans, _, err := storage.LoanModel.GetAllLoan(models.Query{
+			Filters: []models.Filter{{Field: "user_id", Op: models.FilterEqual, Value: borrower.ID}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, policy.MaxActive, len(loans))
+	})
+}
+
+func TestFailedCheckout(t *testing.T) {
+	if storage.DB == nil {
+		t.Skip("the loan insert is made to fail through a gorm callback")
+	}
+
+	reader, _ := storage.UserModel.Insert(models.User{Name: "Unlucky", Email: "unlucky@alterra.id", Password: "password123"})
+	book, _ := storage.BookModel.InsertBook(models.Book{Title: "Sanditon"})
+	copy, _ := storage.CopyModel.InsertCopy(models.Copy{BookID: book.ID, Status: models.CopyAvailable}, policy)
+
+	// the loan insert fails after the copy was claimed
+	storage.DB.Callback().Create().Before("gorm:create").Register("test:fail_loan", func(db *gorm.DB) {
+		if db.Statement.Table == "loans" {
+			db.AddError(errors.New("disk full"))
+		}
+	})
+	defer storage.DB.Callback().Create().Remove("test:fail_loan")
+
+	t.Run("a failed checkout leaves the copy on the shelf", func(t *testing.T) {
+		_, err := storage.LoanModel.CheckoutLoan(models.Loan{CopyID: copy.ID, UserID: reader.ID}, policy)
+		assert.EqualError(t, err, "disk full")
+
+		copy, _ := storage.CopyModel.GetCopy(int(copy.ID))
+		assert.Equal(t, models.CopyAvailable, copy.Status)
+		assert.Equal(t, uint(1), copy.Version)
+
+		loans, _, err := storage.LoanModel.GetAllLoan(models.Query{
+			Filters: []models.Filter{{Field: "user_id", Op: models.FilterEqual, Value: reader.ID}},
+		})
+		assert.NoError(t, err)
+		assert.Empty(t, loans)
+	})
+}
@@ -0,0 +1,15 @@
+package loan
+
+import "project-api/models"
+
+// PostLoanRequest the copy is named by its id or by the barcode scanned
+// at the desk
+type PostLoanRequest struct {
+	CopyID  uint   `json:"copy_id" form:"copy_id"`
+	Barcode string `json:"barcode" form:"barcode" validate:"max=32"`
+	UserID  uint   `json:"user_id" form:"user_id" validate:"required"`
+}
+
+func (r *PostLoanRequest) Normalize() {
+	r.Barcode = models.NormalizeBarcode(r.Barcode)
+}
@@ -0,0 +1,45 @@
+package loan
+
+import (
+	"time"
+
+	"project-api/models"
+)
+
+type GetLoanResponse struct {
+	ID         uint       `json:"id"`
+	CopyID     uint       `json:"copy_id"`
+	BookID     uint       `json:"book_id"`
+	UserID     uint       `json:"user_id"`
+	Status     string     `json:"status"`
+	LoanedAt   time.Time  `json:"loaned_at"`
+	DueAt      time.Time  `json:"due_at"`
+	ReturnedAt *time.Time `json:"returned_at,omitempty"`
+	Overdue    bool       `json:"overdue"`
+
+	Renewals     uint `json:"renewals"`
+	RenewalsLeft uint `json:"renewals_left"`
+
+	Fined int64 `json:"fined"`
+}
+
+func NewGetLoanResponse(loan models.Loan, policy models.LoanPolicy, now time.Time) GetLoanResponse {
+	response := GetLoanResponse{
+		ID:         loan.ID,
+		CopyID:     loan.CopyID,
+		BookID:     loan.BookID,
+		UserID:     loan.UserID,
+		Status:     loan.Status,
+		LoanedAt:   loan.LoanedAt,
+		DueAt:      loan.DueAt,
+		ReturnedAt: loan.ReturnedAt,
+		Overdue:    loan.Overdue(now),
+		Renewals:   loan.Renewals,
+		Fined:      loan.Fined,
+	}
+
+	if loan.Status == models.LoanActive && loan.Renewals < policy.MaxRenewals {
+		response.RenewalsLeft = policy.MaxRenewals - loan.Renewals
+	}
+	return response
+}
@@ -0,0 +1,137 @@
+package publisher
+
+import (
+	"net/http"
+	"strconv"
+
+	"project-api/api/common"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	publisherModel models.PublisherModel
+}
+
+func NewController(publisherModel models.PublisherModel) *Controller {
+	return &Controller{
+		publisherModel,
+	}
+}
+
+// publisherFilters query string parameters filtering GET /publishers
+var publisherFilters = []common.QueryFilter{
+	{Param: "name", Field: "name", Op: models.FilterContains},
+	{Param: "created_from", Field: "created_at", Op: models.FilterFrom, Time: true},
+	{Param: "created_before", Field: "created_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllPublisherController(c echo.Context) error {
+	query, err := common.ParseQuery(c, publisherFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	publisher, page, err := controller.publisherModel.GetAllPublisher(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, publisher)
+}
+
+func (controller *Controller) GetPublisherController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	publisher, err := controller.publisherModel.GetPublisher(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, publisher.Version)
+	return c.JSON(http.StatusOK, NewGetPublisherResponse(publisher))
+}
+
+func (controller *Controller) PostPublisherController(c echo.Context) error {
+	// bind request value
+	var publisherRequest PostPublisherRequest
+
+	if err := c.Bind(&publisherRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	publisherRequest.Normalize()
+	if err := c.Validate(&publisherRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	publisher, err := controller.publisherModel.InsertPublisher(models.Publisher{Name: publisherRequest.Name})
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// the id is needed to link the publisher to books
+	common.SetETag(c, publisher.Version)
+	return c.JSON(http.StatusOK, NewGetPublisherResponse(publisher))
+}
+
+func (controller *Controller) EditPublisherController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// bind request value
+	var publisherRequest EditPublisherRequest
+	if err := c.Bind(&publisherRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	publisherRequest.Normalize()
+	if err := c.Validate(&publisherRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	publisher, err := controller.publisherModel.EditPublisher(models.Publisher{Name: publisherRequest.Name, Version: version}, id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, publisher.Version)
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+func (controller *Controller) DeletePublisherController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if _, err := controller.publisherModel.DeletePublisher(id, version); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
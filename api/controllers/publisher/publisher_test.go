@@ -0,0 +1,160 @@
+package publisher
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"project-api/api/common"
+	"project-api/api/validation"
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.Book{}, &models.Publisher{})
+		db.AutoMigrate(&models.Book{}, &models.Publisher{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "books")
+		models.DropMongoCollection(db, "publishers")
+	}
+}
+
+func TestPublisherController(t *testing.T) {
+	// create controller
+	publisherController := NewController(storage.PublisherModel)
+
+	request := func(method string, id uint, body map[string]string, header http.Header) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		for key := range header {
+			req.Header.Set(key, header.Get(key))
+		}
+		context := e.NewContext(req, res)
+
+		if id == 0 {
+			context.SetPath("/publishers")
+		} else {
+			context.SetPath("/publishers/:id")
+			context.SetParamNames("id")
+			context.SetParamValues(fmt.Sprint(id))
+		}
+
+		switch {
+		case method == http.MethodPost:
+			publisherController.PostPublisherController(context)
+		case method == http.MethodPut:
+			publisherController.EditPublisherController(context)
+		case method == http.MethodDelete:
+			publisherController.DeletePublisherController(context)
+		case id == 0:
+			publisherController.GetAllPublisherController(context)
+		default:
+			publisherController.GetPublisherController(context)
+		}
+		return res
+	}
+
+	var created GetPublisherResponse
+
+	t.Run("POST /publishers", func(t *testing.T) {
+		res := request(http.MethodPost, 0, map[string]string{"name": " Penguin   Books "}, nil)
+		json.Unmarshal(res.Body.Bytes(), &created)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"1"`, res.Header().Get(common.HeaderETag))
+		assert.NotZero(t, created.ID)
+		assert.Equal(t, "Penguin Books", created.Name)
+	})
+
+	t.Run("POST /publishers another spelling", func(t *testing.T) {
+		res := request(http.MethodPost, 0, map[string]string{"name": "PENGUIN BOOKS"}, nil)
+		assert.Equal(t, 409, res.Code)
+
+		res = request(http.MethodPost, 0, map[string]string{"name": "  "}, nil)
+		assert.Equal(t, 422, res.Code)
+	})
+
+	t.Run("GET /publishers", func(t *testing.T) {
+		res := request(http.MethodGet, 0, nil, nil)
+
+		var publishers []models.Publisher
+		json.Unmarshal(res.Body.Bytes(), &publishers)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "1", res.Header().Get(common.HeaderTotalCount))
+		assert.Equal(t, "Penguin Books", publishers[0].Name)
+	})
+
+	t.Run("PUT /publishers/:id", func(t *testing.T) {
+		res := request(http.MethodPut, created.ID, map[string]string{"name": "Penguin Random House"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+
+		res = request(http.MethodPut, created.ID, map[string]string{"name": "Puffin"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 412, res.Code)
+
+		var response GetPublisherResponse
+		res = request(http.MethodGet, created.ID, nil, nil)
+		json.Unmarshal(res.Body.Bytes(), &response)
+		assert.Equal(t, "Penguin Random House", response.Name)
+	})
+
+	t.Run("DELETE /publishers/:id still in use", func(t *testing.T) {
+		book, err := storage.BookModel.InsertBook(models.Book{
+			Title:       "Harry Potter",
+			PublisherID: &created.ID,
+		})
+		assert.NoError(t, err)
+
+		res := request(http.MethodDelete, created.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 409, res.Code)
+
+		// once the book is gone, nothing uses it
+		_, err = storage.BookModel.DeleteBook(int(book.ID), 0)
+		assert.NoError(t, err)
+
+		res = request(http.MethodDelete, created.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+
+		res = request(http.MethodGet, created.ID, nil, nil)
+		assert.Equal(t, 404, res.Code)
+	})
+
+	t.Run("POST /publishers a deleted name", func(t *testing.T) {
+		res := request(http.MethodPost, 0, map[string]string{"name": "Penguin Random House"}, nil)
+		assert.Equal(t, 200, res.Code)
+	})
+}
@@ -0,0 +1,19 @@
+package publisher
+
+import "strings"
+
+type PostPublisherRequest struct {
+	Name string `json:"name" form:"name" validate:"required,max=255"`
+}
+
+type EditPublisherRequest struct {
+	Name string `json:"name" form:"name" validate:"required,max=255"`
+}
+
+func (r *PostPublisherRequest) Normalize() {
+	r.Name = strings.Join(strings.Fields(r.Name), " ")
+}
+
+func (r *EditPublisherRequest) Normalize() {
+	r.Name = strings.Join(strings.Fields(r.Name), " ")
+}
@@ -0,0 +1,15 @@
+package publisher
+
+import "project-api/models"
+
+type GetPublisherResponse struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+}
+
+func NewGetPublisherResponse(publisher models.Publisher) GetPublisherResponse {
+	return GetPublisherResponse{
+		ID:   publisher.ID,
+		Name: publisher.Name,
+	}
+}
@@ -0,0 +1,19 @@
+package series
+
+import "strings"
+
+type PostSeriesRequest struct {
+	Title string `json:"title" form:"title" validate:"required,max=255"`
+}
+
+type EditSeriesRequest struct {
+	Title string `json:"title" form:"title" validate:"required,max=255"`
+}
+
+func (r *PostSeriesRequest) Normalize() {
+	r.Title = strings.Join(strings.Fields(r.Title), " ")
+}
+
+func (r *EditSeriesRequest) Normalize() {
+	r.Title = strings.Join(strings.Fields(r.Title), " ")
+}
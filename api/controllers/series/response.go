@@ -0,0 +1,32 @@
+package series
+
+import "project-api/models"
+
+type GetSeriesResponse struct {
+	ID    uint                 `json:"id"`
+	Title string               `json:"title"`
+	Works []SeriesWorkResponse `json:"works"`
+}
+
+type SeriesWorkResponse struct {
+	ID             uint   `json:"id"`
+	Title          string `json:"title"`
+	SeriesPosition uint   `json:"series_position"`
+}
+
+func NewGetSeriesResponse(series models.Series, works []models.Work) GetSeriesResponse {
+	response := GetSeriesResponse{
+		ID:    series.ID,
+		Title: series.Title,
+		Works: make([]SeriesWorkResponse, 0, len(works)),
+	}
+
+	for _, work := range works {
+		response.Works = append(response.Works, SeriesWorkResponse{
+			ID:             work.ID,
+			Title:          work.Title,
+			SeriesPosition: work.SeriesPosition,
+		})
+	}
+	return response
+}
@@ -0,0 +1,167 @@
+package series
+
+import (
+	"net/http"
+	"strconv"
+
+	"project-api/api/common"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	seriesModel models.SeriesModel
+	workModel   models.WorkModel
+}
+
+func NewController(seriesModel models.SeriesModel, workModel models.WorkModel) *Controller {
+	return &Controller{
+		seriesModel,
+		workModel,
+	}
+}
+
+// seriesFilters query string parameters filtering GET /series
+var seriesFilters = []common.QueryFilter{
+	{Param: "title", Field: "title", Op: models.FilterContains},
+	{Param: "created_from", Field: "created_at", Op: models.FilterFrom, Time: true},
+	{Param: "created_before", Field: "created_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllSeriesController(c echo.Context) error {
+	query, err := common.ParseQuery(c, seriesFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	series, page, err := controller.seriesModel.GetAllSeries(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, series)
+}
+
+func (controller *Controller) GetSeriesController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	series, err := controller.seriesModel.GetSeries(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	works, err := controller.works(series.ID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, series.Version)
+	return c.JSON(http.StatusOK, NewGetSeriesResponse(series, works))
+}
+
+func (controller *Controller) PostSeriesController(c echo.Context) error {
+	// bind request value
+	var seriesRequest PostSeriesRequest
+
+	if err := c.Bind(&seriesRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	seriesRequest.Normalize()
+	if err := c.Validate(&seriesRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	series, err := controller.seriesModel.InsertSeries(models.Series{Title: seriesRequest.Title})
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// the id is needed to file works under the series
+	common.SetETag(c, series.Version)
+	return c.JSON(http.StatusOK, NewGetSeriesResponse(series, nil))
+}
+
+func (controller *Controller) EditSeriesController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// bind request value
+	var seriesRequest EditSeriesRequest
+	if err := c.Bind(&seriesRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	seriesRequest.Normalize()
+	if err := c.Validate(&seriesRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	series, err := controller.seriesModel.EditSeries(models.Series{Title: seriesRequest.Title, Version: version}, id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, series.Version)
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+func (controller *Controller) DeleteSeriesController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if _, err := controller.seriesModel.DeleteSeries(id, version); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+// works every work of the series in reading order
+func (controller *Controller) works(seriesID uint) ([]models.Work, error) {
+	query := models.Query{
+		Limit:   models.MaxLimit,
+		Filters: []models.Filter{{Field: "series_id", Op: models.FilterEqual, Value: seriesID}},
+		Sort:    []models.Sort{{Field: "series_position"}},
+	}
+
+	var works []models.Work
+	for {
+		work, page, err := controller.workModel.GetAllWork(query)
+		if err != nil {
+			return nil, err
+		}
+		works = append(works, work...)
+
+		if page.NextCursor == "" {
+			return works, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,155 @@
+package series
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"project-api/api/common"
+	"project-api/api/validation"
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.Work{}, &models.Series{})
+		db.AutoMigrate(&models.Work{}, &models.Series{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "works")
+		models.DropMongoCollection(db, "series")
+	}
+}
+
+func TestSeriesController(t *testing.T) {
+	// create controller
+	seriesController := NewController(storage.SeriesModel, storage.WorkModel)
+
+	request := func(method string, id uint, body map[string]interface{}, header http.Header) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		for key := range header {
+			req.Header.Set(key, header.Get(key))
+		}
+		context := e.NewContext(req, res)
+
+		if id == 0 {
+			context.SetPath("/series")
+		} else {
+			context.SetPath("/series/:id")
+			context.SetParamNames("id")
+			context.SetParamValues(fmt.Sprint(id))
+		}
+
+		switch {
+		case method == http.MethodPost:
+			seriesController.PostSeriesController(context)
+		case method == http.MethodPut:
+			seriesController.EditSeriesController(context)
+		case method == http.MethodDelete:
+			seriesController.DeleteSeriesController(context)
+		case id == 0:
+			seriesController.GetAllSeriesController(context)
+		default:
+			seriesController.GetSeriesController(context)
+		}
+		return res
+	}
+
+	var created GetSeriesResponse
+
+	t.Run("POST /series", func(t *testing.T) {
+		res := request(http.MethodPost, 0, map[string]interface{}{"title": "The  Earthsea Cycle"}, nil)
+		json.Unmarshal(res.Body.Bytes(), &created)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"1"`, res.Header().Get(common.HeaderETag))
+		assert.NotZero(t, created.ID)
+		assert.Equal(t, "The Earthsea Cycle", created.Title)
+
+		res = request(http.MethodPost, 0, map[string]interface{}{"title": ""}, nil)
+		assert.Equal(t, 422, res.Code)
+	})
+
+	t.Run("GET /series/:id lists the works in reading order", func(t *testing.T) {
+		for _, work := range []models.Work{
+			{Title: "The Tombs of Atuan", SeriesID: &created.ID, SeriesPosition: 2},
+			{Title: "A Wizard of Earthsea", SeriesID: &created.ID, SeriesPosition: 1},
+			{Title: "The Farthest Shore", SeriesID: &created.ID, SeriesPosition: 3},
+			{Title: "The Left Hand of Darkness"},
+		} {
+			_, err := storage.WorkModel.InsertWork(work)
+			assert.NoError(t, err)
+		}
+
+		var response GetSeriesResponse
+		res := request(http.MethodGet, created.ID, nil, nil)
+		json.Unmarshal(res.Body.Bytes(), &response)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 3, len(response.Works))
+		assert.Equal(t, "A Wizard of Earthsea", response.Works[0].Title)
+		assert.Equal(t, "The Tombs of Atuan", response.Works[1].Title)
+		assert.Equal(t, uint(3), response.Works[2].SeriesPosition)
+	})
+
+	t.Run("PUT /series/:id", func(t *testing.T) {
+		res := request(http.MethodPut, created.ID, map[string]interface{}{"title": "Earthsea"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+
+		res = request(http.MethodPut, created.ID, map[string]interface{}{"title": "Earthsea"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 412, res.Code)
+	})
+
+	t.Run("DELETE /series/:id still in use", func(t *testing.T) {
+		res := request(http.MethodDelete, created.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 409, res.Code)
+
+		works, _, err := storage.WorkModel.GetAllWork(models.Query{
+			Filters: []models.Filter{{Field: "series_id", Op: models.FilterEqual, Value: created.ID}},
+		})
+		assert.NoError(t, err)
+		for _, work := range works {
+			_, err := storage.WorkModel.DeleteWork(int(work.ID), 0)
+			assert.NoError(t, err)
+		}
+
+		res = request(http.MethodDelete, created.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+
+		res = request(http.MethodGet, created.ID, nil, nil)
+		assert.Equal(t, 404, res.Code)
+	})
+}
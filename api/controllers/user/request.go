@@ -1,20 +1,25 @@
 package user
 
 type PostUserRequest struct {
-	Name     string `json:"name" form:"name"`
-	Email    string `json:"email" form:"email"`
+	Name  string `json:"name" form:"name" validate:"required,max=255"`
+	Email string `json:"email" form:"email" validate:"required,email,max=255"`
 	//Gender   string `json:"gender" form:"gender"`
-	Password string `json:"password" form:"password"`
+	Password string `json:"password" form:"password" validate:"required,min=6,max=72"`
 }
 
 type EditUserRequest struct {
-	Name     string `json:"name" form:"name"`
-	Email    string `json:"email" form:"email"`
+	Name  string `json:"name" form:"name" validate:"required,max=255"`
+	Email string `json:"email" form:"email" validate:"required,email,max=255"`
 	//Gender   string `json:"gender" form:"gender"`
-	Password string `json:"password" form:"password"`
+	Password string `json:"password" form:"password" validate:"omitempty,min=6,max=72"`
+	Role     string `json:"role" form:"role" validate:"omitempty,oneof=admin librarian member"`
 }
 
 type LoginUserRequest struct {
-	Email    string `json:"email" form:"email"`
-	Password string `json:"password" form:"password"`
+	Email    string `json:"email" form:"email" validate:"required,email"`
+	Password string `json:"password" form:"password" validate:"required"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" form:"refresh_token" validate:"required"`
 }
@@ -1,31 +1,53 @@
 package user
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"project-api/api/common"
+	"project-api/api/middlewares"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
 	"project-api/models"
 
 	echo "github.com/labstack/echo/v4"
 )
 
 type Controller struct {
-	userModel models.UserModel
+	userModel    models.UserModel
+	sessionModel models.SessionModel
 }
 
-func NewController(userModel models.UserModel) *Controller {
+func NewController(userModel models.UserModel, sessionModel models.SessionModel) *Controller {
 	return &Controller{
 		userModel,
+		sessionModel,
 	}
 }
 
+// userFilters query string parameters filtering GET /users
+var userFilters = []common.QueryFilter{
+	{Param: "name", Field: "name", Op: models.FilterContains},
+	{Param: "email", Field: "email", Op: models.FilterEqual},
+	{Param: "role", Field: "role", Op: models.FilterEqual},
+	{Param: "created_from", Field: "created_at", Op: models.FilterFrom, Time: true},
+	{Param: "created_before", Field: "created_at", Op: models.FilterBefore, Time: true},
+}
+
 func (controller *Controller) GetAllUserController(c echo.Context) error {
-	user, err := controller.userModel.GetAll()
+	query, err := common.ParseQuery(c, userFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	user, page, err := controller.userModel.GetAll(query)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, err)
 	}
 
+	common.SetPageHeaders(c, page)
 	return c.JSON(http.StatusOK, user)
 }
 
@@ -33,19 +55,21 @@ func (controller *Controller) GetUserController(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
 	}
 
 	user, err := controller.userModel.Get(id)
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, err)
 	}
 
 	response := GetUserResponse{
 		Name:  user.Name,
 		Email: user.Email,
+		Role:  user.Role,
 	}
 
+	common.SetETag(c, user.Version)
 	return c.JSON(http.StatusOK, response)
 }
 
@@ -54,7 +78,11 @@ func (controller *Controller) PostUserController(c echo.Context) error {
 	var userRequest PostUserRequest
 
 	if err := c.Bind(&userRequest); err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	if err := c.Validate(&userRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
 	}
 
 	user := models.User{
@@ -66,7 +94,7 @@ func (controller *Controller) PostUserController(c echo.Context) error {
 	_, err := controller.userModel.Insert(user)
 
 	if err != nil {
-		return c.JSON(http.StatusInternalServerError, common.NewInternalServerErrorResponse())
+		return common.ErrorResponse(c, err)
 	}
 
 	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
@@ -76,25 +104,51 @@ func (controller *Controller) EditUserController(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
 	// bind request value
 	var userRequest EditUserRequest
 	if err := c.Bind(&userRequest); err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	if err := c.Validate(&userRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	// only admins hand out roles
+	if userRequest.Role != "" && middlewares.ExtractTokenRole(c) != models.RoleAdmin {
+		return common.ErrorResponse(c, common.NewForbiddenProblem("only admins can change roles"))
 	}
 
 	user := models.User{
 		Name:     userRequest.Name,
 		Email:    userRequest.Email,
 		Password: userRequest.Password,
+		Role:     userRequest.Role,
+		Version:  version,
+	}
+
+	user, err = controller.userModel.Edit(user, id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
-	if _, err := controller.userModel.Edit(user, id); err != nil {
-		return c.JSON(http.StatusNotFound, common.NewBadRequestResponse())
+	// a new password logs every device out, including this one
+	if userRequest.Password != "" {
+		if _, err := controller.sessionModel.RevokeUserSessions(id); err != nil {
+			return common.ErrorResponse(c, err)
+		}
 	}
 
+	common.SetETag(c, user.Version)
+
 	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
 }
 
@@ -102,11 +156,21 @@ func (controller *Controller) DeleteUserController(c echo.Context) error {
 	id, err := strconv.Atoi(c.Param("id"))
 
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
-	if _, err := controller.userModel.Delete(id); err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+	if _, err := controller.userModel.Delete(id, version); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// tokens already issued to the user stop working
+	if _, err := controller.sessionModel.RevokeUserSessions(id); err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
 	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
@@ -116,16 +180,133 @@ func (controller *Controller) LoginUserController(c echo.Context) error {
 	var userRequest LoginUserRequest
 
 	if err := c.Bind(&userRequest); err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	if err := c.Validate(&userRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
 	}
 
 	user, err := controller.userModel.Login(userRequest.Email, userRequest.Password)
 
 	if err != nil {
-		return c.JSON(http.StatusBadRequest, common.NewBadRequestResponse())
+		return common.ErrorResponse(c, err)
+	}
+
+	refreshToken, err := middlewares.CreateRefreshToken()
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	session := models.Session{
+		UserID:    user.ID,
+		UserAgent: c.Request().UserAgent(),
+		IP:        c.RealIP(),
+	}
+
+	session, err = controller.sessionModel.CreateSession(session, refreshToken)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return controller.tokenResponse(c, user, session, refreshToken)
+}
+
+func (controller *Controller) RefreshTokenController(c echo.Context) error {
+	var tokenRequest RefreshTokenRequest
+
+	if err := c.Bind(&tokenRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	if err := c.Validate(&tokenRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	refreshToken, err := middlewares.CreateRefreshToken()
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	session, err := controller.sessionModel.RotateRefreshToken(tokenRequest.RefreshToken, refreshToken)
+
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// the role is read again so a changed role applies on the next refresh
+	user, err := controller.userModel.Get(int(session.UserID))
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return controller.tokenResponse(c, user, session, refreshToken)
+}
+
+func (controller *Controller) LogoutUserController(c echo.Context) error {
+	userId := middlewares.ExtractTokenUserId(c)
+	sessionId := middlewares.ExtractTokenSessionId(c)
+
+	err := controller.sessionModel.RevokeSession(userId, sessionId)
+	if err != nil && !errors.Is(err, models.ErrSessionNotFound) {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+func (controller *Controller) GetSessionsController(c echo.Context) error {
+	userId := middlewares.ExtractTokenUserId(c)
+	sessionId := middlewares.ExtractTokenSessionId(c)
+
+	sessions, err := controller.sessionModel.GetSessions(userId)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	response := make([]GetSessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, GetSessionResponse{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastUsedAt: session.LastUsedAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    int(session.ID) == sessionId,
+		})
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+func (controller *Controller) DeleteSessionController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	err = controller.sessionModel.RevokeSession(middlewares.ExtractTokenUserId(c), id)
+
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+// tokenResponse issue an access token for the session next to its
+// refresh token
+func (controller *Controller) tokenResponse(c echo.Context, user models.User, session models.Session, refreshToken string) error {
+	token, err := middlewares.CreateToken(int(user.ID), int(session.ID), user.Role)
+	if err != nil {
+		return common.ErrorResponse(c, err)
 	}
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"token": user.Token,
+	return c.JSON(http.StatusOK, TokenResponse{
+		Token:        token,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(middlewares.AccessTokenTTL.Seconds()),
 	})
 }
@@ -7,8 +7,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strconv"
 	"testing"
 
+	"project-api/api/common"
+	"project-api/api/middlewares"
+	"project-api/api/validation"
 	"project-api/config"
 	"project-api/models"
 	"project-api/util"
@@ -17,19 +21,41 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+var storage *util.Storage
+
 func TestMain(m *testing.M) {
 	setup()
 	os.Exit(m.Run())
 }
 
 func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// the tokens need a signing secret, there is no default one
+	if os.Getenv("AUTH_JWT_SECRET") == "" {
+		os.Setenv("AUTH_JWT_SECRET", "user-test-secret")
+	}
+
 	// create database connection
 	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
+	storage = util.DatabaseConnection(config)
 
 	// cleaning data before testing
-	db.Migrator().DropTable(&models.User{})
-	db.AutoMigrate(&models.User{})
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.User{}, &models.Session{}, &models.RefreshToken{})
+		db.AutoMigrate(&models.User{}, &models.Session{}, &models.RefreshToken{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "users")
+		models.DropMongoCollection(db, "sessions")
+		models.DropMongoCollection(db, "refresh_tokens")
+	}
+
+	// reject access tokens of revoked sessions like the server does
+	middlewares.SetSessionChecker(storage.SessionModel.IsSessionActive)
 
 	// preparate dummy data
 	var newUser models.User
@@ -38,7 +64,7 @@ func setup() {
 	newUser.Password = "password123"
 
 	// user dummy data with model
-	userModel := models.NewUserModel(db)
+	userModel := storage.UserModel
 	_, err := userModel.Insert(newUser)
 	if err != nil {
 		fmt.Println(err)
@@ -46,14 +72,12 @@ func setup() {
 }
 
 func TestGetAllUserController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	userModel := models.NewUserModel(db)
-	userController := NewController(userModel)
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	res := httptest.NewRecorder()
 	context := e.NewContext(req, res)
@@ -81,14 +105,12 @@ func TestGetAllUserController(t *testing.T) {
 }
 
 func TestGetUserController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	userModel := models.NewUserModel(db)
-	userController := NewController(userModel)
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	res := httptest.NewRecorder()
 	context := e.NewContext(req, res)
@@ -117,21 +139,19 @@ func TestGetUserController(t *testing.T) {
 }
 
 func TestPostUserController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	userModel := models.NewUserModel(db)
-	userController := NewController(userModel)
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
 
 	// input controller
 	reqBody, _ := json.Marshal(map[string]string{
 		"name":     "Name Test",
-		"email":    "test@alterra.id",
+		"email":    "test2@alterra.id",
 		"password": "test123",
 	})
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
 	res := httptest.NewRecorder()
 	req.Header.Set("Content-Type", "application/json")
@@ -156,12 +176,78 @@ func TestPostUserController(t *testing.T) {
 	})
 }
 
+func TestPostUserValidation(t *testing.T) {
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	// input controller
+	reqBody, _ := json.Marshal(map[string]string{
+		"name":     "",
+		"email":    "alterra",
+		"password": "short",
+	})
+
+	// setting controller
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+	res := httptest.NewRecorder()
+	req.Header.Set("Content-Type", "application/json")
+	context := e.NewContext(req, res)
+	context.SetPath("/users")
+
+	userController.PostUserController(context)
+
+	var response common.Problem
+	json.Unmarshal(res.Body.Bytes(), &response)
+
+	// testing stuff
+	t.Run("POST /users invalid", func(t *testing.T) {
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, 3, len(response.Errors))
+		assert.Equal(t, "name", response.Errors[0].Field)
+		assert.Equal(t, "required", response.Errors[0].Rule)
+		assert.Equal(t, "email", response.Errors[1].Field)
+		assert.Equal(t, "password", response.Errors[2].Field)
+		assert.Equal(t, "min", response.Errors[2].Rule)
+	})
+}
+
+func TestPostUserDuplicateController(t *testing.T) {
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	// input controller, the email belongs to the dummy user
+	reqBody, _ := json.Marshal(map[string]string{
+		"name":     "Name Test",
+		"email":    "test@alterra.id",
+		"password": "test123",
+	})
+
+	// setting controller
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+	res := httptest.NewRecorder()
+	req.Header.Set("Content-Type", "application/json")
+	context := e.NewContext(req, res)
+	context.SetPath("/users")
+
+	userController.PostUserController(context)
+
+	var response common.Problem
+	json.Unmarshal(res.Body.Bytes(), &response)
+
+	// testing stuff
+	t.Run("POST /users duplicate email", func(t *testing.T) {
+		assert.Equal(t, 409, res.Code)
+		assert.Equal(t, "duplicate", response.Code)
+	})
+}
+
 func TestEditUserController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	userModel := models.NewUserModel(db)
-	userController := NewController(userModel)
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
 
 	// input controller
 	reqBody, _ := json.Marshal(map[string]string{
@@ -172,9 +258,11 @@ func TestEditUserController(t *testing.T) {
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(reqBody))
 	res := httptest.NewRecorder()
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(common.HeaderIfMatch, "*")
 	context := e.NewContext(req, res)
 	context.SetPath("/users/:id")
 	context.SetParamNames("id")
@@ -198,18 +286,274 @@ func TestEditUserController(t *testing.T) {
 	})
 }
 
+func TestEditUserRoleController(t *testing.T) {
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	editRole := func(role string) int {
+		reqBody, _ := json.Marshal(map[string]string{
+			"name":  "Name Test New",
+			"email": "test@alterra.id",
+			"role":  role,
+		})
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(common.HeaderIfMatch, "*")
+		context := e.NewContext(req, res)
+		context.SetPath("/users/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController.EditUserController(context)
+		return res.Code
+	}
+
+	t.Run("PUT /users/:id role without admin", func(t *testing.T) {
+		assert.Equal(t, 403, editRole(models.RoleAdmin))
+	})
+
+	t.Run("PUT /users/:id unknown role", func(t *testing.T) {
+		assert.Equal(t, 422, editRole("owner"))
+	})
+}
+
+func TestLoginUserController(t *testing.T) {
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	login := func(email, password string) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(map[string]string{
+			"email":    email,
+			"password": password,
+		})
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		context := e.NewContext(req, res)
+		context.SetPath("/users/login")
+
+		userController.LoginUserController(context)
+		return res
+	}
+
+	// build struct response
+	type Response struct {
+		Token string `json:"token"`
+	}
+
+	t.Run("POST /users/login", func(t *testing.T) {
+		res := login("test@alterra.id", "test123")
+
+		var response Response
+		json.Unmarshal(res.Body.Bytes(), &response)
+
+		assert.Equal(t, 200, res.Code)
+		assert.NotEmpty(t, response.Token)
+	})
+
+	t.Run("POST /users/login wrong password", func(t *testing.T) {
+		res := login("test@alterra.id", "wrong")
+		assert.Equal(t, 401, res.Code)
+	})
+
+	t.Run("POST /users/login unknown email", func(t *testing.T) {
+		res := login("nobody@alterra.id", "test123")
+		assert.Equal(t, 401, res.Code)
+	})
+}
+
+// loginTokens log the edited user in and return its token pair
+func loginTokens(userController *Controller) TokenResponse {
+	reqBody, _ := json.Marshal(map[string]string{
+		"email":    "test@alterra.id",
+		"password": "test123",
+	})
+
+	e := echo.New()
+	e.Validator = validation.NewValidator()
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+	res := httptest.NewRecorder()
+	req.Header.Set("Content-Type", "application/json")
+	context := e.NewContext(req, res)
+	context.SetPath("/users/login")
+
+	userController.LoginUserController(context)
+
+	var response TokenResponse
+	json.Unmarshal(res.Body.Bytes(), &response)
+	return response
+}
+
+func TestRefreshTokenController(t *testing.T) {
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	refresh := func(refreshToken string) (*httptest.ResponseRecorder, TokenResponse) {
+		reqBody, _ := json.Marshal(map[string]string{
+			"refresh_token": refreshToken,
+		})
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		context := e.NewContext(req, res)
+		context.SetPath("/users/refresh")
+
+		userController.RefreshTokenController(context)
+
+		var response TokenResponse
+		json.Unmarshal(res.Body.Bytes(), &response)
+		return res, response
+	}
+
+	login := loginTokens(userController)
+
+	res, rotated := refresh(login.RefreshToken)
+	t.Run("POST /users/refresh", func(t *testing.T) {
+		assert.Equal(t, 200, res.Code)
+		assert.NotEmpty(t, rotated.Token)
+		assert.NotEqual(t, login.RefreshToken, rotated.RefreshToken)
+	})
+
+	t.Run("POST /users/refresh reused token revokes the family", func(t *testing.T) {
+		res, _ := refresh(login.RefreshToken)
+		assert.Equal(t, 401, res.Code)
+
+		res, _ = refresh(rotated.RefreshToken)
+		assert.Equal(t, 401, res.Code)
+	})
+
+	t.Run("POST /users/refresh unknown token", func(t *testing.T) {
+		res, _ := refresh("unknown")
+		assert.Equal(t, 401, res.Code)
+	})
+}
+
+func TestSessionsController(t *testing.T) {
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	// call a handler behind the jwt middleware like the router does
+	call := func(handler echo.HandlerFunc, token, method, path string, params ...string) *httptest.ResponseRecorder {
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		res := httptest.NewRecorder()
+		context := e.NewContext(req, res)
+		context.SetPath(path)
+		if len(params) > 0 {
+			context.SetParamNames("id")
+			context.SetParamValues(params...)
+		}
+
+		middlewares.JWTMiddleware()(handler)(context)
+		return res
+	}
+
+	phone := loginTokens(userController)
+	laptop := loginTokens(userController)
+
+	var sessions []GetSessionResponse
+	res := call(userController.GetSessionsController, laptop.Token, http.MethodGet, "/users/me/sessions")
+	json.Unmarshal(res.Body.Bytes(), &sessions)
+
+	t.Run("GET /users/me/sessions", func(t *testing.T) {
+		assert.Equal(t, 200, res.Code)
+		assert.True(t, len(sessions) >= 2)
+		assert.True(t, sessions[0].Current)
+	})
+
+	t.Run("DELETE /users/me/sessions/:id", func(t *testing.T) {
+		if len(sessions) < 2 {
+			t.FailNow()
+		}
+		phoneSession := strconv.Itoa(int(sessions[1].ID))
+
+		res := call(userController.DeleteSessionController, laptop.Token, http.MethodDelete, "/users/me/sessions/:id", phoneSession)
+		assert.Equal(t, 200, res.Code)
+
+		res = call(userController.GetSessionsController, phone.Token, http.MethodGet, "/users/me/sessions")
+		assert.Equal(t, 401, res.Code)
+
+		res = call(userController.DeleteSessionController, laptop.Token, http.MethodDelete, "/users/me/sessions/:id", phoneSession)
+		assert.Equal(t, 404, res.Code)
+	})
+
+	t.Run("POST /users/logout", func(t *testing.T) {
+		res := call(userController.LogoutUserController, laptop.Token, http.MethodPost, "/users/logout")
+		assert.Equal(t, 200, res.Code)
+
+		res = call(userController.GetSessionsController, laptop.Token, http.MethodGet, "/users/me/sessions")
+		assert.Equal(t, 401, res.Code)
+	})
+}
+
+func TestPasswordChangeRevokesSessions(t *testing.T) {
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	edit := func(body map[string]string) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(common.HeaderIfMatch, "*")
+		context := e.NewContext(req, res)
+		context.SetPath("/users/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		userController.EditUserController(context)
+		return res
+	}
+
+	loginTokens(userController)
+
+	t.Run("PUT /users/:id without a password", func(t *testing.T) {
+		res := edit(map[string]string{"name": "Name Test New", "email": "test@alterra.id"})
+		assert.Equal(t, 200, res.Code)
+
+		sessions, err := storage.SessionModel.GetSessions(1)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, sessions)
+	})
+
+	t.Run("PUT /users/:id with a password", func(t *testing.T) {
+		res := edit(map[string]string{"name": "Name Test New", "email": "test@alterra.id", "password": "test123"})
+		assert.Equal(t, 200, res.Code)
+
+		sessions, err := storage.SessionModel.GetSessions(1)
+		assert.NoError(t, err)
+		assert.Empty(t, sessions)
+	})
+}
+
 func TestDeleteUserController(t *testing.T) {
-	// create database connection and create controller
-	config := config.GetConfig()
-	db := util.MysqlDatabaseConnection(config)
-	userModel := models.NewUserModel(db)
-	userController := NewController(userModel)
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	loginTokens(userController)
 
 	// setting controller
 	e := echo.New()
+	e.Validator = validation.NewValidator()
 	req := httptest.NewRequest(http.MethodPut, "/", nil)
 	res := httptest.NewRecorder()
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(common.HeaderIfMatch, "*")
 	context := e.NewContext(req, res)
 	context.SetPath("/users/:id")
 	context.SetParamNames("id")
@@ -230,5 +574,90 @@ func TestDeleteUserController(t *testing.T) {
 	t.Run("PUT /users/:id", func(t *testing.T) {
 		assert.Equal(t, 200, res.Code)
 		assert.Equal(t, "Successful Operation", response.Message)
+
+		sessions, err := storage.SessionModel.GetSessions(1)
+		assert.NoError(t, err)
+		assert.Empty(t, sessions)
+	})
+}
+
+func TestGetDeletedUserController(t *testing.T) {
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodDelete} {
+		reqBody, _ := json.Marshal(map[string]string{
+			"name":  "Name Test New",
+			"email": "test@alterra.id",
+		})
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(common.HeaderIfMatch, "*")
+		context := e.NewContext(req, res)
+		context.SetPath("/users/:id")
+		context.SetParamNames("id")
+		context.SetParamValues("1")
+
+		switch method {
+		case http.MethodGet:
+			userController.GetUserController(context)
+		case http.MethodPut:
+			userController.EditUserController(context)
+		case http.MethodDelete:
+			userController.DeleteUserController(context)
+		}
+
+		t.Run(method+" /users/:id deleted", func(t *testing.T) {
+			assert.Equal(t, 404, res.Code)
+		})
+	}
+
+	t.Run("the email of a deleted user is free", func(t *testing.T) {
+		_, err := storage.UserModel.Insert(models.User{Name: "Name Test", Email: "test@alterra.id", Password: "test123"})
+		assert.NoError(t, err)
+	})
+}
+
+func TestConditionalUserController(t *testing.T) {
+	// create controller
+	userController := NewController(storage.UserModel, storage.SessionModel)
+
+	user, err := storage.UserModel.Insert(models.User{Name: "Versioned", Email: "versioned@alterra.id", Password: "password123"})
+	assert.Nil(t, err)
+
+	edit := func(ifMatch string) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(map[string]string{
+			"name":  "Versioned Edit",
+			"email": "versioned@alterra.id",
+		})
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(http.MethodPut, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(common.HeaderIfMatch, ifMatch)
+		context := e.NewContext(req, res)
+		context.SetPath("/users/:id")
+		context.SetParamNames("id")
+		context.SetParamValues(fmt.Sprint(user.ID))
+
+		userController.EditUserController(context)
+		return res
+	}
+
+	t.Run("PUT /users/:id with the current ETag", func(t *testing.T) {
+		res := edit(common.ETag(user.Version))
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, common.ETag(user.Version+1), res.Header().Get(common.HeaderETag))
+	})
+
+	t.Run("PUT /users/:id with a stale ETag", func(t *testing.T) {
+		res := edit(common.ETag(user.Version))
+		assert.Equal(t, 412, res.Code)
 	})
 }
@@ -0,0 +1,23 @@
+package work
+
+import "strings"
+
+type PostWorkRequest struct {
+	Title          string `json:"title" form:"title" validate:"required,max=255"`
+	SeriesID       uint   `json:"series_id" form:"series_id"`
+	SeriesPosition uint   `json:"series_position" form:"series_position"`
+}
+
+type EditWorkRequest struct {
+	Title          string `json:"title" form:"title" validate:"required,max=255"`
+	SeriesID       uint   `json:"series_id" form:"series_id"`
+	SeriesPosition uint   `json:"series_position" form:"series_position"`
+}
+
+func (r *PostWorkRequest) Normalize() {
+	r.Title = strings.Join(strings.Fields(r.Title), " ")
+}
+
+func (r *EditWorkRequest) Normalize() {
+	r.Title = strings.Join(strings.Fields(r.Title), " ")
+}
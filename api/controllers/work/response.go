@@ -0,0 +1,50 @@
+package work
+
+import (
+	"project-api/isbn"
+	"project-api/models"
+)
+
+type GetWorkResponse struct {
+	ID             uint              `json:"id"`
+	Title          string            `json:"title"`
+	SeriesID       uint              `json:"series_id,omitempty"`
+	SeriesPosition uint              `json:"series_position,omitempty"`
+	Editions       []EditionResponse `json:"editions"`
+}
+
+type EditionResponse struct {
+	ID        uint   `json:"id"`
+	Title     string `json:"title"`
+	Publisher string `json:"publisher"`
+	Year      uint   `json:"year,omitempty"`
+	Language  string `json:"language,omitempty"`
+	ISBN13    string `json:"isbn13,omitempty"`
+}
+
+func NewGetWorkResponse(work models.Work, editions []models.Book) GetWorkResponse {
+	response := GetWorkResponse{
+		ID:             work.ID,
+		Title:          work.Title,
+		SeriesPosition: work.SeriesPosition,
+		Editions:       make([]EditionResponse, 0, len(editions)),
+	}
+
+	if work.SeriesID != nil {
+		response.SeriesID = *work.SeriesID
+	}
+	for _, book := range editions {
+		edition := EditionResponse{
+			ID:        book.ID,
+			Title:     book.Title,
+			Publisher: book.Publisher,
+			Year:      book.Year,
+			Language:  book.Language,
+		}
+		if book.ISBN != nil {
+			edition.ISBN13 = isbn.Hyphenate(*book.ISBN)
+		}
+		response.Editions = append(response.Editions, edition)
+	}
+	return response
+}
@@ -0,0 +1,211 @@
+package work
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"project-api/api/common"
+	// the model errors answered with their own problem
+	_ "project-api/api/problems"
+	"project-api/api/validation"
+	"project-api/models"
+
+	echo "github.com/labstack/echo/v4"
+)
+
+type Controller struct {
+	workModel   models.WorkModel
+	seriesModel models.SeriesModel
+	bookModel   models.BookModel
+}
+
+func NewController(workModel models.WorkModel, seriesModel models.SeriesModel, bookModel models.BookModel) *Controller {
+	return &Controller{
+		workModel,
+		seriesModel,
+		bookModel,
+	}
+}
+
+// workFilters query string parameters filtering GET /works
+var workFilters = []common.QueryFilter{
+	{Param: "title", Field: "title", Op: models.FilterContains},
+	{Param: "series_id", Field: "series_id", Op: models.FilterEqual},
+	{Param: "created_from", Field: "created_at", Op: models.FilterFrom, Time: true},
+	{Param: "created_before", Field: "created_at", Op: models.FilterBefore, Time: true},
+}
+
+func (controller *Controller) GetAllWorkController(c echo.Context) error {
+	query, err := common.ParseQuery(c, workFilters)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	work, page, err := controller.workModel.GetAllWork(query)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetPageHeaders(c, page)
+	return c.JSON(http.StatusOK, work)
+}
+
+func (controller *Controller) GetWorkController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	work, err := controller.workModel.GetWork(id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	editions, err := controller.editions(work.ID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, work.Version)
+	return c.JSON(http.StatusOK, NewGetWorkResponse(work, editions))
+}
+
+func (controller *Controller) PostWorkController(c echo.Context) error {
+	// bind request value
+	var workRequest PostWorkRequest
+
+	if err := c.Bind(&workRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	workRequest.Normalize()
+	if err := c.Validate(&workRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	seriesID, err := controller.series(workRequest.SeriesID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	work, err := controller.workModel.InsertWork(models.Work{
+		Title:          workRequest.Title,
+		SeriesID:       seriesID,
+		SeriesPosition: workRequest.SeriesPosition,
+	})
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// the id is needed to file editions under the work
+	common.SetETag(c, work.Version)
+	return c.JSON(http.StatusOK, NewGetWorkResponse(work, nil))
+}
+
+func (controller *Controller) EditWorkController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	// bind request value
+	var workRequest EditWorkRequest
+	if err := c.Bind(&workRequest); err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("malformed request body"))
+	}
+
+	workRequest.Normalize()
+	if err := c.Validate(&workRequest); err != nil {
+		return common.ErrorResponse(c, common.NewValidationProblem(validation.FieldErrors(err)))
+	}
+
+	seriesID, err := controller.series(workRequest.SeriesID)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	work, err := controller.workModel.EditWork(models.Work{
+		Title:          workRequest.Title,
+		SeriesID:       seriesID,
+		SeriesPosition: workRequest.SeriesPosition,
+		Version:        version,
+	}, id)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	common.SetETag(c, work.Version)
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+func (controller *Controller) DeleteWorkController(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+
+	if err != nil {
+		return common.ErrorResponse(c, common.NewBadRequestProblem("id must be an integer"))
+	}
+
+	version, err := common.IfMatch(c)
+	if err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	if _, err := controller.workModel.DeleteWork(id, version); err != nil {
+		return common.ErrorResponse(c, err)
+	}
+
+	return c.JSON(http.StatusOK, common.NewSuccessOperationResponse())
+}
+
+// series the id of the requested series, nil for a work outside any
+// series
+func (controller *Controller) series(seriesID uint) (*uint, error) {
+	if seriesID == 0 {
+		return nil, nil
+	}
+
+	series, err := controller.seriesModel.GetSeries(int(seriesID))
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, common.NewValidationProblem([]common.FieldError{{
+			Field:   "series_id",
+			Rule:    "exists",
+			Message: fmt.Sprintf("series %v does not exist", seriesID),
+		}})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &series.ID, nil
+}
+
+// editions every edition of the work, oldest first
+func (controller *Controller) editions(workID uint) ([]models.Book, error) {
+	query := models.Query{
+		Limit:   models.MaxLimit,
+		Filters: []models.Filter{{Field: "work_id", Op: models.FilterEqual, Value: workID}},
+	}
+
+	var editions []models.Book
+	for {
+		books, page, err := controller.bookModel.GetAllBook(query)
+		if err != nil {
+			return nil, err
+		}
+		editions = append(editions, books...)
+
+		if page.NextCursor == "" {
+			return editions, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
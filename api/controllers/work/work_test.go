@@ -0,0 +1,190 @@
+package work
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"project-api/api/common"
+	"project-api/api/validation"
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.Book{}, &models.BookAuthor{}, &models.Work{}, &models.Series{})
+		db.AutoMigrate(&models.Book{}, &models.BookAuthor{}, &models.Work{}, &models.Series{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "books")
+		models.DropMongoCollection(db, "works")
+		models.DropMongoCollection(db, "series")
+	}
+}
+
+func TestWorkController(t *testing.T) {
+	// create controller
+	workController := NewController(storage.WorkModel, storage.SeriesModel, storage.BookModel)
+
+	request := func(method string, id uint, body map[string]interface{}, header http.Header) *httptest.ResponseRecorder {
+		reqBody, _ := json.Marshal(body)
+
+		e := echo.New()
+		e.Validator = validation.NewValidator()
+		req := httptest.NewRequest(method, "/", bytes.NewBuffer(reqBody))
+		res := httptest.NewRecorder()
+		req.Header.Set("Content-Type", "application/json")
+		for key := range header {
+			req.Header.Set(key, header.Get(key))
+		}
+		context := e.NewContext(req, res)
+
+		if id == 0 {
+			context.SetPath("/works")
+		} else {
+			context.SetPath("/works/:id")
+			context.SetParamNames("id")
+			context.SetParamValues(fmt.Sprint(id))
+		}
+
+		switch {
+		case method == http.MethodPost:
+			workController.PostWorkController(context)
+		case method == http.MethodPut:
+			workController.EditWorkController(context)
+		case method == http.MethodDelete:
+			workController.DeleteWorkController(context)
+		case id == 0:
+			workController.GetAllWorkController(context)
+		default:
+			workController.GetWorkController(context)
+		}
+		return res
+	}
+
+	series, err := storage.SeriesModel.InsertSeries(models.Series{Title: "Discworld"})
+	assert.NoError(t, err)
+
+	var created GetWorkResponse
+
+	t.Run("POST /works", func(t *testing.T) {
+		res := request(http.MethodPost, 0, map[string]interface{}{"title": " Mort ", "series_id": series.ID, "series_position": 4}, nil)
+		json.Unmarshal(res.Body.Bytes(), &created)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"1"`, res.Header().Get(common.HeaderETag))
+		assert.NotZero(t, created.ID)
+		assert.Equal(t, "Mort", created.Title)
+		assert.Equal(t, series.ID, created.SeriesID)
+		assert.Equal(t, []EditionResponse{}, created.Editions)
+
+		var problem common.Problem
+		res = request(http.MethodPost, 0, map[string]interface{}{"title": "Sourcery", "series_id": 9999}, nil)
+		json.Unmarshal(res.Body.Bytes(), &problem)
+		assert.Equal(t, 422, res.Code)
+		assert.Equal(t, "series_id", problem.Errors[0].Field)
+
+		res = request(http.MethodPost, 0, map[string]interface{}{"title": " "}, nil)
+		assert.Equal(t, 422, res.Code)
+	})
+
+	t.Run("GET /works/:id lists the editions", func(t *testing.T) {
+		isbn13 := "9780552131063"
+		for _, book := range []models.Book{
+			{Title: "Mort", Publisher: "Corgi", Year: 1988, Language: "en", ISBN: &isbn13, WorkID: &created.ID},
+			{Title: "Gevatter Tod", Publisher: "Goldmann", Year: 1991, Language: "de", WorkID: &created.ID},
+			{Title: "Pyramids", Publisher: "Corgi", Year: 1989, Language: "en"},
+		} {
+			_, err := storage.BookModel.InsertBook(book)
+			assert.NoError(t, err)
+		}
+
+		var response GetWorkResponse
+		res := request(http.MethodGet, created.ID, nil, nil)
+		json.Unmarshal(res.Body.Bytes(), &response)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 2, len(response.Editions))
+		assert.Equal(t, "978-0-552-13106-3", response.Editions[0].ISBN13)
+		assert.Equal(t, "Gevatter Tod", response.Editions[1].Title)
+		assert.Equal(t, "de", response.Editions[1].Language)
+
+		res = request(http.MethodGet, 9999, nil, nil)
+		assert.Equal(t, 404, res.Code)
+	})
+
+	t.Run("GET /works", func(t *testing.T) {
+		var works []models.Work
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/?series_id=%v", series.ID), nil)
+		res := httptest.NewRecorder()
+		workController.GetAllWorkController(echo.New().NewContext(req, res))
+		json.Unmarshal(res.Body.Bytes(), &works)
+
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, "1", res.Header().Get(common.HeaderTotalCount))
+		assert.Equal(t, "Mort", works[0].Title)
+	})
+
+	t.Run("PUT /works/:id", func(t *testing.T) {
+		res := request(http.MethodPut, created.ID, map[string]interface{}{"title": "Mort", "series_position": 4}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, `"2"`, res.Header().Get(common.HeaderETag))
+
+		res = request(http.MethodPut, created.ID, map[string]interface{}{"title": "Death"}, http.Header{"If-Match": {`"1"`}})
+		assert.Equal(t, 412, res.Code)
+
+		// no series_id takes the work out of its series
+		var response GetWorkResponse
+		res = request(http.MethodGet, created.ID, nil, nil)
+		json.Unmarshal(res.Body.Bytes(), &response)
+		assert.Zero(t, response.SeriesID)
+	})
+
+	t.Run("DELETE /works/:id still in use", func(t *testing.T) {
+		res := request(http.MethodDelete, created.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 409, res.Code)
+
+		// once its editions are gone, nothing uses it
+		books, _, err := storage.BookModel.GetAllBook(models.Query{
+			Filters: []models.Filter{{Field: "work_id", Op: models.FilterEqual, Value: created.ID}},
+		})
+		assert.NoError(t, err)
+		for _, book := range books {
+			_, err := storage.BookModel.DeleteBook(int(book.ID), 0)
+			assert.NoError(t, err)
+		}
+
+		res = request(http.MethodDelete, created.ID, nil, http.Header{"If-Match": {"*"}})
+		assert.Equal(t, 200, res.Code)
+
+		res = request(http.MethodGet, created.ID, nil, nil)
+		assert.Equal(t, 404, res.Code)
+	})
+}
@@ -0,0 +1,217 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+
+	"project-api/config"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+//SigningKey one key of a KeySet, identified by the token kid header
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+	// Private used to sign, nil for keys only accepted for verification
+	Private interface{}
+	// Public used to verify, same as Private for HMAC secrets
+	Public interface{}
+}
+
+//KeySet keys used to sign and verify tokens, the current key signs new
+//tokens and every key verifies tokens carrying its kid
+type KeySet struct {
+	current string
+	keys    map[string]*SigningKey
+}
+
+//JSONWebKey public part of a signing key, RFC 7517
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+//JSONWebKeySet published key set, RFC 7517
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+//NewKeySet build the key set described by Auth.Jwt
+func NewKeySet(config *config.AppConfig) (*KeySet, error) {
+	jwtConfig := config.Auth.Jwt
+	keySet := &KeySet{
+		current: jwtConfig.KeyId,
+		keys:    map[string]*SigningKey{},
+	}
+
+	current, err := loadSigningKey(jwtConfig.KeyId, jwtConfig.Algorithm, jwtConfig.Secret, jwtConfig.PrivateKey, true)
+	if err != nil {
+		return nil, err
+	}
+	keySet.keys[current.ID] = current
+
+	for _, key := range jwtConfig.Keys {
+		if _, dup := keySet.keys[key.KeyId]; dup {
+			return nil, fmt.Errorf("jwt key %q is configured twice", key.KeyId)
+		}
+
+		retired, err := loadSigningKey(key.KeyId, key.Algorithm, key.Secret, key.PublicKey, false)
+		if err != nil {
+			return nil, err
+		}
+		keySet.keys[retired.ID] = retired
+	}
+
+	return keySet, nil
+}
+
+func loadSigningKey(id, algorithm, secret, pemFile string, private bool) (*SigningKey, error) {
+	method := jwt.GetSigningMethod(algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("jwt key %q: unsupported algorithm %q", id, algorithm)
+	}
+
+	key := &SigningKey{ID: id, Method: method}
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if secret == "" {
+			return nil, fmt.Errorf("jwt key %q: %v needs a secret", id, algorithm)
+		}
+		key.Public = []byte(secret)
+		if private {
+			key.Private = key.Public
+		}
+		return key, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodECDSA:
+	default:
+		return nil, fmt.Errorf("jwt key %q: unsupported algorithm %q", id, algorithm)
+	}
+
+	if pemFile == "" {
+		return nil, fmt.Errorf("jwt key %q: %v needs a PEM file", id, algorithm)
+	}
+	data, err := ioutil.ReadFile(pemFile)
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %q: %w", id, err)
+	}
+
+	switch method.(type) {
+	case *jwt.SigningMethodRSA:
+		if private {
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("jwt key %q: %w", id, err)
+			}
+			key.Private, key.Public = privateKey, &privateKey.PublicKey
+		} else {
+			key.Public, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		}
+	case *jwt.SigningMethodECDSA:
+		if private {
+			privateKey, err := jwt.ParseECPrivateKeyFromPEM(data)
+			if err != nil {
+				return nil, fmt.Errorf("jwt key %q: %w", id, err)
+			}
+			key.Private, key.Public = privateKey, &privateKey.PublicKey
+		} else {
+			key.Public, err = jwt.ParseECPublicKeyFromPEM(data)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("jwt key %q: %w", id, err)
+	}
+
+	return key, nil
+}
+
+//Sign sign the claims with the current key and set its kid header
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key := k.keys[k.current]
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+//Keyfunc select the verification key by kid, tokens without a kid are
+//checked against the current key
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		kid = k.current
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown jwt key %q", kid)
+	}
+
+	// never let the token pick the algorithm, a HS256 token must not be
+	// verified with an RSA public key used as HMAC secret
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method %v for key %q", token.Method.Alg(), kid)
+	}
+
+	return key.Public, nil
+}
+
+//JWKS public keys of the set, HMAC secrets are never published
+func (k *KeySet) JWKS() JSONWebKeySet {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, id := range ids {
+		key := k.keys[id]
+		jwk := JSONWebKey{Kid: key.ID, Alg: key.Method.Alg(), Use: "sig"}
+
+		switch public := key.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = encodeBase64URL(public.N.Bytes())
+			jwk.E = encodeBase64URL(big.NewInt(int64(public.E)).Bytes())
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			jwk.Kty = "EC"
+			jwk.Crv = public.Curve.Params().Name
+			jwk.X = encodeBase64URL(padLeft(public.X.Bytes(), size))
+			jwk.Y = encodeBase64URL(padLeft(public.Y.Bytes(), size))
+		default:
+			continue
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func encodeBase64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func padLeft(data []byte, size int) []byte {
+	if len(data) >= size {
+		return data
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(data):], data)
+	return padded
+}
@@ -1,27 +1,144 @@
 package middlewares
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"sync"
 	"time"
 
+	"project-api/api/common"
+	"project-api/config"
+
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
 )
 
-func CreateToken(userId int) (string, error) {
+//AccessTokenTTL lifetime of access tokens, refresh tokens renew them
+var AccessTokenTTL = 15 * time.Minute
+
+var keyLock = &sync.Mutex{}
+var keySet *KeySet
+
+// sessionActive report whether a session may still use its access
+// tokens, nil accepts every session
+var sessionActive func(sessionId int) (bool, error)
+
+//SetKeySet replace the keys used by CreateToken and JWTMiddleware
+func SetKeySet(keys *KeySet) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	keySet = keys
+}
+
+// keys the configured key set, loaded from the app config on first use
+func keys() *KeySet {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	if keySet == nil {
+		keys, err := NewKeySet(config.GetConfig())
+		if err != nil {
+			panic(err)
+		}
+		keySet = keys
+	}
+
+	return keySet
+}
+
+//SetSessionChecker make JWTMiddleware reject access tokens of revoked sessions
+func SetSessionChecker(checker func(sessionId int) (bool, error)) {
+	keyLock.Lock()
+	defer keyLock.Unlock()
+
+	sessionActive = checker
+}
+
+func CreateToken(userId, sessionId int, role string) (string, error) {
 	claims := jwt.MapClaims{}
 	claims["authorized"] = true
 	claims["userId"] = int(userId)
-	claims["exp"] = time.Now().Add(time.Hour * 1).Unix()
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte("RAHASIA"))
+	claims["sid"] = int(sessionId)
+	claims["role"] = role
+	claims["exp"] = time.Now().Add(AccessTokenTTL).Unix()
+	return keys().Sign(claims)
+}
+
+//CreateRefreshToken random opaque refresh token
+func CreateRefreshToken() (string, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
 }
 
 func ExtractTokenUserId(c echo.Context) int {
-	user := c.Get("user").(*jwt.Token)
-	if user.Valid {
+	return extractClaim(c, "userId")
+}
+
+func ExtractTokenSessionId(c echo.Context) int {
+	return extractClaim(c, "sid")
+}
+
+func ExtractTokenRole(c echo.Context) string {
+	user, ok := c.Get("user").(*jwt.Token)
+	if ok && user.Valid {
+		claims := user.Claims.(jwt.MapClaims)
+		role, _ := claims["role"].(string)
+		return role
+	}
+	return ""
+}
+
+func extractClaim(c echo.Context, name string) int {
+	user, ok := c.Get("user").(*jwt.Token)
+	if ok && user.Valid {
 		claims := user.Claims.(jwt.MapClaims)
-		userId := int(claims["userId"].(float64))
-		return userId
+		value, _ := claims[name].(float64)
+		return int(value)
 	}
 	return 0
 }
+
+//JWTMiddleware reject requests without a valid bearer token or whose
+//session was revoked, and store the parsed token under "user"
+func JWTMiddleware() echo.MiddlewareFunc {
+	jwtMiddleware := middleware.JWTWithConfig(middleware.JWTConfig{
+		ContextKey: "user",
+		KeyFunc: func(token *jwt.Token) (interface{}, error) {
+			return keys().Keyfunc(token)
+		},
+		ErrorHandlerWithContext: func(err error, c echo.Context) error {
+			return common.ErrorResponse(c, common.NewUnauthorizedProblem("invalid_token", "missing, malformed or expired token"))
+		},
+	})
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return jwtMiddleware(func(c echo.Context) error {
+			keyLock.Lock()
+			checker := sessionActive
+			keyLock.Unlock()
+
+			if checker != nil {
+				active, err := checker(ExtractTokenSessionId(c))
+				if err != nil {
+					return common.ErrorResponse(c, err)
+				}
+				if !active {
+					return common.ErrorResponse(c, common.NewUnauthorizedProblem("session_revoked", "the session of this token was logged out or revoked"))
+				}
+			}
+
+			return next(c)
+		})
+	}
+}
+
+//JWKSController publish the public verification keys
+func JWKSController(c echo.Context) error {
+	return c.JSON(http.StatusOK, keys().JWKS())
+}
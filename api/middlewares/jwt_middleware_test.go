@@ -0,0 +1,193 @@
+package middlewares
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"project-api/config"
+
+	"github.com/dgrijalva/jwt-go"
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func writePEM(t *testing.T, blockType string, der []byte) string {
+	file := filepath.Join(t.TempDir(), "key.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// callProtected run a request carrying the token through JWTMiddleware
+func callProtected(token string) (*httptest.ResponseRecorder, int) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res := httptest.NewRecorder()
+	context := e.NewContext(req, res)
+
+	var userId int
+	handler := JWTMiddleware()(func(c echo.Context) error {
+		userId = ExtractTokenUserId(c)
+		return c.NoContent(http.StatusOK)
+	})
+	handler(context)
+
+	return res, userId
+}
+
+func TestJWTMiddleware(t *testing.T) {
+	defer SetKeySet(nil)
+
+	t.Run("HS256 secret", func(t *testing.T) {
+		var appConfig config.AppConfig
+		appConfig.Auth.Jwt.Algorithm = "HS256"
+		appConfig.Auth.Jwt.KeyId = "hs"
+		appConfig.Auth.Jwt.Secret = "secret"
+
+		keys, err := NewKeySet(&appConfig)
+		assert.NoError(t, err)
+		SetKeySet(keys)
+
+		token, err := CreateToken(7, 7, "member")
+		assert.NoError(t, err)
+
+		res, userId := callProtected(token)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 7, userId)
+
+		// HMAC secrets are never published
+		assert.Empty(t, keys.JWKS().Keys)
+	})
+
+	t.Run("missing token", func(t *testing.T) {
+		res, _ := callProtected("")
+		assert.Equal(t, 401, res.Code)
+	})
+
+	t.Run("RS256 key pair with rotation", func(t *testing.T) {
+		oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+		newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+
+		var oldConfig config.AppConfig
+		oldConfig.Auth.Jwt.Algorithm = "RS256"
+		oldConfig.Auth.Jwt.KeyId = "2021"
+		oldConfig.Auth.Jwt.PrivateKey = writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(oldKey))
+
+		oldKeys, err := NewKeySet(&oldConfig)
+		assert.NoError(t, err)
+		SetKeySet(oldKeys)
+		oldToken, _ := CreateToken(1, 1, "member")
+
+		publicDER, _ := x509.MarshalPKIXPublicKey(&oldKey.PublicKey)
+
+		var newConfig config.AppConfig
+		newConfig.Auth.Jwt.Algorithm = "RS256"
+		newConfig.Auth.Jwt.KeyId = "2022"
+		newConfig.Auth.Jwt.PrivateKey = writePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(newKey))
+		newConfig.Auth.Jwt.Keys = append(newConfig.Auth.Jwt.Keys, config.JwtKey{KeyId: "2021", Algorithm: "RS256", PublicKey: writePEM(t, "PUBLIC KEY", publicDER)})
+
+		newKeys, err := NewKeySet(&newConfig)
+		assert.NoError(t, err)
+		SetKeySet(newKeys)
+		newToken, _ := CreateToken(2, 2, "member")
+
+		parsed, _ := jwt.Parse(newToken, newKeys.Keyfunc)
+		assert.Equal(t, "2022", parsed.Header["kid"])
+
+		res, userId := callProtected(oldToken)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 1, userId)
+
+		res, userId = callProtected(newToken)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 2, userId)
+
+		jwks := newKeys.JWKS()
+		assert.Equal(t, 2, len(jwks.Keys))
+		assert.Equal(t, "2021", jwks.Keys[0].Kid)
+		assert.Equal(t, "RSA", jwks.Keys[0].Kty)
+		assert.Equal(t, "AQAB", jwks.Keys[0].E)
+	})
+
+	t.Run("ES256 key pair", func(t *testing.T) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		der, _ := x509.MarshalECPrivateKey(key)
+
+		var appConfig config.AppConfig
+		appConfig.Auth.Jwt.Algorithm = "ES256"
+		appConfig.Auth.Jwt.KeyId = "ec"
+		appConfig.Auth.Jwt.PrivateKey = writePEM(t, "EC PRIVATE KEY", der)
+
+		keys, err := NewKeySet(&appConfig)
+		assert.NoError(t, err)
+		SetKeySet(keys)
+
+		token, _ := CreateToken(3, 3, "member")
+		res, userId := callProtected(token)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 3, userId)
+
+		jwks := keys.JWKS()
+		assert.Equal(t, "EC", jwks.Keys[0].Kty)
+		assert.Equal(t, "P-256", jwks.Keys[0].Crv)
+		assert.Equal(t, 43, len(jwks.Keys[0].X))
+	})
+
+	t.Run("algorithm mismatch", func(t *testing.T) {
+		// a HS256 token must not verify against an ES256 key id
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"userId": 4})
+		token.Header["kid"] = "ec"
+		signed, _ := token.SignedString([]byte("secret"))
+
+		res, _ := callProtected(signed)
+		assert.Equal(t, 401, res.Code)
+	})
+
+	t.Run("revoked session", func(t *testing.T) {
+		defer SetSessionChecker(nil)
+		SetSessionChecker(func(sessionId int) (bool, error) {
+			return sessionId != 5, nil
+		})
+
+		token, _ := CreateToken(5, 5, "member")
+		res, _ := callProtected(token)
+		assert.Equal(t, 401, res.Code)
+
+		token, _ = CreateToken(5, 6, "member")
+		res, userId := callProtected(token)
+		assert.Equal(t, 200, res.Code)
+		assert.Equal(t, 5, userId)
+	})
+
+	t.Run("HS256 without a secret", func(t *testing.T) {
+		var appConfig config.AppConfig
+		appConfig.Auth.Jwt.Algorithm = "HS256"
+		appConfig.Auth.Jwt.KeyId = "default"
+
+		_, err := NewKeySet(&appConfig)
+		assert.Error(t, err)
+	})
+
+	t.Run("missing PEM file", func(t *testing.T) {
+		var appConfig config.AppConfig
+		appConfig.Auth.Jwt.Algorithm = "RS256"
+		appConfig.Auth.Jwt.KeyId = "rs"
+
+		_, err := NewKeySet(&appConfig)
+		assert.Error(t, err)
+	})
+}
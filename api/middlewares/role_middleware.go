@@ -0,0 +1,39 @@
+package middlewares
+
+import (
+	"strconv"
+
+	"project-api/api/common"
+
+	"github.com/labstack/echo/v4"
+)
+
+//RequireRole allow the request only when the token role is one of roles,
+//must run after JWTMiddleware
+func RequireRole(roles ...string) echo.MiddlewareFunc {
+	return RequireRoleOrSelf("", roles...)
+}
+
+//RequireRoleOrSelf like RequireRole, but also allow the request when the
+//path parameter param is the id of the token user
+func RequireRoleOrSelf(param string, roles ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role := ExtractTokenRole(c)
+			for _, allowed := range roles {
+				if role == allowed {
+					return next(c)
+				}
+			}
+
+			if param != "" {
+				id, err := strconv.Atoi(c.Param(param))
+				if err == nil && id != 0 && id == ExtractTokenUserId(c) {
+					return next(c)
+				}
+			}
+
+			return common.ErrorResponse(c, common.NewForbiddenProblem("your role is not allowed to do this"))
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	echo "github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// callWithRole run the policy with a token for user 3 holding role
+func callWithRole(policy echo.MiddlewareFunc, role, id string) int {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	res := httptest.NewRecorder()
+	context := e.NewContext(req, res)
+	context.SetPath("/users/:id")
+	context.SetParamNames("id")
+	context.SetParamValues(id)
+	context.Set("user", &jwt.Token{
+		Valid:  true,
+		Claims: jwt.MapClaims{"userId": float64(3), "role": role},
+	})
+
+	policy(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})(context)
+
+	return res.Code
+}
+
+func TestRequireRole(t *testing.T) {
+	staff := RequireRole("admin", "librarian")
+
+	t.Run("allowed role", func(t *testing.T) {
+		assert.Equal(t, 200, callWithRole(staff, "librarian", "1"))
+	})
+
+	t.Run("other role", func(t *testing.T) {
+		assert.Equal(t, 403, callWithRole(staff, "member", "3"))
+	})
+}
+
+func TestRequireRoleOrSelf(t *testing.T) {
+	adminOrSelf := RequireRoleOrSelf("id", "admin")
+
+	t.Run("admin on another user", func(t *testing.T) {
+		assert.Equal(t, 200, callWithRole(adminOrSelf, "admin", "1"))
+	})
+
+	t.Run("member on itself", func(t *testing.T) {
+		assert.Equal(t, 200, callWithRole(adminOrSelf, "member", "3"))
+	})
+
+	t.Run("member on another user", func(t *testing.T) {
+		assert.Equal(t, 403, callWithRole(adminOrSelf, "member", "1"))
+	})
+
+	t.Run("librarian on another user", func(t *testing.T) {
+		assert.Equal(t, 403, callWithRole(adminOrSelf, "librarian", "1"))
+	})
+}
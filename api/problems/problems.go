@@ -0,0 +1,38 @@
+package problems
+
+import (
+	"net/http"
+
+	"project-api/api/common"
+	"project-api/metadata"
+	"project-api/models"
+)
+
+// the controllers import this package for its side effect: every error
+// of the models and the metadata providers answered with its own problem
+func init() {
+	common.RegisterProblem(models.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials")
+	common.RegisterProblem(models.ErrInvalidRefreshToken, http.StatusUnauthorized, "invalid_refresh_token")
+	common.RegisterProblem(models.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused")
+	common.RegisterProblem(models.ErrNotFound, http.StatusNotFound, "not_found")
+	common.RegisterProblem(models.ErrDuplicate, http.StatusConflict, "duplicate")
+	common.RegisterProblem(models.ErrVersionMismatch, http.StatusPreconditionFailed, "precondition_failed")
+	common.RegisterProblem(models.ErrConflict, http.StatusConflict, "conflict")
+	common.RegisterProblem(models.ErrInUse, http.StatusConflict, "in_use")
+	common.RegisterProblem(models.ErrCategoryCycle, http.StatusUnprocessableEntity, "category_cycle")
+	common.RegisterProblem(models.ErrCopyUnavailable, http.StatusConflict, "copy_unavailable")
+	common.RegisterProblem(models.ErrCopyStatus, http.StatusConflict, "copy_status")
+	common.RegisterProblem(models.ErrLoanLimit, http.StatusConflict, "loan_limit")
+	common.RegisterProblem(models.ErrRenewalLimit, http.StatusConflict, "renewal_limit")
+	common.RegisterProblem(models.ErrLoanReturned, http.StatusConflict, "loan_returned")
+	common.RegisterProblem(models.ErrCopyAvailable, http.StatusConflict, "copy_available")
+	common.RegisterProblem(models.ErrHoldLimit, http.StatusConflict, "hold_limit")
+	common.RegisterProblem(models.ErrHoldClosed, http.StatusConflict, "hold_closed")
+	common.RegisterProblem(models.ErrHoldsWaiting, http.StatusConflict, "holds_waiting")
+	common.RegisterProblem(models.ErrFinesOwed, http.StatusConflict, "fines_owed")
+	common.RegisterProblem(models.ErrOverpayment, http.StatusUnprocessableEntity, "overpayment")
+	common.RegisterProblem(models.ErrJobRunning, http.StatusConflict, "job_running")
+	common.RegisterProblem(models.ErrInvalidQuery, http.StatusBadRequest, "invalid_query")
+	common.RegisterProblem(metadata.ErrNotFound, http.StatusNotFound, "metadata_not_found")
+	common.RegisterProblem(metadata.ErrUnavailable, http.StatusBadGateway, "upstream_error")
+}
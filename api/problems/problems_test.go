@@ -0,0 +1,26 @@
+package problems
+
+import (
+	"fmt"
+	"testing"
+
+	"project-api/api/common"
+	"project-api/metadata"
+	"project-api/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProblems(t *testing.T) {
+	t.Run("model error", func(t *testing.T) {
+		problem := common.ToProblem(fmt.Errorf("login: %w", models.ErrInvalidCredentials))
+		assert.Equal(t, 401, problem.Status)
+		assert.Equal(t, "invalid_credentials", problem.Code)
+	})
+
+	t.Run("metadata error", func(t *testing.T) {
+		problem := common.ToProblem(fmt.Errorf("lookup: %w", metadata.ErrUnavailable))
+		assert.Equal(t, 502, problem.Status)
+		assert.Equal(t, "upstream_error", problem.Code)
+	})
+}
@@ -1,33 +1,196 @@
 package api
 
 import (
+	"project-api/api/controllers/author"
 	"project-api/api/controllers/book"
+	"project-api/api/controllers/category"
+	"project-api/api/controllers/fine"
+	"project-api/api/controllers/hold"
+	"project-api/api/controllers/inventory"
+	"project-api/api/controllers/job"
+	"project-api/api/controllers/loan"
+	"project-api/api/controllers/publisher"
+	"project-api/api/controllers/series"
 	"project-api/api/controllers/user"
+	"project-api/api/controllers/work"
+	"project-api/api/middlewares"
+	"project-api/models"
 
 	echo "github.com/labstack/echo/v4"
 )
 
+func RegisterPathAuth(e *echo.Echo) {
+	e.GET("/.well-known/jwks.json", middlewares.JWKSController)
+}
+
 func RegisterPath(e *echo.Echo, userController *user.Controller) {
 
 	// ------------------------------------------------------------------
 	// Login & register
 	// ------------------------------------------------------------------
 	e.POST("/users/register", userController.PostUserController)
-	e.POST("/users/login", userController.DeleteUserController)
+	e.POST("/users/login", userController.LoginUserController)
+	e.POST("/users/refresh", userController.RefreshTokenController)
+
+	// ------------------------------------------------------------------
+	// Sessions
+	// ------------------------------------------------------------------
+	jwt := middlewares.JWTMiddleware()
+	e.POST("/users/logout", userController.LogoutUserController, jwt)
+	e.GET("/users/me/sessions", userController.GetSessionsController, jwt)
+	e.DELETE("/users/me/sessions/:id", userController.DeleteSessionController, jwt)
 
 	// ------------------------------------------------------------------
 	// CRUD Customer
 	// ------------------------------------------------------------------
-	e.GET("/users", userController.GetAllUserController)
-	e.GET("/users/:id", userController.GetUserController)
-	e.PUT("/users/:id", userController.EditUserController)
-	e.DELETE("/users/:id", userController.DeleteUserController)
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+	staffOrSelf := middlewares.RequireRoleOrSelf("id", models.RoleAdmin, models.RoleLibrarian)
+	adminOrSelf := middlewares.RequireRoleOrSelf("id", models.RoleAdmin)
+
+	e.GET("/users", userController.GetAllUserController, jwt, staff)
+	e.GET("/users/:id", userController.GetUserController, jwt, staffOrSelf)
+	e.PUT("/users/:id", userController.EditUserController, jwt, adminOrSelf)
+	e.DELETE("/users/:id", userController.DeleteUserController, jwt, adminOrSelf)
 
 }
 
 func RegisterPathBook(e *echo.Echo, bookController *book.Controller) {
-	e.GET("/books", bookController.GetAllBookController)
-	e.GET("/books/:id", bookController.GetBookController)
-	e.PUT("/books/:id", bookController.EditBookController)
-	e.DELETE("/books/:id", bookController.DeleteBookController)
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+
+	e.GET("/books", bookController.GetAllBookController, jwt)
+	e.GET("/books/search", bookController.SearchBookController, jwt)
+	e.GET("/books/suggest", bookController.SuggestBookController, jwt)
+	e.GET("/books/facets", bookController.GetBookFacetsController, jwt)
+	e.GET("/books/isbn/:isbn", bookController.GetBookByISBNController, jwt)
+	e.GET("/books/:id", bookController.GetBookController, jwt)
+	e.GET("/books/:id/editions", bookController.GetBookEditionsController, jwt)
+	e.POST("/books", bookController.PostBookController, jwt, staff)
+	e.POST("/books/enrich/:id", bookController.EnrichBookController, jwt, staff)
+	e.POST("/books/:id/tags", bookController.TagBookController, jwt, staff)
+	e.DELETE("/books/:id/tags/:tag", bookController.UntagBookController, jwt, staff)
+	e.POST("/books/:id/categories", bookController.CategorizeBookController, jwt, staff)
+	e.DELETE("/books/:id/categories/:category_id", bookController.UncategorizeBookController, jwt, staff)
+	e.PUT("/books/:id", bookController.EditBookController, jwt, staff)
+	e.DELETE("/books/:id", bookController.DeleteBookController, jwt, staff)
+}
+
+func RegisterPathAuthor(e *echo.Echo, authorController *author.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+
+	e.GET("/authors", authorController.GetAllAuthorController, jwt)
+	e.GET("/authors/:id", authorController.GetAuthorController, jwt)
+	e.POST("/authors", authorController.PostAuthorController, jwt, staff)
+	e.PUT("/authors/:id", authorController.EditAuthorController, jwt, staff)
+	e.DELETE("/authors/:id", authorController.DeleteAuthorController, jwt, staff)
+}
+
+func RegisterPathPublisher(e *echo.Echo, publisherController *publisher.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+
+	e.GET("/publishers", publisherController.GetAllPublisherController, jwt)
+	e.GET("/publishers/:id", publisherController.GetPublisherController, jwt)
+	e.POST("/publishers", publisherController.PostPublisherController, jwt, staff)
+	e.PUT("/publishers/:id", publisherController.EditPublisherController, jwt, staff)
+	e.DELETE("/publishers/:id", publisherController.DeletePublisherController, jwt, staff)
+}
+
+func RegisterPathWork(e *echo.Echo, workController *work.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+
+	e.GET("/works", workController.GetAllWorkController, jwt)
+	e.GET("/works/:id", workController.GetWorkController, jwt)
+	e.POST("/works", workController.PostWorkController, jwt, staff)
+	e.PUT("/works/:id", workController.EditWorkController, jwt, staff)
+	e.DELETE("/works/:id", workController.DeleteWorkController, jwt, staff)
+}
+
+func RegisterPathSeries(e *echo.Echo, seriesController *series.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+
+	e.GET("/series", seriesController.GetAllSeriesController, jwt)
+	e.GET("/series/:id", seriesController.GetSeriesController, jwt)
+	e.POST("/series", seriesController.PostSeriesController, jwt, staff)
+	e.PUT("/series/:id", seriesController.EditSeriesController, jwt, staff)
+	e.DELETE("/series/:id", seriesController.DeleteSeriesController, jwt, staff)
+}
+
+func RegisterPathCategory(e *echo.Echo, categoryController *category.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+
+	e.GET("/categories", categoryController.GetAllCategoryController, jwt)
+	e.GET("/categories/:id", categoryController.GetCategoryController, jwt)
+	e.POST("/categories", categoryController.PostCategoryController, jwt, staff)
+	e.PUT("/categories/:id", categoryController.EditCategoryController, jwt, staff)
+	e.DELETE("/categories/:id", categoryController.DeleteCategoryController, jwt, staff)
+}
+
+func RegisterPathInventory(e *echo.Echo, inventoryController *inventory.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+
+	e.GET("/books/:id/copies", inventoryController.GetAllCopyController, jwt)
+	e.GET("/books/:id/copies/:copy_id", inventoryController.GetCopyController, jwt)
+	e.POST("/books/:id/copies", inventoryController.PostCopyController, jwt, staff)
+	e.PUT("/books/:id/copies/:copy_id", inventoryController.EditCopyController, jwt, staff)
+	e.DELETE("/books/:id/copies/:copy_id", inventoryController.DeleteCopyController, jwt, staff)
+	e.POST("/copies/stocktake", inventoryController.StocktakeController, jwt, staff)
+}
+
+func RegisterPathLoan(e *echo.Echo, loanController *loan.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+	staffOrSelf := middlewares.RequireRoleOrSelf("id", models.RoleAdmin, models.RoleLibrarian)
+
+	// borrowers may look at and renew their own loans, the controller
+	// checks the loan belongs to them
+	e.GET("/loans", loanController.GetAllLoanController, jwt, staff)
+	e.GET("/loans/:id", loanController.GetLoanController, jwt)
+	e.POST("/loans", loanController.PostLoanController, jwt, staff)
+	e.POST("/loans/:id/renew", loanController.RenewLoanController, jwt)
+	e.POST("/loans/:id/return", loanController.ReturnLoanController, jwt, staff)
+	e.GET("/users/:id/loans", loanController.GetUserLoansController, jwt, staffOrSelf)
+}
+
+func RegisterPathHold(e *echo.Echo, holdController *hold.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+	staffOrSelf := middlewares.RequireRoleOrSelf("id", models.RoleAdmin, models.RoleLibrarian)
+
+	// users place, look at and cancel their own holds, the controller
+	// checks the hold belongs to them
+	e.GET("/holds", holdController.GetAllHoldController, jwt, staff)
+	e.GET("/holds/:id", holdController.GetHoldController, jwt)
+	e.POST("/holds", holdController.PostHoldController, jwt)
+	e.POST("/holds/expire", holdController.ExpireHoldsController, jwt, staff)
+	e.DELETE("/holds/:id", holdController.CancelHoldController, jwt)
+	e.GET("/users/:id/holds", holdController.GetUserHoldsController, jwt, staffOrSelf)
+}
+
+func RegisterPathFine(e *echo.Echo, fineController *fine.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	staff := middlewares.RequireRole(models.RoleAdmin, models.RoleLibrarian)
+	staffOrSelf := middlewares.RequireRoleOrSelf("id", models.RoleAdmin, models.RoleLibrarian)
+
+	// users see what they owe, payments and waivers are taken at the desk
+	e.GET("/fines", fineController.GetAllFineController, jwt, staff)
+	e.POST("/fines/accrue", fineController.AccrueFinesController, jwt, staff)
+	e.GET("/users/:id/fines", fineController.GetUserFinesController, jwt, staffOrSelf)
+	e.GET("/users/:id/fines/entries", fineController.GetUserFineEntriesController, jwt, staffOrSelf)
+	e.POST("/users/:id/fines/payments", fineController.PayFineController, jwt, staff)
+	e.POST("/users/:id/fines/waivers", fineController.WaiveFineController, jwt, staff)
+}
+
+func RegisterPathJob(e *echo.Echo, jobController *job.Controller) {
+	jwt := middlewares.JWTMiddleware()
+	admin := middlewares.RequireRole(models.RoleAdmin)
+
+	e.GET("/jobs", jobController.GetAllJobController, jwt, admin)
+	e.GET("/jobs/runs", jobController.GetAllJobRunController, jwt, admin)
+	e.POST("/jobs/:name/run", jobController.RunJobController, jwt, admin)
 }
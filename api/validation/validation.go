@@ -1,21 +1,107 @@
 package validation
 
-// import (
-// 	validator "github.com/go-playground/validator/v10"
-// )
-
-// type PostCustomerRequest struct {
-// 	Name     string `json:"name" form:"name" validate:"required"`
-// 	Email    string `json:"email" form:"email" validate:"required,email"`
-// 	Password string `json:"password" form:"password" validate:"required"`
-// }
-
-// func (p PostCustomerRequest) PostUserValidation() error {
-// 	validate := validator.New()
-
-// 	err := validate.Struct(p)
-// 	if err == nil {
-// 		return nil
-// 	}
-// 	return err
-// }
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"project-api/api/common"
+
+	validator "github.com/go-playground/validator/v10"
+)
+
+//Validator echo.Validator checking the `validate` struct tags
+type Validator struct {
+	validate *validator.Validate
+}
+
+//NewValidator validator reporting fields by their json name
+func NewValidator() *Validator {
+	validate := validator.New()
+
+	validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	return &Validator{validate: validate}
+}
+
+//Validate check every tagged field of the request struct
+func (v *Validator) Validate(i interface{}) error {
+	return v.validate.Struct(i)
+}
+
+//FieldErrors one FieldError per broken rule of a Validate error
+func FieldErrors(err error) []common.FieldError {
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return []common.FieldError{{Rule: "invalid", Message: err.Error()}}
+	}
+
+	fieldErrors := make([]common.FieldError, 0, len(validationErrors))
+	for _, fieldError := range validationErrors {
+		fieldErrors = append(fieldErrors, common.FieldError{
+			Field:   fieldError.Field(),
+			Rule:    fieldError.Tag(),
+			Message: message(fieldError),
+		})
+	}
+	return fieldErrors
+}
+
+// message human readable explanation of a broken rule
+func message(fieldError validator.FieldError) string {
+	field, param := fieldError.Field(), fieldError.Param()
+
+	switch fieldError.Tag() {
+	case "required":
+		return fmt.Sprintf("%v is required", field)
+	case "email":
+		return fmt.Sprintf("%v must be a valid email address", field)
+	case "min":
+		if numeric(fieldError.Kind()) {
+			return fmt.Sprintf("%v must be at least %v", field, param)
+		}
+		if fieldError.Kind() == reflect.Slice {
+			return fmt.Sprintf("%v must have at least %v items", field, param)
+		}
+		return fmt.Sprintf("%v must be at least %v characters", field, param)
+	case "max":
+		if numeric(fieldError.Kind()) {
+			return fmt.Sprintf("%v must be at most %v", field, param)
+		}
+		if fieldError.Kind() == reflect.Slice {
+			return fmt.Sprintf("%v must have at most %v items", field, param)
+		}
+		return fmt.Sprintf("%v must be at most %v characters", field, param)
+	case "len":
+		return fmt.Sprintf("%v must be exactly %v characters", field, param)
+	case "isbn":
+		return fmt.Sprintf("%v must be a valid ISBN-10 or ISBN-13", field)
+	case "url":
+		return fmt.Sprintf("%v must be a valid URL", field)
+	case "alpha":
+		return fmt.Sprintf("%v must contain letters only", field)
+	case "oneof":
+		return fmt.Sprintf("%v must be one of %v", field, strings.Join(strings.Fields(param), ", "))
+	}
+	return fmt.Sprintf("%v is not valid", field)
+}
+
+func numeric(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
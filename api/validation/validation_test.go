@@ -0,0 +1,40 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidator(t *testing.T) {
+	type Request struct {
+		Name  string `json:"name" validate:"required,max=5"`
+		Email string `json:"email" validate:"required,email"`
+		Role  string `json:"role" validate:"omitempty,oneof=admin member"`
+	}
+
+	validator := NewValidator()
+
+	t.Run("valid request", func(t *testing.T) {
+		err := validator.Validate(&Request{Name: "Name", Email: "test@alterra.id"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("func FieldErrors()", func(t *testing.T) {
+		err := validator.Validate(&Request{Name: "Name Test", Email: "alterra", Role: "owner"})
+		fieldErrors := FieldErrors(err)
+
+		assert.Equal(t, 3, len(fieldErrors))
+		assert.Equal(t, "name", fieldErrors[0].Field)
+		assert.Equal(t, "max", fieldErrors[0].Rule)
+		assert.Equal(t, "name must be at most 5 characters", fieldErrors[0].Message)
+		assert.Equal(t, "email must be a valid email address", fieldErrors[1].Message)
+		assert.Equal(t, "role must be one of admin, member", fieldErrors[2].Message)
+	})
+
+	t.Run("func FieldErrors() other error", func(t *testing.T) {
+		fieldErrors := FieldErrors(errors.New("validator not registered"))
+		assert.Equal(t, "invalid", fieldErrors[0].Rule)
+	})
+}
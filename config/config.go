@@ -1,7 +1,9 @@
 package config
 
 import (
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/labstack/gommon/log"
 	"github.com/spf13/viper"
@@ -18,6 +20,89 @@ type AppConfig struct {
 		Username string `yaml:"username"`
 		Password string `yaml:"password"`
 	}
+	Auth struct {
+		PasswordCost    int           `yaml:"passwordCost"`
+		AccessTokenTTL  time.Duration `yaml:"accessTokenTTL"`
+		RefreshTokenTTL time.Duration `yaml:"refreshTokenTTL"`
+		// Admin account created on startup when it does not exist yet
+		Admin struct {
+			Name     string `yaml:"name"`
+			Email    string `yaml:"email"`
+			Password string `yaml:"password"`
+		}
+		Jwt struct {
+			Algorithm  string `yaml:"algorithm"`
+			KeyId      string `yaml:"keyId"`
+			Secret     string `yaml:"secret"`
+			PrivateKey string `yaml:"privateKey"`
+			// Keys retired keys still accepted for verification
+			Keys []JwtKey `yaml:"keys"`
+		}
+	}
+	// Metadata catalogue filling in books from their ISBN
+	Metadata struct {
+		Provider string        `yaml:"provider"`
+		Url      string        `yaml:"url"`
+		Timeout  time.Duration `yaml:"timeout"`
+		CacheTTL time.Duration `yaml:"cacheTTL"`
+	}
+	// Loans lending policy, MaxActive and MaxHolds zero let a borrower
+	// hold any number of loans and holds
+	Loans struct {
+		Period      time.Duration `yaml:"period"`
+		MaxRenewals uint          `yaml:"maxRenewals"`
+		MaxActive   int           `yaml:"maxActive"`
+		HoldPickup  time.Duration `yaml:"holdPickup"`
+		MaxHolds    int           `yaml:"maxHolds"`
+	}
+	// Fines what late loans cost in cents, a borrower owing more than
+	// MaxBalance can not borrow, zero never blocks. Overrides replace the
+	// rule for the books filed under a category code
+	Fines struct {
+		Rate       int64          `yaml:"rate"`
+		Grace      time.Duration  `yaml:"grace"`
+		Cap        int64          `yaml:"cap"`
+		MaxBalance int64          `yaml:"maxBalance"`
+		Overrides  []FineOverride `yaml:"overrides"`
+	}
+	// Jobs background jobs, a job without schedule never runs on its own.
+	// SessionRetention is how long ended sessions are kept, ReportPeriod
+	// the time the circulation report covers
+	Jobs struct {
+		Enabled          bool          `yaml:"enabled"`
+		Owner            string        `yaml:"owner"`
+		SessionRetention time.Duration `yaml:"sessionRetention"`
+		ReportPeriod     time.Duration `yaml:"reportPeriod"`
+		Fines            JobConfig     `yaml:"fines"`
+		Holds            JobConfig     `yaml:"holds"`
+		Sessions         JobConfig     `yaml:"sessions"`
+		Report           JobConfig     `yaml:"report"`
+		Search           JobConfig     `yaml:"search"`
+	}
+}
+
+//JobConfig cron schedule of a background job, the most its start is
+//delayed by and how long it may run
+type JobConfig struct {
+	Schedule string        `yaml:"schedule"`
+	Jitter   time.Duration `yaml:"jitter"`
+	Timeout  time.Duration `yaml:"timeout"`
+}
+
+//FineOverride fine rule of the books filed under a category code
+type FineOverride struct {
+	Category string        `yaml:"category"`
+	Rate     int64         `yaml:"rate"`
+	Grace    time.Duration `yaml:"grace"`
+	Cap      int64         `yaml:"cap"`
+}
+
+//JwtKey verification key of a retired signing key
+type JwtKey struct {
+	KeyId     string `yaml:"keyId"`
+	Algorithm string `yaml:"algorithm"`
+	Secret    string `yaml:"secret"`
+	PublicKey string `yaml:"publicKey"`
 }
 
 var lock = &sync.Mutex{}
@@ -44,15 +129,93 @@ func initConfig() *AppConfig {
 	defaultConfig.Database.Port = 3306
 	defaultConfig.Database.Username = "root"
 	defaultConfig.Database.Password = "toor"
+	defaultConfig.Auth.PasswordCost = 10
+	defaultConfig.Auth.AccessTokenTTL = 15 * time.Minute
+	defaultConfig.Auth.RefreshTokenTTL = 30 * 24 * time.Hour
+	defaultConfig.Auth.Jwt.Algorithm = "HS256"
+	defaultConfig.Auth.Jwt.KeyId = "default"
+	defaultConfig.Metadata.Provider = "openlibrary"
+	defaultConfig.Metadata.Url = "https://openlibrary.org"
+	defaultConfig.Metadata.Timeout = 5 * time.Second
+	defaultConfig.Metadata.CacheTTL = 24 * time.Hour
+	defaultConfig.Loans.Period = 21 * 24 * time.Hour
+	defaultConfig.Loans.MaxRenewals = 2
+	defaultConfig.Loans.MaxActive = 10
+	defaultConfig.Loans.HoldPickup = 72 * time.Hour
+	defaultConfig.Loans.MaxHolds = 5
+	defaultConfig.Fines.Rate = 25
+	defaultConfig.Fines.Grace = 24 * time.Hour
+	defaultConfig.Fines.Cap = 1000
+	defaultConfig.Fines.MaxBalance = 1000
+	defaultConfig.Jobs.Enabled = true
+	defaultConfig.Jobs.SessionRetention = 7 * 24 * time.Hour
+	defaultConfig.Jobs.ReportPeriod = 24 * time.Hour
+	defaultConfig.Jobs.Fines = JobConfig{Schedule: "0 2 * * *", Jitter: 5 * time.Minute, Timeout: 30 * time.Minute}
+	defaultConfig.Jobs.Holds = JobConfig{Schedule: "*/15 * * * *", Jitter: time.Minute, Timeout: 5 * time.Minute}
+	defaultConfig.Jobs.Sessions = JobConfig{Schedule: "30 3 * * *", Jitter: 5 * time.Minute, Timeout: 10 * time.Minute}
+	defaultConfig.Jobs.Report = JobConfig{Schedule: "0 6 * * *", Jitter: 5 * time.Minute, Timeout: 10 * time.Minute}
+	defaultConfig.Jobs.Search = JobConfig{Schedule: "*/15 * * * *", Jitter: time.Minute, Timeout: 5 * time.Minute}
+
+	viper.SetDefault("port", defaultConfig.Port)
+	viper.SetDefault("database.driver", defaultConfig.Database.Driver)
+	viper.SetDefault("database.name", defaultConfig.Database.Name)
+	viper.SetDefault("database.address", defaultConfig.Database.Address)
+	viper.SetDefault("database.port", defaultConfig.Database.Port)
+	viper.SetDefault("database.username", defaultConfig.Database.Username)
+	viper.SetDefault("database.password", defaultConfig.Database.Password)
+	viper.SetDefault("auth.passwordCost", defaultConfig.Auth.PasswordCost)
+	viper.SetDefault("auth.accessTokenTTL", defaultConfig.Auth.AccessTokenTTL)
+	viper.SetDefault("auth.refreshTokenTTL", defaultConfig.Auth.RefreshTokenTTL)
+	viper.SetDefault("auth.admin.name", defaultConfig.Auth.Admin.Name)
+	viper.SetDefault("auth.admin.email", defaultConfig.Auth.Admin.Email)
+	viper.SetDefault("auth.admin.password", defaultConfig.Auth.Admin.Password)
+	viper.SetDefault("auth.jwt.algorithm", defaultConfig.Auth.Jwt.Algorithm)
+	viper.SetDefault("auth.jwt.keyId", defaultConfig.Auth.Jwt.KeyId)
+	viper.SetDefault("auth.jwt.secret", defaultConfig.Auth.Jwt.Secret)
+	viper.SetDefault("auth.jwt.privateKey", defaultConfig.Auth.Jwt.PrivateKey)
+	viper.SetDefault("metadata.provider", defaultConfig.Metadata.Provider)
+	viper.SetDefault("metadata.url", defaultConfig.Metadata.Url)
+	viper.SetDefault("metadata.timeout", defaultConfig.Metadata.Timeout)
+	viper.SetDefault("metadata.cacheTTL", defaultConfig.Metadata.CacheTTL)
+	viper.SetDefault("loans.period", defaultConfig.Loans.Period)
+	viper.SetDefault("loans.maxRenewals", defaultConfig.Loans.MaxRenewals)
+	viper.SetDefault("loans.maxActive", defaultConfig.Loans.MaxActive)
+	viper.SetDefault("loans.holdPickup", defaultConfig.Loans.HoldPickup)
+	viper.SetDefault("loans.maxHolds", defaultConfig.Loans.MaxHolds)
+	viper.SetDefault("fines.rate", defaultConfig.Fines.Rate)
+	viper.SetDefault("fines.grace", defaultConfig.Fines.Grace)
+	viper.SetDefault("fines.cap", defaultConfig.Fines.Cap)
+	viper.SetDefault("fines.maxBalance", defaultConfig.Fines.MaxBalance)
+	viper.SetDefault("jobs.enabled", defaultConfig.Jobs.Enabled)
+	viper.SetDefault("jobs.owner", defaultConfig.Jobs.Owner)
+	viper.SetDefault("jobs.sessionRetention", defaultConfig.Jobs.SessionRetention)
+	viper.SetDefault("jobs.reportPeriod", defaultConfig.Jobs.ReportPeriod)
+	viper.SetDefault("jobs.fines.schedule", defaultConfig.Jobs.Fines.Schedule)
+	viper.SetDefault("jobs.fines.jitter", defaultConfig.Jobs.Fines.Jitter)
+	viper.SetDefault("jobs.fines.timeout", defaultConfig.Jobs.Fines.Timeout)
+	viper.SetDefault("jobs.holds.schedule", defaultConfig.Jobs.Holds.Schedule)
+	viper.SetDefault("jobs.holds.jitter", defaultConfig.Jobs.Holds.Jitter)
+	viper.SetDefault("jobs.holds.timeout", defaultConfig.Jobs.Holds.Timeout)
+	viper.SetDefault("jobs.sessions.schedule", defaultConfig.Jobs.Sessions.Schedule)
+	viper.SetDefault("jobs.sessions.jitter", defaultConfig.Jobs.Sessions.Jitter)
+	viper.SetDefault("jobs.sessions.timeout", defaultConfig.Jobs.Sessions.Timeout)
+	viper.SetDefault("jobs.report.schedule", defaultConfig.Jobs.Report.Schedule)
+	viper.SetDefault("jobs.report.jitter", defaultConfig.Jobs.Report.Jitter)
+	viper.SetDefault("jobs.report.timeout", defaultConfig.Jobs.Report.Timeout)
+	viper.SetDefault("jobs.search.schedule", defaultConfig.Jobs.Search.Schedule)
+	viper.SetDefault("jobs.search.jitter", defaultConfig.Jobs.Search.Jitter)
+	viper.SetDefault("jobs.search.timeout", defaultConfig.Jobs.Search.Timeout)
+
+	//every key can be overridden from environment, e.g. DATABASE_DRIVER=sqlite
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	viper.AutomaticEnv()
 
 	viper.SetConfigType("yaml")
 	viper.SetConfigName("config")
 	viper.AddConfigPath("./config/")
 
-	if err := viper.ReadInConfig(); err != nil {
-		// log.Info("error to load config file, will use default value ", err)
-		return &defaultConfig
-	}
+	//config file is optional, defaults and environment are used without it
+	_ = viper.ReadInConfig()
 
 	var finalConfig AppConfig
 	err := viper.Unmarshal(&finalConfig)
@@ -0,0 +1,102 @@
+package isbn
+
+import "strings"
+
+// rangeRule digits of the next element for the 7 digit windows between
+// from and to, zero means the range is not in use
+type rangeRule struct {
+	from, to string
+	length   int
+}
+
+// groups registration group ranges per prefix, published by the
+// International ISBN Agency
+var groups = map[string][]rangeRule{
+	"978": {
+		{"0000000", "5999999", 1},
+		{"6000000", "6499999", 3},
+		{"6500000", "6599999", 2},
+		{"6600000", "6999999", 0},
+		{"7000000", "7999999", 1},
+		{"8000000", "9499999", 2},
+		{"9500000", "9899999", 3},
+		{"9900000", "9989999", 4},
+		{"9990000", "9999999", 5},
+	},
+	"979": {
+		{"0000000", "0999999", 0},
+		{"1000000", "1299999", 2},
+		{"1300000", "7999999", 0},
+		{"8000000", "8099999", 1},
+		{"8100000", "9999999", 0},
+	},
+}
+
+// registrants registrant ranges of the registration groups they are
+// known for, the english language groups. Other groups hyphenate by
+// registration group only
+var registrants = map[string][]rangeRule{
+	"978-0": {
+		{"0000000", "1999999", 2},
+		{"2000000", "6999999", 3},
+		{"7000000", "8499999", 4},
+		{"8500000", "8999999", 5},
+		{"9000000", "9499999", 6},
+		{"9500000", "9999999", 7},
+	},
+	"978-1": {
+		{"0000000", "0999999", 2},
+		{"1000000", "3999999", 3},
+		{"4000000", "5499999", 4},
+		{"5500000", "8697999", 5},
+		{"8698000", "9989999", 6},
+		{"9990000", "9999999", 7},
+	},
+}
+
+// lookup length of the element starting digits
+func lookup(rules []rangeRule, digits string) int {
+	window := (digits + "0000000")[:7]
+	for _, rule := range rules {
+		if window >= rule.from && window <= rule.to {
+			return rule.length
+		}
+	}
+	return 0
+}
+
+//Hyphenate split a valid ISBN into prefix, registration group,
+//registrant, publication and check digit, the ISBN-10 form has no
+//prefix. The registrant is only split off for groups with known ranges,
+//an invalid ISBN is returned as is
+func Hyphenate(value string) string {
+	clean := Clean(value)
+
+	isbn13, err := Parse(clean)
+	if err != nil {
+		return value
+	}
+
+	prefix, body := isbn13[:3], isbn13[3:12]
+	parts := []string{prefix}
+
+	group := lookup(groups[prefix], body)
+	if group == 0 {
+		parts = append(parts, body)
+	} else {
+		parts = append(parts, body[:group])
+		rest := body[group:]
+
+		registrant := lookup(registrants[prefix+"-"+body[:group]], rest)
+		if registrant == 0 || registrant >= len(rest) {
+			parts = append(parts, rest)
+		} else {
+			parts = append(parts, rest[:registrant], rest[registrant:])
+		}
+	}
+
+	if len(clean) == 10 {
+		return strings.Join(append(parts[1:], clean[9:]), "-")
+	}
+	return strings.Join(append(parts, isbn13[12:]), "-")
+}
@@ -0,0 +1,118 @@
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+//ErrInvalid not a well formed ISBN or its check digit does not match
+var ErrInvalid = errors.New("invalid isbn")
+
+//ErrNoISBN10 ISBN-13 outside the 978 prefix, it has no ISBN-10 form
+var ErrNoISBN10 = errors.New("isbn has no isbn-10 form")
+
+//Clean strip hyphens and spaces, an x check digit becomes X
+func Clean(value string) string {
+	value = strings.NewReplacer("-", "", " ", "").Replace(value)
+	return strings.ToUpper(value)
+}
+
+//Parse validate an ISBN-10 or ISBN-13, hyphens and spaces allowed, and
+//return its canonical form: the 13 digits of the ISBN-13
+func Parse(value string) (string, error) {
+	value = Clean(value)
+
+	switch len(value) {
+	case 10:
+		if !ValidISBN10(value) {
+			return "", ErrInvalid
+		}
+		return To13(value)
+	case 13:
+		if !ValidISBN13(value) {
+			return "", ErrInvalid
+		}
+		return value, nil
+	}
+	return "", ErrInvalid
+}
+
+//ValidISBN10 check an ISBN-10 without hyphens
+func ValidISBN10(value string) bool {
+	if len(value) != 10 || !digits(value[:9]) {
+		return false
+	}
+	return checkDigit10(value[:9]) == value[9]
+}
+
+//ValidISBN13 check an ISBN-13 without hyphens
+func ValidISBN13(value string) bool {
+	if len(value) != 13 || !digits(value) {
+		return false
+	}
+	if !strings.HasPrefix(value, "978") && !strings.HasPrefix(value, "979") {
+		return false
+	}
+	return checkDigit13(value[:12]) == value[12]
+}
+
+//To13 ISBN-13 of a valid ISBN-10
+func To13(isbn10 string) (string, error) {
+	isbn10 = Clean(isbn10)
+	if !ValidISBN10(isbn10) {
+		return "", ErrInvalid
+	}
+
+	body := "978" + isbn10[:9]
+	return body + string(checkDigit13(body)), nil
+}
+
+//To10 ISBN-10 of a valid ISBN-13, only 978 ISBNs have one
+func To10(isbn13 string) (string, error) {
+	isbn13 = Clean(isbn13)
+	if !ValidISBN13(isbn13) {
+		return "", ErrInvalid
+	}
+	if !strings.HasPrefix(isbn13, "978") {
+		return "", ErrNoISBN10
+	}
+
+	body := isbn13[3:12]
+	return body + string(checkDigit10(body)), nil
+}
+
+func digits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDigit10 weights 10 down to 2, modulo 11, 10 is written X
+func checkDigit10(body string) byte {
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(body[i]-'0')
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// checkDigit13 alternating weights 1 and 3, modulo 10
+func checkDigit13(body string) byte {
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(body[i]-'0')
+	}
+	return byte('0' + (10-sum%10)%10)
+}
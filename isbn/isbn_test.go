@@ -0,0 +1,59 @@
+package isbn
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	cases := map[string]string{
+		"978-0-306-40615-7": "9780306406157",
+		"0-306-40615-2":     "9780306406157",
+		"0 306 40615 2":     "9780306406157",
+		"080442957x":        "9780804429573",
+		"9791090636071":     "9791090636071",
+	}
+	for value, want := range cases {
+		got, err := Parse(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, want, got, value)
+	}
+
+	for _, value := range []string{"", "0-306-40615-3", "9780306406158", "12345", "978030640615X", "9770306406150"} {
+		_, err := Parse(value)
+		assert.Equal(t, ErrInvalid, err, value)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	isbn10, err := To10("9780306406157")
+	assert.NoError(t, err)
+	assert.Equal(t, "0306406152", isbn10)
+
+	isbn10, err = To10("9780804429573")
+	assert.NoError(t, err)
+	assert.Equal(t, "080442957X", isbn10)
+
+	_, err = To10("9791090636071")
+	assert.Equal(t, ErrNoISBN10, err)
+
+	isbn13, err := To13("0-8044-2957-X")
+	assert.NoError(t, err)
+	assert.Equal(t, "9780804429573", isbn13)
+}
+
+func TestHyphenate(t *testing.T) {
+	cases := map[string]string{
+		"9780306406157": "978-0-306-40615-7",
+		"0306406152":    "0-306-40615-2",
+		"9781402894626": "978-1-4028-9462-6",
+		"9780804429573": "978-0-8044-2957-3",
+		"9783161484100": "978-3-16148410-0",
+		"9791090636071": "979-10-9063607-1",
+		"invalid":       "invalid",
+	}
+	for value, want := range cases {
+		assert.Equal(t, want, Hyphenate(value), value)
+	}
+}
@@ -2,12 +2,28 @@ package main
 
 import (
 	"project-api/api"
+	"project-api/api/common"
+	"project-api/api/middlewares"
+	"project-api/api/validation"
 
+	authorController "project-api/api/controllers/author"
 	bookController "project-api/api/controllers/book"
+	categoryController "project-api/api/controllers/category"
+	fineController "project-api/api/controllers/fine"
+	holdController "project-api/api/controllers/hold"
+	inventoryController "project-api/api/controllers/inventory"
+	jobController "project-api/api/controllers/job"
+	loanController "project-api/api/controllers/loan"
+	publisherController "project-api/api/controllers/publisher"
+	seriesController "project-api/api/controllers/series"
 	userController "project-api/api/controllers/user"
+	workController "project-api/api/controllers/work"
 
 	"project-api/config"
+	"project-api/metadata"
 	"project-api/models"
+	"project-api/scheduler"
+	"project-api/search"
 	"project-api/util"
 
 	"fmt"
@@ -20,22 +36,123 @@ func main() {
 	//load config if available or set to default
 	config := config.GetConfig()
 
-	//initialize database connection based on given config
-	db := util.MysqlDatabaseConnection(config)
+	//hash passwords with the configured bcrypt cost
+	models.PasswordCost = config.Auth.PasswordCost
+
+	//token lifetimes
+	middlewares.AccessTokenTTL = config.Auth.AccessTokenTTL
+	models.RefreshTokenTTL = config.Auth.RefreshTokenTTL
+
+	//load the token signing keys, a broken key file or a missing HS256
+	//secret (AUTH_JWT_SECRET) stops the server here
+	keys, err := middlewares.NewKeySet(config)
+	if err != nil {
+		log.Fatal("failed to load jwt keys: ", err)
+	}
+	middlewares.SetKeySet(keys)
+
+	//initialize storage (models) based on the configured database driver
+	storage := util.DatabaseConnection(config)
+
+	//make sure the configured admin account exists
+	if err := util.SeedAdmin(storage, config); err != nil {
+		log.Fatal("failed to create admin account: ", err)
+	}
+
+	//link books still naming their authors and publisher in free text only
+	if err := util.MigrateContributors(storage); err != nil {
+		log.Fatal("failed to link books to their authors and publishers: ", err)
+	}
+
+	//index the books for full-text search, writes through this server keep
+	//it up to date, the search job reloads it for the writes of the others
+	index := search.NewIndex()
+	if err := index.Rebuild(storage.BookModel); err != nil {
+		log.Fatal("failed to build the search index: ", err)
+	}
+	bookModel := search.NewIndexedBookModel(storage.BookModel, index)
+
+	//fill in books from the configured catalogue, nil turns it off
+	provider, err := metadata.NewProvider(config)
+	if err != nil {
+		log.Fatal("failed to set up the metadata provider: ", err)
+	}
+
+	//reject access tokens of logged out or revoked sessions
+	middlewares.SetSessionChecker(storage.SessionModel.IsSessionActive)
 
-	//initiate user model
-	userModel := models.NewUserModel(db)
-	bookModel := models.NewBookModel(db)
 	//initiate user controller
-	newUserController := userController.NewController(userModel)
-	newBookController := bookController.NewController(bookModel)
+	newUserController := userController.NewController(storage.UserModel, storage.SessionModel)
+	newBookController := bookController.NewController(bookModel, storage.AuthorModel, storage.PublisherModel, storage.WorkModel, storage.CategoryModel, index, provider)
+	newAuthorController := authorController.NewController(storage.AuthorModel)
+	newPublisherController := publisherController.NewController(storage.PublisherModel)
+	newWorkController := workController.NewController(storage.WorkModel, storage.SeriesModel, bookModel)
+	newSeriesController := seriesController.NewController(storage.SeriesModel, storage.WorkModel)
+	newCategoryController := categoryController.NewController(storage.CategoryModel)
+	loanPolicy := models.LoanPolicy{
+		Period:      config.Loans.Period,
+		MaxRenewals: config.Loans.MaxRenewals,
+		MaxActive:   config.Loans.MaxActive,
+		HoldPickup:  config.Loans.HoldPickup,
+		MaxHolds:    config.Loans.MaxHolds,
+		Fines: models.FinePolicy{
+			FineRule: models.FineRule{
+				Rate:  config.Fines.Rate,
+				Grace: config.Fines.Grace,
+				Cap:   config.Fines.Cap,
+			},
+			MaxBalance: config.Fines.MaxBalance,
+		},
+	}
+	for _, override := range config.Fines.Overrides {
+		loanPolicy.Fines.Overrides = append(loanPolicy.Fines.Overrides, models.FineOverride{
+			Category: override.Category,
+			FineRule: models.FineRule{Rate: override.Rate, Grace: override.Grace, Cap: override.Cap},
+		})
+	}
+	newInventoryController := inventoryController.NewController(storage.CopyModel, bookModel, loanPolicy)
+	newLoanController := loanController.NewController(storage.LoanModel, storage.CopyModel, storage.UserModel, loanPolicy)
+	newHoldController := holdController.NewController(storage.HoldModel, bookModel, storage.UserModel, loanPolicy)
+	newFineController := fineController.NewController(storage.FineModel, storage.UserModel, loanPolicy)
+
+	//schedule the background jobs, a lease in the database keeps every run
+	//to one replica
+	jobs := scheduler.New(storage.JobModel, config.Jobs.Owner)
+	if err := scheduleJobs(jobs, storage, config, loanPolicy, index); err != nil {
+		log.Fatal("failed to schedule the background jobs: ", err)
+	}
+	newJobController := jobController.NewController(jobs, storage.JobModel)
 
 	//create echo http
 	e := echo.New()
 
+	//answer every error with an application/problem+json document
+	e.HTTPErrorHandler = common.HTTPErrorHandler
+
+	//check request structs by their validate tags
+	e.Validator = validation.NewValidator()
+
 	//register API path and controller
+	api.RegisterPathAuth(e)
 	api.RegisterPath(e, newUserController)
 	api.RegisterPathBook(e, newBookController)
+	api.RegisterPathAuthor(e, newAuthorController)
+	api.RegisterPathPublisher(e, newPublisherController)
+	api.RegisterPathWork(e, newWorkController)
+	api.RegisterPathSeries(e, newSeriesController)
+	api.RegisterPathCategory(e, newCategoryController)
+	api.RegisterPathInventory(e, newInventoryController)
+	api.RegisterPathLoan(e, newLoanController)
+	api.RegisterPathHold(e, newHoldController)
+	api.RegisterPathFine(e, newFineController)
+	api.RegisterPathJob(e, newJobController)
+
+	//run the jobs alongside the server, jobs can still be run by hand when
+	//disabled
+	if config.Jobs.Enabled {
+		jobs.Start()
+		defer jobs.Stop()
+	}
 
 	// run server
 	address := fmt.Sprintf(":%d", config.Port)
@@ -44,3 +161,41 @@ func main() {
 		log.Info("shutting down the server")
 	}
 }
+
+// scheduleJobs add every background job with a schedule
+func scheduleJobs(jobs *scheduler.Scheduler, storage *util.Storage, appConfig *config.AppConfig, policy models.LoanPolicy, index *search.Index) error {
+	settings := []struct {
+		name   string
+		config config.JobConfig
+		local  bool
+		run    scheduler.RunFunc
+	}{
+		{"fines", appConfig.Jobs.Fines, false, scheduler.AccrueFines(storage.FineModel, policy.Fines)},
+		{"holds", appConfig.Jobs.Holds, false, scheduler.ExpireHolds(storage.HoldModel, policy)},
+		{"sessions", appConfig.Jobs.Sessions, false, scheduler.PurgeSessions(storage.SessionModel, appConfig.Jobs.SessionRetention)},
+		{"report", appConfig.Jobs.Report, false, scheduler.ReportCirculation(storage.LoanModel, storage.HoldModel, storage.FineModel, appConfig.Jobs.ReportPeriod)},
+		{"search", appConfig.Jobs.Search, true, scheduler.RebuildSearchIndex(index, storage.BookModel)},
+	}
+
+	for _, setting := range settings {
+		if setting.config.Schedule == "" {
+			continue
+		}
+		schedule, err := scheduler.Parse(setting.config.Schedule)
+		if err != nil {
+			return err
+		}
+		err = jobs.Add(scheduler.Job{
+			Name:     setting.name,
+			Schedule: schedule,
+			Jitter:   setting.config.Jitter,
+			Timeout:  setting.config.Timeout,
+			Local:    setting.local,
+			Run:      setting.run,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
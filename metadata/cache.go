@@ -0,0 +1,69 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// cacheEntry answer of the wrapped provider, a record or ErrNotFound
+type cacheEntry struct {
+	meta    Metadata
+	err     error
+	expires time.Time
+}
+
+//CachedProvider Provider remembering the answers of another one for a
+//while. Records and misses are cached, failures are not so the next
+//lookup tries again
+type CachedProvider struct {
+	provider Provider
+	ttl      time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]cacheEntry
+	lastSweep time.Time
+}
+
+func NewCachedProvider(provider Provider, ttl time.Duration) *CachedProvider {
+	return &CachedProvider{
+		provider:  provider,
+		ttl:       ttl,
+		entries:   make(map[string]cacheEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+func (p *CachedProvider) Lookup(ctx context.Context, isbn13 string) (Metadata, error) {
+	now := time.Now()
+
+	p.mu.Lock()
+	entry, ok := p.entries[isbn13]
+	p.mu.Unlock()
+	if ok && now.Before(entry.expires) {
+		return entry.meta, entry.err
+	}
+
+	meta, err := p.provider.Lookup(ctx, isbn13)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return meta, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.entries[isbn13] = cacheEntry{meta, err, now.Add(p.ttl)}
+
+	// drop the expired entries once per ttl so the cache does not grow
+	// with every isbn ever asked for
+	if now.Sub(p.lastSweep) >= p.ttl {
+		for key, entry := range p.entries {
+			if !now.Before(entry.expires) {
+				delete(p.entries, key)
+			}
+		}
+		p.lastSweep = now
+	}
+	return meta, err
+}
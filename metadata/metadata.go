@@ -0,0 +1,92 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"project-api/config"
+	"project-api/models"
+)
+
+var (
+	//ErrNotFound the catalogue does not know the ISBN
+	ErrNotFound = errors.New("no catalogue record for the isbn")
+	//ErrUnavailable the catalogue could not be reached or answered garbage
+	ErrUnavailable = errors.New("catalogue unavailable")
+)
+
+//Metadata bibliographic record of a book in an external catalogue, empty
+//fields are unknown to the catalogue
+type Metadata struct {
+	Title     string
+	Author    string
+	Publisher string
+	Year      uint
+	CoverURL  string
+}
+
+//Provider external catalogue looking books up by their ISBN-13
+type Provider interface {
+	Lookup(ctx context.Context, isbn13 string) (Metadata, error)
+}
+
+//NewProvider provider of the configured catalogue, cached for
+//Metadata.CacheTTL. Nil when enrichment is turned off
+func NewProvider(config *config.AppConfig) (Provider, error) {
+	switch config.Metadata.Provider {
+	case "", "none":
+		return nil, nil
+	case "openlibrary":
+		client := &http.Client{Timeout: config.Metadata.Timeout}
+		return NewCachedProvider(NewOpenLibrary(config.Metadata.Url, client), config.Metadata.CacheTTL), nil
+	}
+	return nil, fmt.Errorf("unknown metadata provider %q", config.Metadata.Provider)
+}
+
+//Strategy how Merge treats fields the book already has
+type Strategy int
+
+const (
+	//FillEmpty only fill the blank fields, what the librarian typed wins
+	FillEmpty Strategy = iota
+	//Overwrite replace every field the catalogue knows
+	Overwrite
+)
+
+//ParseStrategy strategy by its name, fill or overwrite
+func ParseStrategy(name string) (Strategy, error) {
+	switch name {
+	case "", "fill":
+		return FillEmpty, nil
+	case "overwrite":
+		return Overwrite, nil
+	}
+	return FillEmpty, fmt.Errorf("unknown merge strategy %q", name)
+}
+
+//Merge copy the catalogue record into the book following the strategy,
+//fields the catalogue does not know are never cleared
+func Merge(book models.Book, meta Metadata, strategy Strategy) models.Book {
+	takes := func(empty bool) bool {
+		return empty || strategy == Overwrite
+	}
+
+	if meta.Title != "" && takes(book.Title == "") {
+		book.Title = meta.Title
+	}
+	if meta.Author != "" && takes(book.Author == "") {
+		book.Author = meta.Author
+	}
+	if meta.Publisher != "" && takes(book.Publisher == "") {
+		book.Publisher = meta.Publisher
+	}
+	if meta.Year != 0 && takes(book.Year == 0) {
+		book.Year = meta.Year
+	}
+	if meta.CoverURL != "" && takes(book.CoverURL == "") {
+		book.CoverURL = meta.CoverURL
+	}
+	return book
+}
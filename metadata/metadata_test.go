@@ -0,0 +1,154 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-api/config"
+	"project-api/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// stub Open Library answering one isbn, 9780262033848 is down
+func stub(hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+
+		if r.URL.Path != "/api/books" || r.URL.Query().Get("jscmd") != "data" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		switch r.URL.Query().Get("bibkeys") {
+		case "ISBN:9780140328721":
+			fmt.Fprint(w, `{"ISBN:9780140328721": {
+				"title": " Fantastic Mr Fox ",
+				"authors": [{"name": "Roald Dahl"}, {"name": "Quentin Blake"}],
+				"publishers": [{"name": "Puffin"}, {"name": "Penguin"}],
+				"publish_date": "October 1, 1988",
+				"cover": {"small": "https://covers.example/S.jpg", "large": "https://covers.example/L.jpg"}
+			}}`)
+		case "ISBN:9780262033848":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, `{}`)
+		}
+	}))
+}
+
+func TestOpenLibrary(t *testing.T) {
+	var hits int32
+	server := stub(&hits)
+	defer server.Close()
+
+	client := NewOpenLibrary(server.URL+"/", nil)
+
+	t.Run("known isbn", func(t *testing.T) {
+		meta, err := client.Lookup(context.Background(), "9780140328721")
+		assert.NoError(t, err)
+		assert.Equal(t, Metadata{
+			Title:     "Fantastic Mr Fox",
+			Author:    "Roald Dahl, Quentin Blake",
+			Publisher: "Puffin",
+			Year:      1988,
+			CoverURL:  "https://covers.example/L.jpg",
+		}, meta)
+	})
+
+	t.Run("unknown isbn", func(t *testing.T) {
+		_, err := client.Lookup(context.Background(), "9781402894626")
+		assert.Equal(t, ErrNotFound, err)
+	})
+
+	t.Run("catalogue down", func(t *testing.T) {
+		_, err := client.Lookup(context.Background(), "9780262033848")
+		assert.True(t, errors.Is(err, ErrUnavailable))
+
+		_, err = NewOpenLibrary("http://127.0.0.1:1", nil).Lookup(context.Background(), "9780140328721")
+		assert.True(t, errors.Is(err, ErrUnavailable))
+	})
+}
+
+func TestCachedProvider(t *testing.T) {
+	var hits int32
+	server := stub(&hits)
+	defer server.Close()
+
+	provider := NewCachedProvider(NewOpenLibrary(server.URL, nil), time.Hour)
+	lookup := func(isbn13 string) error {
+		_, err := provider.Lookup(context.Background(), isbn13)
+		return err
+	}
+
+	t.Run("records and misses are cached", func(t *testing.T) {
+		assert.NoError(t, lookup("9780140328721"))
+		assert.NoError(t, lookup("9780140328721"))
+		assert.Equal(t, ErrNotFound, lookup("9781402894626"))
+		assert.Equal(t, ErrNotFound, lookup("9781402894626"))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("failures are not", func(t *testing.T) {
+		atomic.StoreInt32(&hits, 0)
+		assert.Error(t, lookup("9780262033848"))
+		assert.Error(t, lookup("9780262033848"))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	})
+
+	t.Run("entries expire", func(t *testing.T) {
+		provider := NewCachedProvider(NewOpenLibrary(server.URL, nil), time.Millisecond)
+
+		atomic.StoreInt32(&hits, 0)
+		provider.Lookup(context.Background(), "9780140328721")
+		time.Sleep(2 * time.Millisecond)
+		provider.Lookup(context.Background(), "9780140328721")
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+		assert.Equal(t, 1, len(provider.entries))
+	})
+}
+
+func TestMerge(t *testing.T) {
+	meta := Metadata{Title: "Fantastic Mr Fox", Author: "Roald Dahl", Year: 1988}
+	book := models.Book{Title: "Mr Fox", Publisher: "Puffin"}
+
+	merged := Merge(book, meta, FillEmpty)
+	assert.Equal(t, "Mr Fox", merged.Title)
+	assert.Equal(t, "Roald Dahl", merged.Author)
+	assert.Equal(t, "Puffin", merged.Publisher)
+	assert.Equal(t, uint(1988), merged.Year)
+
+	merged = Merge(book, meta, Overwrite)
+	assert.Equal(t, "Fantastic Mr Fox", merged.Title)
+	assert.Equal(t, "Puffin", merged.Publisher)
+
+	strategy, err := ParseStrategy("overwrite")
+	assert.NoError(t, err)
+	assert.Equal(t, Overwrite, strategy)
+
+	_, err = ParseStrategy("replace")
+	assert.Error(t, err)
+}
+
+func TestNewProvider(t *testing.T) {
+	var appConfig config.AppConfig
+
+	provider, err := NewProvider(&appConfig)
+	assert.NoError(t, err)
+	assert.Nil(t, provider)
+
+	appConfig.Metadata.Provider = "openlibrary"
+	provider, err = NewProvider(&appConfig)
+	assert.NoError(t, err)
+	assert.NotNil(t, provider)
+
+	appConfig.Metadata.Provider = "worldcat"
+	_, err = NewProvider(&appConfig)
+	assert.Error(t, err)
+}
@@ -0,0 +1,115 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//OpenLibrary client of the Open Library books API, any server speaking
+//the same protocol works
+type OpenLibrary struct {
+	baseURL string
+	client  *http.Client
+}
+
+func NewOpenLibrary(baseURL string, client *http.Client) *OpenLibrary {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &OpenLibrary{strings.TrimRight(baseURL, "/"), client}
+}
+
+// openLibraryBook record of the jscmd=data answer, keyed by bibkey
+type openLibraryBook struct {
+	Title   string `json:"title"`
+	Authors []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Publishers []struct {
+		Name string `json:"name"`
+	} `json:"publishers"`
+	PublishDate string `json:"publish_date"`
+	Cover       struct {
+		Small  string `json:"small"`
+		Medium string `json:"medium"`
+		Large  string `json:"large"`
+	} `json:"cover"`
+}
+
+// yearPattern publish dates come as "1988", "October 1, 1988" or "1988-10"
+var yearPattern = regexp.MustCompile(`\b[1-9][0-9]{3}\b`)
+
+//Lookup fetch the record of an ISBN-13, ErrNotFound when the catalogue
+//has none and ErrUnavailable on any transport or protocol failure
+func (o *OpenLibrary) Lookup(ctx context.Context, isbn13 string) (Metadata, error) {
+	bibkey := "ISBN:" + isbn13
+
+	query := url.Values{}
+	query.Set("bibkeys", bibkey)
+	query.Set("format", "json")
+	query.Set("jscmd", "data")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, o.baseURL+"/api/books?"+query.Encode(), nil)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := o.client.Do(req)
+	if err != nil {
+		return Metadata{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return Metadata{}, fmt.Errorf("%w: status %v", ErrUnavailable, res.StatusCode)
+	}
+
+	var records map[string]openLibraryBook
+	if err := json.NewDecoder(res.Body).Decode(&records); err != nil {
+		return Metadata{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	// unknown bibkeys are left out of the answer
+	record, ok := records[bibkey]
+	if !ok {
+		return Metadata{}, ErrNotFound
+	}
+	return record.metadata(), nil
+}
+
+func (b openLibraryBook) metadata() Metadata {
+	meta := Metadata{Title: strings.TrimSpace(b.Title)}
+
+	authors := make([]string, 0, len(b.Authors))
+	for _, author := range b.Authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			authors = append(authors, name)
+		}
+	}
+	meta.Author = strings.Join(authors, ", ")
+
+	if len(b.Publishers) > 0 {
+		meta.Publisher = strings.TrimSpace(b.Publishers[0].Name)
+	}
+
+	if year := yearPattern.FindString(b.PublishDate); year != "" {
+		value, _ := strconv.ParseUint(year, 10, 32)
+		meta.Year = uint(value)
+	}
+
+	// the largest cover there is
+	for _, cover := range []string{b.Cover.Large, b.Cover.Medium, b.Cover.Small} {
+		if cover != "" {
+			meta.CoverURL = cover
+			break
+		}
+	}
+	return meta
+}
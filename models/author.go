@@ -0,0 +1,176 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Model Author
+
+//Author person credited on books, NameKey keeps two spellings of the
+//same name from becoming two people. It is cleared on delete so the
+//name can be taken again
+type Author struct {
+	gorm.Model
+	Name    string  `gorm:"size:255"`
+	NameKey *string `gorm:"size:255;uniqueIndex"`
+	Version uint    `gorm:"not null;default:1"`
+}
+
+// authorQuery fields an author list can be filtered and sorted by
+var authorQuery = querySchema{
+	"id":         {column: "id", kind: kindUint, sortable: true},
+	"name":       {column: "name", kind: kindString, sortable: true},
+	"created_at": {column: "created_at", kind: kindTime, sortable: true},
+	"updated_at": {column: "updated_at", kind: kindTime, sortable: true},
+}
+
+func (a Author) sortValue(field string) interface{} {
+	switch field {
+	case "name":
+		return a.Name
+	case "created_at":
+		return a.CreatedAt
+	case "updated_at":
+		return a.UpdatedAt
+	}
+	return a.ID
+}
+
+type GormAuthorModel struct {
+	db *gorm.DB
+}
+
+func NewAuthorModel(db *gorm.DB) *GormAuthorModel {
+	return &GormAuthorModel{db: db}
+}
+
+// Interface Author
+
+type AuthorModel interface {
+	GetAllAuthor(query Query) ([]Author, Page, error)
+	GetAuthor(authorId int) (Author, error)
+	EnsureAuthor(name string) (Author, error)
+	InsertAuthor(Author) (Author, error)
+	EditAuthor(author Author, authorId int) (Author, error)
+	DeleteAuthor(authorId int, version uint) (Author, error)
+}
+
+func (m *GormAuthorModel) GetAllAuthor(query Query) ([]Author, Page, error) {
+	query, err := authorQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var author []Author
+	total, err := authorQuery.gormFind(m.db, &Author{}, &author, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(author) > query.Limit {
+		author = author[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, author[len(author)-1])
+	}
+	return author, page, nil
+}
+
+func (m *GormAuthorModel) GetAuthor(authorId int) (Author, error) {
+	var author Author
+	if err := m.db.First(&author, authorId).Error; err != nil {
+		return author, translateError(err)
+	}
+	return author, nil
+}
+
+//EnsureAuthor the author going by name or a spelling of it, created
+//when there is none yet
+func (m *GormAuthorModel) EnsureAuthor(name string) (Author, error) {
+	var author Author
+	err := m.db.Where("name_key = ?", NameKey(name)).First(&author).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return author, translateError(err)
+	}
+
+	author, err = m.InsertAuthor(Author{Name: name})
+	if errors.Is(err, ErrDuplicate) {
+		// created in the meantime by another request
+		err = translateError(m.db.Where("name_key = ?", NameKey(name)).First(&author).Error)
+	}
+	return author, err
+}
+
+func (m *GormAuthorModel) InsertAuthor(author Author) (Author, error) {
+	key := NameKey(author.Name)
+	author.NameKey = &key
+	author.Version = 1
+	if err := m.db.Save(&author).Error; err != nil {
+		return author, translateError(err)
+	}
+	return author, nil
+}
+
+func (m *GormAuthorModel) EditAuthor(newAuthor Author, authorId int) (Author, error) {
+	var author Author
+	if err := m.db.First(&author, authorId).Error; err != nil {
+		return author, translateError(err)
+	}
+
+	if err := checkVersion(author.Version, newAuthor.Version); err != nil {
+		return author, err
+	}
+
+	// the update only applies to the version read above
+	result := m.db.Model(&author).Where("version = ?", author.Version).Updates(map[string]interface{}{
+		"name":     newAuthor.Name,
+		"name_key": NameKey(newAuthor.Name),
+		"version":  author.Version + 1,
+	})
+	if result.Error != nil {
+		return author, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return author, ErrConflict
+	}
+
+	return m.GetAuthor(authorId)
+}
+
+func (m *GormAuthorModel) DeleteAuthor(authorId int, version uint) (Author, error) {
+	var author Author
+	if err := m.db.First(&author, authorId).Error; err != nil {
+		return author, translateError(err)
+	}
+
+	if err := checkVersion(author.Version, version); err != nil {
+		return author, err
+	}
+
+	// books keep pointing at their authors, unlink them first
+	var books int64
+	err := m.db.Table("book_authors").
+		Joins("JOIN books ON books.id = book_authors.book_id AND books.deleted_at IS NULL").
+		Where("book_authors.author_id = ?", author.ID).
+		Count(&books).Error
+	if err != nil {
+		return author, err
+	}
+	if books > 0 {
+		return author, ErrInUse
+	}
+
+	result := m.db.Model(&author).Where("version = ?", author.Version).Updates(map[string]interface{}{
+		"name_key":   nil,
+		"deleted_at": time.Now(),
+	})
+	if result.Error != nil {
+		return author, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return author, ErrConflict
+	}
+	return author, nil
+}
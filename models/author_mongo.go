@@ -0,0 +1,208 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+const authorCollection = "authors"
+
+// mongoAuthor document layout of an Author
+type mongoAuthor struct {
+	ID        uint       `bson:"_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at"`
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+	Name      string     `bson:"name"`
+	NameKey   *string    `bson:"name_key,omitempty"`
+	Version   uint       `bson:"version"`
+}
+
+func (d mongoAuthor) toAuthor() Author {
+	author := Author{
+		Name:    d.Name,
+		NameKey: d.NameKey,
+		Version: d.Version,
+	}
+	author.ID = d.ID
+	author.CreatedAt = d.CreatedAt
+	author.UpdatedAt = d.UpdatedAt
+	if d.DeletedAt != nil {
+		author.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+	}
+	return author
+}
+
+type MongoAuthorModel struct {
+	db *mongo.Database
+}
+
+func NewMongoAuthorModel(db *mongo.Database) *MongoAuthorModel {
+	return &MongoAuthorModel{db: db}
+}
+
+func (m *MongoAuthorModel) collection() *mongo.Collection {
+	return m.db.Collection(authorCollection)
+}
+
+func (m *MongoAuthorModel) GetAllAuthor(query Query) ([]Author, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := authorQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoAuthor
+	total, err := authorQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	author := make([]Author, 0, len(documents))
+	for _, document := range documents {
+		author = append(author, document.toAuthor())
+	}
+
+	page := query.page(total)
+	if len(author) > query.Limit {
+		author = author[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, author[len(author)-1])
+	}
+	return author, page, nil
+}
+
+func (m *MongoAuthorModel) GetAuthor(authorId int) (Author, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoAuthor
+	if err := m.collection().FindOne(ctx, withID(authorId)).Decode(&document); err != nil {
+		return Author{}, translateError(err)
+	}
+	return document.toAuthor(), nil
+}
+
+func (m *MongoAuthorModel) EnsureAuthor(name string) (Author, error) {
+	find := func() (Author, error) {
+		ctx, cancel := mongoContext()
+		defer cancel()
+
+		var document mongoAuthor
+		err := m.collection().FindOne(ctx, bson.M{"name_key": NameKey(name)}).Decode(&document)
+		return document.toAuthor(), err
+	}
+
+	author, err := find()
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return author, translateError(err)
+	}
+
+	author, err = m.InsertAuthor(Author{Name: name})
+	if errors.Is(err, ErrDuplicate) {
+		// created in the meantime by another request
+		author, err = find()
+		err = translateError(err)
+	}
+	return author, err
+}
+
+func (m *MongoAuthorModel) InsertAuthor(author Author) (Author, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if author.ID == 0 {
+		id, err := nextSequence(ctx, m.db, authorCollection)
+		if err != nil {
+			return author, err
+		}
+		author.ID = id
+	}
+
+	now := time.Now()
+	key := NameKey(author.Name)
+	author.CreatedAt = now
+	author.UpdatedAt = now
+	author.NameKey = &key
+	author.Version = 1
+
+	document := mongoAuthor{
+		ID:        author.ID,
+		CreatedAt: author.CreatedAt,
+		UpdatedAt: author.UpdatedAt,
+		Name:      author.Name,
+		NameKey:   author.NameKey,
+		Version:   author.Version,
+	}
+
+	if _, err := m.collection().InsertOne(ctx, document); err != nil {
+		return author, translateError(err)
+	}
+	return author, nil
+}
+
+func (m *MongoAuthorModel) EditAuthor(newAuthor Author, authorId int) (Author, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":       newAuthor.Name,
+			"name_key":   NameKey(newAuthor.Name),
+			"updated_at": time.Now(),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	var document mongoAuthor
+	err := m.collection().FindOneAndUpdate(ctx, withVersion(authorId, newAuthor.Version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Author{}, versionError(ctx, m.collection(), authorId, newAuthor.Version)
+	}
+	if err != nil {
+		return Author{}, translateError(err)
+	}
+	return document.toAuthor(), nil
+}
+
+func (m *MongoAuthorModel) DeleteAuthor(authorId int, version uint) (Author, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// books keep pointing at their authors, unlink them first
+	books, err := m.db.Collection(bookCollection).CountDocuments(ctx, bson.M{
+		"authors.author_id": authorId,
+		"deleted_at":        bson.M{"$exists": false},
+	})
+	if err != nil {
+		return Author{}, err
+	}
+	if books > 0 {
+		return Author{}, ErrInUse
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"deleted_at": time.Now()},
+		"$unset": bson.M{"name_key": ""},
+	}
+
+	var document mongoAuthor
+	err = m.collection().FindOneAndUpdate(ctx, withVersion(authorId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Author{}, versionError(ctx, m.collection(), authorId, version)
+	}
+	if err != nil {
+		return Author{}, translateError(err)
+	}
+	return document.toAuthor(), nil
+}
@@ -1,6 +1,13 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
 
 // Model Customer
 
@@ -10,10 +17,72 @@ type Book struct {
 	Author string
 	//Gender   string `sql:"type:ENUM('male', 'female')"`
 	Publisher string
+	Year      uint    `gorm:"index"`
+	Language  string  `gorm:"size:2;index"`
+	ISBN      *string `gorm:"size:13;uniqueIndex"`
+	CoverURL  string  `gorm:"size:512"`
+	Version   uint    `gorm:"not null;default:1"`
+
+	// PublisherID and Authors the linked publisher and authors in credit
+	// order, Publisher and Author are the names as printed. WorkID the
+	// work this book is an edition of. ContributorsLinked the by-line
+	// and publisher were linked once, they are never linked again
+	PublisherID        *uint         `gorm:"index"`
+	Authors            []Contributor `gorm:"-"`
+	WorkID             *uint         `gorm:"index"`
+	ContributorsLinked bool          `gorm:"not null;default:false;index" json:"-"`
+
+	// Tags and Categories free-form tags and subject categories, they are
+	// attached and detached on their own rather than edited with the book
+	Tags       []string   `gorm:"-"`
+	Categories []Category `gorm:"-"`
+
+	// Copies the physical copies of the book per status, read only
+	Copies CopyCounts `gorm:"-"`
 
 	Token string `gorm:"<-:false"`
 }
 
+// bookQuery fields a book list can be filtered and sorted by
+var bookQuery = querySchema{
+	"id":           {column: "id", kind: kindUint, sortable: true},
+	"title":        {column: "title", kind: kindString, sortable: true},
+	"author":       {column: "author", kind: kindString, sortable: true, facet: true},
+	"publisher":    {column: "publisher", kind: kindString, sortable: true, facet: true},
+	"year":         {column: "year", kind: kindUint, sortable: true, facet: true},
+	"language":     {column: "language", kind: kindString, sortable: true, facet: true},
+	"created_at":   {column: "created_at", kind: kindTime, sortable: true},
+	"updated_at":   {column: "updated_at", kind: kindTime, sortable: true},
+	"publisher_id": {column: "publisher_id", kind: kindUint},
+	"work_id":      {column: "work_id", kind: kindUint},
+	"tag":          {column: "tags", kind: kindString, facet: true, link: "id IN (SELECT book_id FROM book_tags WHERE tag IN ?)", linkFacet: "SELECT book_id AS id, tag AS value FROM book_tags"},
+	"category_id":  {column: "categories", kind: kindUint, link: "id IN (SELECT book_id FROM book_categories WHERE category_id IN ?)"},
+	"author_id":    {column: "authors.author_id", kind: kindUint, link: "id IN (SELECT book_id FROM book_authors WHERE author_id IN ?)"},
+
+	// books the contributor migration is still to link
+	"contributors_linked": {column: "contributors_linked", kind: kindBool},
+}
+
+func (b Book) sortValue(field string) interface{} {
+	switch field {
+	case "title":
+		return b.Title
+	case "author":
+		return b.Author
+	case "publisher":
+		return b.Publisher
+	case "year":
+		return b.Year
+	case "language":
+		return b.Language
+	case "created_at":
+		return b.CreatedAt
+	case "updated_at":
+		return b.UpdatedAt
+	}
+	return b.ID
+}
+
 type GormBookModel struct {
 	db *gorm.DB
 }
@@ -25,59 +94,479 @@ func NewBookModel(db *gorm.DB) *GormBookModel {
 // Interface Customer
 
 type BookModel interface {
-	GetAllBook() ([]Book, error)
+	GetAllBook(query Query) ([]Book, Page, error)
+	GetBookFacets(query Query, fields []string, limit int) (Facets, error)
 	GetBook(bookId int) (Book, error)
+	GetBookByISBN(isbn string) (Book, error)
+	GetBookEditions(bookId int, query Query) ([]Book, Page, error)
 	InsertBook(Book) (Book, error)
 	EditBook(book Book, bookId int) (Book, error)
-	DeleteBook(bookId int) (Book, error)
+	AttachBook(bookId int, version uint, tags []string, categoryIds []uint) (Book, error)
+	DetachBook(bookId int, version uint, tags []string, categoryIds []uint) (Book, error)
+	DeleteBook(bookId int, version uint) (Book, error)
+	MarkContributorsLinked(bookIds []uint) error
 }
 
-func (m *GormBookModel) GetAllBook() ([]Book, error) {
+func (m *GormBookModel) GetAllBook(query Query) ([]Book, Page, error) {
+	query, err := subtreeFilters(query, func(categoryId interface{}) ([]interface{}, error) {
+		return gormSubtree(m.db, categoryId)
+	})
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query, err = bookQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
 	var book []Book
-	if err := m.db.Find(&book).Error; err != nil {
+	total, err := bookQuery.gormFind(m.db, &Book{}, &book, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(book) > query.Limit {
+		book = book[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, book[len(book)-1])
+	}
+	return book, page, gormLinks(m.db, book)
+}
+
+func (m *GormBookModel) GetBookFacets(query Query, fields []string, limit int) (Facets, error) {
+	query, err := subtreeFilters(query, func(categoryId interface{}) ([]interface{}, error) {
+		return gormSubtree(m.db, categoryId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query, err = bookQuery.normalize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, limit, err = bookQuery.facetFields(fields, limit)
+	if err != nil {
 		return nil, err
 	}
-	return book, nil
+
+	return bookQuery.gormFacets(m.db, &Book{}, query, fields, limit)
 }
 
 func (m *GormBookModel) GetBook(bookId int) (Book, error) {
 	var book Book
-	if err := m.db.Find(&book, bookId).Error; err != nil {
-		return book, err
+	if err := m.db.First(&book, bookId).Error; err != nil {
+		return book, translateError(err)
 	}
-	return book, nil
+	books := []Book{book}
+	err := gormLinks(m.db, books)
+	return books[0], err
 }
 
-func (m *GormBookModel) InsertBook(book Book) (Book, error) {
-	if err := m.db.Save(&book).Error; err != nil {
-		return book, err
+func (m *GormBookModel) GetBookByISBN(isbn string) (Book, error) {
+	var book Book
+	if err := m.db.Where("isbn = ?", isbn).First(&book).Error; err != nil {
+		return book, translateError(err)
 	}
-	return book, nil
+	books := []Book{book}
+	err := gormLinks(m.db, books)
+	return books[0], err
+}
+
+func (m *GormBookModel) GetBookEditions(bookId int, query Query) ([]Book, Page, error) {
+	book, err := m.GetBook(bookId)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	return editions(m, book, query)
+}
+
+func (m *GormBookModel) InsertBook(book Book) (Book, error) {
+	book.Version = 1
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := gormCheckWork(tx, book.WorkID); err != nil {
+			return err
+		}
+		if err := tx.Save(&book).Error; err != nil {
+			return err
+		}
+		if err := gormLinkTags(tx, book.ID, book.Tags); err != nil {
+			return err
+		}
+		if err := gormLinkCategories(tx, book.ID, categoryIDs(book.Categories)); err != nil {
+			return err
+		}
+		return gormLinkAuthors(tx, book.ID, book.Authors)
+	})
+	return book, translateError(err)
 }
 
 func (m *GormBookModel) EditBook(newBook Book, bookId int) (Book, error) {
 	var book Book
-	if err := m.db.Find(&book, "id=?", bookId).Error; err != nil {
+	if err := m.db.First(&book, bookId).Error; err != nil {
+		return book, translateError(err)
+	}
+
+	if err := checkVersion(book.Version, newBook.Version); err != nil {
 		return book, err
 	}
 
-	book.Title = newBook.Title
-	book.Author = newBook.Author
-	book.Publisher = newBook.Publisher
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := gormCheckWork(tx, newBook.WorkID); err != nil {
+			return err
+		}
+
+		// the update only applies to the version read above
+		result := tx.Model(&book).Where("version = ?", book.Version).Updates(map[string]interface{}{
+			"title":        newBook.Title,
+			"author":       newBook.Author,
+			"publisher":    newBook.Publisher,
+			"publisher_id": newBook.PublisherID,
+			"work_id":      newBook.WorkID,
+			"year":         newBook.Year,
+			"language":     newBook.Language,
+			"isbn":         newBook.ISBN,
+			"cover_url":    newBook.CoverURL,
+			"version":      book.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		if err := tx.Where("book_id = ?", book.ID).Delete(&BookAuthor{}).Error; err != nil {
+			return err
+		}
+		return gormLinkAuthors(tx, book.ID, newBook.Authors)
+	})
+	if err != nil {
+		return book, translateError(err)
+	}
+
+	return m.GetBook(bookId)
+}
+
+func (m *GormBookModel) AttachBook(bookId int, version uint, tags []string, categoryIds []uint) (Book, error) {
+	return m.relink(bookId, version, func(tx *gorm.DB, book Book) error {
+		if err := gormLinkTags(tx, book.ID, tags); err != nil {
+			return err
+		}
+		return gormLinkCategories(tx, book.ID, categoryIds)
+	})
+}
+
+func (m *GormBookModel) DetachBook(bookId int, version uint, tags []string, categoryIds []uint) (Book, error) {
+	return m.relink(bookId, version, func(tx *gorm.DB, book Book) error {
+		if len(tags) > 0 {
+			if err := tx.Where("book_id = ? AND tag IN ?", book.ID, tags).Delete(&BookTag{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(categoryIds) > 0 {
+			if err := tx.Where("book_id = ? AND category_id IN ?", book.ID, categoryIds).Delete(&BookCategory{}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
 
-	if err := m.db.Save(&book).Error; err != nil {
+// relink change the tags or categories of a book and move it on to its
+// next version, like any other edit
+func (m *GormBookModel) relink(bookId int, version uint, change func(tx *gorm.DB, book Book) error) (Book, error) {
+	var book Book
+	if err := m.db.First(&book, bookId).Error; err != nil {
+		return book, translateError(err)
+	}
+
+	if err := checkVersion(book.Version, version); err != nil {
 		return book, err
 	}
-	return book, nil
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		// the update only applies to the version read above
+		result := tx.Model(&book).Where("version = ?", book.Version).Update("version", book.Version+1)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+		return change(tx, book)
+	})
+	if err != nil {
+		return book, translateError(err)
+	}
+
+	return m.GetBook(bookId)
 }
 
-func (m *GormBookModel) DeleteBook(bookId int) (Book, error) {
+func (m *GormBookModel) DeleteBook(bookId int, version uint) (Book, error) {
 	var book Book
-	if err := m.db.Find(&book, "id=?", bookId).Error; err != nil {
+	if err := m.db.First(&book, bookId).Error; err != nil {
+		return book, translateError(err)
+	}
+
+	if err := checkVersion(book.Version, version); err != nil {
 		return book, err
 	}
-	if err := m.db.Delete(&book).Error; err != nil {
+
+	// copies keep pointing at their book, withdraw them first
+	var copies int64
+	if err := m.db.Model(&Copy{}).Where("book_id = ?", book.ID).Count(&copies).Error; err != nil {
 		return book, err
 	}
-	return book, nil
+	if copies > 0 {
+		return book, ErrInUse
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		// the isbn is free for a new record of the book
+		result := tx.Model(&book).Where("version = ?", book.Version).Updates(map[string]interface{}{
+			"isbn":       nil,
+			"deleted_at": time.Now(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		// the links go with the book, nothing keeps pointing at it
+		for _, link := range []interface{}{&BookAuthor{}, &BookTag{}, &BookCategory{}} {
+			if err := tx.Where("book_id = ?", book.ID).Delete(link).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return book, translateError(err)
+}
+
+//MarkContributorsLinked flag the books as linked to their authors and
+//publisher, the version stays as it is
+func (m *GormBookModel) MarkContributorsLinked(bookIds []uint) error {
+	if len(bookIds) == 0 {
+		return nil
+	}
+	return m.db.Model(&Book{}).Where("id IN ?", bookIds).UpdateColumn("contributors_linked", true).Error
+}
+
+// editions list the other editions of the work book belongs to, a book
+// without a work has none
+func editions(m BookModel, book Book, query Query) ([]Book, Page, error) {
+	if book.WorkID == nil {
+		query, err := bookQuery.normalize(query)
+		if err != nil {
+			return nil, Page{}, err
+		}
+		return []Book{}, query.page(0), nil
+	}
+
+	filters := make([]Filter, 0, len(query.Filters)+2)
+	filters = append(filters, query.Filters...)
+	query.Filters = append(filters,
+		Filter{Field: "work_id", Op: FilterEqual, Value: *book.WorkID},
+		Filter{Field: "id", Op: FilterNotEqual, Value: book.ID},
+	)
+	return m.GetAllBook(query)
+}
+
+// gormLinkAuthors link a book to its contributors, every one of them
+// has to be a live author
+func gormLinkAuthors(tx *gorm.DB, bookId uint, contributors []Contributor) error {
+	if len(contributors) == 0 {
+		return nil
+	}
+
+	ids := contributorIDs(contributors)
+	var authors int64
+	if err := tx.Model(&Author{}).Where("id IN ?", ids).Count(&authors).Error; err != nil {
+		return err
+	}
+	if authors != int64(len(ids)) {
+		return fmt.Errorf("author %w", ErrNotFound)
+	}
+
+	links := make([]BookAuthor, 0, len(contributors))
+	for i, contributor := range contributors {
+		links = append(links, BookAuthor{
+			BookID:   bookId,
+			AuthorID: contributor.AuthorID,
+			Role:     contributor.Role,
+			Position: uint(i),
+		})
+	}
+	return tx.Create(&links).Error
+}
+
+// subtreeFilters turn every category filter into one matching the
+// category and all of its descendants, an unknown category is left for
+// the filter to match nothing
+func subtreeFilters(query Query, subtree func(categoryId interface{}) ([]interface{}, error)) (Query, error) {
+	filters := make([]Filter, 0, len(query.Filters))
+	for _, filter := range query.Filters {
+		if filter.Field == "category_id" && filter.Op == FilterEqual {
+			categoryId, err := bookQuery["category_id"].value(filter.Value)
+			if err != nil {
+				return query, fmt.Errorf("%w: filter %v %v", ErrInvalidQuery, filter.Field, err)
+			}
+
+			ids, err := subtree(categoryId)
+			if err != nil && !errors.Is(err, ErrNotFound) {
+				return query, err
+			}
+			if err == nil {
+				filter = Filter{Field: filter.Field, Op: FilterIn, Value: ids}
+			}
+		}
+		filters = append(filters, filter)
+	}
+	query.Filters = filters
+	return query, nil
+}
+
+// gormLinkTags tag a book, tags it already has are kept as they are
+func gormLinkTags(tx *gorm.DB, bookId uint, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	links := make([]BookTag, 0, len(tags))
+	for _, tag := range tags {
+		links = append(links, BookTag{BookID: bookId, Tag: tag})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+// gormLinkCategories file a book under live categories, categories it is
+// already filed under are kept as they are
+func gormLinkCategories(tx *gorm.DB, bookId uint, categoryIds []uint) error {
+	if len(categoryIds) == 0 {
+		return nil
+	}
+
+	categoryIds = uniqueIDs(categoryIds)
+	var categories int64
+	if err := tx.Model(&Category{}).Where("id IN ?", categoryIds).Count(&categories).Error; err != nil {
+		return err
+	}
+	if categories != int64(len(categoryIds)) {
+		return fmt.Errorf("category %w", ErrNotFound)
+	}
+
+	links := make([]BookCategory, 0, len(categoryIds))
+	for _, categoryId := range categoryIds {
+		links = append(links, BookCategory{BookID: bookId, CategoryID: categoryId})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
+
+// gormLinks fill in the authors, tags and categories linked to the books
+// and count their copies
+func gormLinks(db *gorm.DB, books []Book) error {
+	if err := gormContributors(db, books); err != nil {
+		return err
+	}
+	if err := gormTags(db, books); err != nil {
+		return err
+	}
+	if err := gormCategories(db, books); err != nil {
+		return err
+	}
+	return gormCopyCounts(db, books)
+}
+
+// bookIndex ids of the books and the position of each id
+func bookIndex(books []Book) ([]uint, map[uint]int) {
+	ids := make([]uint, 0, len(books))
+	index := make(map[uint]int, len(books))
+	for i, book := range books {
+		ids = append(ids, book.ID)
+		index[book.ID] = i
+	}
+	return ids, index
+}
+
+// gormContributors fill in the linked authors of the books
+func gormContributors(db *gorm.DB, books []Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids, index := bookIndex(books)
+
+	var rows []struct {
+		BookID uint
+		Contributor
+	}
+	err := db.Table("book_authors").
+		Select("book_authors.book_id, book_authors.author_id, authors.name, book_authors.role").
+		Joins("JOIN authors ON authors.id = book_authors.author_id AND authors.deleted_at IS NULL").
+		Where("book_authors.book_id IN ?", ids).
+		Order("book_authors.book_id, book_authors.position").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		i := index[row.BookID]
+		books[i].Authors = append(books[i].Authors, row.Contributor)
+	}
+	return nil
+}
+
+// gormTags fill in the tags of the books in alphabetical order
+func gormTags(db *gorm.DB, books []Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids, index := bookIndex(books)
+
+	var links []BookTag
+	if err := db.Where("book_id IN ?", ids).Order("book_id, tag").Find(&links).Error; err != nil {
+		return err
+	}
+
+	for _, link := range links {
+		i := index[link.BookID]
+		books[i].Tags = append(books[i].Tags, link.Tag)
+	}
+	return nil
+}
+
+// gormCategories fill in the live categories of the books in tree order
+func gormCategories(db *gorm.DB, books []Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids, index := bookIndex(books)
+
+	var rows []struct {
+		BookID uint
+		Category
+	}
+	err := db.Table("book_categories").
+		Select("book_categories.book_id, categories.*").
+		Joins("JOIN categories ON categories.id = book_categories.category_id").
+		Where("book_categories.book_id IN ? AND categories.deleted_at IS NULL", ids).
+		Order("book_categories.book_id, categories.path").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		i := index[row.BookID]
+		books[i].Categories = append(books[i].Categories, row.Category)
+	}
+	return nil
 }
@@ -0,0 +1,547 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+const bookCollection = "books"
+
+// mongoBook document layout of a Book
+type mongoBook struct {
+	ID        uint       `bson:"_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at"`
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+	Title     string     `bson:"title"`
+	Author    string     `bson:"author"`
+	Publisher string     `bson:"publisher"`
+	Year      uint       `bson:"year"`
+	Language  string     `bson:"language"`
+	ISBN      *string    `bson:"isbn,omitempty"`
+	CoverURL  string     `bson:"cover_url"`
+	Version   uint       `bson:"version"`
+
+	PublisherID        *uint              `bson:"publisher_id,omitempty"`
+	Authors            []mongoContributor `bson:"authors,omitempty"`
+	WorkID             *uint              `bson:"work_id,omitempty"`
+	Tags               []string           `bson:"tags,omitempty"`
+	Categories         []uint             `bson:"categories,omitempty"`
+	ContributorsLinked bool               `bson:"contributors_linked,omitempty"`
+}
+
+// mongoContributor link of a book document to an author, the array
+// order is the credit order
+type mongoContributor struct {
+	AuthorID uint   `bson:"author_id"`
+	Role     string `bson:"role"`
+}
+
+func toMongoContributors(contributors []Contributor) []mongoContributor {
+	documents := make([]mongoContributor, 0, len(contributors))
+	for _, contributor := range contributors {
+		documents = append(documents, mongoContributor{contributor.AuthorID, contributor.Role})
+	}
+	return documents
+}
+
+func (d mongoBook) toBook() Book {
+	book := Book{
+		Title:     d.Title,
+		Author:    d.Author,
+		Publisher: d.Publisher,
+		Year:      d.Year,
+		Language:  d.Language,
+		ISBN:      d.ISBN,
+		CoverURL:  d.CoverURL,
+		Version:   d.Version,
+
+		PublisherID:        d.PublisherID,
+		WorkID:             d.WorkID,
+		Tags:               d.Tags,
+		ContributorsLinked: d.ContributorsLinked,
+	}
+	for _, contributor := range d.Authors {
+		book.Authors = append(book.Authors, Contributor{AuthorID: contributor.AuthorID, Role: contributor.Role})
+	}
+	for _, categoryId := range d.Categories {
+		category := Category{}
+		category.ID = categoryId
+		book.Categories = append(book.Categories, category)
+	}
+	book.ID = d.ID
+	book.CreatedAt = d.CreatedAt
+	book.UpdatedAt = d.UpdatedAt
+	if d.DeletedAt != nil {
+		book.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+	}
+	return book
+}
+
+type MongoBookModel struct {
+	db *mongo.Database
+}
+
+func NewMongoBookModel(db *mongo.Database) *MongoBookModel {
+	return &MongoBookModel{db: db}
+}
+
+func (m *MongoBookModel) collection() *mongo.Collection {
+	return m.db.Collection(bookCollection)
+}
+
+func (m *MongoBookModel) GetAllBook(query Query) ([]Book, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := subtreeFilters(query, func(categoryId interface{}) ([]interface{}, error) {
+		return mongoSubtree(ctx, m.db, categoryId)
+	})
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	query, err = bookQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoBook
+	total, err := bookQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	book := make([]Book, 0, len(documents))
+	for _, document := range documents {
+		book = append(book, document.toBook())
+	}
+
+	page := query.page(total)
+	if len(book) > query.Limit {
+		book = book[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, book[len(book)-1])
+	}
+	return book, page, m.links(ctx, book)
+}
+
+func (m *MongoBookModel) GetBookFacets(query Query, fields []string, limit int) (Facets, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := subtreeFilters(query, func(categoryId interface{}) ([]interface{}, error) {
+		return mongoSubtree(ctx, m.db, categoryId)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	query, err = bookQuery.normalize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, limit, err = bookQuery.facetFields(fields, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return bookQuery.mongoFacets(ctx, m.collection(), query, fields, limit)
+}
+
+func (m *MongoBookModel) GetBook(bookId int) (Book, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoBook
+	if err := m.collection().FindOne(ctx, withID(bookId)).Decode(&document); err != nil {
+		return Book{}, translateError(err)
+	}
+	books := []Book{document.toBook()}
+	err := m.links(ctx, books)
+	return books[0], err
+}
+
+func (m *MongoBookModel) GetBookByISBN(isbn string) (Book, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	filter := bson.M{"isbn": isbn, "deleted_at": bson.M{"$exists": false}}
+
+	var document mongoBook
+	if err := m.collection().FindOne(ctx, filter).Decode(&document); err != nil {
+		return Book{}, translateError(err)
+	}
+	books := []Book{document.toBook()}
+	err := m.links(ctx, books)
+	return books[0], err
+}
+
+func (m *MongoBookModel) GetBookEditions(bookId int, query Query) ([]Book, Page, error) {
+	book, err := m.GetBook(bookId)
+	if err != nil {
+		return nil, Page{}, err
+	}
+	return editions(m, book, query)
+}
+
+func (m *MongoBookModel) InsertBook(book Book) (Book, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if book.ID == 0 {
+		id, err := nextSequence(ctx, m.db, bookCollection)
+		if err != nil {
+			return book, err
+		}
+		book.ID = id
+	}
+
+	if err := m.checkAuthors(ctx, book.Authors); err != nil {
+		return book, err
+	}
+	if err := checkReference(ctx, m.db, workCollection, "work", book.WorkID); err != nil {
+		return book, err
+	}
+	if err := m.checkCategories(ctx, categoryIDs(book.Categories)); err != nil {
+		return book, err
+	}
+
+	now := time.Now()
+	book.CreatedAt = now
+	book.UpdatedAt = now
+	book.Version = 1
+
+	document := mongoBook{
+		ID:        book.ID,
+		CreatedAt: book.CreatedAt,
+		UpdatedAt: book.UpdatedAt,
+		Title:     book.Title,
+		Author:    book.Author,
+		Publisher: book.Publisher,
+		Year:      book.Year,
+		Language:  book.Language,
+		ISBN:      book.ISBN,
+		CoverURL:  book.CoverURL,
+		Version:   book.Version,
+
+		PublisherID:        book.PublisherID,
+		Authors:            toMongoContributors(book.Authors),
+		WorkID:             book.WorkID,
+		Tags:               book.Tags,
+		Categories:         categoryIDs(book.Categories),
+		ContributorsLinked: book.ContributorsLinked,
+	}
+
+	if _, err := m.collection().InsertOne(ctx, document); err != nil {
+		return book, translateError(err)
+	}
+	return book, nil
+}
+
+func (m *MongoBookModel) EditBook(newBook Book, bookId int) (Book, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if err := m.checkAuthors(ctx, newBook.Authors); err != nil {
+		return Book{}, err
+	}
+	if err := checkReference(ctx, m.db, workCollection, "work", newBook.WorkID); err != nil {
+		return Book{}, err
+	}
+
+	fields := bson.M{
+		"title":      newBook.Title,
+		"author":     newBook.Author,
+		"publisher":  newBook.Publisher,
+		"year":       newBook.Year,
+		"language":   newBook.Language,
+		"cover_url":  newBook.CoverURL,
+		"updated_at": time.Now(),
+	}
+	unset := bson.M{}
+
+	// a missing isbn is a missing key, the unique index skips those
+	if newBook.ISBN != nil {
+		fields["isbn"] = *newBook.ISBN
+	} else {
+		unset["isbn"] = ""
+	}
+	if newBook.PublisherID != nil {
+		fields["publisher_id"] = *newBook.PublisherID
+	} else {
+		unset["publisher_id"] = ""
+	}
+	if newBook.WorkID != nil {
+		fields["work_id"] = *newBook.WorkID
+	} else {
+		unset["work_id"] = ""
+	}
+	if len(newBook.Authors) > 0 {
+		fields["authors"] = toMongoContributors(newBook.Authors)
+	} else {
+		unset["authors"] = ""
+	}
+
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var document mongoBook
+	err := m.collection().FindOneAndUpdate(ctx, withVersion(bookId, newBook.Version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Book{}, versionError(ctx, m.collection(), bookId, newBook.Version)
+	}
+	if err != nil {
+		return Book{}, translateError(err)
+	}
+	books := []Book{document.toBook()}
+	err = m.links(ctx, books)
+	return books[0], err
+}
+
+func (m *MongoBookModel) AttachBook(bookId int, version uint, tags []string, categoryIds []uint) (Book, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if err := m.checkCategories(ctx, categoryIds); err != nil {
+		return Book{}, err
+	}
+
+	add := bson.M{}
+	if len(tags) > 0 {
+		add["tags"] = bson.M{"$each": tags}
+	}
+	if len(categoryIds) > 0 {
+		add["categories"] = bson.M{"$each": categoryIds}
+	}
+	return m.relink(ctx, bookId, version, "$addToSet", add)
+}
+
+func (m *MongoBookModel) DetachBook(bookId int, version uint, tags []string, categoryIds []uint) (Book, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	remove := bson.M{}
+	if len(tags) > 0 {
+		remove["tags"] = bson.M{"$in": tags}
+	}
+	if len(categoryIds) > 0 {
+		remove["categories"] = bson.M{"$in": categoryIds}
+	}
+	return m.relink(ctx, bookId, version, "$pull", remove)
+}
+
+// relink apply an array operator to the tags or categories of a book and
+// move it on to its next version, like any other edit
+func (m *MongoBookModel) relink(ctx context.Context, bookId int, version uint, operator string, arrays bson.M) (Book, error) {
+	update := bson.M{
+		"$set": bson.M{"updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}
+	if len(arrays) > 0 {
+		update[operator] = arrays
+	}
+
+	var document mongoBook
+	err := m.collection().FindOneAndUpdate(ctx, withVersion(bookId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Book{}, versionError(ctx, m.collection(), bookId, version)
+	}
+	if err != nil {
+		return Book{}, translateError(err)
+	}
+	books := []Book{document.toBook()}
+	err = m.links(ctx, books)
+	return books[0], err
+}
+
+func (m *MongoBookModel) DeleteBook(bookId int, version uint) (Book, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// copies keep pointing at their book, withdraw them first
+	copies, err := m.db.Collection(copyCollection).CountDocuments(ctx, bson.M{
+		"book_id":    bookId,
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return Book{}, err
+	}
+	if copies > 0 {
+		return Book{}, ErrInUse
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"deleted_at": time.Now()},
+		"$unset": bson.M{"isbn": ""},
+	}
+
+	var document mongoBook
+	err = m.collection().FindOneAndUpdate(ctx, withVersion(bookId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Book{}, versionError(ctx, m.collection(), bookId, version)
+	}
+	if err != nil {
+		return Book{}, translateError(err)
+	}
+	return document.toBook(), nil
+}
+
+func (m *MongoBookModel) MarkContributorsLinked(bookIds []uint) error {
+	if len(bookIds) == 0 {
+		return nil
+	}
+
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	_, err := m.collection().UpdateMany(ctx, bson.M{"_id": bson.M{"$in": bookIds}}, bson.M{
+		"$set": bson.M{"contributors_linked": true},
+	})
+	return err
+}
+
+// checkAuthors make sure every contributor is a live author
+func (m *MongoBookModel) checkAuthors(ctx context.Context, contributors []Contributor) error {
+	if len(contributors) == 0 {
+		return nil
+	}
+
+	ids := contributorIDs(contributors)
+	authors, err := m.db.Collection(authorCollection).CountDocuments(ctx, bson.M{
+		"_id":        bson.M{"$in": ids},
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	if authors != int64(len(ids)) {
+		return fmt.Errorf("author %w", ErrNotFound)
+	}
+	return nil
+}
+
+// checkCategories make sure every category is live
+func (m *MongoBookModel) checkCategories(ctx context.Context, categoryIds []uint) error {
+	if len(categoryIds) == 0 {
+		return nil
+	}
+
+	ids := uniqueIDs(categoryIds)
+	categories, err := m.db.Collection(categoryCollection).CountDocuments(ctx, bson.M{
+		"_id":        bson.M{"$in": ids},
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	if categories != int64(len(ids)) {
+		return fmt.Errorf("category %w", ErrNotFound)
+	}
+	return nil
+}
+
+// links fill in the authors and categories linked to the books and
+// count their copies
+func (m *MongoBookModel) links(ctx context.Context, books []Book) error {
+	if err := m.contributors(ctx, books); err != nil {
+		return err
+	}
+	if err := m.categories(ctx, books); err != nil {
+		return err
+	}
+	return mongoCopyCounts(ctx, m.db, books)
+}
+
+// categories replace the category ids of the books with the live
+// categories in tree order
+func (m *MongoBookModel) categories(ctx context.Context, books []Book) error {
+	var ids []uint
+	for _, book := range books {
+		ids = append(ids, categoryIDs(book.Categories)...)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	cursor, err := m.db.Collection(categoryCollection).Find(ctx, bson.M{
+		"_id":        bson.M{"$in": ids},
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	var documents []mongoCategory
+	if err := cursor.All(ctx, &documents); err != nil {
+		return err
+	}
+
+	categories := make(map[uint]Category, len(documents))
+	for _, document := range documents {
+		categories[document.ID] = document.toCategory()
+	}
+	for i := range books {
+		var live []Category
+		for _, category := range books[i].Categories {
+			if category, ok := categories[category.ID]; ok {
+				live = append(live, category)
+			}
+		}
+		sort.Slice(live, func(a, b int) bool { return live[a].Path < live[b].Path })
+		books[i].Categories = live
+	}
+	return nil
+}
+
+// contributors fill in the names of the authors linked to the books,
+// deleted authors are left out like gormContributors does
+func (m *MongoBookModel) contributors(ctx context.Context, books []Book) error {
+	var ids []uint
+	for _, book := range books {
+		ids = append(ids, contributorIDs(book.Authors)...)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	cursor, err := m.db.Collection(authorCollection).Find(ctx, bson.M{"_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$exists": false}})
+	if err != nil {
+		return err
+	}
+	var authors []mongoAuthor
+	if err := cursor.All(ctx, &authors); err != nil {
+		return err
+	}
+
+	names := make(map[uint]string, len(authors))
+	for _, author := range authors {
+		names[author.ID] = author.Name
+	}
+	for i := range books {
+		live := books[i].Authors[:0]
+		for _, contributor := range books[i].Authors {
+			if name, ok := names[contributor.AuthorID]; ok {
+				contributor.Name = name
+				live = append(live, contributor)
+			}
+		}
+		books[i].Authors = live
+	}
+	return nil
+}
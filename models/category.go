@@ -0,0 +1,297 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+//ErrCategoryCycle a category can not be moved below itself or one of its
+//descendants
+var ErrCategoryCycle = errors.New("category can not be moved below itself")
+
+// Model Category
+
+//Category node of the subject tree, Code is the class number of the
+//scheme the tree follows, if any. Path lists the ids from the root down
+//to the category itself, /1/4/9/, so a subtree is a path prefix
+type Category struct {
+	gorm.Model
+	Name     string `gorm:"size:255"`
+	Code     string `gorm:"size:32;index"`
+	ParentID *uint  `gorm:"index"`
+	Path     string `gorm:"size:255;index"`
+	Version  uint   `gorm:"not null;default:1"`
+}
+
+//BookCategory link of a book to one of its subject categories
+type BookCategory struct {
+	BookID     uint `gorm:"primaryKey"`
+	CategoryID uint `gorm:"primaryKey;index"`
+}
+
+// categoryQuery fields a category list can be filtered and sorted by
+var categoryQuery = querySchema{
+	"id":         {column: "id", kind: kindUint, sortable: true},
+	"name":       {column: "name", kind: kindString, sortable: true},
+	"code":       {column: "code", kind: kindString, sortable: true},
+	"parent_id":  {column: "parent_id", kind: kindUint},
+	"created_at": {column: "created_at", kind: kindTime, sortable: true},
+	"updated_at": {column: "updated_at", kind: kindTime, sortable: true},
+}
+
+func (c Category) sortValue(field string) interface{} {
+	switch field {
+	case "name":
+		return c.Name
+	case "code":
+		return c.Code
+	case "created_at":
+		return c.CreatedAt
+	case "updated_at":
+		return c.UpdatedAt
+	}
+	return c.ID
+}
+
+//AncestorIDs ids of the categories above this one, root first
+func (c Category) AncestorIDs() []uint {
+	var ids []uint
+	for _, part := range strings.Split(strings.Trim(c.Path, "/"), "/") {
+		id, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint(id) == c.ID {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
+
+// categoryIDs ids of the categories, each one once
+func categoryIDs(categories []Category) []uint {
+	ids := make([]uint, 0, len(categories))
+	for _, category := range categories {
+		ids = append(ids, category.ID)
+	}
+	return uniqueIDs(ids)
+}
+
+// uniqueIDs ids without repeats, in the order given
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// categoryPath path of a category below parent, nil for a root
+func categoryPath(parent *Category, id uint) string {
+	path := "/"
+	if parent != nil {
+		path = parent.Path
+	}
+	return fmt.Sprintf("%v%v/", path, id)
+}
+
+type GormCategoryModel struct {
+	db *gorm.DB
+}
+
+func NewCategoryModel(db *gorm.DB) *GormCategoryModel {
+	return &GormCategoryModel{db: db}
+}
+
+// Interface Category
+
+type CategoryModel interface {
+	GetAllCategory(query Query) ([]Category, Page, error)
+	GetCategory(categoryId int) (Category, error)
+	InsertCategory(Category) (Category, error)
+	EditCategory(category Category, categoryId int) (Category, error)
+	DeleteCategory(categoryId int, version uint) (Category, error)
+}
+
+func (m *GormCategoryModel) GetAllCategory(query Query) ([]Category, Page, error) {
+	query, err := categoryQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var category []Category
+	total, err := categoryQuery.gormFind(m.db, &Category{}, &category, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(category) > query.Limit {
+		category = category[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, category[len(category)-1])
+	}
+	return category, page, nil
+}
+
+func (m *GormCategoryModel) GetCategory(categoryId int) (Category, error) {
+	var category Category
+	if err := m.db.First(&category, categoryId).Error; err != nil {
+		return category, translateError(err)
+	}
+	return category, nil
+}
+
+func (m *GormCategoryModel) InsertCategory(category Category) (Category, error) {
+	category.Version = 1
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		parent, err := gormParentCategory(tx, category.ParentID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Save(&category).Error; err != nil {
+			return err
+		}
+
+		// the path ends with the id, only known once saved
+		category.Path = categoryPath(parent, category.ID)
+		return tx.Model(&category).Update("path", category.Path).Error
+	})
+	return category, translateError(err)
+}
+
+func (m *GormCategoryModel) EditCategory(newCategory Category, categoryId int) (Category, error) {
+	var category Category
+	if err := m.db.First(&category, categoryId).Error; err != nil {
+		return category, translateError(err)
+	}
+
+	if err := checkVersion(category.Version, newCategory.Version); err != nil {
+		return category, err
+	}
+
+	// the update below overwrites the path of category
+	oldPath := category.Path
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		parent, err := gormParentCategory(tx, newCategory.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent != nil && strings.HasPrefix(parent.Path, oldPath) {
+			return ErrCategoryCycle
+		}
+		path := categoryPath(parent, category.ID)
+
+		// the update only applies to the version read above
+		result := tx.Model(&category).Where("version = ?", category.Version).Updates(map[string]interface{}{
+			"name":      newCategory.Name,
+			"code":      newCategory.Code,
+			"parent_id": newCategory.ParentID,
+			"path":      path,
+			"version":   category.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		if path == oldPath {
+			return nil
+		}
+
+		// a move takes the whole subtree along
+		var descendants []Category
+		if err := tx.Where("path LIKE ? AND id <> ?", oldPath+"%", category.ID).Find(&descendants).Error; err != nil {
+			return err
+		}
+		for _, descendant := range descendants {
+			moved := path + strings.TrimPrefix(descendant.Path, oldPath)
+			if err := tx.Model(&descendant).Update("path", moved).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return category, translateError(err)
+	}
+
+	return m.GetCategory(categoryId)
+}
+
+func (m *GormCategoryModel) DeleteCategory(categoryId int, version uint) (Category, error) {
+	var category Category
+	if err := m.db.First(&category, categoryId).Error; err != nil {
+		return category, translateError(err)
+	}
+
+	if err := checkVersion(category.Version, version); err != nil {
+		return category, err
+	}
+
+	// subcategories and books keep pointing at the category, move them first
+	var children int64
+	if err := m.db.Model(&Category{}).Where("parent_id = ?", category.ID).Count(&children).Error; err != nil {
+		return category, err
+	}
+	var books int64
+	err := m.db.Model(&Book{}).
+		Where("id IN (SELECT book_id FROM book_categories WHERE category_id = ?)", category.ID).
+		Count(&books).Error
+	if err != nil {
+		return category, err
+	}
+	if children > 0 || books > 0 {
+		return category, ErrInUse
+	}
+
+	result := m.db.Where("version = ?", category.Version).Delete(&category)
+	if result.Error != nil {
+		return category, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return category, ErrConflict
+	}
+	return category, nil
+}
+
+// gormParentCategory the live parent a category is put below, nil for a
+// root
+func gormParentCategory(db *gorm.DB, parentId *uint) (*Category, error) {
+	if parentId == nil {
+		return nil, nil
+	}
+
+	var parent Category
+	if err := db.First(&parent, *parentId).Error; err != nil {
+		return nil, fmt.Errorf("parent category %w", translateError(err))
+	}
+	return &parent, nil
+}
+
+// gormSubtree ids of the live categories at or below a category
+func gormSubtree(db *gorm.DB, categoryId interface{}) ([]interface{}, error) {
+	var category Category
+	if err := db.First(&category, categoryId).Error; err != nil {
+		return nil, translateError(err)
+	}
+
+	var ids []uint
+	if err := db.Model(&Category{}).Where("path LIKE ?", category.Path+"%").Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	values := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id)
+	}
+	return values, nil
+}
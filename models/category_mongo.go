@@ -0,0 +1,296 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+const categoryCollection = "categories"
+
+// mongoCategory document layout of a Category
+type mongoCategory struct {
+	ID        uint       `bson:"_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at"`
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+	Name      string     `bson:"name"`
+	Code      string     `bson:"code"`
+	ParentID  *uint      `bson:"parent_id,omitempty"`
+	Path      string     `bson:"path"`
+	Version   uint       `bson:"version"`
+}
+
+func (d mongoCategory) toCategory() Category {
+	category := Category{
+		Name:     d.Name,
+		Code:     d.Code,
+		ParentID: d.ParentID,
+		Path:     d.Path,
+		Version:  d.Version,
+	}
+	category.ID = d.ID
+	category.CreatedAt = d.CreatedAt
+	category.UpdatedAt = d.UpdatedAt
+	if d.DeletedAt != nil {
+		category.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+	}
+	return category
+}
+
+type MongoCategoryModel struct {
+	db *mongo.Database
+}
+
+func NewMongoCategoryModel(db *mongo.Database) *MongoCategoryModel {
+	return &MongoCategoryModel{db: db}
+}
+
+func (m *MongoCategoryModel) collection() *mongo.Collection {
+	return m.db.Collection(categoryCollection)
+}
+
+func (m *MongoCategoryModel) GetAllCategory(query Query) ([]Category, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := categoryQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoCategory
+	total, err := categoryQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	category := make([]Category, 0, len(documents))
+	for _, document := range documents {
+		category = append(category, document.toCategory())
+	}
+
+	page := query.page(total)
+	if len(category) > query.Limit {
+		category = category[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, category[len(category)-1])
+	}
+	return category, page, nil
+}
+
+func (m *MongoCategoryModel) GetCategory(categoryId int) (Category, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoCategory
+	if err := m.collection().FindOne(ctx, withID(categoryId)).Decode(&document); err != nil {
+		return Category{}, translateError(err)
+	}
+	return document.toCategory(), nil
+}
+
+func (m *MongoCategoryModel) InsertCategory(category Category) (Category, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	parent, err := m.parent(ctx, category.ParentID)
+	if err != nil {
+		return category, err
+	}
+
+	if category.ID == 0 {
+		id, err := nextSequence(ctx, m.db, categoryCollection)
+		if err != nil {
+			return category, err
+		}
+		category.ID = id
+	}
+
+	now := time.Now()
+	category.CreatedAt = now
+	category.UpdatedAt = now
+	category.Path = categoryPath(parent, category.ID)
+	category.Version = 1
+
+	document := mongoCategory{
+		ID:        category.ID,
+		CreatedAt: category.CreatedAt,
+		UpdatedAt: category.UpdatedAt,
+		Name:      category.Name,
+		Code:      category.Code,
+		ParentID:  category.ParentID,
+		Path:      category.Path,
+		Version:   category.Version,
+	}
+
+	if _, err := m.collection().InsertOne(ctx, document); err != nil {
+		return category, translateError(err)
+	}
+	return category, nil
+}
+
+func (m *MongoCategoryModel) EditCategory(newCategory Category, categoryId int) (Category, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var current mongoCategory
+	if err := m.collection().FindOne(ctx, withID(categoryId)).Decode(&current); err != nil {
+		return Category{}, translateError(err)
+	}
+
+	parent, err := m.parent(ctx, newCategory.ParentID)
+	if err != nil {
+		return Category{}, err
+	}
+	if parent != nil && strings.HasPrefix(parent.Path, current.Path) {
+		return Category{}, ErrCategoryCycle
+	}
+	path := categoryPath(parent, current.ID)
+
+	fields := bson.M{
+		"name":       newCategory.Name,
+		"code":       newCategory.Code,
+		"path":       path,
+		"updated_at": time.Now(),
+	}
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+
+	if newCategory.ParentID != nil {
+		fields["parent_id"] = *newCategory.ParentID
+	} else {
+		update["$unset"] = bson.M{"parent_id": ""}
+	}
+
+	// the path read above only holds while the version does
+	version := newCategory.Version
+	if version == 0 {
+		version = current.Version
+	}
+
+	var document mongoCategory
+	err = m.collection().FindOneAndUpdate(ctx, withVersion(categoryId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if newCategory.Version == 0 {
+			return Category{}, ErrConflict
+		}
+		return Category{}, versionError(ctx, m.collection(), categoryId, version)
+	}
+	if err != nil {
+		return Category{}, translateError(err)
+	}
+
+	if path != current.Path {
+		// a move takes the whole subtree along
+		cursor, err := m.collection().Find(ctx, bson.M{"path": subtreePattern(current.Path), "_id": bson.M{"$ne": current.ID}})
+		if err != nil {
+			return Category{}, err
+		}
+		var descendants []mongoCategory
+		if err := cursor.All(ctx, &descendants); err != nil {
+			return Category{}, err
+		}
+		for _, descendant := range descendants {
+			moved := path + strings.TrimPrefix(descendant.Path, current.Path)
+			_, err := m.collection().UpdateOne(ctx, bson.M{"_id": descendant.ID}, bson.M{"$set": bson.M{"path": moved}})
+			if err != nil {
+				return Category{}, err
+			}
+		}
+	}
+	return document.toCategory(), nil
+}
+
+func (m *MongoCategoryModel) DeleteCategory(categoryId int, version uint) (Category, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// subcategories and books keep pointing at the category, move them first
+	children, err := m.collection().CountDocuments(ctx, bson.M{
+		"parent_id":  categoryId,
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return Category{}, err
+	}
+	books, err := m.db.Collection(bookCollection).CountDocuments(ctx, bson.M{
+		"categories": categoryId,
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return Category{}, err
+	}
+	if children > 0 || books > 0 {
+		return Category{}, ErrInUse
+	}
+
+	update := bson.M{"$set": bson.M{"deleted_at": time.Now()}}
+
+	var document mongoCategory
+	err = m.collection().FindOneAndUpdate(ctx, withVersion(categoryId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Category{}, versionError(ctx, m.collection(), categoryId, version)
+	}
+	if err != nil {
+		return Category{}, translateError(err)
+	}
+	return document.toCategory(), nil
+}
+
+// parent the live parent a category is put below, nil for a root
+func (m *MongoCategoryModel) parent(ctx context.Context, parentId *uint) (*Category, error) {
+	if parentId == nil {
+		return nil, nil
+	}
+
+	var document mongoCategory
+	if err := m.collection().FindOne(ctx, withID(int(*parentId))).Decode(&document); err != nil {
+		return nil, fmt.Errorf("parent category %w", translateError(err))
+	}
+	parent := document.toCategory()
+	return &parent, nil
+}
+
+// subtreePattern matches the paths at or below path
+func subtreePattern(path string) primitive.Regex {
+	return primitive.Regex{Pattern: "^" + regexp.QuoteMeta(path)}
+}
+
+// mongoSubtree ids of the live categories at or below a category
+func mongoSubtree(ctx context.Context, db *mongo.Database, categoryId interface{}) ([]interface{}, error) {
+	var category mongoCategory
+	filter := bson.M{"_id": categoryId, "deleted_at": bson.M{"$exists": false}}
+	if err := db.Collection(categoryCollection).FindOne(ctx, filter).Decode(&category); err != nil {
+		return nil, translateError(err)
+	}
+
+	cursor, err := db.Collection(categoryCollection).Find(ctx, bson.M{
+		"path":       subtreePattern(category.Path),
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var documents []mongoCategory
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+
+	ids := make([]interface{}, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document.ID)
+	}
+	return ids, nil
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCategoryPath(t *testing.T) {
+	root := Category{Path: categoryPath(nil, 1)}
+	root.ID = 1
+	child := Category{Path: categoryPath(&root, 4)}
+	child.ID = 4
+
+	assert.Equal(t, "/1/4/", child.Path)
+	assert.Equal(t, []uint{1}, child.AncestorIDs())
+	assert.Nil(t, root.AncestorIDs())
+}
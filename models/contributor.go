@@ -0,0 +1,128 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// contributor roles
+const (
+	ContributorAuthor     = "author"
+	ContributorEditor     = "editor"
+	ContributorTranslator = "translator"
+)
+
+//Contributor author credited on a book in a given role, Name is filled
+//in on reads
+type Contributor struct {
+	AuthorID uint
+	Name     string
+	Role     string
+}
+
+//BookAuthor link between a book and one of its authors, Position keeps
+//the order they are credited in
+type BookAuthor struct {
+	BookID   uint   `gorm:"primaryKey;autoIncrement:false"`
+	AuthorID uint   `gorm:"primaryKey;autoIncrement:false;index"`
+	Role     string `gorm:"primaryKey;size:16"`
+	Position uint
+}
+
+// bylineSeparators what stands between two names of a by-line. A comma
+// is not one, "Tolkien, J. R. R." is a single name
+var bylineSeparators = regexp.MustCompile(`(?i)\s*(?:[;&]|\band\b)\s*`)
+
+//SplitByline names of the people a free-text by-line credits,
+//"Neil Gaiman and Terry Pratchett" credits two of them. A single comma
+//inverts a name, "Tolkien, J. R. R." is "J. R. R. Tolkien", while two
+//or more commas separate the names of a list
+func SplitByline(byline string) []string {
+	var names []string
+	for _, part := range bylineSeparators.Split(byline, -1) {
+		for _, name := range commaNames(part) {
+			if name = NormalizeName(name); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// commaNames the names of one part of a by-line, "Last, First" turned
+// around or the items of a comma separated list
+func commaNames(part string) []string {
+	fields := strings.Split(part, ",")
+	if len(fields) == 2 {
+		last, first := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+		if last != "" && first != "" {
+			return []string{first + " " + last}
+		}
+	}
+	return fields
+}
+
+//NormalizeName trim and collapse runs of whitespace, plus one space
+//after the dots of initials so "J.K.  Rowling" reads "J. K. Rowling"
+func NormalizeName(name string) string {
+	name = strings.ReplaceAll(name, ".", ". ")
+	return strings.ReplaceAll(strings.Join(strings.Fields(name), " "), " .", ".")
+}
+
+//NameKey what two spellings of the same name have in common, lowercase
+//letters and digits only, so "J.K. Rowling" and "J. K. Rowling" match
+func NameKey(name string) string {
+	var key strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			key.WriteRune(r)
+		}
+	}
+	return key.String()
+}
+
+//LinkContributors point a book without linked authors at the authors
+//of its by-line and a book without a linked publisher at the publisher
+//it names, creating the ones not known yet. The book is marked as
+//linked but nothing is written to it
+func LinkContributors(authors AuthorModel, publishers PublisherModel, book Book) (Book, error) {
+	if len(book.Authors) == 0 {
+		linked := map[uint]bool{}
+		for _, name := range SplitByline(book.Author) {
+			author, err := authors.EnsureAuthor(name)
+			if err != nil {
+				return book, err
+			}
+			// "A. Smith and A Smith" credits one person once
+			if !linked[author.ID] {
+				linked[author.ID] = true
+				book.Authors = append(book.Authors, Contributor{AuthorID: author.ID, Name: author.Name, Role: ContributorAuthor})
+			}
+		}
+	}
+
+	if book.PublisherID == nil && NameKey(book.Publisher) != "" {
+		publisher, err := publishers.EnsurePublisher(book.Publisher)
+		if err != nil {
+			return book, err
+		}
+		book.PublisherID = &publisher.ID
+	}
+
+	book.ContributorsLinked = true
+	return book, nil
+}
+
+// contributorIDs distinct author ids of the contributors
+func contributorIDs(contributors []Contributor) []uint {
+	seen := map[uint]bool{}
+	ids := make([]uint, 0, len(contributors))
+	for _, contributor := range contributors {
+		if !seen[contributor.AuthorID] {
+			seen[contributor.AuthorID] = true
+			ids = append(ids, contributor.AuthorID)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitByline(t *testing.T) {
+	cases := map[string][]string{
+		"J.K.  Rowling":                           {"J. K. Rowling"},
+		"Neil Gaiman and Terry Pratchett":         {"Neil Gaiman", "Terry Pratchett"},
+		"Tolkien, J.R.R.":                         {"J. R. R. Tolkien"},
+		"Tolkien, J. R. R.; Tolkien, Christopher": {"J. R. R. Tolkien", "Christopher Tolkien"},
+		"Kernighan, Brian & Ritchie, Dennis":      {"Brian Kernighan", "Dennis Ritchie"},
+		"Kernighan, Ritchie, Pike & AND x":        {"Kernighan", "Ritchie", "Pike", "x"},
+		"Rowling,":                                {"Rowling"},
+		"Alexander Anderson":                      {"Alexander Anderson"},
+		" , ":                                     nil,
+	}
+	for byline, want := range cases {
+		assert.Equal(t, want, SplitByline(byline), byline)
+	}
+}
+
+func TestNameKey(t *testing.T) {
+	assert.Equal(t, NameKey("J.K. Rowling"), NameKey("j. k. rowling"))
+	assert.Equal(t, "garcíamárquez", NameKey("García-Márquez"))
+	assert.NotEqual(t, NameKey("Terry Pratchett"), NameKey("Terry Brooks"))
+}
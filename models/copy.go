@@ -0,0 +1,377 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//ErrCopyStatus the status of the copy is set by loans and holds, not by
+//hand
+var ErrCopyStatus = errors.New("copy status can only be changed by loans and holds")
+
+// copy statuses
+const (
+	CopyAvailable = "available"
+	CopyOnLoan    = "on_loan"
+	CopyLost      = "lost"
+	CopyInRepair  = "in_repair"
+	CopyOnHold    = "on_hold"
+)
+
+// copy conditions
+const (
+	ConditionNew     = "new"
+	ConditionGood    = "good"
+	ConditionFair    = "fair"
+	ConditionPoor    = "poor"
+	ConditionDamaged = "damaged"
+)
+
+// Model Copy
+
+//Copy physical copy of a book. Barcode is unique among the live copies,
+//it is cleared when a copy is withdrawn so the label can be reused.
+//LastSeenAt is the last stocktake that found the copy
+type Copy struct {
+	gorm.Model
+	BookID     uint    `gorm:"not null;index"`
+	Barcode    *string `gorm:"size:32;uniqueIndex"`
+	Condition  string  `gorm:"size:16"`
+	Location   string  `gorm:"size:64;index"`
+	Status     string  `gorm:"size:16;index"`
+	LastSeenAt *time.Time
+	Version    uint `gorm:"not null;default:1"`
+}
+
+// editableStatuses statuses staff may move a copy between, on_loan and
+// on_hold are set by the loan and hold workflows
+var editableStatuses = map[string]bool{CopyAvailable: true, CopyLost: true, CopyInRepair: true}
+
+// inUseStatuses statuses of the copies a loan or a hold points at
+var inUseStatuses = []string{CopyOnLoan, CopyOnHold}
+
+// checkStatusChange ErrCopyStatus unless staff may move a copy from one
+// status to the other
+func checkStatusChange(from, to string) error {
+	if from == to || editableStatuses[from] && editableStatuses[to] {
+		return nil
+	}
+	return ErrCopyStatus
+}
+
+//CopyCounts copies of a book per status
+type CopyCounts struct {
+	Total     int64
+	Available int64
+	OnLoan    int64
+	Lost      int64
+	InRepair  int64
+	OnHold    int64
+}
+
+// add count the copies of a status
+func (c *CopyCounts) add(status string, count int64) {
+	c.Total += count
+	switch status {
+	case CopyAvailable:
+		c.Available += count
+	case CopyOnLoan:
+		c.OnLoan += count
+	case CopyLost:
+		c.Lost += count
+	case CopyInRepair:
+		c.InRepair += count
+	case CopyOnHold:
+		c.OnHold += count
+	}
+}
+
+//NormalizeBarcode barcode as printed without surrounding blanks, letters
+//in upper case since scanners differ
+func NormalizeBarcode(barcode string) string {
+	return strings.ToUpper(strings.TrimSpace(barcode))
+}
+
+// copyQuery fields a copy list can be filtered and sorted by
+var copyQuery = querySchema{
+	"id":           {column: "id", kind: kindUint, sortable: true},
+	"book_id":      {column: "book_id", kind: kindUint},
+	"barcode":      {column: "barcode", kind: kindString, sortable: true},
+	"condition":    {column: "condition", kind: kindString},
+	"location":     {column: "location", kind: kindString, sortable: true},
+	"status":       {column: "status", kind: kindString, sortable: true},
+	"last_seen_at": {column: "last_seen_at", kind: kindTime, sortable: true},
+	"created_at":   {column: "created_at", kind: kindTime, sortable: true},
+	"updated_at":   {column: "updated_at", kind: kindTime, sortable: true},
+}
+
+func (c Copy) sortValue(field string) interface{} {
+	switch field {
+	case "barcode":
+		if c.Barcode == nil {
+			return ""
+		}
+		return *c.Barcode
+	case "location":
+		return c.Location
+	case "status":
+		return c.Status
+	case "last_seen_at":
+		if c.LastSeenAt == nil {
+			return time.Time{}
+		}
+		return *c.LastSeenAt
+	case "created_at":
+		return c.CreatedAt
+	case "updated_at":
+		return c.UpdatedAt
+	}
+	return c.ID
+}
+
+type GormCopyModel struct {
+	db *gorm.DB
+}
+
+func NewCopyModel(db *gorm.DB) *GormCopyModel {
+	return &GormCopyModel{db: db}
+}
+
+// Interface Copy
+
+type CopyModel interface {
+	GetAllCopy(query Query) ([]Copy, Page, error)
+	GetCopy(copyId int) (Copy, error)
+	InsertCopy(copy Copy, policy LoanPolicy) (Copy, error)
+	EditCopy(copy Copy, copyId int, policy LoanPolicy) (Copy, error)
+	DeleteCopy(copyId int, version uint) (Copy, error)
+	ApplyStockChange(change StockChange, policy LoanPolicy) (Copy, error)
+}
+
+func (m *GormCopyModel) GetAllCopy(query Query) ([]Copy, Page, error) {
+	query, err := copyQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var copies []Copy
+	total, err := copyQuery.gormFind(m.db, &Copy{}, &copies, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(copies) > query.Limit {
+		copies = copies[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, copies[len(copies)-1])
+	}
+	return copies, page, nil
+}
+
+func (m *GormCopyModel) GetCopy(copyId int) (Copy, error) {
+	var copy Copy
+	if err := m.db.First(&copy, copyId).Error; err != nil {
+		return copy, translateError(err)
+	}
+	return copy, nil
+}
+
+//InsertCopy save a new copy, an available one goes to the oldest waiting
+//hold of its book like a copy coming back
+func (m *GormCopyModel) InsertCopy(copy Copy, policy LoanPolicy) (Copy, error) {
+	if err := m.db.First(&Book{}, copy.BookID).Error; err != nil {
+		return copy, fmt.Errorf("book %w", translateError(err))
+	}
+
+	copy.Version = 1
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if copy.Status != CopyAvailable {
+			return tx.Save(&copy).Error
+		}
+
+		if err := gormLockBook(tx, copy.BookID); err != nil {
+			return err
+		}
+		if err := tx.Save(&copy).Error; err != nil {
+			return err
+		}
+
+		// a copy nobody waits for is left as saved
+		var waiting int64
+		if err := tx.Model(&Hold{}).Where("book_id = ? AND status = ?", copy.BookID, HoldWaiting).Count(&waiting).Error; err != nil {
+			return err
+		}
+		if waiting == 0 {
+			return nil
+		}
+		return gormReleaseCopy(tx, copy.ID, copy.BookID, []string{CopyAvailable}, policy, time.Now())
+	})
+	if err != nil {
+		return copy, translateError(err)
+	}
+
+	return m.GetCopy(int(copy.ID))
+}
+
+//EditCopy save the copy. The status can only move between available,
+//lost and in_repair, and a copy becoming available goes to the hold queue
+//of its book first
+func (m *GormCopyModel) EditCopy(newCopy Copy, copyId int, policy LoanPolicy) (Copy, error) {
+	var copy Copy
+	if err := m.db.First(&copy, copyId).Error; err != nil {
+		return copy, translateError(err)
+	}
+
+	if err := checkVersion(copy.Version, newCopy.Version); err != nil {
+		return copy, err
+	}
+	if err := checkStatusChange(copy.Status, newCopy.Status); err != nil {
+		return copy, err
+	}
+
+	// the release below sets the status of a copy back in stock
+	released := newCopy.Status == CopyAvailable && copy.Status != CopyAvailable
+	status := newCopy.Status
+	if released {
+		status = copy.Status
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if released {
+			if err := gormLockBook(tx, copy.BookID); err != nil {
+				return err
+			}
+		}
+
+		// the update only applies to the version read above
+		result := tx.Model(&copy).Where("version = ?", copy.Version).Updates(map[string]interface{}{
+			"barcode":      newCopy.Barcode,
+			"condition":    newCopy.Condition,
+			"location":     newCopy.Location,
+			"status":       status,
+			"last_seen_at": newCopy.LastSeenAt,
+			"version":      copy.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		if !released {
+			return nil
+		}
+		return gormReleaseCopy(tx, copy.ID, copy.BookID, []string{status}, policy, time.Now())
+	})
+	if err != nil {
+		return copy, translateError(err)
+	}
+
+	return m.GetCopy(copyId)
+}
+
+func (m *GormCopyModel) DeleteCopy(copyId int, version uint) (Copy, error) {
+	var copy Copy
+	if err := m.db.First(&copy, copyId).Error; err != nil {
+		return copy, translateError(err)
+	}
+
+	if err := checkVersion(copy.Version, version); err != nil {
+		return copy, err
+	}
+
+	// a copy on loan is withdrawn once it is back, one set aside once its
+	// hold is collected, cancelled or expired
+	if copy.Status == CopyOnLoan || copy.Status == CopyOnHold {
+		return copy, ErrInUse
+	}
+
+	// the barcode goes with the copy, it may be put on another one
+	result := m.db.Model(&copy).Where("version = ? AND status NOT IN ?", copy.Version, inUseStatuses).Updates(map[string]interface{}{
+		"barcode":    nil,
+		"deleted_at": time.Now(),
+	})
+	if result.Error != nil {
+		return copy, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return copy, ErrConflict
+	}
+	return copy, nil
+}
+
+//ApplyStockChange write what a stocktake found out about a copy, only
+//while it has the status and location the change expects. ErrConflict
+//when it no longer has
+func (m *GormCopyModel) ApplyStockChange(change StockChange, policy LoanPolicy) (Copy, error) {
+	status := change.NewStatus
+	if change.released() {
+		status = change.Status
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if change.released() {
+			if err := gormLockBook(tx, change.BookID); err != nil {
+				return err
+			}
+		}
+
+		result := tx.Model(&Copy{}).
+			Where("id = ? AND status = ? AND location = ?", change.CopyID, change.Status, change.Location).
+			Updates(map[string]interface{}{
+				"status":       status,
+				"location":     change.NewLocation,
+				"last_seen_at": change.SeenAt,
+				"version":      gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		if !change.released() {
+			return nil
+		}
+		return gormReleaseCopy(tx, change.CopyID, change.BookID, []string{status}, policy, time.Now())
+	})
+	if err != nil {
+		return Copy{}, translateError(err)
+	}
+
+	return m.GetCopy(int(change.CopyID))
+}
+
+// gormCopyCounts fill in the copy counts of the books
+func gormCopyCounts(db *gorm.DB, books []Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids, index := bookIndex(books)
+
+	var rows []struct {
+		BookID uint
+		Status string
+		Count  int64
+	}
+	err := db.Model(&Copy{}).
+		Select("book_id, status, COUNT(*) AS count").
+		Where("book_id IN ?", ids).
+		Group("book_id, status").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		books[index[row.BookID]].Copies.add(row.Status, row.Count)
+	}
+	return nil
+}
@@ -0,0 +1,351 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+const copyCollection = "copies"
+
+// mongoCopy document layout of a Copy
+type mongoCopy struct {
+	ID         uint       `bson:"_id"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	UpdatedAt  time.Time  `bson:"updated_at"`
+	DeletedAt  *time.Time `bson:"deleted_at,omitempty"`
+	BookID     uint       `bson:"book_id"`
+	Barcode    *string    `bson:"barcode,omitempty"`
+	Condition  string     `bson:"condition"`
+	Location   string     `bson:"location"`
+	Status     string     `bson:"status"`
+	LastSeenAt *time.Time `bson:"last_seen_at,omitempty"`
+	Version    uint       `bson:"version"`
+}
+
+func (d mongoCopy) toCopy() Copy {
+	copy := Copy{
+		BookID:     d.BookID,
+		Barcode:    d.Barcode,
+		Condition:  d.Condition,
+		Location:   d.Location,
+		Status:     d.Status,
+		LastSeenAt: d.LastSeenAt,
+		Version:    d.Version,
+	}
+	copy.ID = d.ID
+	copy.CreatedAt = d.CreatedAt
+	copy.UpdatedAt = d.UpdatedAt
+	if d.DeletedAt != nil {
+		copy.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+	}
+	return copy
+}
+
+type MongoCopyModel struct {
+	db *mongo.Database
+}
+
+func NewMongoCopyModel(db *mongo.Database) *MongoCopyModel {
+	return &MongoCopyModel{db: db}
+}
+
+func (m *MongoCopyModel) collection() *mongo.Collection {
+	return m.db.Collection(copyCollection)
+}
+
+func (m *MongoCopyModel) GetAllCopy(query Query) ([]Copy, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := copyQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoCopy
+	total, err := copyQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	copies := make([]Copy, 0, len(documents))
+	for _, document := range documents {
+		copies = append(copies, document.toCopy())
+	}
+
+	page := query.page(total)
+	if len(copies) > query.Limit {
+		copies = copies[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, copies[len(copies)-1])
+	}
+	return copies, page, nil
+}
+
+func (m *MongoCopyModel) GetCopy(copyId int) (Copy, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoCopy
+	if err := m.collection().FindOne(ctx, withID(copyId)).Decode(&document); err != nil {
+		return Copy{}, translateError(err)
+	}
+	return document.toCopy(), nil
+}
+
+//InsertCopy see GormCopyModel.InsertCopy, the copy is saved and handed
+//to the queue in one transaction
+func (m *MongoCopyModel) InsertCopy(copy Copy, policy LoanPolicy) (Copy, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	bookId := copy.BookID
+	if err := checkReference(ctx, m.db, bookCollection, "book", &bookId); err != nil {
+		return copy, err
+	}
+
+	if copy.ID == 0 {
+		id, err := nextSequence(ctx, m.db, copyCollection)
+		if err != nil {
+			return copy, err
+		}
+		copy.ID = id
+	}
+
+	now := time.Now()
+	copy.CreatedAt = now
+	copy.UpdatedAt = now
+	copy.Version = 1
+
+	document := mongoCopy{
+		ID:         copy.ID,
+		CreatedAt:  copy.CreatedAt,
+		UpdatedAt:  copy.UpdatedAt,
+		BookID:     copy.BookID,
+		Barcode:    copy.Barcode,
+		Condition:  copy.Condition,
+		Location:   copy.Location,
+		Status:     copy.Status,
+		LastSeenAt: copy.LastSeenAt,
+		Version:    copy.Version,
+	}
+
+	err := mongoTransaction(ctx, m.db, func(ctx mongo.SessionContext) error {
+		if _, err := m.collection().InsertOne(ctx, document); err != nil {
+			return err
+		}
+		if copy.Status != CopyAvailable {
+			return nil
+		}
+
+		// a copy nobody waits for is left as saved
+		waiting, err := m.db.Collection(holdCollection).CountDocuments(ctx, bson.M{"book_id": copy.BookID, "status": HoldWaiting})
+		if err != nil || waiting == 0 {
+			return err
+		}
+		return mongoReleaseCopy(ctx, m.db, copy.ID, copy.BookID, []string{CopyAvailable}, policy, now)
+	})
+	if err != nil {
+		return copy, translateError(err)
+	}
+
+	if err := m.collection().FindOne(ctx, withID(int(copy.ID))).Decode(&document); err != nil {
+		return copy, translateError(err)
+	}
+	return document.toCopy(), nil
+}
+
+//EditCopy see GormCopyModel.EditCopy, the update only applies to the
+//version and status read first
+func (m *MongoCopyModel) EditCopy(newCopy Copy, copyId int, policy LoanPolicy) (Copy, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var current mongoCopy
+	if err := m.collection().FindOne(ctx, withID(copyId)).Decode(&current); err != nil {
+		return Copy{}, translateError(err)
+	}
+	if err := checkVersion(current.Version, newCopy.Version); err != nil {
+		return Copy{}, err
+	}
+	if err := checkStatusChange(current.Status, newCopy.Status); err != nil {
+		return Copy{}, err
+	}
+
+	// the release below sets the status of a copy back in stock
+	released := newCopy.Status == CopyAvailable && current.Status != CopyAvailable
+	status := newCopy.Status
+	if released {
+		status = current.Status
+	}
+
+	fields := bson.M{
+		"condition":  newCopy.Condition,
+		"location":   newCopy.Location,
+		"status":     status,
+		"updated_at": time.Now(),
+	}
+	unset := bson.M{}
+
+	// a missing barcode is a missing key, the unique index skips those
+	if newCopy.Barcode != nil {
+		fields["barcode"] = *newCopy.Barcode
+	} else {
+		unset["barcode"] = ""
+	}
+	if newCopy.LastSeenAt != nil {
+		fields["last_seen_at"] = *newCopy.LastSeenAt
+	} else {
+		unset["last_seen_at"] = ""
+	}
+
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	filter := withVersion(copyId, current.Version)
+	filter["status"] = current.Status
+
+	var document mongoCopy
+	err := m.collection().FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Copy{}, ErrConflict
+	}
+	if err != nil {
+		return Copy{}, translateError(err)
+	}
+	if !released {
+		return document.toCopy(), nil
+	}
+
+	if err := mongoReleaseCopy(ctx, m.db, document.ID, document.BookID, []string{status}, policy, time.Now()); err != nil {
+		return Copy{}, err
+	}
+	if err := m.collection().FindOne(ctx, withID(copyId)).Decode(&document); err != nil {
+		return Copy{}, translateError(err)
+	}
+	return document.toCopy(), nil
+}
+
+func (m *MongoCopyModel) DeleteCopy(copyId int, version uint) (Copy, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// the barcode goes with the copy, it may be put on another one
+	update := bson.M{
+		"$set":   bson.M{"deleted_at": time.Now()},
+		"$unset": bson.M{"barcode": ""},
+	}
+
+	// a copy on loan is withdrawn once it is back, one set aside once its
+	// hold is collected, cancelled or expired
+	filter := withVersion(copyId, version)
+	filter["status"] = bson.M{"$nin": inUseStatuses}
+
+	var document mongoCopy
+	err := m.collection().FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		inUse := withID(copyId)
+		inUse["status"] = bson.M{"$in": inUseStatuses}
+		if count, err := m.collection().CountDocuments(ctx, inUse); err == nil && count > 0 {
+			return Copy{}, ErrInUse
+		}
+		return Copy{}, versionError(ctx, m.collection(), copyId, version)
+	}
+	if err != nil {
+		return Copy{}, translateError(err)
+	}
+	return document.toCopy(), nil
+}
+
+func (m *MongoCopyModel) ApplyStockChange(change StockChange, policy LoanPolicy) (Copy, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	status := change.NewStatus
+	if change.released() {
+		status = change.Status
+	}
+
+	fields := bson.M{"status": status, "location": change.NewLocation, "updated_at": time.Now()}
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+	if change.SeenAt != nil {
+		fields["last_seen_at"] = *change.SeenAt
+	} else {
+		update["$unset"] = bson.M{"last_seen_at": ""}
+	}
+
+	filter := withID(int(change.CopyID))
+	filter["status"] = change.Status
+	filter["location"] = change.Location
+
+	var document mongoCopy
+	err := m.collection().FindOneAndUpdate(ctx, filter, update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Copy{}, ErrConflict
+	}
+	if err != nil {
+		return Copy{}, translateError(err)
+	}
+	if !change.released() {
+		return document.toCopy(), nil
+	}
+
+	if err := mongoReleaseCopy(ctx, m.db, change.CopyID, change.BookID, []string{status}, policy, time.Now()); err != nil {
+		return Copy{}, err
+	}
+	if err := m.collection().FindOne(ctx, withID(int(change.CopyID))).Decode(&document); err != nil {
+		return Copy{}, translateError(err)
+	}
+	return document.toCopy(), nil
+}
+
+// mongoCopyCounts fill in the copy counts of the books
+func mongoCopyCounts(ctx context.Context, db *mongo.Database, books []Book) error {
+	if len(books) == 0 {
+		return nil
+	}
+
+	ids, index := bookIndex(books)
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"book_id": bson.M{"$in": ids}, "deleted_at": bson.M{"$exists": false}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"book_id": "$book_id", "status": "$status"},
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+	cursor, err := db.Collection(copyCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	var rows []struct {
+		ID struct {
+			BookID uint   `bson:"book_id"`
+			Status string `bson:"status"`
+		} `bson:"_id"`
+		Count int64 `bson:"count"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return fmt.Errorf("copy counts: %w", err)
+	}
+
+	for _, row := range rows {
+		books[index[row.ID.BookID]].Copies.add(row.ID.Status, row.Count)
+	}
+	return nil
+}
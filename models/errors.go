@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+//ErrNotFound no record with the given id, returned by every backend
+var ErrNotFound = errors.New("record not found")
+
+//ErrDuplicate a record with the same unique value already exists
+var ErrDuplicate = errors.New("record already exists")
+
+//ErrVersionMismatch the caller expected another version of the record,
+//it has been modified since the caller read it
+var ErrVersionMismatch = errors.New("record version does not match")
+
+//ErrConflict the record has been modified by someone else while it was
+//being written
+var ErrConflict = errors.New("data has been modified")
+
+//ErrInUse the record is still referenced by others and can not be deleted
+var ErrInUse = errors.New("record is still in use")
+
+// checkVersion compare the stored version with the one the caller
+// expects, zero means the caller does not care
+func checkVersion(current, expected uint) error {
+	if expected != 0 && current != expected {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// translateError turn backend specific errors into the models errors
+func translateError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, mongo.ErrNoDocuments):
+		return ErrNotFound
+	case isDuplicate(err):
+		return ErrDuplicate
+	}
+	return err
+}
+
+func isDuplicate(err error) bool {
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		return mysqlError.Number == 1062
+	}
+
+	var pgError *pgconn.PgError
+	if errors.As(err, &pgError) {
+		return pgError.Code == "23505"
+	}
+
+	if mongo.IsDuplicateKeyError(err) {
+		return true
+	}
+
+	// sqlite, matched on the message to keep cgo out of this package
+	return strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+	other := errors.New("connection refused")
+
+	cases := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"nil", nil, nil},
+		{"gorm not found", fmt.Errorf("query: %w", gorm.ErrRecordNotFound), ErrNotFound},
+		{"mongo not found", mongo.ErrNoDocuments, ErrNotFound},
+		{"mysql duplicate", &mysql.MySQLError{Number: 1062, Message: "Duplicate entry"}, ErrDuplicate},
+		{"postgres duplicate", &pgconn.PgError{Code: "23505"}, ErrDuplicate},
+		{"sqlite duplicate", errors.New("UNIQUE constraint failed: users.email"), ErrDuplicate},
+		{"other", other, other},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.want, translateError(c.err))
+		})
+	}
+}
@@ -0,0 +1,412 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//ErrFinesOwed the borrower owes more fines than the policy allows to borrow
+var ErrFinesOwed = errors.New("outstanding fines exceed the allowed balance")
+
+//ErrOverpayment the payment or waiver is larger than the balance owed
+var ErrOverpayment = errors.New("amount exceeds the balance owed")
+
+// fine ledger entry kinds
+const (
+	FineCharge  = "charge"
+	FinePayment = "payment"
+	FineWaiver  = "waiver"
+)
+
+//FineRule what a late loan costs: Rate per started day late once the
+//Grace period is over, at most Cap per loan, zero Cap is no cap. Amounts
+//are in the minor unit of the currency, cents
+type FineRule struct {
+	Rate  int64
+	Grace time.Duration
+	Cap   int64
+}
+
+//Fine total fine of a loan due at due and returned, or still out, at end.
+//The grace period is not charged
+func (r FineRule) Fine(due, end time.Time) int64 {
+	late := end.Sub(due) - r.Grace
+	if late <= 0 || r.Rate <= 0 {
+		return 0
+	}
+
+	days := int64((late + 24*time.Hour - 1) / (24 * time.Hour))
+	fine := days * r.Rate
+	if r.Cap > 0 && fine > r.Cap {
+		fine = r.Cap
+	}
+	return fine
+}
+
+//FineOverride rule of the books filed under the category with the given
+//code or below it
+type FineOverride struct {
+	Category string
+	FineRule
+}
+
+//FinePolicy default fine rule and its overrides, a book under several
+//overridden categories gets the rule of the most specific one, of the
+//same depth the first listed. A borrower owing more than MaxBalance can
+//not borrow, zero MaxBalance never blocks
+type FinePolicy struct {
+	FineRule
+	MaxBalance int64
+	Overrides  []FineOverride
+}
+
+// codes category codes the overrides are keyed by
+func (p FinePolicy) codes() []string {
+	codes := make([]string, 0, len(p.Overrides))
+	for _, override := range p.Overrides {
+		codes = append(codes, override.Category)
+	}
+	return codes
+}
+
+// overridden index of the override of every category carrying an
+// overridden code, by category id
+func (p FinePolicy) overridden(categories []Category) map[uint]int {
+	overridden := make(map[uint]int, len(categories))
+	for _, category := range categories {
+		for i, override := range p.Overrides {
+			if override.Category == category.Code {
+				overridden[category.ID] = i
+				break
+			}
+		}
+	}
+	return overridden
+}
+
+// rule fine rule of a book filed under the categories with the given
+// paths, overridden as by FinePolicy.overridden
+func (p FinePolicy) rule(paths []string, overridden map[uint]int) FineRule {
+	best, bestDepth := -1, -1
+	for _, path := range paths {
+		ids := strings.Split(strings.Trim(path, "/"), "/")
+		for depth := len(ids) - 1; depth >= 0; depth-- {
+			id, err := strconv.ParseUint(ids[depth], 10, 32)
+			if err != nil {
+				continue
+			}
+			i, ok := overridden[uint(id)]
+			if !ok {
+				continue
+			}
+			if depth > bestDepth || (depth == bestDepth && i < best) {
+				best, bestDepth = i, depth
+			}
+			break
+		}
+	}
+
+	if best < 0 {
+		return p.FineRule
+	}
+	return p.Overrides[best].FineRule
+}
+
+//FineBalance what a user was charged, paid and had waived, Balance is
+//what is still owed
+type FineBalance struct {
+	UserID  uint
+	Charged int64
+	Paid    int64
+	Waived  int64
+	Balance int64
+}
+
+// add book an entry amount under its kind
+func (b *FineBalance) add(kind string, amount int64) {
+	switch kind {
+	case FineCharge:
+		b.Charged += amount
+	case FinePayment:
+		b.Paid -= amount
+	case FineWaiver:
+		b.Waived -= amount
+	}
+	b.Balance += amount
+}
+
+//FineAccrual outcome of an accrual run, the loans charged and the total
+//amount charged
+type FineAccrual struct {
+	Loans  int
+	Amount int64
+}
+
+// Model FineEntry
+
+//FineEntry line of the fine ledger of a user. Charges are positive,
+//payments and waivers negative, so the balance is the sum of the
+//amounts. Charges name the loan they are for, RecordedBy is the staff
+//member who took a payment or granted a waiver
+type FineEntry struct {
+	gorm.Model
+	UserID     uint   `gorm:"not null;index"`
+	LoanID     *uint  `gorm:"index"`
+	Kind       string `gorm:"size:16;index"`
+	Amount     int64  `gorm:"not null"`
+	Note       string `gorm:"size:255"`
+	RecordedBy *uint
+}
+
+// fineQuery fields a ledger can be filtered and sorted by
+var fineQuery = querySchema{
+	"id":         {column: "id", kind: kindUint, sortable: true},
+	"user_id":    {column: "user_id", kind: kindUint},
+	"loan_id":    {column: "loan_id", kind: kindUint},
+	"kind":       {column: "kind", kind: kindString},
+	"created_at": {column: "created_at", kind: kindTime, sortable: true},
+}
+
+func (f FineEntry) sortValue(field string) interface{} {
+	switch field {
+	case "created_at":
+		return f.CreatedAt
+	}
+	return f.ID
+}
+
+// settlement check a payment or waiver of amount against what is owed
+func settlement(kind string, amount int64, balance FineBalance) error {
+	if kind != FinePayment && kind != FineWaiver {
+		return fmt.Errorf("a %v does not settle fines", kind)
+	}
+	if amount <= 0 || amount > balance.Balance {
+		return ErrOverpayment
+	}
+	return nil
+}
+
+type GormFineModel struct {
+	db *gorm.DB
+}
+
+func NewFineModel(db *gorm.DB) *GormFineModel {
+	return &GormFineModel{db: db}
+}
+
+// Interface FineEntry
+
+type FineModel interface {
+	GetAllFineEntry(query Query) ([]FineEntry, Page, error)
+	GetFineBalance(userId int) (FineBalance, error)
+	SettleFine(entry FineEntry) (FineEntry, error)
+	AccrueFines(policy FinePolicy, now time.Time) (FineAccrual, error)
+}
+
+func (m *GormFineModel) GetAllFineEntry(query Query) ([]FineEntry, Page, error) {
+	query, err := fineQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var entries []FineEntry
+	total, err := fineQuery.gormFind(m.db, &FineEntry{}, &entries, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, entries[len(entries)-1])
+	}
+	return entries, page, nil
+}
+
+func (m *GormFineModel) GetFineBalance(userId int) (FineBalance, error) {
+	if err := m.db.First(&User{}, userId).Error; err != nil {
+		return FineBalance{}, translateError(err)
+	}
+	return gormFineBalance(m.db, uint(userId))
+}
+
+//SettleFine record a payment or a waiver, given as a positive amount and
+//booked as a negative one. Nobody pays more than they owe
+func (m *GormFineModel) SettleFine(entry FineEntry) (FineEntry, error) {
+	amount := entry.Amount
+	entry.ID = 0
+	entry.LoanID = nil
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&User{}, entry.UserID).Error; err != nil {
+			return fmt.Errorf("user %w", translateError(err))
+		}
+
+		balance, err := gormFineBalance(tx, entry.UserID)
+		if err != nil {
+			return err
+		}
+		if err := settlement(entry.Kind, amount, balance); err != nil {
+			return err
+		}
+
+		entry.Amount = -amount
+		return tx.Create(&entry).Error
+	})
+	return entry, translateError(err)
+}
+
+//AccrueFines bring the fines of every overdue loan up to now. A loan is
+//charged the difference between its fine so far and what it has already
+//been charged, so running twice a day, or on two servers at once, never
+//charges twice. Returned loans get their final charge and are closed
+func (m *GormFineModel) AccrueFines(policy FinePolicy, now time.Time) (FineAccrual, error) {
+	var accrual FineAccrual
+
+	var categories []Category
+	if len(policy.Overrides) > 0 {
+		if err := m.db.Where("code IN ?", policy.codes()).Find(&categories).Error; err != nil {
+			return accrual, err
+		}
+	}
+	overridden := policy.overridden(categories)
+
+	var last uint
+	for {
+		var loans []Loan
+		err := m.db.Where("id > ?", last).
+			Where("(status = ? AND due_at < ?) OR (status = ? AND NOT fines_closed AND returned_at > due_at)", LoanActive, now, LoanReturned).
+			Order("id").Limit(MaxLimit).Find(&loans).Error
+		if err != nil {
+			return accrual, err
+		}
+		if len(loans) == 0 {
+			return accrual, nil
+		}
+		last = loans[len(loans)-1].ID
+
+		paths, err := gormCategoryPaths(m.db, loans, overridden)
+		if err != nil {
+			return accrual, err
+		}
+
+		for _, loan := range loans {
+			charged, err := m.accrue(loan, policy.rule(paths[loan.BookID], overridden), now)
+			if err != nil {
+				return accrual, err
+			}
+			if charged > 0 {
+				accrual.Loans++
+				accrual.Amount += charged
+			}
+		}
+	}
+}
+
+// accrue charge a loan what its fine grew by since its last charge, the
+// amount charged
+func (m *GormFineModel) accrue(loan Loan, rule FineRule, now time.Time) (int64, error) {
+	end, closed := now, false
+	if loan.Status == LoanReturned && loan.ReturnedAt != nil {
+		end, closed = *loan.ReturnedAt, true
+	}
+
+	fine := rule.Fine(loan.DueAt, end)
+	charge := fine - loan.Fined
+	if charge <= 0 {
+		// a lowered rate never refunds what was charged
+		charge, fine = 0, loan.Fined
+		if !closed {
+			return 0, nil
+		}
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		// only the run that still finds the fine charged so far books the
+		// difference
+		result := tx.Model(&Loan{}).Where("id = ? AND fined = ? AND NOT fines_closed", loan.ID, loan.Fined).Updates(map[string]interface{}{
+			"fined":        fine,
+			"fines_closed": closed,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		if charge == 0 {
+			return nil
+		}
+		loanId := loan.ID
+		return tx.Create(&FineEntry{
+			UserID: loan.UserID,
+			LoanID: &loanId,
+			Kind:   FineCharge,
+			Amount: charge,
+			Note:   fmt.Sprintf("overdue since %v", loan.DueAt.Format("2006-01-02")),
+		}).Error
+	})
+	if errors.Is(err, ErrConflict) {
+		// accrued by another run meanwhile
+		return 0, nil
+	}
+	return charge, err
+}
+
+// gormFineBalance what the user owes, read through db which may be a
+// transaction
+func gormFineBalance(db *gorm.DB, userId uint) (FineBalance, error) {
+	var sums []struct {
+		Kind   string
+		Amount int64
+	}
+	err := db.Model(&FineEntry{}).Select("kind, SUM(amount) AS amount").
+		Where("user_id = ?", userId).Group("kind").Scan(&sums).Error
+	if err != nil {
+		return FineBalance{}, err
+	}
+
+	balance := FineBalance{UserID: userId}
+	for _, sum := range sums {
+		balance.add(sum.Kind, sum.Amount)
+	}
+	return balance, nil
+}
+
+// gormCategoryPaths paths of the categories the books of the loans are
+// filed under, by book id, none when no category is overridden
+func gormCategoryPaths(db *gorm.DB, loans []Loan, overridden map[uint]int) (map[uint][]string, error) {
+	paths := make(map[uint][]string, len(loans))
+	if len(overridden) == 0 {
+		return paths, nil
+	}
+
+	bookIds := make([]uint, 0, len(loans))
+	for _, loan := range loans {
+		bookIds = append(bookIds, loan.BookID)
+	}
+
+	var links []struct {
+		BookID uint
+		Path   string
+	}
+	err := db.Table("book_categories").
+		Select("book_categories.book_id, categories.path").
+		Joins("JOIN categories ON categories.id = book_categories.category_id").
+		Where("book_categories.book_id IN ? AND categories.deleted_at IS NULL", uniqueIDs(bookIds)).
+		Scan(&links).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, link := range links {
+		paths[link.BookID] = append(paths[link.BookID], link.Path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,350 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const fineCollection = "fines"
+
+// mongoFineEntry document layout of a FineEntry
+type mongoFineEntry struct {
+	ID         uint       `bson:"_id"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	UpdatedAt  time.Time  `bson:"updated_at"`
+	DeletedAt  *time.Time `bson:"deleted_at,omitempty"`
+	UserID     uint       `bson:"user_id"`
+	LoanID     *uint      `bson:"loan_id,omitempty"`
+	Kind       string     `bson:"kind"`
+	Amount     int64      `bson:"amount"`
+	Note       string     `bson:"note,omitempty"`
+	RecordedBy *uint      `bson:"recorded_by,omitempty"`
+}
+
+func (d mongoFineEntry) toFineEntry() FineEntry {
+	entry := FineEntry{
+		UserID:     d.UserID,
+		LoanID:     d.LoanID,
+		Kind:       d.Kind,
+		Amount:     d.Amount,
+		Note:       d.Note,
+		RecordedBy: d.RecordedBy,
+	}
+	entry.ID = d.ID
+	entry.CreatedAt = d.CreatedAt
+	entry.UpdatedAt = d.UpdatedAt
+	return entry
+}
+
+//MongoFineModel see GormFineModel. Accruals are kept apart by a
+//conditional update of the fine charged so far on the loan, payments are
+//checked against the balance without a transaction
+type MongoFineModel struct {
+	db *mongo.Database
+}
+
+func NewMongoFineModel(db *mongo.Database) *MongoFineModel {
+	return &MongoFineModel{db: db}
+}
+
+func (m *MongoFineModel) collection() *mongo.Collection {
+	return m.db.Collection(fineCollection)
+}
+
+func (m *MongoFineModel) GetAllFineEntry(query Query) ([]FineEntry, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := fineQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoFineEntry
+	total, err := fineQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	entries := make([]FineEntry, 0, len(documents))
+	for _, document := range documents {
+		entries = append(entries, document.toFineEntry())
+	}
+
+	page := query.page(total)
+	if len(entries) > query.Limit {
+		entries = entries[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, entries[len(entries)-1])
+	}
+	return entries, page, nil
+}
+
+func (m *MongoFineModel) GetFineBalance(userId int) (FineBalance, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var user mongoUser
+	if err := m.db.Collection(userCollection).FindOne(ctx, withID(userId)).Decode(&user); err != nil {
+		return FineBalance{}, translateError(err)
+	}
+	return mongoFineBalance(ctx, m.db, user.ID)
+}
+
+func (m *MongoFineModel) SettleFine(entry FineEntry) (FineEntry, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	userId := entry.UserID
+	if err := checkReference(ctx, m.db, userCollection, "user", &userId); err != nil {
+		return entry, err
+	}
+
+	balance, err := mongoFineBalance(ctx, m.db, entry.UserID)
+	if err != nil {
+		return entry, err
+	}
+	if err := settlement(entry.Kind, entry.Amount, balance); err != nil {
+		return entry, err
+	}
+
+	id, err := nextSequence(ctx, m.db, fineCollection)
+	if err != nil {
+		return entry, err
+	}
+
+	now := time.Now()
+	entry.ID = id
+	entry.CreatedAt = now
+	entry.UpdatedAt = now
+	entry.LoanID = nil
+	entry.Amount = -entry.Amount
+
+	if _, err := m.collection().InsertOne(ctx, mongoFineEntry{
+		ID:         entry.ID,
+		CreatedAt:  entry.CreatedAt,
+		UpdatedAt:  entry.UpdatedAt,
+		UserID:     entry.UserID,
+		Kind:       entry.Kind,
+		Amount:     entry.Amount,
+		Note:       entry.Note,
+		RecordedBy: entry.RecordedBy,
+	}); err != nil {
+		return entry, translateError(err)
+	}
+	return entry, nil
+}
+
+func (m *MongoFineModel) AccrueFines(policy FinePolicy, now time.Time) (FineAccrual, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var accrual FineAccrual
+
+	var categories []Category
+	if len(policy.Overrides) > 0 {
+		filter := bson.M{"code": bson.M{"$in": policy.codes()}, "deleted_at": bson.M{"$exists": false}}
+		cursor, err := m.db.Collection(categoryCollection).Find(ctx, filter)
+		if err != nil {
+			return accrual, err
+		}
+		var documents []mongoCategory
+		if err := cursor.All(ctx, &documents); err != nil {
+			return accrual, err
+		}
+		for _, document := range documents {
+			categories = append(categories, document.toCategory())
+		}
+	}
+	overridden := policy.overridden(categories)
+
+	overdue := bson.M{"$or": bson.A{
+		bson.M{"status": LoanActive, "due_at": bson.M{"$lt": now}},
+		bson.M{"status": LoanReturned, "fines_closed": bson.M{"$ne": true}, "$expr": bson.M{"$gt": bson.A{"$returned_at", "$due_at"}}},
+	}}
+
+	var last uint
+	for {
+		filter := bson.M{"$and": bson.A{overdue, bson.M{"_id": bson.M{"$gt": last}}}}
+		cursor, err := m.db.Collection(loanCollection).Find(ctx, filter,
+			options.Find().SetSort(bson.M{"_id": 1}).SetLimit(int64(MaxLimit)))
+		if err != nil {
+			return accrual, err
+		}
+		var documents []mongoLoan
+		if err := cursor.All(ctx, &documents); err != nil {
+			return accrual, err
+		}
+		if len(documents) == 0 {
+			return accrual, nil
+		}
+		last = documents[len(documents)-1].ID
+
+		loans := make([]Loan, 0, len(documents))
+		for _, document := range documents {
+			loans = append(loans, document.toLoan())
+		}
+
+		paths, err := mongoCategoryPaths(ctx, m.db, loans, overridden)
+		if err != nil {
+			return accrual, err
+		}
+
+		for _, loan := range loans {
+			charged, err := m.accrue(ctx, loan, policy.rule(paths[loan.BookID], overridden), now)
+			if err != nil {
+				return accrual, err
+			}
+			if charged > 0 {
+				accrual.Loans++
+				accrual.Amount += charged
+			}
+		}
+	}
+}
+
+// accrue see GormFineModel.accrue, the loan is updated first and put back
+// when the charge can not be written
+func (m *MongoFineModel) accrue(ctx context.Context, loan Loan, rule FineRule, now time.Time) (int64, error) {
+	end, closed := now, false
+	if loan.Status == LoanReturned && loan.ReturnedAt != nil {
+		end, closed = *loan.ReturnedAt, true
+	}
+
+	fine := rule.Fine(loan.DueAt, end)
+	charge := fine - loan.Fined
+	if charge <= 0 {
+		// a lowered rate never refunds what was charged
+		charge, fine = 0, loan.Fined
+		if !closed {
+			return 0, nil
+		}
+	}
+
+	loans := m.db.Collection(loanCollection)
+	result, err := loans.UpdateOne(ctx, bson.M{"_id": loan.ID, "fined": loan.Fined, "fines_closed": bson.M{"$ne": true}}, bson.M{
+		"$set": bson.M{"fined": fine, "fines_closed": closed},
+	})
+	if err != nil {
+		return 0, err
+	}
+	if result.MatchedCount == 0 {
+		// accrued by another run meanwhile
+		return 0, nil
+	}
+	if charge == 0 {
+		return 0, nil
+	}
+
+	id, err := nextSequence(ctx, m.db, fineCollection)
+	if err == nil {
+		loanId := loan.ID
+		createdAt := time.Now()
+		_, err = m.collection().InsertOne(ctx, mongoFineEntry{
+			ID:        id,
+			CreatedAt: createdAt,
+			UpdatedAt: createdAt,
+			UserID:    loan.UserID,
+			LoanID:    &loanId,
+			Kind:      FineCharge,
+			Amount:    charge,
+			Note:      fmt.Sprintf("overdue since %v", loan.DueAt.Format("2006-01-02")),
+		})
+	}
+	if err != nil {
+		loans.UpdateOne(ctx, bson.M{"_id": loan.ID, "fined": fine}, bson.M{
+			"$set": bson.M{"fined": loan.Fined, "fines_closed": false},
+		})
+		return 0, err
+	}
+	return charge, nil
+}
+
+// mongoFineBalance what the user owes
+func mongoFineBalance(ctx context.Context, db *mongo.Database, userId uint) (FineBalance, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"user_id": userId, "deleted_at": bson.M{"$exists": false}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$kind", "amount": bson.M{"$sum": "$amount"}}}},
+	}
+	cursor, err := db.Collection(fineCollection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return FineBalance{}, err
+	}
+
+	var rows []struct {
+		Kind   string `bson:"_id"`
+		Amount int64  `bson:"amount"`
+	}
+	if err := cursor.All(ctx, &rows); err != nil {
+		return FineBalance{}, err
+	}
+
+	balance := FineBalance{UserID: userId}
+	for _, row := range rows {
+		balance.add(row.Kind, row.Amount)
+	}
+	return balance, nil
+}
+
+// mongoCategoryPaths see gormCategoryPaths
+func mongoCategoryPaths(ctx context.Context, db *mongo.Database, loans []Loan, overridden map[uint]int) (map[uint][]string, error) {
+	paths := make(map[uint][]string, len(loans))
+	if len(overridden) == 0 {
+		return paths, nil
+	}
+
+	bookIds := make([]uint, 0, len(loans))
+	for _, loan := range loans {
+		bookIds = append(bookIds, loan.BookID)
+	}
+
+	cursor, err := db.Collection(bookCollection).Find(ctx, bson.M{"_id": bson.M{"$in": uniqueIDs(bookIds)}},
+		options.Find().SetProjection(bson.M{"categories": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var books []struct {
+		ID         uint   `bson:"_id"`
+		Categories []uint `bson:"categories"`
+	}
+	if err := cursor.All(ctx, &books); err != nil {
+		return nil, err
+	}
+
+	var categoryIds []uint
+	for _, book := range books {
+		categoryIds = append(categoryIds, book.Categories...)
+	}
+	if len(categoryIds) == 0 {
+		return paths, nil
+	}
+
+	cursor, err = db.Collection(categoryCollection).Find(ctx, bson.M{
+		"_id":        bson.M{"$in": uniqueIDs(categoryIds)},
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var categories []mongoCategory
+	if err := cursor.All(ctx, &categories); err != nil {
+		return nil, err
+	}
+	categoryPaths := make(map[uint]string, len(categories))
+	for _, category := range categories {
+		categoryPaths[category.ID] = category.Path
+	}
+
+	for _, book := range books {
+		for _, categoryId := range book.Categories {
+			if path, ok := categoryPaths[categoryId]; ok {
+				paths[book.ID] = append(paths[book.ID], path)
+			}
+		}
+	}
+	return paths, nil
+}
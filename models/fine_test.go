@@ -0,0 +1,73 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestFineRule(t *testing.T) {
+	day := 24 * time.Hour
+	due := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	rule := FineRule{Rate: 25, Grace: day, Cap: 200}
+
+	t.Run("nothing within the grace period", func(t *testing.T) {
+		assert.Equal(t, int64(0), rule.Fine(due, due.Add(-time.Hour)))
+		assert.Equal(t, int64(0), rule.Fine(due, due.Add(day)))
+	})
+
+	t.Run("every started day after the grace period", func(t *testing.T) {
+		assert.Equal(t, int64(25), rule.Fine(due, due.Add(day+time.Minute)))
+		assert.Equal(t, int64(75), rule.Fine(due, due.Add(4*day)))
+	})
+
+	t.Run("capped", func(t *testing.T) {
+		assert.Equal(t, int64(200), rule.Fine(due, due.Add(30*day)))
+		assert.Equal(t, int64(725), FineRule{Rate: 25}.Fine(due, due.Add(29*day)))
+	})
+
+	t.Run("free", func(t *testing.T) {
+		assert.Equal(t, int64(0), FineRule{}.Fine(due, due.Add(30*day)))
+	})
+}
+
+func TestFinePolicyRule(t *testing.T) {
+	reference := FineRule{Rate: 100}
+	periodicals := FineRule{Rate: 50}
+	policy := FinePolicy{
+		FineRule: FineRule{Rate: 10},
+		Overrides: []FineOverride{
+			{Category: "REF", FineRule: reference},
+			{Category: "PER", FineRule: periodicals},
+		},
+	}
+
+	// 1 is filed as REF, 2 below it as PER, 3 as PER too
+	overridden := policy.overridden([]Category{
+		{Model: gorm.Model{ID: 1}, Code: "REF"},
+		{Model: gorm.Model{ID: 2}, Code: "PER"},
+		{Model: gorm.Model{ID: 3}, Code: "PER"},
+		{Model: gorm.Model{ID: 4}, Code: "FIC"},
+	})
+	assert.Equal(t, map[uint]int{1: 0, 2: 1, 3: 1}, overridden)
+
+	t.Run("default", func(t *testing.T) {
+		assert.Equal(t, policy.FineRule, policy.rule(nil, overridden))
+		assert.Equal(t, policy.FineRule, policy.rule([]string{"/4/", "/5/6/"}, overridden))
+	})
+
+	t.Run("inherited by the subtree", func(t *testing.T) {
+		assert.Equal(t, reference, policy.rule([]string{"/1/7/8/"}, overridden))
+	})
+
+	t.Run("most specific wins", func(t *testing.T) {
+		assert.Equal(t, periodicals, policy.rule([]string{"/1/", "/1/2/"}, overridden))
+		assert.Equal(t, periodicals, policy.rule([]string{"/1/2/9/", "/1/"}, overridden))
+	})
+
+	t.Run("first listed of the same depth", func(t *testing.T) {
+		assert.Equal(t, reference, policy.rule([]string{"/3/", "/1/"}, overridden))
+	})
+}
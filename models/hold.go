@@ -0,0 +1,373 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+//ErrCopyAvailable a copy of the book is on the shelf, check it out instead
+var ErrCopyAvailable = errors.New("a copy is available, no hold needed")
+
+//ErrHoldLimit the user already has as many holds as the policy allows
+var ErrHoldLimit = errors.New("hold limit reached")
+
+//ErrHoldClosed the hold was collected, cancelled or has expired
+var ErrHoldClosed = errors.New("hold is no longer active")
+
+//ErrHoldsWaiting other users are waiting for the book
+var ErrHoldsWaiting = errors.New("other users are waiting for the book")
+
+// hold statuses, waiting and ready holds are active
+const (
+	HoldWaiting   = "waiting"
+	HoldReady     = "ready"
+	HoldCollected = "collected"
+	HoldCancelled = "cancelled"
+	HoldExpired   = "expired"
+)
+
+// Model Hold
+
+//Hold place of a user in the queue for a book. Holds are served first
+//come first served: a copy coming back goes to the oldest waiting hold,
+//which is then ready with the copy set aside until ExpiresAt. Position
+//is the place in the queue of a waiting hold, counting from 1
+type Hold struct {
+	gorm.Model
+	BookID    uint      `gorm:"not null;index"`
+	UserID    uint      `gorm:"not null;index"`
+	CopyID    *uint     `gorm:"index"`
+	Status    string    `gorm:"size:16;index"`
+	QueuedAt  time.Time `gorm:"not null"`
+	ReadyAt   *time.Time
+	ExpiresAt *time.Time `gorm:"index"`
+	Position  int        `gorm:"-"`
+	Version   uint       `gorm:"not null;default:1"`
+}
+
+//Active the hold still waits for or holds a copy
+func (h Hold) Active() bool {
+	return h.Status == HoldWaiting || h.Status == HoldReady
+}
+
+// holdQuery fields a hold list can be filtered and sorted by
+var holdQuery = querySchema{
+	"id":         {column: "id", kind: kindUint, sortable: true},
+	"book_id":    {column: "book_id", kind: kindUint},
+	"user_id":    {column: "user_id", kind: kindUint},
+	"copy_id":    {column: "copy_id", kind: kindUint},
+	"status":     {column: "status", kind: kindString},
+	"queued_at":  {column: "queued_at", kind: kindTime, sortable: true},
+	"expires_at": {column: "expires_at", kind: kindTime, sortable: true},
+}
+
+func (h Hold) sortValue(field string) interface{} {
+	switch field {
+	case "queued_at":
+		return h.QueuedAt
+	case "expires_at":
+		if h.ExpiresAt == nil {
+			return time.Time{}
+		}
+		return *h.ExpiresAt
+	}
+	return h.ID
+}
+
+type GormHoldModel struct {
+	db *gorm.DB
+}
+
+func NewHoldModel(db *gorm.DB) *GormHoldModel {
+	return &GormHoldModel{db: db}
+}
+
+// Interface Hold
+
+type HoldModel interface {
+	GetAllHold(query Query) ([]Hold, Page, error)
+	GetHold(holdId int) (Hold, error)
+	PlaceHold(hold Hold, policy LoanPolicy) (Hold, error)
+	CancelHold(holdId int, version uint, policy LoanPolicy, now time.Time) (Hold, error)
+	ExpireHolds(policy LoanPolicy, now time.Time) (int, error)
+}
+
+func (m *GormHoldModel) GetAllHold(query Query) ([]Hold, Page, error) {
+	query, err := holdQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var holds []Hold
+	total, err := holdQuery.gormFind(m.db, &Hold{}, &holds, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(holds) > query.Limit {
+		holds = holds[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, holds[len(holds)-1])
+	}
+	return holds, page, gormHoldPositions(m.db, holds)
+}
+
+func (m *GormHoldModel) GetHold(holdId int) (Hold, error) {
+	var hold Hold
+	if err := m.db.First(&hold, holdId).Error; err != nil {
+		return hold, translateError(err)
+	}
+
+	holds := []Hold{hold}
+	if err := gormHoldPositions(m.db, holds); err != nil {
+		return hold, err
+	}
+	return holds[0], nil
+}
+
+//PlaceHold queue the user for the book. Only books without a copy on the
+//shelf can be held, and a user holds a book once at most
+func (m *GormHoldModel) PlaceHold(hold Hold, policy LoanPolicy) (Hold, error) {
+	hold.Status = HoldWaiting
+	hold.QueuedAt = time.Now()
+	hold.CopyID = nil
+	hold.ReadyAt = nil
+	hold.ExpiresAt = nil
+	hold.Version = 1
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := gormLockBook(tx, hold.BookID); err != nil {
+			return err
+		}
+		if err := tx.First(&User{}, hold.UserID).Error; err != nil {
+			return fmt.Errorf("user %w", translateError(err))
+		}
+
+		var held int64
+		if err := tx.Model(&Hold{}).Where("book_id = ? AND user_id = ? AND status IN ?", hold.BookID, hold.UserID, activeHolds).Count(&held).Error; err != nil {
+			return err
+		}
+		if held > 0 {
+			return ErrDuplicate
+		}
+
+		if policy.MaxHolds > 0 {
+			var active int64
+			if err := tx.Model(&Hold{}).Where("user_id = ? AND status IN ?", hold.UserID, activeHolds).Count(&active).Error; err != nil {
+				return err
+			}
+			if active >= int64(policy.MaxHolds) {
+				return ErrHoldLimit
+			}
+		}
+
+		var available int64
+		if err := tx.Model(&Copy{}).Where("book_id = ? AND status = ?", hold.BookID, CopyAvailable).Count(&available).Error; err != nil {
+			return err
+		}
+		if available > 0 {
+			return ErrCopyAvailable
+		}
+
+		return tx.Create(&hold).Error
+	})
+	if err != nil {
+		return hold, translateError(err)
+	}
+
+	return m.GetHold(int(hold.ID))
+}
+
+//CancelHold take the user out of the queue, the copy set aside for a
+//ready hold goes to the next in line
+func (m *GormHoldModel) CancelHold(holdId int, version uint, policy LoanPolicy, now time.Time) (Hold, error) {
+	var hold Hold
+	if err := m.db.First(&hold, holdId).Error; err != nil {
+		return hold, translateError(err)
+	}
+
+	if err := checkVersion(hold.Version, version); err != nil {
+		return hold, err
+	}
+	if !hold.Active() {
+		return hold, ErrHoldClosed
+	}
+
+	if err := m.close(hold, HoldCancelled, policy, now); err != nil {
+		return hold, err
+	}
+	return m.GetHold(holdId)
+}
+
+//ExpireHolds close the ready holds not collected in time and pass their
+//copies on, returns how many expired
+func (m *GormHoldModel) ExpireHolds(policy LoanPolicy, now time.Time) (int, error) {
+	var holds []Hold
+	if err := m.db.Where("status = ? AND expires_at < ?", HoldReady, now).Order("id").Find(&holds).Error; err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, hold := range holds {
+		err := m.close(hold, HoldExpired, policy, now)
+		if errors.Is(err, ErrConflict) {
+			// collected or cancelled meanwhile
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// close end an active hold with status and release the copy it held
+func (m *GormHoldModel) close(hold Hold, status string, policy LoanPolicy, now time.Time) error {
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := gormLockBook(tx, hold.BookID); err != nil {
+			return err
+		}
+
+		result := tx.Model(&Hold{}).Where("id = ? AND version = ? AND status = ?", hold.ID, hold.Version, hold.Status).Updates(map[string]interface{}{
+			"status":  status,
+			"version": hold.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		if hold.CopyID == nil {
+			return nil
+		}
+		return gormReleaseCopy(tx, *hold.CopyID, hold.BookID, []string{CopyOnHold}, policy, now)
+	})
+	return translateError(err)
+}
+
+// activeHolds statuses of the holds still in the queue
+var activeHolds = []string{HoldWaiting, HoldReady}
+
+// gormLockBook lock the book row until the transaction ends, changes to
+// the hold queue of a book go one at a time. sqlite has a single writer
+// and ignores the lock
+func gormLockBook(tx *gorm.DB, bookId uint) error {
+	var book Book
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&book, bookId).Error; err != nil {
+		return fmt.Errorf("book %w", translateError(err))
+	}
+	return nil
+}
+
+// gormReleaseCopy hand a copy coming back to the oldest waiting hold of
+// its book, or put it on the shelf when nobody waits. Nothing happens
+// when the copy is no longer in one of the from statuses. The book must
+// be locked by the transaction
+func gormReleaseCopy(tx *gorm.DB, copyId, bookId uint, from []string, policy LoanPolicy, now time.Time) error {
+	var hold Hold
+	err := tx.Where("book_id = ? AND status = ?", bookId, HoldWaiting).Order("id").First(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Model(&Copy{}).Where("id = ? AND status IN ?", copyId, from).Updates(map[string]interface{}{
+			"status":  CopyAvailable,
+			"version": gorm.Expr("version + 1"),
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	result := tx.Model(&Copy{}).Where("id = ? AND status IN ?", copyId, from).Updates(map[string]interface{}{
+		"status":  CopyOnHold,
+		"version": gorm.Expr("version + 1"),
+	})
+	if result.Error != nil || result.RowsAffected == 0 {
+		return result.Error
+	}
+
+	expires := now.Add(policy.HoldPickup)
+	return tx.Model(&Hold{}).Where("id = ?", hold.ID).Updates(map[string]interface{}{
+		"status":     HoldReady,
+		"copy_id":    copyId,
+		"ready_at":   now,
+		"expires_at": expires,
+		"version":    hold.Version + 1,
+	}).Error
+}
+
+// gormCollectHold check a copy set aside out to the user it is set aside
+// for, ErrCopyUnavailable when it is not
+func gormCollectHold(tx *gorm.DB, copyId, userId uint) error {
+	var hold Hold
+	err := tx.Where("copy_id = ? AND user_id = ? AND status = ?", copyId, userId, HoldReady).First(&hold).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrCopyUnavailable
+	}
+	if err != nil {
+		return err
+	}
+
+	result := tx.Model(&Hold{}).Where("id = ? AND status = ?", hold.ID, HoldReady).Updates(map[string]interface{}{
+		"status":  HoldCollected,
+		"version": hold.Version + 1,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCopyUnavailable
+	}
+
+	result = tx.Model(&Copy{}).Where("id = ? AND status = ?", copyId, CopyOnHold).Updates(map[string]interface{}{
+		"status":  CopyOnLoan,
+		"version": gorm.Expr("version + 1"),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCopyUnavailable
+	}
+	return nil
+}
+
+// gormHoldPositions fill in the queue position of the waiting holds
+func gormHoldPositions(db *gorm.DB, holds []Hold) error {
+	ids := make([]uint, 0, len(holds))
+	index := make(map[uint]int, len(holds))
+	for i, hold := range holds {
+		if hold.Status == HoldWaiting {
+			ids = append(ids, hold.ID)
+			index[hold.ID] = i
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// a hold is preceded by the waiting holds of its book queued before it
+	var rows []struct {
+		ID       uint
+		Position int
+	}
+	err := db.Table("holds AS h").
+		Select("h.id AS id, COUNT(q.id) AS position").
+		Joins("JOIN holds AS q ON q.book_id = h.book_id AND q.status = ? AND q.id <= h.id AND q.deleted_at IS NULL", HoldWaiting).
+		Where("h.id IN ?", ids).
+		Group("h.id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		holds[index[row.ID]].Position = row.Position
+	}
+	return nil
+}
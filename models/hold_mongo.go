@@ -0,0 +1,354 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const holdCollection = "holds"
+
+// mongoHold document layout of a Hold
+type mongoHold struct {
+	ID        uint       `bson:"_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at"`
+	BookID    uint       `bson:"book_id"`
+	UserID    uint       `bson:"user_id"`
+	CopyID    *uint      `bson:"copy_id,omitempty"`
+	Status    string     `bson:"status"`
+	QueuedAt  time.Time  `bson:"queued_at"`
+	ReadyAt   *time.Time `bson:"ready_at,omitempty"`
+	ExpiresAt *time.Time `bson:"expires_at,omitempty"`
+	Version   uint       `bson:"version"`
+}
+
+func (d mongoHold) toHold() Hold {
+	hold := Hold{
+		BookID:    d.BookID,
+		UserID:    d.UserID,
+		CopyID:    d.CopyID,
+		Status:    d.Status,
+		QueuedAt:  d.QueuedAt,
+		ReadyAt:   d.ReadyAt,
+		ExpiresAt: d.ExpiresAt,
+		Version:   d.Version,
+	}
+	hold.ID = d.ID
+	hold.CreatedAt = d.CreatedAt
+	hold.UpdatedAt = d.UpdatedAt
+	return hold
+}
+
+//MongoHoldModel see GormHoldModel. Without multi-document transactions
+//the queue relies on single document conditional updates: the head of
+//the queue is claimed atomically and a copy only moves between statuses
+//it is known to be in
+type MongoHoldModel struct {
+	db *mongo.Database
+}
+
+func NewMongoHoldModel(db *mongo.Database) *MongoHoldModel {
+	return &MongoHoldModel{db: db}
+}
+
+func (m *MongoHoldModel) collection() *mongo.Collection {
+	return m.db.Collection(holdCollection)
+}
+
+func (m *MongoHoldModel) GetAllHold(query Query) ([]Hold, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := holdQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoHold
+	total, err := holdQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	holds := make([]Hold, 0, len(documents))
+	for _, document := range documents {
+		holds = append(holds, document.toHold())
+	}
+
+	page := query.page(total)
+	if len(holds) > query.Limit {
+		holds = holds[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, holds[len(holds)-1])
+	}
+	return holds, page, mongoHoldPositions(ctx, m.db, holds)
+}
+
+func (m *MongoHoldModel) GetHold(holdId int) (Hold, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoHold
+	if err := m.collection().FindOne(ctx, withID(holdId)).Decode(&document); err != nil {
+		return Hold{}, translateError(err)
+	}
+
+	holds := []Hold{document.toHold()}
+	if err := mongoHoldPositions(ctx, m.db, holds); err != nil {
+		return holds[0], err
+	}
+	return holds[0], nil
+}
+
+func (m *MongoHoldModel) PlaceHold(hold Hold, policy LoanPolicy) (Hold, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	bookId, userId := hold.BookID, hold.UserID
+	if err := checkReference(ctx, m.db, bookCollection, "book", &bookId); err != nil {
+		return hold, err
+	}
+	if err := checkReference(ctx, m.db, userCollection, "user", &userId); err != nil {
+		return hold, err
+	}
+
+	active := bson.M{"$in": bson.A{HoldWaiting, HoldReady}}
+	held, err := m.collection().CountDocuments(ctx, bson.M{"book_id": hold.BookID, "user_id": hold.UserID, "status": active})
+	if err != nil {
+		return hold, err
+	}
+	if held > 0 {
+		return hold, ErrDuplicate
+	}
+
+	if policy.MaxHolds > 0 {
+		count, err := m.collection().CountDocuments(ctx, bson.M{"user_id": hold.UserID, "status": active})
+		if err != nil {
+			return hold, err
+		}
+		if count >= int64(policy.MaxHolds) {
+			return hold, ErrHoldLimit
+		}
+	}
+
+	available := withBook(hold.BookID)
+	available["status"] = CopyAvailable
+	count, err := m.db.Collection(copyCollection).CountDocuments(ctx, available)
+	if err != nil {
+		return hold, err
+	}
+	if count > 0 {
+		return hold, ErrCopyAvailable
+	}
+
+	id, err := nextSequence(ctx, m.db, holdCollection)
+	if err != nil {
+		return hold, err
+	}
+
+	now := time.Now()
+	hold.ID = id
+	hold.CreatedAt = now
+	hold.UpdatedAt = now
+	hold.Status = HoldWaiting
+	hold.QueuedAt = now
+	hold.CopyID = nil
+	hold.ReadyAt = nil
+	hold.ExpiresAt = nil
+	hold.Version = 1
+
+	document := mongoHold{
+		ID:        hold.ID,
+		CreatedAt: hold.CreatedAt,
+		UpdatedAt: hold.UpdatedAt,
+		BookID:    hold.BookID,
+		UserID:    hold.UserID,
+		Status:    hold.Status,
+		QueuedAt:  hold.QueuedAt,
+		Version:   hold.Version,
+	}
+	if _, err := m.collection().InsertOne(ctx, document); err != nil {
+		return hold, translateError(err)
+	}
+
+	holds := []Hold{hold}
+	if err := mongoHoldPositions(ctx, m.db, holds); err != nil {
+		return hold, err
+	}
+	return holds[0], nil
+}
+
+func (m *MongoHoldModel) CancelHold(holdId int, version uint, policy LoanPolicy, now time.Time) (Hold, error) {
+	hold, err := m.GetHold(holdId)
+	if err != nil {
+		return hold, err
+	}
+
+	if err := checkVersion(hold.Version, version); err != nil {
+		return hold, err
+	}
+	if !hold.Active() {
+		return hold, ErrHoldClosed
+	}
+
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if err := m.close(ctx, hold, HoldCancelled, policy, now); err != nil {
+		return hold, err
+	}
+	return m.GetHold(holdId)
+}
+
+func (m *MongoHoldModel) ExpireHolds(policy LoanPolicy, now time.Time) (int, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	cursor, err := m.collection().Find(ctx, bson.M{"status": HoldReady, "expires_at": bson.M{"$lt": now}},
+		options.Find().SetSort(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var documents []mongoHold
+	if err := cursor.All(ctx, &documents); err != nil {
+		return 0, err
+	}
+
+	expired := 0
+	for _, document := range documents {
+		err := m.close(ctx, document.toHold(), HoldExpired, policy, now)
+		if errors.Is(err, ErrConflict) {
+			// collected or cancelled meanwhile
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+// close end an active hold with status and release the copy it held
+func (m *MongoHoldModel) close(ctx context.Context, hold Hold, status string, policy LoanPolicy, now time.Time) error {
+	filter := withVersion(int(hold.ID), hold.Version)
+	filter["status"] = hold.Status
+
+	result, err := m.collection().UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{"status": status, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrConflict
+	}
+
+	if hold.CopyID == nil {
+		return nil
+	}
+	return mongoReleaseCopy(ctx, m.db, *hold.CopyID, hold.BookID, []string{CopyOnHold}, policy, now)
+}
+
+// withBook filter matching the live copies of a book
+func withBook(bookId uint) bson.M {
+	return bson.M{"book_id": bookId, "deleted_at": bson.M{"$exists": false}}
+}
+
+// mongoReleaseCopy see gormReleaseCopy. The oldest waiting hold is
+// claimed first and put back in the queue when the copy turns out to be
+// gone
+func mongoReleaseCopy(ctx context.Context, db *mongo.Database, copyId, bookId uint, from []string, policy LoanPolicy, now time.Time) error {
+	copies := db.Collection(copyCollection)
+	holds := db.Collection(holdCollection)
+
+	release := withID(int(copyId))
+	release["status"] = bson.M{"$in": from}
+
+	var hold mongoHold
+	err := holds.FindOneAndUpdate(ctx, bson.M{"book_id": bookId, "status": HoldWaiting}, bson.M{
+		"$set": bson.M{"status": HoldReady, "copy_id": copyId, "ready_at": now, "expires_at": now.Add(policy.HoldPickup), "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	}, options.FindOneAndUpdate().SetSort(bson.M{"_id": 1})).Decode(&hold)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		_, err := copies.UpdateOne(ctx, release, bson.M{
+			"$set": bson.M{"status": CopyAvailable, "updated_at": time.Now()},
+			"$inc": bson.M{"version": 1},
+		})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	result, err := copies.UpdateOne(ctx, release, bson.M{
+		"$set": bson.M{"status": CopyOnHold, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	})
+	if err == nil && result.MatchedCount > 0 {
+		return nil
+	}
+
+	// the copy is gone, the hold keeps its place in the queue
+	_, undo := holds.UpdateOne(ctx, bson.M{"_id": hold.ID, "status": HoldReady}, bson.M{
+		"$set":   bson.M{"status": HoldWaiting, "updated_at": time.Now()},
+		"$unset": bson.M{"copy_id": "", "ready_at": "", "expires_at": ""},
+		"$inc":   bson.M{"version": 1},
+	})
+	if err != nil {
+		return err
+	}
+	return undo
+}
+
+// mongoCollectHold see gormCollectHold
+func mongoCollectHold(ctx context.Context, db *mongo.Database, copyId, userId uint) error {
+	result, err := db.Collection(holdCollection).UpdateOne(ctx,
+		bson.M{"copy_id": copyId, "user_id": userId, "status": HoldReady},
+		bson.M{"$set": bson.M{"status": HoldCollected, "updated_at": time.Now()}, "$inc": bson.M{"version": 1}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCopyUnavailable
+	}
+
+	claim := withID(int(copyId))
+	claim["status"] = CopyOnHold
+	result, err = db.Collection(copyCollection).UpdateOne(ctx, claim, bson.M{
+		"$set": bson.M{"status": CopyOnLoan, "updated_at": time.Now()},
+		"$inc": bson.M{"version": 1},
+	})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCopyUnavailable
+	}
+	return nil
+}
+
+// mongoHoldPositions fill in the queue position of the waiting holds
+func mongoHoldPositions(ctx context.Context, db *mongo.Database, holds []Hold) error {
+	for i, hold := range holds {
+		if hold.Status != HoldWaiting {
+			continue
+		}
+		ahead, err := db.Collection(holdCollection).CountDocuments(ctx, bson.M{
+			"book_id": hold.BookID,
+			"status":  HoldWaiting,
+			"_id":     bson.M{"$lte": hold.ID},
+		})
+		if err != nil {
+			return err
+		}
+		holds[i].Position = int(ahead)
+	}
+	return nil
+}
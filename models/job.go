@@ -0,0 +1,170 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//ErrJobRunning the job is running on this or another server
+var ErrJobRunning = errors.New("job is already running")
+
+// job run statuses
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobTimedOut  = "timed_out"
+)
+
+// Model JobRun
+
+//JobRun one run of a scheduled job. ScheduledAt is the time the schedule
+//called for, StartedAt later by the jitter. Owner names the server which
+//ran it, Output is what the job reported and Error why it failed
+type JobRun struct {
+	gorm.Model
+	Job         string    `gorm:"size:64;index"`
+	Owner       string    `gorm:"size:128"`
+	Status      string    `gorm:"size:16;index"`
+	ScheduledAt time.Time `gorm:"not null"`
+	StartedAt   time.Time `gorm:"not null;index"`
+	FinishedAt  *time.Time
+	Output      string `gorm:"type:text"`
+	Error       string `gorm:"type:text"`
+}
+
+//JobLease lock of a job, the server holding it runs the job until
+//LockedUntil at the latest. ScheduledAt is the last run claimed, so of
+//the servers waking up for the same run only the first one gets it
+type JobLease struct {
+	Job         string    `gorm:"primaryKey;size:64"`
+	Owner       string    `gorm:"size:128"`
+	ScheduledAt time.Time `gorm:"not null"`
+	LockedUntil time.Time `gorm:"not null"`
+}
+
+// jobRunQuery fields a run history can be filtered and sorted by
+var jobRunQuery = querySchema{
+	"id":           {column: "id", kind: kindUint, sortable: true},
+	"job":          {column: "job", kind: kindString},
+	"owner":        {column: "owner", kind: kindString},
+	"status":       {column: "status", kind: kindString},
+	"scheduled_at": {column: "scheduled_at", kind: kindTime, sortable: true},
+	"started_at":   {column: "started_at", kind: kindTime, sortable: true},
+}
+
+func (r JobRun) sortValue(field string) interface{} {
+	switch field {
+	case "scheduled_at":
+		return r.ScheduledAt
+	case "started_at":
+		return r.StartedAt
+	}
+	return r.ID
+}
+
+type GormJobModel struct {
+	db *gorm.DB
+}
+
+func NewJobModel(db *gorm.DB) *GormJobModel {
+	return &GormJobModel{db: db}
+}
+
+// Interface Job
+
+type JobModel interface {
+	GetAllJobRun(query Query) ([]JobRun, Page, error)
+	AcquireJobLease(lease JobLease, now time.Time) (bool, error)
+	ReleaseJobLease(lease JobLease, now time.Time) error
+	StartJobRun(run JobRun) (JobRun, error)
+	FinishJobRun(run JobRun) (JobRun, error)
+}
+
+func (m *GormJobModel) GetAllJobRun(query Query) ([]JobRun, Page, error) {
+	query, err := jobRunQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var runs []JobRun
+	total, err := jobRunQuery.gormFind(m.db, &JobRun{}, &runs, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(runs) > query.Limit {
+		runs = runs[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, runs[len(runs)-1])
+	}
+	return runs, page, nil
+}
+
+//AcquireJobLease claim the run of the job scheduled at lease.ScheduledAt
+//for lease.Owner until lease.LockedUntil. A run claimed before, or a job
+//still locked, even by the same owner, is not acquired
+func (m *GormJobModel) AcquireJobLease(lease JobLease, now time.Time) (bool, error) {
+	result := m.db.Model(&JobLease{}).
+		Where("job = ? AND scheduled_at < ? AND locked_until < ?", lease.Job, lease.ScheduledAt, now).
+		Updates(map[string]interface{}{
+			"owner":        lease.Owner,
+			"scheduled_at": lease.ScheduledAt,
+			"locked_until": lease.LockedUntil,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return true, nil
+	}
+
+	// the first run of the job ever creates the lease
+	err := translateError(m.db.Create(&lease).Error)
+	if errors.Is(err, ErrDuplicate) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+//ReleaseJobLease unlock the job, the run it was claimed for stays claimed
+func (m *GormJobModel) ReleaseJobLease(lease JobLease, now time.Time) error {
+	return m.db.Model(&JobLease{}).
+		Where("job = ? AND owner = ? AND scheduled_at = ?", lease.Job, lease.Owner, lease.ScheduledAt).
+		Update("locked_until", now).Error
+}
+
+func (m *GormJobModel) StartJobRun(run JobRun) (JobRun, error) {
+	run.ID = 0
+	run.Status = JobRunning
+	run.FinishedAt = nil
+
+	if err := m.db.Create(&run).Error; err != nil {
+		return run, translateError(err)
+	}
+	return run, nil
+}
+
+//FinishJobRun record the outcome of a started run
+func (m *GormJobModel) FinishJobRun(run JobRun) (JobRun, error) {
+	result := m.db.Model(&JobRun{}).Where("id = ? AND status = ?", run.ID, JobRunning).Updates(map[string]interface{}{
+		"status":      run.Status,
+		"finished_at": run.FinishedAt,
+		"output":      run.Output,
+		"error":       run.Error,
+	})
+	if result.Error != nil {
+		return run, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return run, ErrConflict
+	}
+
+	var finished JobRun
+	if err := m.db.First(&finished, run.ID).Error; err != nil {
+		return run, translateError(err)
+	}
+	return finished, nil
+}
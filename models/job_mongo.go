@@ -0,0 +1,181 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const jobRunCollection = "job_runs"
+const jobLeaseCollection = "job_leases"
+
+// mongoJobRun document layout of a JobRun
+type mongoJobRun struct {
+	ID          uint       `bson:"_id"`
+	CreatedAt   time.Time  `bson:"created_at"`
+	UpdatedAt   time.Time  `bson:"updated_at"`
+	Job         string     `bson:"job"`
+	Owner       string     `bson:"owner"`
+	Status      string     `bson:"status"`
+	ScheduledAt time.Time  `bson:"scheduled_at"`
+	StartedAt   time.Time  `bson:"started_at"`
+	FinishedAt  *time.Time `bson:"finished_at,omitempty"`
+	Output      string     `bson:"output,omitempty"`
+	Error       string     `bson:"error,omitempty"`
+}
+
+func (d mongoJobRun) toJobRun() JobRun {
+	run := JobRun{
+		Job:         d.Job,
+		Owner:       d.Owner,
+		Status:      d.Status,
+		ScheduledAt: d.ScheduledAt,
+		StartedAt:   d.StartedAt,
+		FinishedAt:  d.FinishedAt,
+		Output:      d.Output,
+		Error:       d.Error,
+	}
+	run.ID = d.ID
+	run.CreatedAt = d.CreatedAt
+	run.UpdatedAt = d.UpdatedAt
+	return run
+}
+
+// mongoJobLease document layout of a JobLease, keyed by the job
+type mongoJobLease struct {
+	Job         string    `bson:"_id"`
+	Owner       string    `bson:"owner"`
+	ScheduledAt time.Time `bson:"scheduled_at"`
+	LockedUntil time.Time `bson:"locked_until"`
+}
+
+//MongoJobModel see GormJobModel, a lease is claimed with a single upsert
+//which fails on the unique _id when the job is taken
+type MongoJobModel struct {
+	db *mongo.Database
+}
+
+func NewMongoJobModel(db *mongo.Database) *MongoJobModel {
+	return &MongoJobModel{db: db}
+}
+
+func (m *MongoJobModel) collection() *mongo.Collection {
+	return m.db.Collection(jobRunCollection)
+}
+
+func (m *MongoJobModel) GetAllJobRun(query Query) ([]JobRun, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := jobRunQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoJobRun
+	total, err := jobRunQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	runs := make([]JobRun, 0, len(documents))
+	for _, document := range documents {
+		runs = append(runs, document.toJobRun())
+	}
+
+	page := query.page(total)
+	if len(runs) > query.Limit {
+		runs = runs[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, runs[len(runs)-1])
+	}
+	return runs, page, nil
+}
+
+func (m *MongoJobModel) AcquireJobLease(lease JobLease, now time.Time) (bool, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	filter := bson.M{
+		"_id":          lease.Job,
+		"scheduled_at": bson.M{"$lt": lease.ScheduledAt},
+		"locked_until": bson.M{"$lt": now},
+	}
+	_, err := m.db.Collection(jobLeaseCollection).UpdateOne(ctx, filter, bson.M{
+		"$set": bson.M{"owner": lease.Owner, "scheduled_at": lease.ScheduledAt, "locked_until": lease.LockedUntil},
+	}, options.Update().SetUpsert(true))
+
+	// the lease exists but did not match, the upsert collides with it
+	if errors.Is(translateError(err), ErrDuplicate) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (m *MongoJobModel) ReleaseJobLease(lease JobLease, now time.Time) error {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	_, err := m.db.Collection(jobLeaseCollection).UpdateOne(ctx,
+		bson.M{"_id": lease.Job, "owner": lease.Owner, "scheduled_at": lease.ScheduledAt},
+		bson.M{"$set": bson.M{"locked_until": now}},
+	)
+	return err
+}
+
+func (m *MongoJobModel) StartJobRun(run JobRun) (JobRun, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	id, err := nextSequence(ctx, m.db, jobRunCollection)
+	if err != nil {
+		return run, err
+	}
+
+	now := time.Now()
+	run.ID = id
+	run.CreatedAt = now
+	run.UpdatedAt = now
+	run.Status = JobRunning
+	run.FinishedAt = nil
+
+	document := mongoJobRun{
+		ID:          run.ID,
+		CreatedAt:   run.CreatedAt,
+		UpdatedAt:   run.UpdatedAt,
+		Job:         run.Job,
+		Owner:       run.Owner,
+		Status:      run.Status,
+		ScheduledAt: run.ScheduledAt,
+		StartedAt:   run.StartedAt,
+	}
+	if _, err := m.collection().InsertOne(ctx, document); err != nil {
+		return run, translateError(err)
+	}
+	return run, nil
+}
+
+func (m *MongoJobModel) FinishJobRun(run JobRun) (JobRun, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoJobRun
+	err := m.collection().FindOneAndUpdate(ctx, bson.M{"_id": run.ID, "status": JobRunning}, bson.M{
+		"$set": bson.M{
+			"status":      run.Status,
+			"finished_at": run.FinishedAt,
+			"output":      run.Output,
+			"error":       run.Error,
+			"updated_at":  time.Now(),
+		},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return run, ErrConflict
+	}
+	if err != nil {
+		return run, err
+	}
+	return document.toJobRun(), nil
+}
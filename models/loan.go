@@ -0,0 +1,320 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//ErrCopyUnavailable the copy is on loan, lost or in repair
+var ErrCopyUnavailable = errors.New("copy is not available for loan")
+
+//ErrLoanLimit the borrower already has as many loans as the policy allows
+var ErrLoanLimit = errors.New("loan limit reached")
+
+//ErrRenewalLimit the loan has been renewed as often as the policy allows
+var ErrRenewalLimit = errors.New("renewal limit reached")
+
+//ErrLoanReturned the loan is over, it can not be renewed or returned
+var ErrLoanReturned = errors.New("loan already returned")
+
+// loan statuses
+const (
+	LoanActive   = "active"
+	LoanReturned = "returned"
+)
+
+//LoanPolicy how long a loan runs, how often it can be renewed and how
+//many loans a borrower may hold at once, zero MaxActive is no limit.
+//HoldPickup is how long a copy is set aside for a hold, MaxHolds how many
+//holds a user may place, zero is no limit. Fines is what late loans cost
+type LoanPolicy struct {
+	Period      time.Duration
+	MaxRenewals uint
+	MaxActive   int
+	HoldPickup  time.Duration
+	MaxHolds    int
+	Fines       FinePolicy
+}
+
+//DueAt due date of a loan made or renewed at from
+func (p LoanPolicy) DueAt(from time.Time) time.Time {
+	return from.Add(p.Period)
+}
+
+// Model Loan
+
+//Loan copy lent to a user. BookID is the book of the copy at checkout,
+//kept so loans can be listed by book without joining the copies. Fined
+//is what the loan was charged in fines so far, FinesClosed is set once
+//the final fine of a returned loan is charged
+type Loan struct {
+	gorm.Model
+	CopyID      uint      `gorm:"not null;index"`
+	BookID      uint      `gorm:"not null;index"`
+	UserID      uint      `gorm:"not null;index"`
+	Status      string    `gorm:"size:16;index"`
+	LoanedAt    time.Time `gorm:"not null"`
+	DueAt       time.Time `gorm:"not null;index"`
+	ReturnedAt  *time.Time
+	Renewals    uint
+	Fined       int64 `gorm:"not null;default:0"`
+	FinesClosed bool  `gorm:"not null;default:false"`
+	Version     uint  `gorm:"not null;default:1"`
+}
+
+//Overdue the loan is still running past its due date
+func (l Loan) Overdue(now time.Time) bool {
+	return l.Status == LoanActive && now.After(l.DueAt)
+}
+
+// loanQuery fields a loan list can be filtered and sorted by
+var loanQuery = querySchema{
+	"id":          {column: "id", kind: kindUint, sortable: true},
+	"copy_id":     {column: "copy_id", kind: kindUint},
+	"book_id":     {column: "book_id", kind: kindUint},
+	"user_id":     {column: "user_id", kind: kindUint},
+	"status":      {column: "status", kind: kindString},
+	"loaned_at":   {column: "loaned_at", kind: kindTime, sortable: true},
+	"due_at":      {column: "due_at", kind: kindTime, sortable: true},
+	"returned_at": {column: "returned_at", kind: kindTime, sortable: true},
+	"created_at":  {column: "created_at", kind: kindTime, sortable: true},
+}
+
+func (l Loan) sortValue(field string) interface{} {
+	switch field {
+	case "loaned_at":
+		return l.LoanedAt
+	case "due_at":
+		return l.DueAt
+	case "returned_at":
+		if l.ReturnedAt == nil {
+			return time.Time{}
+		}
+		return *l.ReturnedAt
+	case "created_at":
+		return l.CreatedAt
+	}
+	return l.ID
+}
+
+// renewal due date of the loan renewed at now, a renewal never brings
+// the due date forward
+func (l Loan) renewal(policy LoanPolicy, now time.Time) (time.Time, error) {
+	if l.Status != LoanActive {
+		return time.Time{}, ErrLoanReturned
+	}
+	if l.Renewals >= policy.MaxRenewals {
+		return time.Time{}, ErrRenewalLimit
+	}
+
+	due := policy.DueAt(now)
+	if due.Before(l.DueAt) {
+		due = l.DueAt
+	}
+	return due, nil
+}
+
+type GormLoanModel struct {
+	db *gorm.DB
+}
+
+func NewLoanModel(db *gorm.DB) *GormLoanModel {
+	return &GormLoanModel{db: db}
+}
+
+// Interface Loan
+
+type LoanModel interface {
+	GetAllLoan(query Query) ([]Loan, Page, error)
+	GetLoan(loanId int) (Loan, error)
+	CheckoutLoan(loan Loan, policy LoanPolicy) (Loan, error)
+	RenewLoan(loanId int, version uint, policy LoanPolicy, now time.Time) (Loan, error)
+	ReturnLoan(loanId int, version uint, policy LoanPolicy, now time.Time) (Loan, error)
+}
+
+func (m *GormLoanModel) GetAllLoan(query Query) ([]Loan, Page, error) {
+	query, err := loanQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var loans []Loan
+	total, err := loanQuery.gormFind(m.db, &Loan{}, &loans, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(loans) > query.Limit {
+		loans = loans[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, loans[len(loans)-1])
+	}
+	return loans, page, nil
+}
+
+func (m *GormLoanModel) GetLoan(loanId int) (Loan, error) {
+	var loan Loan
+	if err := m.db.First(&loan, loanId).Error; err != nil {
+		return loan, translateError(err)
+	}
+	return loan, nil
+}
+
+//CheckoutLoan lend the copy to the user, the loan starts at LoanedAt or
+//now and is due as the policy says. The copy is claimed in the same
+//transaction, of two checkouts of one copy only the first succeeds. A
+//copy set aside for a hold is only lent to the holder, collecting it.
+//Borrowers owing more fines than the policy allows are turned away
+func (m *GormLoanModel) CheckoutLoan(loan Loan, policy LoanPolicy) (Loan, error) {
+	if loan.LoanedAt.IsZero() {
+		loan.LoanedAt = time.Now()
+	}
+	loan.DueAt = policy.DueAt(loan.LoanedAt)
+	loan.Status = LoanActive
+	loan.ReturnedAt = nil
+	loan.Renewals = 0
+	loan.Fined = 0
+	loan.FinesClosed = false
+	loan.Version = 1
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id").First(&User{}, loan.UserID).Error; err != nil {
+			return fmt.Errorf("user %w", translateError(err))
+		}
+		// writing the user row holds it until the loan is created, so
+		// concurrent checkouts of one user are counted one after the other.
+		// A write locks the row on mysql and postgres and the database on
+		// sqlite, where a SELECT FOR UPDATE would not lock anything
+		if err := tx.Model(&User{}).Where("id = ?", loan.UserID).UpdateColumn("id", gorm.Expr("id")).Error; err != nil {
+			return err
+		}
+
+		if policy.MaxActive > 0 {
+			var active int64
+			if err := tx.Model(&Loan{}).Where("user_id = ? AND status = ?", loan.UserID, LoanActive).Count(&active).Error; err != nil {
+				return err
+			}
+			if active >= int64(policy.MaxActive) {
+				return ErrLoanLimit
+			}
+		}
+
+		if policy.Fines.MaxBalance > 0 {
+			balance, err := gormFineBalance(tx, loan.UserID)
+			if err != nil {
+				return err
+			}
+			if balance.Balance > policy.Fines.MaxBalance {
+				return ErrFinesOwed
+			}
+		}
+
+		var copy Copy
+		if err := tx.First(&copy, loan.CopyID).Error; err != nil {
+			return fmt.Errorf("copy %w", translateError(err))
+		}
+
+		// only the checkout that still finds the copy available claims it
+		result := tx.Model(&Copy{}).Where("id = ? AND status = ?", copy.ID, CopyAvailable).Updates(map[string]interface{}{
+			"status":  CopyOnLoan,
+			"version": gorm.Expr("version + 1"),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			if err := gormCollectHold(tx, copy.ID, loan.UserID); err != nil {
+				return err
+			}
+		}
+
+		loan.BookID = copy.BookID
+		return tx.Create(&loan).Error
+	})
+	return loan, translateError(err)
+}
+
+func (m *GormLoanModel) RenewLoan(loanId int, version uint, policy LoanPolicy, now time.Time) (Loan, error) {
+	var loan Loan
+	if err := m.db.First(&loan, loanId).Error; err != nil {
+		return loan, translateError(err)
+	}
+
+	if err := checkVersion(loan.Version, version); err != nil {
+		return loan, err
+	}
+
+	due, err := loan.renewal(policy, now)
+	if err != nil {
+		return loan, err
+	}
+
+	// the copy goes to the queue once back
+	var waiting int64
+	if err := m.db.Model(&Hold{}).Where("book_id = ? AND status = ?", loan.BookID, HoldWaiting).Count(&waiting).Error; err != nil {
+		return loan, err
+	}
+	if waiting > 0 {
+		return loan, ErrHoldsWaiting
+	}
+
+	// the update only applies to the version read above
+	result := m.db.Model(&loan).Where("version = ? AND status = ?", loan.Version, LoanActive).Updates(map[string]interface{}{
+		"due_at":   due,
+		"renewals": loan.Renewals + 1,
+		"version":  loan.Version + 1,
+	})
+	if result.Error != nil {
+		return loan, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return loan, ErrConflict
+	}
+
+	return m.GetLoan(loanId)
+}
+
+//ReturnLoan end the loan and hand its copy to the next hold or put it
+//back on the shelf, a copy marked lost while on loan is found again
+func (m *GormLoanModel) ReturnLoan(loanId int, version uint, policy LoanPolicy, now time.Time) (Loan, error) {
+	var loan Loan
+	if err := m.db.First(&loan, loanId).Error; err != nil {
+		return loan, translateError(err)
+	}
+
+	if err := checkVersion(loan.Version, version); err != nil {
+		return loan, err
+	}
+	if loan.Status != LoanActive {
+		return loan, ErrLoanReturned
+	}
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := gormLockBook(tx, loan.BookID); err != nil {
+			return err
+		}
+
+		result := tx.Model(&Loan{}).Where("id = ? AND version = ? AND status = ?", loan.ID, loan.Version, LoanActive).Updates(map[string]interface{}{
+			"status":      LoanReturned,
+			"returned_at": now,
+			"version":     loan.Version + 1,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		// a copy withdrawn meanwhile stays withdrawn
+		return gormReleaseCopy(tx, loan.CopyID, loan.BookID, []string{CopyOnLoan, CopyLost}, policy, now)
+	})
+	if err != nil {
+		return loan, translateError(err)
+	}
+
+	return m.GetLoan(loanId)
+}
@@ -0,0 +1,289 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const loanCollection = "loans"
+
+// mongoLoan document layout of a Loan
+type mongoLoan struct {
+	ID          uint       `bson:"_id"`
+	CreatedAt   time.Time  `bson:"created_at"`
+	UpdatedAt   time.Time  `bson:"updated_at"`
+	CopyID      uint       `bson:"copy_id"`
+	BookID      uint       `bson:"book_id"`
+	UserID      uint       `bson:"user_id"`
+	Status      string     `bson:"status"`
+	LoanedAt    time.Time  `bson:"loaned_at"`
+	DueAt       time.Time  `bson:"due_at"`
+	ReturnedAt  *time.Time `bson:"returned_at,omitempty"`
+	Renewals    uint       `bson:"renewals"`
+	Fined       int64      `bson:"fined"`
+	FinesClosed bool       `bson:"fines_closed"`
+	Version     uint       `bson:"version"`
+}
+
+func (d mongoLoan) toLoan() Loan {
+	loan := Loan{
+		CopyID:      d.CopyID,
+		BookID:      d.BookID,
+		UserID:      d.UserID,
+		Status:      d.Status,
+		LoanedAt:    d.LoanedAt,
+		DueAt:       d.DueAt,
+		ReturnedAt:  d.ReturnedAt,
+		Renewals:    d.Renewals,
+		Fined:       d.Fined,
+		FinesClosed: d.FinesClosed,
+		Version:     d.Version,
+	}
+	loan.ID = d.ID
+	loan.CreatedAt = d.CreatedAt
+	loan.UpdatedAt = d.UpdatedAt
+	return loan
+}
+
+type MongoLoanModel struct {
+	db *mongo.Database
+}
+
+func NewMongoLoanModel(db *mongo.Database) *MongoLoanModel {
+	return &MongoLoanModel{db: db}
+}
+
+func (m *MongoLoanModel) collection() *mongo.Collection {
+	return m.db.Collection(loanCollection)
+}
+
+func (m *MongoLoanModel) GetAllLoan(query Query) ([]Loan, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := loanQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoLoan
+	total, err := loanQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	loans := make([]Loan, 0, len(documents))
+	for _, document := range documents {
+		loans = append(loans, document.toLoan())
+	}
+
+	page := query.page(total)
+	if len(loans) > query.Limit {
+		loans = loans[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, loans[len(loans)-1])
+	}
+	return loans, page, nil
+}
+
+func (m *MongoLoanModel) GetLoan(loanId int) (Loan, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoLoan
+	if err := m.collection().FindOne(ctx, withID(loanId)).Decode(&document); err != nil {
+		return Loan{}, translateError(err)
+	}
+	return document.toLoan(), nil
+}
+
+//CheckoutLoan see GormLoanModel.CheckoutLoan. The checks and writes run
+//in one transaction, a failed checkout leaves nothing behind
+func (m *MongoLoanModel) CheckoutLoan(loan Loan, policy LoanPolicy) (Loan, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// ids are handed out outside the transaction, a failed checkout only
+	// leaves a gap
+	id, err := nextSequence(ctx, m.db, loanCollection)
+	if err != nil {
+		return loan, err
+	}
+
+	now := time.Now()
+	if loan.LoanedAt.IsZero() {
+		loan.LoanedAt = now
+	}
+	loan.ID = id
+	loan.CreatedAt = now
+	loan.UpdatedAt = now
+	loan.DueAt = policy.DueAt(loan.LoanedAt)
+	loan.Status = LoanActive
+	loan.ReturnedAt = nil
+	loan.Renewals = 0
+	loan.Fined = 0
+	loan.FinesClosed = false
+	loan.Version = 1
+
+	err = mongoTransaction(ctx, m.db, func(ctx mongo.SessionContext) error {
+		// the write makes concurrent checkouts of one user conflict, the
+		// loser is retried and counts the loan of the winner
+		result, err := m.db.Collection(userCollection).UpdateOne(ctx, withID(int(loan.UserID)), bson.M{"$inc": bson.M{"checkouts": 1}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return fmt.Errorf("user %w", ErrNotFound)
+		}
+
+		if policy.MaxActive > 0 {
+			active, err := m.collection().CountDocuments(ctx, bson.M{"user_id": loan.UserID, "status": LoanActive})
+			if err != nil {
+				return err
+			}
+			if active >= int64(policy.MaxActive) {
+				return ErrLoanLimit
+			}
+		}
+
+		if policy.Fines.MaxBalance > 0 {
+			balance, err := mongoFineBalance(ctx, m.db, loan.UserID)
+			if err != nil {
+				return err
+			}
+			if balance.Balance > policy.Fines.MaxBalance {
+				return ErrFinesOwed
+			}
+		}
+
+		copies := m.db.Collection(copyCollection)
+		claim := withID(int(loan.CopyID))
+		claim["status"] = CopyAvailable
+
+		// only the checkout that still finds the copy available claims it
+		var copy mongoCopy
+		err = copies.FindOneAndUpdate(ctx, claim, bson.M{
+			"$set": bson.M{"status": CopyOnLoan, "updated_at": time.Now()},
+			"$inc": bson.M{"version": 1},
+		}).Decode(&copy)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			if err := copies.FindOne(ctx, withID(int(loan.CopyID))).Decode(&copy); err != nil {
+				return fmt.Errorf("copy %w", translateError(err))
+			}
+			// a copy set aside goes to its holder only
+			err = mongoCollectHold(ctx, m.db, loan.CopyID, loan.UserID)
+		}
+		if err != nil {
+			return err
+		}
+
+		loan.BookID = copy.BookID
+		document := mongoLoan{
+			ID:        loan.ID,
+			CreatedAt: loan.CreatedAt,
+			UpdatedAt: loan.UpdatedAt,
+			CopyID:    loan.CopyID,
+			BookID:    loan.BookID,
+			UserID:    loan.UserID,
+			Status:    loan.Status,
+			LoanedAt:  loan.LoanedAt,
+			DueAt:     loan.DueAt,
+			Version:   loan.Version,
+		}
+		_, err = m.collection().InsertOne(ctx, document)
+		return err
+	})
+	return loan, translateError(err)
+}
+
+func (m *MongoLoanModel) RenewLoan(loanId int, version uint, policy LoanPolicy, now time.Time) (Loan, error) {
+	loan, err := m.GetLoan(loanId)
+	if err != nil {
+		return loan, err
+	}
+
+	if err := checkVersion(loan.Version, version); err != nil {
+		return loan, err
+	}
+
+	due, err := loan.renewal(policy, now)
+	if err != nil {
+		return loan, err
+	}
+
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// the copy goes to the queue once back
+	waiting, err := m.db.Collection(holdCollection).CountDocuments(ctx, bson.M{"book_id": loan.BookID, "status": HoldWaiting})
+	if err != nil {
+		return loan, err
+	}
+	if waiting > 0 {
+		return loan, ErrHoldsWaiting
+	}
+
+	// the update only applies to the version read above
+	filter := withVersion(loanId, loan.Version)
+	filter["status"] = LoanActive
+
+	var document mongoLoan
+	err = m.collection().FindOneAndUpdate(ctx, filter, bson.M{
+		"$set": bson.M{"due_at": due, "updated_at": time.Now()},
+		"$inc": bson.M{"renewals": 1, "version": 1},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return loan, ErrConflict
+	}
+	if err != nil {
+		return loan, translateError(err)
+	}
+	return document.toLoan(), nil
+}
+
+//ReturnLoan see GormLoanModel.ReturnLoan, the loan is closed and its
+//copy released in one transaction
+func (m *MongoLoanModel) ReturnLoan(loanId int, version uint, policy LoanPolicy, now time.Time) (Loan, error) {
+	loan, err := m.GetLoan(loanId)
+	if err != nil {
+		return loan, err
+	}
+
+	if err := checkVersion(loan.Version, version); err != nil {
+		return loan, err
+	}
+	if loan.Status != LoanActive {
+		return loan, ErrLoanReturned
+	}
+
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoLoan
+	err = mongoTransaction(ctx, m.db, func(ctx mongo.SessionContext) error {
+		filter := withVersion(loanId, loan.Version)
+		filter["status"] = LoanActive
+
+		err := m.collection().FindOneAndUpdate(ctx, filter, bson.M{
+			"$set": bson.M{"status": LoanReturned, "returned_at": now, "updated_at": time.Now()},
+			"$inc": bson.M{"version": 1},
+		}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&document)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return ErrConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		// a copy withdrawn meanwhile stays withdrawn
+		return mongoReleaseCopy(ctx, m.db, loan.CopyID, loan.BookID, []string{CopyOnLoan, CopyLost}, policy, now)
+	})
+	if err != nil {
+		return loan, translateError(err)
+	}
+	return document.toLoan(), nil
+}
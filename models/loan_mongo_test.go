@@ -0,0 +1,107 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestMongoCheckoutLoan(t *testing.T) {
+	db := mongoTestDB(t)
+	users, books, copies, loans := NewMongoUserModel(db), NewMongoBookModel(db), NewMongoCopyModel(db), NewMongoLoanModel(db)
+	policy := LoanPolicy{Period: 14 * 24 * time.Hour, MaxActive: 2}
+
+	user := func(name string) User {
+		user, err := users.Insert(User{Name: name, Email: name + "@alterra.id"})
+		assert.NoError(t, err)
+		return user
+	}
+	book, err := books.InsertBook(Book{Title: "Lady Susan"})
+	assert.NoError(t, err)
+	copy := func() Copy {
+		copy, err := copies.InsertCopy(Copy{BookID: book.ID, Status: CopyAvailable}, policy)
+		assert.NoError(t, err)
+		return copy
+	}
+
+	// checkout run them all at once, returning how many succeeded
+	checkout := func(wanted []Loan, allowed error) int {
+		var wg sync.WaitGroup
+		errs := make(chan error, len(wanted))
+		for _, loan := range wanted {
+			wg.Add(1)
+			go func(loan Loan) {
+				defer wg.Done()
+				_, err := loans.CheckoutLoan(loan, policy)
+				errs <- err
+			}(loan)
+		}
+		wg.Wait()
+		close(errs)
+
+		succeeded := 0
+		for err := range errs {
+			if err == nil {
+				succeeded++
+			} else {
+				assert.ErrorIs(t, err, allowed)
+			}
+		}
+		return succeeded
+	}
+
+	t.Run("only one checkout of a copy succeeds", func(t *testing.T) {
+		claimed := copy()
+		wanted := make([]Loan, 0, 4)
+		for i := 0; i < cap(wanted); i++ {
+			wanted = append(wanted, Loan{CopyID: claimed.ID, UserID: user(fmt.Sprintf("racer%v", i)).ID})
+		}
+		assert.Equal(t, 1, checkout(wanted, ErrCopyUnavailable))
+
+		claimed, _ = copies.GetCopy(int(claimed.ID))
+		assert.Equal(t, CopyOnLoan, claimed.Status)
+	})
+
+	t.Run("one borrower checking out at once stays within the limit", func(t *testing.T) {
+		greedy := user("greedy")
+		wanted := make([]Loan, 0, policy.MaxActive+3)
+		for i := 0; i < cap(wanted); i++ {
+			wanted = append(wanted, Loan{CopyID: copy().ID, UserID: greedy.ID})
+		}
+		assert.Equal(t, policy.MaxActive, checkout(wanted, ErrLoanLimit))
+
+		active, err := db.Collection(loanCollection).CountDocuments(context.Background(), bson.M{"user_id": greedy.ID, "status": LoanActive})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(policy.MaxActive), active)
+	})
+
+	t.Run("a failed checkout leaves the copy on the shelf", func(t *testing.T) {
+		unlucky, shelved := user("unlucky"), copy()
+
+		// the id the checkout is handed is taken already
+		ctx, cancel := mongoContext()
+		defer cancel()
+		var counter struct {
+			Seq uint `bson:"seq"`
+		}
+		assert.NoError(t, db.Collection(counterCollection).FindOne(ctx, bson.M{"_id": loanCollection}).Decode(&counter))
+		_, err := db.Collection(loanCollection).InsertOne(ctx, mongoLoan{ID: counter.Seq + 1, Status: LoanReturned})
+		assert.NoError(t, err)
+
+		_, err = loans.CheckoutLoan(Loan{CopyID: shelved.ID, UserID: unlucky.ID}, policy)
+		assert.ErrorIs(t, err, ErrDuplicate)
+
+		shelved, _ = copies.GetCopy(int(shelved.ID))
+		assert.Equal(t, CopyAvailable, shelved.Status)
+		assert.Equal(t, uint(1), shelved.Version)
+
+		borrowed, err := db.Collection(loanCollection).CountDocuments(ctx, bson.M{"user_id": unlucky.ID})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), borrowed)
+	})
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoanRenewal(t *testing.T) {
+	policy := LoanPolicy{Period: 7 * 24 * time.Hour, MaxRenewals: 1}
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("due a period after the renewal", func(t *testing.T) {
+		loan := Loan{Status: LoanActive, DueAt: now.Add(time.Hour)}
+		due, err := loan.renewal(policy, now)
+		assert.NoError(t, err)
+		assert.Equal(t, now.Add(policy.Period), due)
+	})
+
+	t.Run("never brings the due date forward", func(t *testing.T) {
+		loan := Loan{Status: LoanActive, DueAt: now.Add(30 * 24 * time.Hour)}
+		due, _ := loan.renewal(policy, now)
+		assert.Equal(t, loan.DueAt, due)
+	})
+
+	t.Run("limits", func(t *testing.T) {
+		_, err := Loan{Status: LoanActive, Renewals: 1}.renewal(policy, now)
+		assert.Equal(t, ErrRenewalLimit, err)
+
+		_, err = Loan{Status: LoanReturned}.renewal(policy, now)
+		assert.Equal(t, ErrLoanReturned, err)
+	})
+
+	t.Run("overdue", func(t *testing.T) {
+		assert.True(t, Loan{Status: LoanActive, DueAt: now.Add(-time.Minute)}.Overdue(now))
+		assert.False(t, Loan{Status: LoanReturned, DueAt: now.Add(-time.Minute)}.Overdue(now))
+	})
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTimeout upper bound of a single mongo operation, the model
+// interfaces carry no context so every call gets its own deadline
+const mongoTimeout = 10 * time.Second
+
+// counterCollection keep the last integer id handed out per collection,
+// so mongo documents keep the same integer ids the controllers expect
+const counterCollection = "counters"
+
+// notDeleted filter matching documents which are not soft deleted
+var notDeleted = bson.M{"deleted_at": bson.M{"$exists": false}}
+
+func mongoContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), mongoTimeout)
+}
+
+// nextSequence atomically reserve the next integer id of a collection
+func nextSequence(ctx context.Context, db *mongo.Database, collection string) (uint, error) {
+	var counter struct {
+		Seq uint `bson:"seq"`
+	}
+
+	err := db.Collection(counterCollection).FindOneAndUpdate(ctx,
+		bson.M{"_id": collection},
+		bson.M{"$inc": bson.M{"seq": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+
+	return counter.Seq, err
+}
+
+// withID filter matching a single live document
+func withID(id int) bson.M {
+	return bson.M{"_id": id, "deleted_at": bson.M{"$exists": false}}
+}
+
+// withVersion filter matching a single live document at the expected
+// version, zero matches any version
+func withVersion(id int, version uint) bson.M {
+	filter := withID(id)
+	if version != 0 {
+		filter["version"] = version
+	}
+	return filter
+}
+
+// versionError tell apart a missing document from a stale version once a
+// withVersion filter matched nothing
+func versionError(ctx context.Context, collection *mongo.Collection, id int, version uint) error {
+	if version == 0 {
+		return ErrNotFound
+	}
+
+	count, err := collection.CountDocuments(ctx, withID(id))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrNotFound
+	}
+	return ErrVersionMismatch
+}
+
+// mongoTransaction run fn in a transaction, retried while the server
+// reports a transient conflict. Transactions need a replica set
+func mongoTransaction(ctx context.Context, db *mongo.Database, fn func(ctx mongo.SessionContext) error) error {
+	return db.Client().UseSession(ctx, func(sc mongo.SessionContext) error {
+		_, err := sc.WithTransaction(sc, func(sc mongo.SessionContext) (interface{}, error) {
+			return nil, fn(sc)
+		})
+		return err
+	})
+}
+
+// checkReference make sure id, if any, points at a live document of
+// collection, name tells which reference is dangling
+func checkReference(ctx context.Context, db *mongo.Database, collection, name string, id *uint) error {
+	if id == nil {
+		return nil
+	}
+
+	count, err := db.Collection(collection).CountDocuments(ctx, withID(int(*id)))
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%v %w", name, ErrNotFound)
+	}
+	return nil
+}
+
+//EnsureMongoIndexes create the indexes the mongo models rely on
+func EnsureMongoIndexes(db *mongo.Database) error {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		userCollection: {
+			{Keys: bson.M{"email": 1}, Options: options.Index().SetUnique(true)},
+		},
+		sessionCollection: {
+			{Keys: bson.M{"user_id": 1}},
+		},
+		bookCollection: {
+			{Keys: bson.M{"isbn": 1}, Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"isbn": bson.M{"$type": "string"}})},
+			{Keys: bson.M{"authors.author_id": 1}},
+			{Keys: bson.M{"publisher_id": 1}},
+			{Keys: bson.M{"work_id": 1}},
+			{Keys: bson.M{"tags": 1}},
+			{Keys: bson.M{"categories": 1}},
+		},
+		holdCollection: {
+			{Keys: bson.D{{Key: "book_id", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.M{"copy_id": 1}},
+			{Keys: bson.M{"expires_at": 1}},
+		},
+		fineCollection: {
+			{Keys: bson.M{"user_id": 1}},
+			{Keys: bson.M{"loan_id": 1}},
+		},
+		jobRunCollection: {
+			{Keys: bson.D{{Key: "job", Value: 1}, {Key: "started_at", Value: -1}}},
+			{Keys: bson.M{"status": 1}},
+		},
+		loanCollection: {
+			{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.M{"copy_id": 1}},
+			{Keys: bson.M{"book_id": 1}},
+			{Keys: bson.M{"due_at": 1}},
+		},
+		copyCollection: {
+			{Keys: bson.M{"book_id": 1}},
+			{Keys: bson.M{"location": 1}},
+			{Keys: bson.M{"status": 1}},
+			{
+				Keys:    bson.M{"barcode": 1},
+				Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"barcode": bson.M{"$exists": true}}),
+			},
+		},
+		categoryCollection: {
+			{Keys: bson.M{"parent_id": 1}},
+			{Keys: bson.M{"path": 1}},
+		},
+		workCollection: {
+			{Keys: bson.M{"series_id": 1}},
+		},
+		authorCollection: {
+			{Keys: bson.M{"name_key": 1}, Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"name_key": bson.M{"$type": "string"}})},
+		},
+		publisherCollection: {
+			{Keys: bson.M{"name_key": 1}, Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"name_key": bson.M{"$type": "string"}})},
+		},
+	}
+
+	for collection, models := range indexes {
+		if _, err := db.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//DropMongoCollection drop a collection together with its id counter
+func DropMongoCollection(db *mongo.Database, collection string) error {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if err := db.Collection(collection).Drop(ctx); err != nil {
+		return err
+	}
+
+	_, err := db.Collection(counterCollection).DeleteOne(ctx, bson.M{"_id": collection})
+	return err
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTestDB fresh database on the mongod at MONGODB_URI, dropped once
+// the test is over. The mongod has to be a replica set, a single node one
+// will do, for the transactions. Tests using it are skipped when the
+// variable is not set
+func mongoTestDB(t *testing.T) *mongo.Database {
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		t.Skip("MONGODB_URI is not set")
+	}
+
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database(fmt.Sprintf("project_api_test_%v", time.Now().UnixNano()))
+	if err := EnsureMongoIndexes(db); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		ctx, cancel := mongoContext()
+		defer cancel()
+
+		db.Drop(ctx)
+		client.Disconnect(ctx)
+	})
+	return db
+}
+
+func TestMongoUserModel(t *testing.T) {
+	users := NewMongoUserModel(mongoTestDB(t))
+
+	user, err := users.Insert(User{Name: "Reader", Email: "reader@alterra.id"})
+	assert.NoError(t, err)
+	assert.Equal(t, RoleMember, user.Role)
+
+	t.Run("email is unique", func(t *testing.T) {
+		_, err := users.Insert(User{Name: "Other", Email: "reader@alterra.id"})
+		assert.ErrorIs(t, err, ErrDuplicate)
+	})
+
+	t.Run("edit only applies to the expected version", func(t *testing.T) {
+		edited, err := users.Edit(User{Name: "Renamed", Email: user.Email, Version: user.Version}, int(user.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, user.Version+1, edited.Version)
+
+		_, err = users.Edit(User{Name: "Stale", Email: user.Email, Version: user.Version}, int(user.ID))
+		assert.ErrorIs(t, err, ErrVersionMismatch)
+
+		_, err = users.Edit(User{Name: "Missing", Email: "missing@alterra.id", Version: 1}, 9999)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("a deleted user is gone", func(t *testing.T) {
+		_, err := users.Delete(int(user.ID), 0)
+		assert.NoError(t, err)
+
+		_, err = users.Get(int(user.ID))
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("the email of a deleted user is free", func(t *testing.T) {
+		_, err := users.Insert(User{Name: "Again", Email: "reader@alterra.id"})
+		assert.NoError(t, err)
+	})
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+//ErrInvalidCredentials email and password do not match a user
+var ErrInvalidCredentials = errors.New("invalid email or password")
+
+//PasswordCost bcrypt cost used for new hashes, stored hashes made with
+//another cost are rehashed on the next successful login
+var PasswordCost = bcrypt.DefaultCost
+
+// dummy hash compared against when no user matches the email, so a login
+// takes the same time whether or not the account exists
+var dummy struct {
+	sync.Mutex
+	hash []byte
+}
+
+//HashPassword hash a plaintext password with PasswordCost
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost())
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+//CheckPassword report whether password matches the stored hash
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+//NeedsRehash report whether the stored hash was made with other parameters
+func NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != passwordCost()
+}
+
+// dummyHash the dummy hash made with the current cost, a hash of another
+// cost would take another time to compare
+func dummyHash() []byte {
+	dummy.Lock()
+	defer dummy.Unlock()
+
+	if cost, err := bcrypt.Cost(dummy.hash); err != nil || cost != passwordCost() {
+		dummy.hash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), passwordCost())
+	}
+	return dummy.hash
+}
+
+func passwordCost() int {
+	if PasswordCost < bcrypt.MinCost || PasswordCost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return PasswordCost
+}
+
+// hashIfSet hash a password, an empty password stays empty
+func hashIfSet(password string) (string, error) {
+	if password == "" {
+		return "", nil
+	}
+	return HashPassword(password)
+}
+
+// verifyPassword check a login attempt, found is false when no user
+// matched the email
+func verifyPassword(user User, found bool, password string) error {
+	if !found {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return ErrInvalidCredentials
+	}
+	if !CheckPassword(user.Password, password) {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPassword(t *testing.T) {
+	defer func(cost int) { PasswordCost = cost }(PasswordCost)
+	PasswordCost = bcrypt.MinCost
+
+	hash, err := HashPassword("password123")
+	assert.NoError(t, err)
+
+	t.Run("func HashPassword()", func(t *testing.T) {
+		assert.NotEqual(t, "password123", hash)
+	})
+
+	t.Run("func CheckPassword()", func(t *testing.T) {
+		assert.True(t, CheckPassword(hash, "password123"))
+		assert.False(t, CheckPassword(hash, "password124"))
+	})
+
+	t.Run("func NeedsRehash()", func(t *testing.T) {
+		assert.False(t, NeedsRehash(hash))
+
+		PasswordCost = bcrypt.MinCost + 1
+		assert.True(t, NeedsRehash(hash))
+		assert.True(t, NeedsRehash("password123"))
+	})
+
+	t.Run("func dummyHash()", func(t *testing.T) {
+		for _, cost := range []int{bcrypt.MinCost, bcrypt.MinCost + 1} {
+			PasswordCost = cost
+			hashCost, err := bcrypt.Cost(dummyHash())
+			assert.NoError(t, err)
+			assert.Equal(t, cost, hashCost)
+		}
+	})
+}
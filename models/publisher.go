@@ -0,0 +1,173 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Model Publisher
+
+//Publisher imprint books are published under, NameKey keeps two
+//spellings of the same name from becoming two publishers. It is cleared
+//on delete so the name can be taken again
+type Publisher struct {
+	gorm.Model
+	Name    string  `gorm:"size:255"`
+	NameKey *string `gorm:"size:255;uniqueIndex"`
+	Version uint    `gorm:"not null;default:1"`
+}
+
+// publisherQuery fields an publisher list can be filtered and sorted by
+var publisherQuery = querySchema{
+	"id":         {column: "id", kind: kindUint, sortable: true},
+	"name":       {column: "name", kind: kindString, sortable: true},
+	"created_at": {column: "created_at", kind: kindTime, sortable: true},
+	"updated_at": {column: "updated_at", kind: kindTime, sortable: true},
+}
+
+func (a Publisher) sortValue(field string) interface{} {
+	switch field {
+	case "name":
+		return a.Name
+	case "created_at":
+		return a.CreatedAt
+	case "updated_at":
+		return a.UpdatedAt
+	}
+	return a.ID
+}
+
+type GormPublisherModel struct {
+	db *gorm.DB
+}
+
+func NewPublisherModel(db *gorm.DB) *GormPublisherModel {
+	return &GormPublisherModel{db: db}
+}
+
+// Interface Publisher
+
+type PublisherModel interface {
+	GetAllPublisher(query Query) ([]Publisher, Page, error)
+	GetPublisher(publisherId int) (Publisher, error)
+	EnsurePublisher(name string) (Publisher, error)
+	InsertPublisher(Publisher) (Publisher, error)
+	EditPublisher(publisher Publisher, publisherId int) (Publisher, error)
+	DeletePublisher(publisherId int, version uint) (Publisher, error)
+}
+
+func (m *GormPublisherModel) GetAllPublisher(query Query) ([]Publisher, Page, error) {
+	query, err := publisherQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var publisher []Publisher
+	total, err := publisherQuery.gormFind(m.db, &Publisher{}, &publisher, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(publisher) > query.Limit {
+		publisher = publisher[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, publisher[len(publisher)-1])
+	}
+	return publisher, page, nil
+}
+
+func (m *GormPublisherModel) GetPublisher(publisherId int) (Publisher, error) {
+	var publisher Publisher
+	if err := m.db.First(&publisher, publisherId).Error; err != nil {
+		return publisher, translateError(err)
+	}
+	return publisher, nil
+}
+
+//EnsurePublisher the publisher going by name or a spelling of it, created
+//when there is none yet
+func (m *GormPublisherModel) EnsurePublisher(name string) (Publisher, error) {
+	var publisher Publisher
+	err := m.db.Where("name_key = ?", NameKey(name)).First(&publisher).Error
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return publisher, translateError(err)
+	}
+
+	publisher, err = m.InsertPublisher(Publisher{Name: name})
+	if errors.Is(err, ErrDuplicate) {
+		// created in the meantime by another request
+		err = translateError(m.db.Where("name_key = ?", NameKey(name)).First(&publisher).Error)
+	}
+	return publisher, err
+}
+
+func (m *GormPublisherModel) InsertPublisher(publisher Publisher) (Publisher, error) {
+	key := NameKey(publisher.Name)
+	publisher.NameKey = &key
+	publisher.Version = 1
+	if err := m.db.Save(&publisher).Error; err != nil {
+		return publisher, translateError(err)
+	}
+	return publisher, nil
+}
+
+func (m *GormPublisherModel) EditPublisher(newPublisher Publisher, publisherId int) (Publisher, error) {
+	var publisher Publisher
+	if err := m.db.First(&publisher, publisherId).Error; err != nil {
+		return publisher, translateError(err)
+	}
+
+	if err := checkVersion(publisher.Version, newPublisher.Version); err != nil {
+		return publisher, err
+	}
+
+	// the update only applies to the version read above
+	result := m.db.Model(&publisher).Where("version = ?", publisher.Version).Updates(map[string]interface{}{
+		"name":     newPublisher.Name,
+		"name_key": NameKey(newPublisher.Name),
+		"version":  publisher.Version + 1,
+	})
+	if result.Error != nil {
+		return publisher, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return publisher, ErrConflict
+	}
+
+	return m.GetPublisher(publisherId)
+}
+
+func (m *GormPublisherModel) DeletePublisher(publisherId int, version uint) (Publisher, error) {
+	var publisher Publisher
+	if err := m.db.First(&publisher, publisherId).Error; err != nil {
+		return publisher, translateError(err)
+	}
+
+	if err := checkVersion(publisher.Version, version); err != nil {
+		return publisher, err
+	}
+
+	// books keep pointing at their publisher, unlink them first
+	var books int64
+	err := m.db.Model(&Book{}).Where("publisher_id = ?", publisher.ID).Count(&books).Error
+	if err != nil {
+		return publisher, err
+	}
+	if books > 0 {
+		return publisher, ErrInUse
+	}
+
+	result := m.db.Model(&publisher).Where("version = ?", publisher.Version).Updates(map[string]interface{}{
+		"name_key":   nil,
+		"deleted_at": time.Now(),
+	})
+	if result.Error != nil {
+		return publisher, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return publisher, ErrConflict
+	}
+	return publisher, nil
+}
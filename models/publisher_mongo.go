@@ -0,0 +1,208 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+const publisherCollection = "publishers"
+
+// mongoPublisher document layout of an Publisher
+type mongoPublisher struct {
+	ID        uint       `bson:"_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at"`
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+	Name      string     `bson:"name"`
+	NameKey   *string    `bson:"name_key,omitempty"`
+	Version   uint       `bson:"version"`
+}
+
+func (d mongoPublisher) toPublisher() Publisher {
+	publisher := Publisher{
+		Name:    d.Name,
+		NameKey: d.NameKey,
+		Version: d.Version,
+	}
+	publisher.ID = d.ID
+	publisher.CreatedAt = d.CreatedAt
+	publisher.UpdatedAt = d.UpdatedAt
+	if d.DeletedAt != nil {
+		publisher.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+	}
+	return publisher
+}
+
+type MongoPublisherModel struct {
+	db *mongo.Database
+}
+
+func NewMongoPublisherModel(db *mongo.Database) *MongoPublisherModel {
+	return &MongoPublisherModel{db: db}
+}
+
+func (m *MongoPublisherModel) collection() *mongo.Collection {
+	return m.db.Collection(publisherCollection)
+}
+
+func (m *MongoPublisherModel) GetAllPublisher(query Query) ([]Publisher, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := publisherQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoPublisher
+	total, err := publisherQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	publisher := make([]Publisher, 0, len(documents))
+	for _, document := range documents {
+		publisher = append(publisher, document.toPublisher())
+	}
+
+	page := query.page(total)
+	if len(publisher) > query.Limit {
+		publisher = publisher[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, publisher[len(publisher)-1])
+	}
+	return publisher, page, nil
+}
+
+func (m *MongoPublisherModel) GetPublisher(publisherId int) (Publisher, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoPublisher
+	if err := m.collection().FindOne(ctx, withID(publisherId)).Decode(&document); err != nil {
+		return Publisher{}, translateError(err)
+	}
+	return document.toPublisher(), nil
+}
+
+func (m *MongoPublisherModel) EnsurePublisher(name string) (Publisher, error) {
+	find := func() (Publisher, error) {
+		ctx, cancel := mongoContext()
+		defer cancel()
+
+		var document mongoPublisher
+		err := m.collection().FindOne(ctx, bson.M{"name_key": NameKey(name)}).Decode(&document)
+		return document.toPublisher(), err
+	}
+
+	publisher, err := find()
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return publisher, translateError(err)
+	}
+
+	publisher, err = m.InsertPublisher(Publisher{Name: name})
+	if errors.Is(err, ErrDuplicate) {
+		// created in the meantime by another request
+		publisher, err = find()
+		err = translateError(err)
+	}
+	return publisher, err
+}
+
+func (m *MongoPublisherModel) InsertPublisher(publisher Publisher) (Publisher, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if publisher.ID == 0 {
+		id, err := nextSequence(ctx, m.db, publisherCollection)
+		if err != nil {
+			return publisher, err
+		}
+		publisher.ID = id
+	}
+
+	now := time.Now()
+	key := NameKey(publisher.Name)
+	publisher.CreatedAt = now
+	publisher.UpdatedAt = now
+	publisher.NameKey = &key
+	publisher.Version = 1
+
+	document := mongoPublisher{
+		ID:        publisher.ID,
+		CreatedAt: publisher.CreatedAt,
+		UpdatedAt: publisher.UpdatedAt,
+		Name:      publisher.Name,
+		NameKey:   publisher.NameKey,
+		Version:   publisher.Version,
+	}
+
+	if _, err := m.collection().InsertOne(ctx, document); err != nil {
+		return publisher, translateError(err)
+	}
+	return publisher, nil
+}
+
+func (m *MongoPublisherModel) EditPublisher(newPublisher Publisher, publisherId int) (Publisher, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"name":       newPublisher.Name,
+			"name_key":   NameKey(newPublisher.Name),
+			"updated_at": time.Now(),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	var document mongoPublisher
+	err := m.collection().FindOneAndUpdate(ctx, withVersion(publisherId, newPublisher.Version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Publisher{}, versionError(ctx, m.collection(), publisherId, newPublisher.Version)
+	}
+	if err != nil {
+		return Publisher{}, translateError(err)
+	}
+	return document.toPublisher(), nil
+}
+
+func (m *MongoPublisherModel) DeletePublisher(publisherId int, version uint) (Publisher, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// books keep pointing at their publisher, unlink them first
+	books, err := m.db.Collection(bookCollection).CountDocuments(ctx, bson.M{
+		"publisher_id": publisherId,
+		"deleted_at":   bson.M{"$exists": false},
+	})
+	if err != nil {
+		return Publisher{}, err
+	}
+	if books > 0 {
+		return Publisher{}, ErrInUse
+	}
+
+	update := bson.M{
+		"$set":   bson.M{"deleted_at": time.Now()},
+		"$unset": bson.M{"name_key": ""},
+	}
+
+	var document mongoPublisher
+	err = m.collection().FindOneAndUpdate(ctx, withVersion(publisherId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Publisher{}, versionError(ctx, m.collection(), publisherId, version)
+	}
+	if err != nil {
+		return Publisher{}, translateError(err)
+	}
+	return document.toPublisher(), nil
+}
@@ -0,0 +1,491 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//ErrInvalidQuery the list query names an unknown field or carries a bad
+//value or cursor
+var ErrInvalidQuery = errors.New("invalid query")
+
+// list limits
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+// filter operators
+const (
+	FilterEqual    = "eq"
+	FilterNotEqual = "ne"
+	FilterContains = "contains"
+	FilterFrom     = "gte"
+	FilterBefore   = "lt"
+	FilterIn       = "in"
+)
+
+//Filter restrict a list to the records whose Field matches Value, the
+//Value of an in filter is a []interface{} of the accepted values
+type Filter struct {
+	Field string
+	Op    string
+	Value interface{}
+}
+
+//Sort order a list by Field, ties are always broken by id
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+//Query paging, filtering and sorting of a list, every backend implements
+//it. Cursor takes over from Offset once set
+type Query struct {
+	Limit   int
+	Offset  int
+	Cursor  string
+	Filters []Filter
+	Sort    []Sort
+}
+
+//Page where a list result sits in the whole filtered list
+type Page struct {
+	Total      int64
+	Limit      int
+	Offset     int
+	NextCursor string
+}
+
+// fieldKind type of a queryable field, decides how filter and cursor
+// values are read
+type fieldKind int
+
+const (
+	kindString fieldKind = iota
+	kindUint
+	kindTime
+	kindBool
+)
+
+// queryField column behind a queryable field. A link field lives in a
+// link table: gorm matches it with the link condition, taking the list
+// of accepted values, mongo with the column as a dotted key or array. It
+// only supports eq and in. linkFacet selects the id and value pairs of
+// the link table a facet of a link field counts
+type queryField struct {
+	column    string
+	kind      fieldKind
+	sortable  bool
+	facet     bool
+	link      string
+	linkFacet string
+}
+
+// querySchema queryable fields of a model keyed by their api name
+type querySchema map[string]queryField
+
+// cursor position after the last record of a page, together with the
+// sort it belongs to
+type cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// sortable record exposing the values it is sorted by
+type sortable interface {
+	sortValue(field string) interface{}
+}
+
+// normalize check the query against the schema, apply the limits and end
+// the sort with the id so the order is total
+func (s querySchema) normalize(query Query) (Query, error) {
+	if query.Limit <= 0 {
+		query.Limit = DefaultLimit
+	}
+	if query.Limit > MaxLimit {
+		query.Limit = MaxLimit
+	}
+	if query.Offset < 0 {
+		return query, fmt.Errorf("%w: offset must not be negative", ErrInvalidQuery)
+	}
+
+	for i, filter := range query.Filters {
+		field, ok := s[filter.Field]
+		if !ok {
+			return query, fmt.Errorf("%w: unknown filter %v", ErrInvalidQuery, filter.Field)
+		}
+		value, err := field.filterValue(filter)
+		if err != nil {
+			return query, fmt.Errorf("%w: filter %v %v", ErrInvalidQuery, filter.Field, err)
+		}
+		switch {
+		case field.link != "" && filter.Op != FilterEqual && filter.Op != FilterIn:
+			return query, fmt.Errorf("%w: filter %v does not support %v", ErrInvalidQuery, filter.Field, filter.Op)
+		case filter.Op == FilterContains && field.kind == kindString:
+		case filter.Op == FilterEqual, filter.Op == FilterNotEqual, filter.Op == FilterFrom, filter.Op == FilterBefore, filter.Op == FilterIn:
+		default:
+			return query, fmt.Errorf("%w: filter %v does not support %v", ErrInvalidQuery, filter.Field, filter.Op)
+		}
+		query.Filters[i].Value = value
+	}
+
+	// ids are unique, nothing sorts after them
+	sorts := make([]Sort, 0, len(query.Sort)+1)
+	for _, sort := range query.Sort {
+		field, ok := s[sort.Field]
+		if !ok || !field.sortable {
+			return query, fmt.Errorf("%w: can not sort by %v", ErrInvalidQuery, sort.Field)
+		}
+		sorts = append(sorts, sort)
+		if sort.Field == "id" {
+			break
+		}
+	}
+	if len(sorts) == 0 || sorts[len(sorts)-1].Field != "id" {
+		sorts = append(sorts, Sort{Field: "id"})
+	}
+	query.Sort = sorts
+
+	return query, nil
+}
+
+// filterValue typed value of a filter, every value of an in filter is
+// converted on its own
+func (f queryField) filterValue(filter Filter) (interface{}, error) {
+	if filter.Op != FilterIn {
+		return f.value(filter.Value)
+	}
+
+	list, ok := filter.Value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("has an invalid value %v", filter.Value)
+	}
+	values := make([]interface{}, 0, len(list))
+	for _, item := range list {
+		value, err := f.value(item)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// value convert a filter or cursor value to the kind of the field
+func (f queryField) value(value interface{}) (interface{}, error) {
+	switch f.kind {
+	case kindTime:
+		// local time, sqlite compares the times as text
+		switch v := value.(type) {
+		case time.Time:
+			return v.Local(), nil
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			return t.Local(), err
+		}
+	case kindUint:
+		switch v := value.(type) {
+		case uint:
+			return v, nil
+		case int:
+			if v >= 0 {
+				return uint(v), nil
+			}
+		case float64:
+			if v >= 0 && v == float64(uint(v)) {
+				return uint(v), nil
+			}
+		case string:
+			if n, err := strconv.ParseUint(v, 10, 32); err == nil {
+				return uint(n), nil
+			}
+		}
+	case kindString:
+		if v, ok := value.(string); ok {
+			return v, nil
+		}
+	case kindBool:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return b, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("has an invalid value %v", value)
+}
+
+// sortKey text form of a sort, a cursor only applies to the sort it was
+// issued for
+func sortKey(sorts []Sort) string {
+	keys := make([]string, 0, len(sorts))
+	for _, sort := range sorts {
+		if sort.Desc {
+			keys = append(keys, "-"+sort.Field)
+		} else {
+			keys = append(keys, sort.Field)
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+// encodeCursor opaque cursor pointing after record
+func encodeCursor(sorts []Sort, record sortable) string {
+	c := cursor{Sort: sortKey(sorts)}
+	for _, sort := range sorts {
+		value := record.sortValue(sort.Field)
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.RFC3339Nano)
+		}
+		c.Values = append(c.Values, value)
+	}
+
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor the sort values a cursor points after
+func (s querySchema) decodeCursor(query Query) ([]interface{}, error) {
+	invalid := fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+
+	raw, err := base64.RawURLEncoding.DecodeString(query.Cursor)
+	if err != nil {
+		return nil, invalid
+	}
+
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil || len(c.Values) != len(query.Sort) {
+		return nil, invalid
+	}
+	if c.Sort != sortKey(query.Sort) {
+		return nil, fmt.Errorf("%w: cursor belongs to another sort", ErrInvalidQuery)
+	}
+
+	values := make([]interface{}, len(c.Values))
+	for i, sort := range query.Sort {
+		if values[i], err = s[sort.Field].value(c.Values[i]); err != nil {
+			return nil, invalid
+		}
+	}
+	return values, nil
+}
+
+// page page info of a list with total matching records, NextCursor is
+// left to the caller which knows whether more records follow
+func (q Query) page(total int64) Page {
+	page := Page{Total: total, Limit: q.Limit, Offset: q.Offset}
+	if q.Cursor != "" {
+		page.Offset = 0
+	}
+	return page
+}
+
+// gormFilter restrict db to the query filters
+func (s querySchema) gormFilter(db *gorm.DB, query Query) *gorm.DB {
+	for _, filter := range query.Filters {
+		column := s[filter.Field].column
+
+		if link := s[filter.Field].link; link != "" {
+			values, ok := filter.Value.([]interface{})
+			if !ok {
+				values = []interface{}{filter.Value}
+			}
+			db = db.Where(link, values)
+			continue
+		}
+
+		switch filter.Op {
+		case FilterEqual:
+			db = db.Where(column+" = ?", filter.Value)
+		case FilterNotEqual:
+			db = db.Where(column+" <> ?", filter.Value)
+		case FilterContains:
+			db = db.Where("LOWER("+column+") LIKE ? ESCAPE '!'", "%"+escapeLike(strings.ToLower(filter.Value.(string)))+"%")
+		case FilterFrom:
+			db = db.Where(column+" >= ?", filter.Value)
+		case FilterBefore:
+			db = db.Where(column+" < ?", filter.Value)
+		case FilterIn:
+			db = db.Where(column+" IN ?", filter.Value)
+		}
+	}
+	return db
+}
+
+// gormFind run the query against model, filling dest with up to limit+1
+// records so the caller can tell whether another page follows
+func (s querySchema) gormFind(db *gorm.DB, model interface{}, dest interface{}, query Query) (int64, error) {
+	var total int64
+	if err := s.gormFilter(db.Model(model), query).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	list := s.gormFilter(db.Model(model), query)
+
+	if query.Cursor != "" {
+		values, err := s.decodeCursor(query)
+		if err != nil {
+			return 0, err
+		}
+		clause, args := s.gormAfter(query.Sort, values)
+		list = list.Where(clause, args...)
+	} else {
+		list = list.Offset(query.Offset)
+	}
+
+	for _, sort := range query.Sort {
+		if sort.Desc {
+			list = list.Order(s[sort.Field].column + " desc")
+		} else {
+			list = list.Order(s[sort.Field].column + " asc")
+		}
+	}
+
+	return total, list.Limit(query.Limit + 1).Find(dest).Error
+}
+
+// gormAfter keyset condition matching the records after values in the
+// sort order: a > x OR (a = x AND b > y) OR ...
+func (s querySchema) gormAfter(sorts []Sort, values []interface{}) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	for i, sort := range sorts {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, s[sorts[j].Field].column+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if sort.Desc {
+			op = " < ?"
+		}
+		parts = append(parts, s[sort.Field].column+op)
+		args = append(args, values[i])
+
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+// escapeLike escape the LIKE wildcards of a user supplied pattern, '!'
+// is an escape character every backend reads the same way
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+//FacetValue number of records sharing one value of a field
+type FacetValue struct {
+	Value interface{}
+	Count int64
+}
+
+//Facets value counts per field of the records matching a query
+type Facets map[string][]FacetValue
+
+// facetFields check the requested facets against the schema, no fields
+// means every facet
+func (s querySchema) facetFields(fields []string, limit int) ([]string, int, error) {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	if len(fields) == 0 {
+		for name, field := range s {
+			if field.facet {
+				fields = append(fields, name)
+			}
+		}
+		sort.Strings(fields)
+	}
+
+	for _, name := range fields {
+		if !s[name].facet {
+			return nil, 0, fmt.Errorf("%w: no facet %v", ErrInvalidQuery, name)
+		}
+	}
+	return fields, limit, nil
+}
+
+// facetValue typed value of a facet bucket as read back from a backend
+func (f queryField) facetValue(value interface{}) interface{} {
+	if f.kind != kindUint {
+		return fmt.Sprint(value)
+	}
+	switch v := value.(type) {
+	case int32:
+		return uint(v)
+	case int64:
+		return uint(v)
+	}
+	n, _ := strconv.ParseUint(fmt.Sprint(value), 10, 32)
+	return uint(n)
+}
+
+// gormFacets count the records per value of each field, most frequent
+// values first, with one GROUP BY per field
+func (s querySchema) gormFacets(db *gorm.DB, model interface{}, query Query, fields []string, limit int) (Facets, error) {
+	facets := Facets{}
+
+	for _, name := range fields {
+		field := s[name]
+
+		var rows []struct {
+			Value string
+			Count int64
+		}
+
+		column := field.column
+		var list *gorm.DB
+		if field.linkFacet != "" {
+			// a record counts once for each value it is linked to
+			column = "link.value"
+			list = db.Table("("+field.linkFacet+") AS link").
+				Where("link.id IN (?)", s.gormFilter(db.Model(model), query).Select("id"))
+		} else {
+			// records without a value are not a facet value
+			list = s.gormFilter(db.Model(model), query).Where(column+" <> ?", field.empty())
+		}
+		err := list.
+			Select(column + " AS value, COUNT(*) AS count").
+			Group(column).
+			Order("count desc").
+			Order(column + " asc").
+			Limit(limit).
+			Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+
+		values := make([]FacetValue, 0, len(rows))
+		for _, row := range rows {
+			values = append(values, FacetValue{Value: field.facetValue(row.Value), Count: row.Count})
+		}
+		facets[name] = values
+	}
+
+	return facets, nil
+}
+
+// empty zero value of the field as stored
+func (f queryField) empty() interface{} {
+	if f.kind == kindUint {
+		return 0
+	}
+	return ""
+}
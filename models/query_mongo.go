@@ -0,0 +1,160 @@
+package models
+
+import (
+	"context"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoKey document key of a field, the column names double as keys
+// except for the id
+func (s querySchema) mongoKey(field string) string {
+	if column := s[field].column; column != "id" {
+		return column
+	}
+	return "_id"
+}
+
+// mongoFilter live documents matching the query filters
+func (s querySchema) mongoFilter(query Query) bson.M {
+	conditions := bson.A{notDeleted}
+
+	for _, filter := range query.Filters {
+		key := s.mongoKey(filter.Field)
+
+		switch filter.Op {
+		case FilterEqual:
+			conditions = append(conditions, bson.M{key: filter.Value})
+		case FilterNotEqual:
+			conditions = append(conditions, bson.M{key: bson.M{"$ne": filter.Value}})
+		case FilterContains:
+			pattern := primitive.Regex{Pattern: regexp.QuoteMeta(filter.Value.(string)), Options: "i"}
+			conditions = append(conditions, bson.M{key: pattern})
+		case FilterFrom:
+			conditions = append(conditions, bson.M{key: bson.M{"$gte": filter.Value}})
+		case FilterBefore:
+			conditions = append(conditions, bson.M{key: bson.M{"$lt": filter.Value}})
+		case FilterIn:
+			conditions = append(conditions, bson.M{key: bson.M{"$in": filter.Value}})
+		}
+	}
+
+	return bson.M{"$and": conditions}
+}
+
+// mongoFind run the query against collection, filling dest with up to
+// limit+1 documents so the caller can tell whether another page follows
+func (s querySchema) mongoFind(ctx context.Context, collection *mongo.Collection, dest interface{}, query Query) (int64, error) {
+	filter := s.mongoFilter(query)
+
+	total, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	find := options.Find().SetLimit(int64(query.Limit + 1))
+
+	if query.Cursor != "" {
+		values, err := s.decodeCursor(query)
+		if err != nil {
+			return 0, err
+		}
+		filter = bson.M{"$and": bson.A{filter, s.mongoAfter(query.Sort, values)}}
+	} else {
+		find.SetSkip(int64(query.Offset))
+	}
+
+	sort := bson.D{}
+	for _, field := range query.Sort {
+		direction := 1
+		if field.Desc {
+			direction = -1
+		}
+		sort = append(sort, bson.E{Key: s.mongoKey(field.Field), Value: direction})
+	}
+	find.SetSort(sort)
+
+	cursor, err := collection.Find(ctx, filter, find)
+	if err != nil {
+		return 0, err
+	}
+	return total, cursor.All(ctx, dest)
+}
+
+// mongoAfter keyset condition matching the documents after values in the
+// sort order, see gormAfter
+func (s querySchema) mongoAfter(sorts []Sort, values []interface{}) bson.M {
+	clauses := bson.A{}
+
+	for i, sort := range sorts {
+		clause := bson.M{}
+		for j := 0; j < i; j++ {
+			clause[s.mongoKey(sorts[j].Field)] = values[j]
+		}
+		op := "$gt"
+		if sort.Desc {
+			op = "$lt"
+		}
+		clause[s.mongoKey(sort.Field)] = bson.M{op: values[i]}
+
+		clauses = append(clauses, clause)
+	}
+
+	return bson.M{"$or": clauses}
+}
+
+// mongoFacets count the documents per value of each field in a single
+// aggregation, one $facet pipeline per field
+func (s querySchema) mongoFacets(ctx context.Context, collection *mongo.Collection, query Query, fields []string, limit int) (Facets, error) {
+	pipelines := bson.M{}
+	for _, name := range fields {
+		field := s[name]
+		key := s.mongoKey(name)
+
+		pipeline := bson.A{
+			bson.M{"$match": bson.M{key: bson.M{"$nin": bson.A{field.empty(), nil}}}},
+			bson.M{"$group": bson.M{"_id": "$" + key, "count": bson.M{"$sum": 1}}},
+			bson.M{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+			bson.M{"$limit": limit},
+		}
+		if field.linkFacet != "" {
+			// a document counts once for each value of the array
+			pipeline = append(bson.A{bson.M{"$unwind": "$" + key}}, pipeline...)
+		}
+		pipelines[name] = pipeline
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: s.mongoFilter(query)}},
+		{{Key: "$facet", Value: pipelines}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []map[string][]struct {
+		Value interface{} `bson:"_id"`
+		Count int64       `bson:"count"`
+	}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	facets := Facets{}
+	for _, name := range fields {
+		values := []FacetValue{}
+		if len(results) > 0 {
+			for _, bucket := range results[0][name] {
+				values = append(values, FacetValue{Value: s[name].facetValue(bucket.Value), Count: bucket.Count})
+			}
+		}
+		facets[name] = values
+	}
+	return facets, nil
+}
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuery(t *testing.T) {
+	t.Run("normalize ends the sort with the id", func(t *testing.T) {
+		query, err := bookQuery.normalize(Query{Sort: []Sort{{Field: "title", Desc: true}}})
+		assert.Nil(t, err)
+		assert.Equal(t, DefaultLimit, query.Limit)
+		assert.Equal(t, []Sort{{Field: "title", Desc: true}, {Field: "id"}}, query.Sort)
+	})
+
+	t.Run("normalize caps the limit", func(t *testing.T) {
+		query, _ := bookQuery.normalize(Query{Limit: 1000})
+		assert.Equal(t, MaxLimit, query.Limit)
+	})
+
+	t.Run("normalize rejects unknown fields", func(t *testing.T) {
+		_, err := bookQuery.normalize(Query{Sort: []Sort{{Field: "password"}}})
+		assert.True(t, errors.Is(err, ErrInvalidQuery))
+
+		_, err = userQuery.normalize(Query{Filters: []Filter{{Field: "password", Op: FilterEqual, Value: "x"}}})
+		assert.True(t, errors.Is(err, ErrInvalidQuery))
+
+		_, err = bookQuery.normalize(Query{Filters: []Filter{{Field: "created_at", Op: FilterContains, Value: "x"}}})
+		assert.True(t, errors.Is(err, ErrInvalidQuery))
+	})
+
+	t.Run("normalize converts every value of an in filter", func(t *testing.T) {
+		query, err := bookQuery.normalize(Query{Filters: []Filter{{Field: "category_id", Op: FilterIn, Value: []interface{}{"3", 4}}}})
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{uint(3), uint(4)}, query.Filters[0].Value)
+
+		_, err = bookQuery.normalize(Query{Filters: []Filter{{Field: "year", Op: FilterIn, Value: 1999}}})
+		assert.True(t, errors.Is(err, ErrInvalidQuery))
+
+		_, err = bookQuery.normalize(Query{Filters: []Filter{{Field: "tag", Op: FilterContains, Value: "x"}}})
+		assert.True(t, errors.Is(err, ErrInvalidQuery))
+	})
+
+	t.Run("cursor round trip", func(t *testing.T) {
+		query, _ := bookQuery.normalize(Query{Sort: []Sort{{Field: "created_at"}}})
+
+		book := Book{Title: "Alfabet"}
+		book.ID = 7
+		book.CreatedAt = time.Date(2021, 8, 17, 10, 0, 0, 123, time.Local)
+
+		query.Cursor = encodeCursor(query.Sort, book)
+		values, err := bookQuery.decodeCursor(query)
+		assert.Nil(t, err)
+		assert.True(t, book.CreatedAt.Equal(values[0].(time.Time)))
+		assert.Equal(t, uint(7), values[1])
+	})
+
+	t.Run("cursor of another sort", func(t *testing.T) {
+		query, _ := bookQuery.normalize(Query{Sort: []Sort{{Field: "title"}}})
+		query.Cursor = encodeCursor([]Sort{{Field: "author"}, {Field: "id"}}, Book{})
+
+		_, err := bookQuery.decodeCursor(query)
+		assert.True(t, errors.Is(err, ErrInvalidQuery))
+	})
+}
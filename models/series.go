@@ -0,0 +1,156 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Model Series
+
+//Series works meant to be read together, each work keeps its own place
+//in the reading order
+type Series struct {
+	gorm.Model
+	Title   string `gorm:"size:255"`
+	Version uint   `gorm:"not null;default:1"`
+}
+
+// seriesQuery fields a series list can be filtered and sorted by
+var seriesQuery = querySchema{
+	"id":         {column: "id", kind: kindUint, sortable: true},
+	"title":      {column: "title", kind: kindString, sortable: true},
+	"created_at": {column: "created_at", kind: kindTime, sortable: true},
+	"updated_at": {column: "updated_at", kind: kindTime, sortable: true},
+}
+
+func (s Series) sortValue(field string) interface{} {
+	switch field {
+	case "title":
+		return s.Title
+	case "created_at":
+		return s.CreatedAt
+	case "updated_at":
+		return s.UpdatedAt
+	}
+	return s.ID
+}
+
+type GormSeriesModel struct {
+	db *gorm.DB
+}
+
+func NewSeriesModel(db *gorm.DB) *GormSeriesModel {
+	return &GormSeriesModel{db: db}
+}
+
+// Interface Series
+
+type SeriesModel interface {
+	GetAllSeries(query Query) ([]Series, Page, error)
+	GetSeries(seriesId int) (Series, error)
+	InsertSeries(Series) (Series, error)
+	EditSeries(series Series, seriesId int) (Series, error)
+	DeleteSeries(seriesId int, version uint) (Series, error)
+}
+
+func (m *GormSeriesModel) GetAllSeries(query Query) ([]Series, Page, error) {
+	query, err := seriesQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var series []Series
+	total, err := seriesQuery.gormFind(m.db, &Series{}, &series, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(series) > query.Limit {
+		series = series[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, series[len(series)-1])
+	}
+	return series, page, nil
+}
+
+func (m *GormSeriesModel) GetSeries(seriesId int) (Series, error) {
+	var series Series
+	if err := m.db.First(&series, seriesId).Error; err != nil {
+		return series, translateError(err)
+	}
+	return series, nil
+}
+
+func (m *GormSeriesModel) InsertSeries(series Series) (Series, error) {
+	series.Version = 1
+	if err := m.db.Save(&series).Error; err != nil {
+		return series, translateError(err)
+	}
+	return series, nil
+}
+
+func (m *GormSeriesModel) EditSeries(newSeries Series, seriesId int) (Series, error) {
+	var series Series
+	if err := m.db.First(&series, seriesId).Error; err != nil {
+		return series, translateError(err)
+	}
+
+	if err := checkVersion(series.Version, newSeries.Version); err != nil {
+		return series, err
+	}
+
+	// the update only applies to the version read above
+	result := m.db.Model(&series).Where("version = ?", series.Version).Updates(map[string]interface{}{
+		"title":   newSeries.Title,
+		"version": series.Version + 1,
+	})
+	if result.Error != nil {
+		return series, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return series, ErrConflict
+	}
+
+	return m.GetSeries(seriesId)
+}
+
+func (m *GormSeriesModel) DeleteSeries(seriesId int, version uint) (Series, error) {
+	var series Series
+	if err := m.db.First(&series, seriesId).Error; err != nil {
+		return series, translateError(err)
+	}
+
+	if err := checkVersion(series.Version, version); err != nil {
+		return series, err
+	}
+
+	// works keep pointing at their series, take them out first
+	var works int64
+	if err := m.db.Model(&Work{}).Where("series_id = ?", series.ID).Count(&works).Error; err != nil {
+		return series, err
+	}
+	if works > 0 {
+		return series, ErrInUse
+	}
+
+	result := m.db.Where("version = ?", series.Version).Delete(&series)
+	if result.Error != nil {
+		return series, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return series, ErrConflict
+	}
+	return series, nil
+}
+
+// gormCheckSeries make sure a work points at a live series, if any
+func gormCheckSeries(db *gorm.DB, seriesId *uint) error {
+	if seriesId == nil {
+		return nil
+	}
+	if err := db.First(&Series{}, *seriesId).Error; err != nil {
+		return fmt.Errorf("series %w", translateError(err))
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+const seriesCollection = "series"
+
+// mongoSeries document layout of a Series
+type mongoSeries struct {
+	ID        uint       `bson:"_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at"`
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+	Title     string     `bson:"title"`
+	Version   uint       `bson:"version"`
+}
+
+func (d mongoSeries) toSeries() Series {
+	series := Series{
+		Title:   d.Title,
+		Version: d.Version,
+	}
+	series.ID = d.ID
+	series.CreatedAt = d.CreatedAt
+	series.UpdatedAt = d.UpdatedAt
+	if d.DeletedAt != nil {
+		series.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+	}
+	return series
+}
+
+type MongoSeriesModel struct {
+	db *mongo.Database
+}
+
+func NewMongoSeriesModel(db *mongo.Database) *MongoSeriesModel {
+	return &MongoSeriesModel{db: db}
+}
+
+func (m *MongoSeriesModel) collection() *mongo.Collection {
+	return m.db.Collection(seriesCollection)
+}
+
+func (m *MongoSeriesModel) GetAllSeries(query Query) ([]Series, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := seriesQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoSeries
+	total, err := seriesQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	series := make([]Series, 0, len(documents))
+	for _, document := range documents {
+		series = append(series, document.toSeries())
+	}
+
+	page := query.page(total)
+	if len(series) > query.Limit {
+		series = series[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, series[len(series)-1])
+	}
+	return series, page, nil
+}
+
+func (m *MongoSeriesModel) GetSeries(seriesId int) (Series, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoSeries
+	if err := m.collection().FindOne(ctx, withID(seriesId)).Decode(&document); err != nil {
+		return Series{}, translateError(err)
+	}
+	return document.toSeries(), nil
+}
+
+func (m *MongoSeriesModel) InsertSeries(series Series) (Series, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if series.ID == 0 {
+		id, err := nextSequence(ctx, m.db, seriesCollection)
+		if err != nil {
+			return series, err
+		}
+		series.ID = id
+	}
+
+	now := time.Now()
+	series.CreatedAt = now
+	series.UpdatedAt = now
+	series.Version = 1
+
+	document := mongoSeries{
+		ID:        series.ID,
+		CreatedAt: series.CreatedAt,
+		UpdatedAt: series.UpdatedAt,
+		Title:     series.Title,
+		Version:   series.Version,
+	}
+
+	if _, err := m.collection().InsertOne(ctx, document); err != nil {
+		return series, translateError(err)
+	}
+	return series, nil
+}
+
+func (m *MongoSeriesModel) EditSeries(newSeries Series, seriesId int) (Series, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	update := bson.M{
+		"$set": bson.M{
+			"title":      newSeries.Title,
+			"updated_at": time.Now(),
+		},
+		"$inc": bson.M{"version": 1},
+	}
+
+	var document mongoSeries
+	err := m.collection().FindOneAndUpdate(ctx, withVersion(seriesId, newSeries.Version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Series{}, versionError(ctx, m.collection(), seriesId, newSeries.Version)
+	}
+	if err != nil {
+		return Series{}, translateError(err)
+	}
+	return document.toSeries(), nil
+}
+
+func (m *MongoSeriesModel) DeleteSeries(seriesId int, version uint) (Series, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// works keep pointing at their series, take them out first
+	works, err := m.db.Collection(workCollection).CountDocuments(ctx, bson.M{
+		"series_id":  seriesId,
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return Series{}, err
+	}
+	if works > 0 {
+		return Series{}, ErrInUse
+	}
+
+	update := bson.M{"$set": bson.M{"deleted_at": time.Now()}}
+
+	var document mongoSeries
+	err = m.collection().FindOneAndUpdate(ctx, withVersion(seriesId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Series{}, versionError(ctx, m.collection(), seriesId, version)
+	}
+	if err != nil {
+		return Series{}, translateError(err)
+	}
+	return document.toSeries(), nil
+}
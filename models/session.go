@@ -0,0 +1,213 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//ErrInvalidRefreshToken refresh token is unknown, expired or its session revoked
+var ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+//ErrRefreshTokenReused an already rotated refresh token came back, the
+//whole token family (session) has been revoked
+var ErrRefreshTokenReused = errors.New("refresh token reused, session revoked")
+
+//ErrSessionNotFound no active session with the given id for the user
+var ErrSessionNotFound = fmt.Errorf("session %w", ErrNotFound)
+
+//RefreshTokenTTL lifetime of a session, extended on every refresh
+var RefreshTokenTTL = 30 * 24 * time.Hour
+
+// Model Session
+
+//Session one logged in device, every refresh token issued to it belongs
+//to the same token family
+type Session struct {
+	gorm.Model
+	UserID     uint `gorm:"index"`
+	UserAgent  string
+	IP         string
+	LastUsedAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+//RefreshToken issued refresh token, only its hash is stored
+type RefreshToken struct {
+	gorm.Model
+	SessionID uint   `gorm:"index"`
+	TokenHash string `gorm:"size:64;uniqueIndex"`
+	UsedAt    *time.Time
+}
+
+// Active report whether the session can still be used
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+type GormSessionModel struct {
+	db *gorm.DB
+}
+
+func NewSessionModel(db *gorm.DB) *GormSessionModel {
+	return &GormSessionModel{db: db}
+}
+
+// Interface Session
+
+type SessionModel interface {
+	CreateSession(session Session, refreshToken string) (Session, error)
+	RotateRefreshToken(oldToken, newToken string) (Session, error)
+	GetSessions(userId int) ([]Session, error)
+	RevokeSession(userId, sessionId int) error
+	RevokeUserSessions(userId int) (int, error)
+	IsSessionActive(sessionId int) (bool, error)
+	PurgeSessions(before time.Time) (int, error)
+}
+
+// hashToken refresh tokens are stored hashed, a leaked table can not be
+// replayed
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m *GormSessionModel) CreateSession(session Session, refreshToken string) (Session, error) {
+	now := time.Now()
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(RefreshTokenTTL)
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+		token := RefreshToken{SessionID: session.ID, TokenHash: hashToken(refreshToken)}
+		return tx.Create(&token).Error
+	})
+	if err != nil {
+		return session, err
+	}
+	return session, nil
+}
+
+func (m *GormSessionModel) RotateRefreshToken(oldToken, newToken string) (Session, error) {
+	var session Session
+	var reused bool
+
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		var token RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(oldToken)).First(&token).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if err := tx.First(&session, token.SessionID).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if !session.Active(now) {
+			return ErrInvalidRefreshToken
+		}
+
+		// only one caller can mark the token used, a second one is a reuse
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			session.RevokedAt = &now
+			return tx.Model(&session).Update("revoked_at", now).Error
+		}
+
+		next := RefreshToken{SessionID: session.ID, TokenHash: hashToken(newToken)}
+		if err := tx.Create(&next).Error; err != nil {
+			return err
+		}
+
+		session.LastUsedAt = now
+		session.ExpiresAt = now.Add(RefreshTokenTTL)
+		return tx.Save(&session).Error
+	})
+	if err != nil {
+		return session, err
+	}
+	if reused {
+		return session, ErrRefreshTokenReused
+	}
+	return session, nil
+}
+
+func (m *GormSessionModel) GetSessions(userId int) ([]Session, error) {
+	var session []Session
+	err := m.db.
+		Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, time.Now()).
+		Order("last_used_at desc").
+		Find(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (m *GormSessionModel) RevokeSession(userId, sessionId int) error {
+	result := m.db.Model(&Session{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", sessionId, userId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+//RevokeUserSessions log the user out everywhere, the number of sessions
+//revoked
+func (m *GormSessionModel) RevokeUserSessions(userId int) (int, error) {
+	result := m.db.Model(&Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now())
+	return int(result.RowsAffected), result.Error
+}
+
+func (m *GormSessionModel) IsSessionActive(sessionId int) (bool, error) {
+	var session Session
+	if err := m.db.First(&session, sessionId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return session.Active(time.Now()), nil
+}
+
+//PurgeSessions delete the sessions which expired or were revoked before
+//the given time together with their refresh tokens, the number of
+//sessions deleted
+func (m *GormSessionModel) PurgeSessions(before time.Time) (int, error) {
+	var purged int64
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		stale := tx.Unscoped().Model(&Session{}).Select("id").Where("expires_at < ? OR revoked_at < ?", before, before)
+
+		if err := tx.Unscoped().Where("session_id IN (?)", stale).Delete(&RefreshToken{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("expires_at < ? OR revoked_at < ?", before, before).Delete(&Session{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return int(purged), err
+}
@@ -0,0 +1,277 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const sessionCollection = "sessions"
+const refreshTokenCollection = "refresh_tokens"
+
+// mongoSession document layout of a Session
+type mongoSession struct {
+	ID         uint       `bson:"_id"`
+	CreatedAt  time.Time  `bson:"created_at"`
+	UpdatedAt  time.Time  `bson:"updated_at"`
+	UserID     uint       `bson:"user_id"`
+	UserAgent  string     `bson:"user_agent"`
+	IP         string     `bson:"ip"`
+	LastUsedAt time.Time  `bson:"last_used_at"`
+	ExpiresAt  time.Time  `bson:"expires_at"`
+	RevokedAt  *time.Time `bson:"revoked_at,omitempty"`
+}
+
+func (d mongoSession) toSession() Session {
+	session := Session{
+		UserID:     d.UserID,
+		UserAgent:  d.UserAgent,
+		IP:         d.IP,
+		LastUsedAt: d.LastUsedAt,
+		ExpiresAt:  d.ExpiresAt,
+		RevokedAt:  d.RevokedAt,
+	}
+	session.ID = d.ID
+	session.CreatedAt = d.CreatedAt
+	session.UpdatedAt = d.UpdatedAt
+	return session
+}
+
+// mongoRefreshToken document layout of a RefreshToken, keyed by its hash
+type mongoRefreshToken struct {
+	TokenHash string     `bson:"_id"`
+	SessionID uint       `bson:"session_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	UsedAt    *time.Time `bson:"used_at,omitempty"`
+}
+
+type MongoSessionModel struct {
+	db *mongo.Database
+}
+
+func NewMongoSessionModel(db *mongo.Database) *MongoSessionModel {
+	return &MongoSessionModel{db: db}
+}
+
+func (m *MongoSessionModel) CreateSession(session Session, refreshToken string) (Session, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	id, err := nextSequence(ctx, m.db, sessionCollection)
+	if err != nil {
+		return session, err
+	}
+
+	now := time.Now()
+	session.ID = id
+	session.CreatedAt = now
+	session.UpdatedAt = now
+	session.LastUsedAt = now
+	session.ExpiresAt = now.Add(RefreshTokenTTL)
+
+	document := mongoSession{
+		ID:         session.ID,
+		CreatedAt:  session.CreatedAt,
+		UpdatedAt:  session.UpdatedAt,
+		UserID:     session.UserID,
+		UserAgent:  session.UserAgent,
+		IP:         session.IP,
+		LastUsedAt: session.LastUsedAt,
+		ExpiresAt:  session.ExpiresAt,
+	}
+	if _, err := m.db.Collection(sessionCollection).InsertOne(ctx, document); err != nil {
+		return session, err
+	}
+
+	token := mongoRefreshToken{TokenHash: hashToken(refreshToken), SessionID: session.ID, CreatedAt: now}
+	if _, err := m.db.Collection(refreshTokenCollection).InsertOne(ctx, token); err != nil {
+		return session, err
+	}
+
+	return session, nil
+}
+
+func (m *MongoSessionModel) RotateRefreshToken(oldToken, newToken string) (Session, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	now := time.Now()
+	tokens := m.db.Collection(refreshTokenCollection)
+	sessions := m.db.Collection(sessionCollection)
+
+	// marking the token used is atomic, only one caller gets the document
+	var token mongoRefreshToken
+	err := tokens.FindOneAndUpdate(ctx,
+		bson.M{"_id": hashToken(oldToken), "used_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"used_at": now}},
+	).Decode(&token)
+
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		if err := tokens.FindOne(ctx, bson.M{"_id": hashToken(oldToken)}).Decode(&token); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				return Session{}, ErrInvalidRefreshToken
+			}
+			return Session{}, err
+		}
+
+		var document mongoSession
+		err := sessions.FindOneAndUpdate(ctx,
+			bson.M{"_id": token.SessionID},
+			bson.M{"$set": bson.M{"revoked_at": now, "updated_at": now}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&document)
+		if err != nil {
+			return Session{}, err
+		}
+		return document.toSession(), ErrRefreshTokenReused
+	}
+	if err != nil {
+		return Session{}, err
+	}
+
+	var document mongoSession
+	err = sessions.FindOneAndUpdate(ctx,
+		bson.M{
+			"_id":        token.SessionID,
+			"revoked_at": bson.M{"$exists": false},
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{
+			"last_used_at": now,
+			"expires_at":   now.Add(RefreshTokenTTL),
+			"updated_at":   now,
+		}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Session{}, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return Session{}, err
+	}
+
+	next := mongoRefreshToken{TokenHash: hashToken(newToken), SessionID: document.ID, CreatedAt: now}
+	if _, err := tokens.InsertOne(ctx, next); err != nil {
+		return Session{}, err
+	}
+
+	return document.toSession(), nil
+}
+
+func (m *MongoSessionModel) GetSessions(userId int) ([]Session, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	filter := bson.M{
+		"user_id":    userId,
+		"revoked_at": bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}
+	cursor, err := m.db.Collection(sessionCollection).Find(ctx, filter,
+		options.Find().SetSort(bson.M{"last_used_at": -1}))
+	if err != nil {
+		return nil, err
+	}
+
+	var documents []mongoSession
+	if err := cursor.All(ctx, &documents); err != nil {
+		return nil, err
+	}
+
+	session := make([]Session, 0, len(documents))
+	for _, document := range documents {
+		session = append(session, document.toSession())
+	}
+	return session, nil
+}
+
+func (m *MongoSessionModel) RevokeSession(userId, sessionId int) error {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	now := time.Now()
+	result, err := m.db.Collection(sessionCollection).UpdateOne(ctx,
+		bson.M{"_id": sessionId, "user_id": userId, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now, "updated_at": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (m *MongoSessionModel) RevokeUserSessions(userId int) (int, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	now := time.Now()
+	result, err := m.db.Collection(sessionCollection).UpdateMany(ctx,
+		bson.M{"user_id": userId, "revoked_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"revoked_at": now, "updated_at": now}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return int(result.ModifiedCount), nil
+}
+
+func (m *MongoSessionModel) IsSessionActive(sessionId int) (bool, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoSession
+	err := m.db.Collection(sessionCollection).FindOne(ctx, bson.M{"_id": sessionId}).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return document.toSession().Active(time.Now()), nil
+}
+
+func (m *MongoSessionModel) PurgeSessions(before time.Time) (int, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	sessions := m.db.Collection(sessionCollection)
+	stale := bson.M{"$or": bson.A{
+		bson.M{"expires_at": bson.M{"$lt": before}},
+		bson.M{"revoked_at": bson.M{"$lt": before}},
+	}}
+
+	cursor, err := sessions.Find(ctx, stale, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var documents []struct {
+		ID uint `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &documents); err != nil {
+		return 0, err
+	}
+	if len(documents) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]uint, 0, len(documents))
+	for _, document := range documents {
+		ids = append(ids, document.ID)
+	}
+
+	// tokens first, a failure leaves sessions which are purged next time
+	if _, err := m.db.Collection(refreshTokenCollection).DeleteMany(ctx, bson.M{"session_id": bson.M{"$in": ids}}); err != nil {
+		return 0, err
+	}
+	result, err := sessions.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, err
+	}
+	return int(result.DeletedCount), nil
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMongoRotateRefreshToken(t *testing.T) {
+	sessions := NewMongoSessionModel(mongoTestDB(t))
+
+	session, err := sessions.CreateSession(Session{UserID: 1}, "token-1")
+	assert.NoError(t, err)
+
+	t.Run("a token is rotated once", func(t *testing.T) {
+		rotated, err := sessions.RotateRefreshToken("token-1", "token-2")
+		assert.NoError(t, err)
+		assert.Equal(t, session.ID, rotated.ID)
+
+		_, err = sessions.RotateRefreshToken("unknown", "token-3")
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("a reused token revokes the session", func(t *testing.T) {
+		_, err := sessions.RotateRefreshToken("token-1", "token-3")
+		assert.ErrorIs(t, err, ErrRefreshTokenReused)
+
+		active, err := sessions.IsSessionActive(int(session.ID))
+		assert.NoError(t, err)
+		assert.False(t, active)
+
+		_, err = sessions.RotateRefreshToken("token-2", "token-3")
+		assert.ErrorIs(t, err, ErrInvalidRefreshToken)
+	})
+
+	t.Run("only one concurrent rotation of a token succeeds", func(t *testing.T) {
+		_, err := sessions.CreateSession(Session{UserID: 2}, "race")
+		assert.NoError(t, err)
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 8)
+		for i := 0; i < cap(errs); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, err := sessions.RotateRefreshToken("race", fmt.Sprintf("race-%v", i))
+				errs <- err
+			}(i)
+		}
+		wg.Wait()
+		close(errs)
+
+		succeeded := 0
+		for err := range errs {
+			if err == nil {
+				succeeded++
+			}
+		}
+		assert.Equal(t, 1, succeeded)
+	})
+}
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+//Stocktake how the barcodes scanned at a location compare to the copies
+//the catalogue expects there. Found copies are where they should be,
+//Missing ones were expected but not scanned, Misplaced ones were scanned
+//but are shelved elsewhere, Unexpected ones were scanned while on loan,
+//lost or in repair, and Unknown barcodes match no live copy. Skipped
+//copies changed while the stocktake was applied and were left as they are
+type Stocktake struct {
+	Location   string
+	Scanned    int
+	Found      []Copy
+	Missing    []Copy
+	Misplaced  []Copy
+	Unexpected []Copy
+	Unknown    []string
+	Skipped    []Copy
+	Applied    bool
+}
+
+//StockChange what a stocktake writes to one copy. It only applies while
+//the copy still has the Status and Location it was reconciled with, the
+//copy is then seen at SeenAt and moves to NewStatus and NewLocation
+type StockChange struct {
+	CopyID      uint
+	BookID      uint
+	Status      string
+	Location    string
+	NewStatus   string
+	NewLocation string
+	SeenAt      *time.Time
+}
+
+// released the change puts the copy back in stock, the hold queue of the
+// book gets it first
+func (c StockChange) released() bool {
+	return c.NewStatus == CopyAvailable && c.Status != CopyAvailable
+}
+
+//TakeStock reconcile the scanned barcodes against the available copies
+//at location, or the whole library when location is empty. With apply
+//the outcome is written back: scanned copies are marked seen, misplaced
+//ones move to location, lost ones scanned are available again and missing
+//ones are marked lost. Each copy is written only if it is still as it was
+//reconciled, the others end up in Skipped
+func TakeStock(copies CopyModel, policy LoanPolicy, location string, barcodes []string, apply bool) (Stocktake, error) {
+	stocktake := Stocktake{Location: location}
+
+	scanned := scannedBarcodes(barcodes)
+	stocktake.Scanned = len(scanned)
+
+	found, err := copiesByBarcode(copies, scanned)
+	if err != nil {
+		return stocktake, err
+	}
+
+	expected, err := expectedCopies(copies, location)
+	if err != nil {
+		return stocktake, err
+	}
+
+	seen := make(map[uint]bool, len(found))
+	for _, barcode := range scanned {
+		copy, ok := found[barcode]
+		if !ok {
+			stocktake.Unknown = append(stocktake.Unknown, barcode)
+			continue
+		}
+		seen[copy.ID] = true
+
+		switch {
+		case copy.Status != CopyAvailable:
+			stocktake.Unexpected = append(stocktake.Unexpected, copy)
+		case location != "" && copy.Location != location:
+			stocktake.Misplaced = append(stocktake.Misplaced, copy)
+		default:
+			stocktake.Found = append(stocktake.Found, copy)
+		}
+	}
+
+	for _, copy := range expected {
+		if !seen[copy.ID] {
+			stocktake.Missing = append(stocktake.Missing, copy)
+		}
+	}
+
+	if !apply {
+		return stocktake, nil
+	}
+	if err := stocktake.apply(copies, policy, time.Now()); err != nil {
+		return stocktake, err
+	}
+	stocktake.Applied = true
+	return stocktake, nil
+}
+
+// apply write the outcome of the stocktake back to the copies, each list
+// is replaced by the copies as saved. A copy changed since it was read is
+// moved to Skipped instead
+func (s *Stocktake) apply(copies CopyModel, policy LoanPolicy, now time.Time) error {
+	update := func(list []Copy, change func(*StockChange)) ([]Copy, error) {
+		saved := list[:0]
+		for _, copy := range list {
+			stockChange := StockChange{
+				CopyID:      copy.ID,
+				BookID:      copy.BookID,
+				Status:      copy.Status,
+				Location:    copy.Location,
+				NewStatus:   copy.Status,
+				NewLocation: copy.Location,
+				SeenAt:      copy.LastSeenAt,
+			}
+			change(&stockChange)
+
+			applied, err := copies.ApplyStockChange(stockChange, policy)
+			if errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+				s.Skipped = append(s.Skipped, copy)
+				continue
+			}
+			if err != nil {
+				return saved, err
+			}
+			saved = append(saved, applied)
+		}
+		return saved, nil
+	}
+
+	seen := func(change *StockChange) {
+		change.SeenAt = &now
+	}
+
+	var err error
+	if s.Found, err = update(s.Found, seen); err != nil {
+		return err
+	}
+	if s.Misplaced, err = update(s.Misplaced, func(change *StockChange) {
+		seen(change)
+		change.NewLocation = s.Location
+	}); err != nil {
+		return err
+	}
+	if s.Unexpected, err = update(s.Unexpected, func(change *StockChange) {
+		seen(change)
+		// a lost copy on the shelf is back in stock, loans and repairs
+		// are settled on their own
+		if change.Status == CopyLost {
+			change.NewStatus = CopyAvailable
+			if s.Location != "" {
+				change.NewLocation = s.Location
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	s.Missing, err = update(s.Missing, func(change *StockChange) {
+		change.NewStatus = CopyLost
+	})
+	return err
+}
+
+// scannedBarcodes normalized barcodes in scan order, without blanks or
+// repeated scans
+func scannedBarcodes(barcodes []string) []string {
+	scanned := make([]string, 0, len(barcodes))
+	seen := make(map[string]bool, len(barcodes))
+	for _, barcode := range barcodes {
+		barcode = NormalizeBarcode(barcode)
+		if barcode == "" || seen[barcode] {
+			continue
+		}
+		seen[barcode] = true
+		scanned = append(scanned, barcode)
+	}
+	return scanned
+}
+
+// copiesByBarcode the live copies carrying the barcodes, looked up a page
+// of barcodes at a time
+func copiesByBarcode(copies CopyModel, barcodes []string) (map[string]Copy, error) {
+	found := make(map[string]Copy, len(barcodes))
+
+	for start := 0; start < len(barcodes); start += MaxLimit {
+		end := start + MaxLimit
+		if end > len(barcodes) {
+			end = len(barcodes)
+		}
+
+		values := make([]interface{}, 0, end-start)
+		for _, barcode := range barcodes[start:end] {
+			values = append(values, barcode)
+		}
+
+		page, _, err := copies.GetAllCopy(Query{
+			Limit:   MaxLimit,
+			Filters: []Filter{{Field: "barcode", Op: FilterIn, Value: values}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, copy := range page {
+			if copy.Barcode != nil {
+				found[*copy.Barcode] = copy
+			}
+		}
+	}
+	return found, nil
+}
+
+// expectedCopies every available copy at location, or anywhere when
+// location is empty
+func expectedCopies(copies CopyModel, location string) ([]Copy, error) {
+	query := Query{
+		Limit:   MaxLimit,
+		Filters: []Filter{{Field: "status", Op: FilterEqual, Value: CopyAvailable}},
+	}
+	if location != "" {
+		query.Filters = append(query.Filters, Filter{Field: "location", Op: FilterEqual, Value: location})
+	}
+
+	var expected []Copy
+	for {
+		page, next, err := copies.GetAllCopy(query)
+		if err != nil {
+			return nil, err
+		}
+		expected = append(expected, page...)
+
+		if next.NextCursor == "" {
+			return expected, nil
+		}
+		query.Cursor = next.NextCursor
+	}
+}
@@ -0,0 +1,31 @@
+package models
+
+import "strings"
+
+//BookTag free-form tag of a book
+type BookTag struct {
+	BookID uint   `gorm:"primaryKey"`
+	Tag    string `gorm:"size:64;primaryKey;index"`
+}
+
+//NormalizeTag lower case tag with runs of whitespace collapsed, so the
+//spellings of a tag are one tag
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+//NormalizeTags normalized tags without blanks and repeats, in the order
+//given
+func NormalizeTags(tags []string) []string {
+	var normalized []string
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag = NormalizeTag(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
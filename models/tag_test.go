@@ -0,0 +1,12 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	assert.Equal(t, []string{"science fiction", "space"}, NormalizeTags([]string{" Science  Fiction", "space", "", "science fiction"}))
+	assert.Nil(t, NormalizeTags([]string{" "}))
+}
@@ -1,20 +1,31 @@
 package models
 
 import (
-	"project-api/api/middlewares"
+	"errors"
+	"fmt"
+	"time"
 
 	"gorm.io/gorm"
 )
 
+// Roles
+
+const (
+	RoleAdmin     = "admin"
+	RoleLibrarian = "librarian"
+	RoleMember    = "member"
+)
+
 // Model Customer
 
 type User struct {
 	gorm.Model
 	Name  string
-	Email string
+	Email string `gorm:"size:255;uniqueIndex"`
 	//Gender   string `sql:"type:ENUM('male', 'female')"`
-	Password string `gorm:"<-:false"`
-	Token    string `gorm:"<-:false"`
+	Password string `json:"-"`
+	Role     string `gorm:"size:20;default:member"`
+	Version  uint   `gorm:"not null;default:1"`
 }
 
 // type Customer struct {
@@ -25,6 +36,32 @@ type User struct {
 // 	Token    string `json:"token" form:"token"`
 // }
 
+// userQuery fields a user list can be filtered and sorted by
+var userQuery = querySchema{
+	"id":         {column: "id", kind: kindUint, sortable: true},
+	"name":       {column: "name", kind: kindString, sortable: true},
+	"email":      {column: "email", kind: kindString, sortable: true},
+	"role":       {column: "role", kind: kindString, sortable: true},
+	"created_at": {column: "created_at", kind: kindTime, sortable: true},
+	"updated_at": {column: "updated_at", kind: kindTime, sortable: true},
+}
+
+func (u User) sortValue(field string) interface{} {
+	switch field {
+	case "name":
+		return u.Name
+	case "email":
+		return u.Email
+	case "role":
+		return u.Role
+	case "created_at":
+		return u.CreatedAt
+	case "updated_at":
+		return u.UpdatedAt
+	}
+	return u.ID
+}
+
 type GormUserModel struct {
 	db *gorm.DB
 }
@@ -36,80 +73,160 @@ func NewUserModel(db *gorm.DB) *GormUserModel {
 // Interface Customer
 
 type UserModel interface {
-	GetAll() ([]User, error)
+	GetAll(query Query) ([]User, Page, error)
 	Get(userId int) (User, error)
+	GetByEmail(email string) (User, error)
 	Insert(User) (User, error)
 	Edit(user User, userId int) (User, error)
-	Delete(userId int) (User, error)
+	Delete(userId int, version uint) (User, error)
 	Login(email, password string) (User, error)
 }
 
-func (m *GormUserModel) GetAll() ([]User, error) {
+func (m *GormUserModel) GetAll(query Query) ([]User, Page, error) {
+	query, err := userQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
 	var user []User
-	if err := m.db.Find(&user).Error; err != nil {
-		return nil, err
+	total, err := userQuery.gormFind(m.db, &User{}, &user, query)
+	if err != nil {
+		return nil, Page{}, err
 	}
-	return user, nil
+
+	page := query.page(total)
+	if len(user) > query.Limit {
+		user = user[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, user[len(user)-1])
+	}
+	return user, page, nil
 }
 
 func (m *GormUserModel) Get(userId int) (User, error) {
 	var user User
-	if err := m.db.Find(&user, userId).Error; err != nil {
-		return user, err
+	if err := m.db.First(&user, userId).Error; err != nil {
+		return user, translateError(err)
+	}
+	return user, nil
+}
+
+func (m *GormUserModel) GetByEmail(email string) (User, error) {
+	var user User
+	if err := m.db.Where("email = ?", email).First(&user).Error; err != nil {
+		return user, translateError(err)
 	}
 	return user, nil
 }
 
 func (m *GormUserModel) Insert(user User) (User, error) {
-	if err := m.db.Save(&user).Error; err != nil {
+	if user.Role == "" {
+		user.Role = RoleMember
+	}
+	user.Version = 1
+
+	var err error
+	if user.Password, err = hashIfSet(user.Password); err != nil {
 		return user, err
 	}
+
+	if err := m.db.Save(&user).Error; err != nil {
+		return user, translateError(err)
+	}
 	return user, nil
 }
 
 func (m *GormUserModel) Edit(newUser User, userId int) (User, error) {
 	var user User
-	if err := m.db.Find(&user, "id=?", userId).Error; err != nil {
+	if err := m.db.First(&user, userId).Error; err != nil {
+		return user, translateError(err)
+	}
+
+	if err := checkVersion(user.Version, newUser.Version); err != nil {
 		return user, err
 	}
 
-	user.Name = newUser.Name
-	user.Email = newUser.Email
-	user.Password = newUser.Password
+	fields := map[string]interface{}{
+		"name":    newUser.Name,
+		"email":   newUser.Email,
+		"version": user.Version + 1,
+	}
+	if newUser.Role != "" {
+		fields["role"] = newUser.Role
+	}
+	if newUser.Password != "" {
+		hash, err := HashPassword(newUser.Password)
+		if err != nil {
+			return user, err
+		}
+		fields["password"] = hash
+	}
 
-	if err := m.db.Save(&user).Error; err != nil {
-		return user, err
+	// the update only applies to the version read above
+	result := m.db.Model(&user).Where("version = ?", user.Version).Updates(fields)
+	if result.Error != nil {
+		return user, translateError(result.Error)
 	}
-	return user, nil
+	if result.RowsAffected == 0 {
+		return user, ErrConflict
+	}
+
+	return m.Get(userId)
 }
 
-func (m *GormUserModel) Delete(userId int) (User, error) {
+func (m *GormUserModel) Delete(userId int, version uint) (User, error) {
 	var user User
-	if err := m.db.Find(&user, "id=?", userId).Error; err != nil {
-		return user, err
+	if err := m.db.First(&user, userId).Error; err != nil {
+		return user, translateError(err)
 	}
-	if err := m.db.Delete(&user).Error; err != nil {
+
+	if err := checkVersion(user.Version, version); err != nil {
 		return user, err
 	}
+
+	// the email is free for a new account
+	result := m.db.Model(&user).Where("version = ?", user.Version).Updates(map[string]interface{}{
+		"email":      deletedEmail(user.ID, user.Email),
+		"deleted_at": time.Now(),
+	})
+	if result.Error != nil {
+		return user, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return user, ErrConflict
+	}
 	return user, nil
 }
 
+// deletedEmail email a deleted user is left with, the id keeps it unique
+// and the old address stays readable
+func deletedEmail(userId uint, email string) string {
+	deleted := fmt.Sprintf("deleted-%v:%v", userId, email)
+	if len(deleted) > 255 {
+		deleted = deleted[:255]
+	}
+	return deleted
+}
+
 func (m *GormUserModel) Login(email, password string) (User, error) {
 	var user User
 	var err error
 
-	if err = m.db.Where("email = ? AND password = ?", email, password).First(&user).Error; err != nil {
+	err = translateError(m.db.Where("email = ?", email).First(&user).Error)
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		return user, err
 	}
 
-	user.Token, err = middlewares.CreateToken(int(user.ID))
-
-	if err != nil {
-		return user, err
+	if err = verifyPassword(user, err == nil, password); err != nil {
+		return User{}, err
 	}
 
-	if err := m.db.Save(user).Error; err != nil {
-		return user, err
+	if NeedsRehash(user.Password) {
+		if user.Password, err = HashPassword(password); err != nil {
+			return user, err
+		}
+		if err := m.db.Model(&user).Update("password", user.Password).Error; err != nil {
+			return user, err
+		}
 	}
 
 	return user, nil
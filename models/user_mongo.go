@@ -0,0 +1,241 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+const userCollection = "users"
+
+// mongoUser document layout of a User
+type mongoUser struct {
+	ID        uint       `bson:"_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	UpdatedAt time.Time  `bson:"updated_at"`
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+	Name      string     `bson:"name"`
+	Email     string     `bson:"email"`
+	Password  string     `bson:"password"`
+	Role      string     `bson:"role"`
+	Version   uint       `bson:"version"`
+}
+
+func (d mongoUser) toUser() User {
+	user := User{
+		Name:     d.Name,
+		Email:    d.Email,
+		Password: d.Password,
+		Role:     d.Role,
+		Version:  d.Version,
+	}
+	user.ID = d.ID
+	user.CreatedAt = d.CreatedAt
+	user.UpdatedAt = d.UpdatedAt
+	if d.DeletedAt != nil {
+		user.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+	}
+	return user
+}
+
+type MongoUserModel struct {
+	db *mongo.Database
+}
+
+func NewMongoUserModel(db *mongo.Database) *MongoUserModel {
+	return &MongoUserModel{db: db}
+}
+
+func (m *MongoUserModel) collection() *mongo.Collection {
+	return m.db.Collection(userCollection)
+}
+
+func (m *MongoUserModel) GetAll(query Query) ([]User, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := userQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoUser
+	total, err := userQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	user := make([]User, 0, len(documents))
+	for _, document := range documents {
+		user = append(user, document.toUser())
+	}
+
+	page := query.page(total)
+	if len(user) > query.Limit {
+		user = user[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, user[len(user)-1])
+	}
+	return user, page, nil
+}
+
+func (m *MongoUserModel) Get(userId int) (User, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoUser
+	if err := m.collection().FindOne(ctx, withID(userId)).Decode(&document); err != nil {
+		return User{}, translateError(err)
+	}
+	return document.toUser(), nil
+}
+
+func (m *MongoUserModel) GetByEmail(email string) (User, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	filter := bson.M{"email": email, "deleted_at": bson.M{"$exists": false}}
+
+	var document mongoUser
+	if err := m.collection().FindOne(ctx, filter).Decode(&document); err != nil {
+		return User{}, translateError(err)
+	}
+	return document.toUser(), nil
+}
+
+func (m *MongoUserModel) Insert(user User) (User, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if user.Role == "" {
+		user.Role = RoleMember
+	}
+
+	var err error
+	if user.Password, err = hashIfSet(user.Password); err != nil {
+		return user, err
+	}
+
+	if user.ID == 0 {
+		id, err := nextSequence(ctx, m.db, userCollection)
+		if err != nil {
+			return user, err
+		}
+		user.ID = id
+	}
+
+	now := time.Now()
+	user.CreatedAt = now
+	user.UpdatedAt = now
+	user.Version = 1
+
+	document := mongoUser{
+		ID:        user.ID,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+		Name:      user.Name,
+		Email:     user.Email,
+		Password:  user.Password,
+		Role:      user.Role,
+		Version:   user.Version,
+	}
+
+	if _, err := m.collection().InsertOne(ctx, document); err != nil {
+		return user, translateError(err)
+	}
+	return user, nil
+}
+
+func (m *MongoUserModel) Edit(newUser User, userId int) (User, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	fields := bson.M{
+		"name":       newUser.Name,
+		"email":      newUser.Email,
+		"updated_at": time.Now(),
+	}
+	if newUser.Role != "" {
+		fields["role"] = newUser.Role
+	}
+	if newUser.Password != "" {
+		hash, err := HashPassword(newUser.Password)
+		if err != nil {
+			return User{}, translateError(err)
+		}
+		fields["password"] = hash
+	}
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+
+	var document mongoUser
+	err := m.collection().FindOneAndUpdate(ctx, withVersion(userId, newUser.Version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, versionError(ctx, m.collection(), userId, newUser.Version)
+	}
+	if err != nil {
+		return User{}, translateError(err)
+	}
+	return document.toUser(), nil
+}
+
+func (m *MongoUserModel) Delete(userId int, version uint) (User, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// the email is free for a new account, see deletedEmail
+	update := bson.A{bson.M{"$set": bson.M{
+		"email":      bson.M{"$concat": bson.A{fmt.Sprintf("deleted-%v:", userId), "$email"}},
+		"deleted_at": time.Now(),
+	}}}
+
+	var document mongoUser
+	err := m.collection().FindOneAndUpdate(ctx, withVersion(userId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return User{}, versionError(ctx, m.collection(), userId, version)
+	}
+	if err != nil {
+		return User{}, translateError(err)
+	}
+	return document.toUser(), nil
+}
+
+func (m *MongoUserModel) Login(email, password string) (User, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	filter := bson.M{
+		"email":      email,
+		"deleted_at": bson.M{"$exists": false},
+	}
+
+	var document mongoUser
+	err := translateError(m.collection().FindOne(ctx, filter).Decode(&document))
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return User{}, err
+	}
+	user := document.toUser()
+
+	if err = verifyPassword(user, err == nil, password); err != nil {
+		return User{}, translateError(err)
+	}
+
+	if NeedsRehash(user.Password) {
+		if user.Password, err = HashPassword(password); err != nil {
+			return user, err
+		}
+		update := bson.M{"$set": bson.M{"password": user.Password}}
+		if _, err := m.collection().UpdateOne(ctx, withID(int(user.ID)), update); err != nil {
+			return user, err
+		}
+	}
+
+	return user, nil
+}
@@ -0,0 +1,173 @@
+package models
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Model Work
+
+//Work what its editions have in common, whatever the publisher, format
+//or language. A work can be part of a series, SeriesPosition is its
+//place in the reading order
+type Work struct {
+	gorm.Model
+	Title          string `gorm:"size:255"`
+	SeriesID       *uint  `gorm:"index"`
+	SeriesPosition uint
+	Version        uint `gorm:"not null;default:1"`
+}
+
+// workQuery fields a work list can be filtered and sorted by
+var workQuery = querySchema{
+	"id":              {column: "id", kind: kindUint, sortable: true},
+	"title":           {column: "title", kind: kindString, sortable: true},
+	"series_id":       {column: "series_id", kind: kindUint},
+	"series_position": {column: "series_position", kind: kindUint, sortable: true},
+	"created_at":      {column: "created_at", kind: kindTime, sortable: true},
+	"updated_at":      {column: "updated_at", kind: kindTime, sortable: true},
+}
+
+func (w Work) sortValue(field string) interface{} {
+	switch field {
+	case "title":
+		return w.Title
+	case "series_position":
+		return w.SeriesPosition
+	case "created_at":
+		return w.CreatedAt
+	case "updated_at":
+		return w.UpdatedAt
+	}
+	return w.ID
+}
+
+type GormWorkModel struct {
+	db *gorm.DB
+}
+
+func NewWorkModel(db *gorm.DB) *GormWorkModel {
+	return &GormWorkModel{db: db}
+}
+
+// Interface Work
+
+type WorkModel interface {
+	GetAllWork(query Query) ([]Work, Page, error)
+	GetWork(workId int) (Work, error)
+	InsertWork(Work) (Work, error)
+	EditWork(work Work, workId int) (Work, error)
+	DeleteWork(workId int, version uint) (Work, error)
+}
+
+func (m *GormWorkModel) GetAllWork(query Query) ([]Work, Page, error) {
+	query, err := workQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var work []Work
+	total, err := workQuery.gormFind(m.db, &Work{}, &work, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	page := query.page(total)
+	if len(work) > query.Limit {
+		work = work[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, work[len(work)-1])
+	}
+	return work, page, nil
+}
+
+func (m *GormWorkModel) GetWork(workId int) (Work, error) {
+	var work Work
+	if err := m.db.First(&work, workId).Error; err != nil {
+		return work, translateError(err)
+	}
+	return work, nil
+}
+
+func (m *GormWorkModel) InsertWork(work Work) (Work, error) {
+	if err := gormCheckSeries(m.db, work.SeriesID); err != nil {
+		return work, err
+	}
+
+	work.Version = 1
+	if err := m.db.Save(&work).Error; err != nil {
+		return work, translateError(err)
+	}
+	return work, nil
+}
+
+func (m *GormWorkModel) EditWork(newWork Work, workId int) (Work, error) {
+	var work Work
+	if err := m.db.First(&work, workId).Error; err != nil {
+		return work, translateError(err)
+	}
+
+	if err := checkVersion(work.Version, newWork.Version); err != nil {
+		return work, err
+	}
+
+	if err := gormCheckSeries(m.db, newWork.SeriesID); err != nil {
+		return work, err
+	}
+
+	// the update only applies to the version read above
+	result := m.db.Model(&work).Where("version = ?", work.Version).Updates(map[string]interface{}{
+		"title":           newWork.Title,
+		"series_id":       newWork.SeriesID,
+		"series_position": newWork.SeriesPosition,
+		"version":         work.Version + 1,
+	})
+	if result.Error != nil {
+		return work, translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return work, ErrConflict
+	}
+
+	return m.GetWork(workId)
+}
+
+func (m *GormWorkModel) DeleteWork(workId int, version uint) (Work, error) {
+	var work Work
+	if err := m.db.First(&work, workId).Error; err != nil {
+		return work, translateError(err)
+	}
+
+	if err := checkVersion(work.Version, version); err != nil {
+		return work, err
+	}
+
+	// editions keep pointing at their work, move them first
+	var editions int64
+	if err := m.db.Model(&Book{}).Where("work_id = ?", work.ID).Count(&editions).Error; err != nil {
+		return work, err
+	}
+	if editions > 0 {
+		return work, ErrInUse
+	}
+
+	result := m.db.Where("version = ?", work.Version).Delete(&work)
+	if result.Error != nil {
+		return work, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return work, ErrConflict
+	}
+	return work, nil
+}
+
+// gormCheckWork make sure a book points at a live work, if any
+func gormCheckWork(db *gorm.DB, workId *uint) error {
+	if workId == nil {
+		return nil
+	}
+	if err := db.First(&Work{}, *workId).Error; err != nil {
+		return fmt.Errorf("work %w", translateError(err))
+	}
+	return nil
+}
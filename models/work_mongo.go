@@ -0,0 +1,194 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"gorm.io/gorm"
+)
+
+const workCollection = "works"
+
+// mongoWork document layout of a Work
+type mongoWork struct {
+	ID             uint       `bson:"_id"`
+	CreatedAt      time.Time  `bson:"created_at"`
+	UpdatedAt      time.Time  `bson:"updated_at"`
+	DeletedAt      *time.Time `bson:"deleted_at,omitempty"`
+	Title          string     `bson:"title"`
+	SeriesID       *uint      `bson:"series_id,omitempty"`
+	SeriesPosition uint       `bson:"series_position"`
+	Version        uint       `bson:"version"`
+}
+
+func (d mongoWork) toWork() Work {
+	work := Work{
+		Title:          d.Title,
+		SeriesID:       d.SeriesID,
+		SeriesPosition: d.SeriesPosition,
+		Version:        d.Version,
+	}
+	work.ID = d.ID
+	work.CreatedAt = d.CreatedAt
+	work.UpdatedAt = d.UpdatedAt
+	if d.DeletedAt != nil {
+		work.DeletedAt = gorm.DeletedAt{Time: *d.DeletedAt, Valid: true}
+	}
+	return work
+}
+
+type MongoWorkModel struct {
+	db *mongo.Database
+}
+
+func NewMongoWorkModel(db *mongo.Database) *MongoWorkModel {
+	return &MongoWorkModel{db: db}
+}
+
+func (m *MongoWorkModel) collection() *mongo.Collection {
+	return m.db.Collection(workCollection)
+}
+
+func (m *MongoWorkModel) GetAllWork(query Query) ([]Work, Page, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	query, err := workQuery.normalize(query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	var documents []mongoWork
+	total, err := workQuery.mongoFind(ctx, m.collection(), &documents, query)
+	if err != nil {
+		return nil, Page{}, err
+	}
+
+	work := make([]Work, 0, len(documents))
+	for _, document := range documents {
+		work = append(work, document.toWork())
+	}
+
+	page := query.page(total)
+	if len(work) > query.Limit {
+		work = work[:query.Limit]
+		page.NextCursor = encodeCursor(query.Sort, work[len(work)-1])
+	}
+	return work, page, nil
+}
+
+func (m *MongoWorkModel) GetWork(workId int) (Work, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	var document mongoWork
+	if err := m.collection().FindOne(ctx, withID(workId)).Decode(&document); err != nil {
+		return Work{}, translateError(err)
+	}
+	return document.toWork(), nil
+}
+
+func (m *MongoWorkModel) InsertWork(work Work) (Work, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if err := checkReference(ctx, m.db, seriesCollection, "series", work.SeriesID); err != nil {
+		return work, err
+	}
+
+	if work.ID == 0 {
+		id, err := nextSequence(ctx, m.db, workCollection)
+		if err != nil {
+			return work, err
+		}
+		work.ID = id
+	}
+
+	now := time.Now()
+	work.CreatedAt = now
+	work.UpdatedAt = now
+	work.Version = 1
+
+	document := mongoWork{
+		ID:             work.ID,
+		CreatedAt:      work.CreatedAt,
+		UpdatedAt:      work.UpdatedAt,
+		Title:          work.Title,
+		SeriesID:       work.SeriesID,
+		SeriesPosition: work.SeriesPosition,
+		Version:        work.Version,
+	}
+
+	if _, err := m.collection().InsertOne(ctx, document); err != nil {
+		return work, translateError(err)
+	}
+	return work, nil
+}
+
+func (m *MongoWorkModel) EditWork(newWork Work, workId int) (Work, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	if err := checkReference(ctx, m.db, seriesCollection, "series", newWork.SeriesID); err != nil {
+		return Work{}, err
+	}
+
+	fields := bson.M{
+		"title":           newWork.Title,
+		"series_position": newWork.SeriesPosition,
+		"updated_at":      time.Now(),
+	}
+	update := bson.M{"$set": fields, "$inc": bson.M{"version": 1}}
+
+	if newWork.SeriesID != nil {
+		fields["series_id"] = *newWork.SeriesID
+	} else {
+		update["$unset"] = bson.M{"series_id": ""}
+	}
+
+	var document mongoWork
+	err := m.collection().FindOneAndUpdate(ctx, withVersion(workId, newWork.Version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Work{}, versionError(ctx, m.collection(), workId, newWork.Version)
+	}
+	if err != nil {
+		return Work{}, translateError(err)
+	}
+	return document.toWork(), nil
+}
+
+func (m *MongoWorkModel) DeleteWork(workId int, version uint) (Work, error) {
+	ctx, cancel := mongoContext()
+	defer cancel()
+
+	// editions keep pointing at their work, move them first
+	editions, err := m.db.Collection(bookCollection).CountDocuments(ctx, bson.M{
+		"work_id":    workId,
+		"deleted_at": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return Work{}, err
+	}
+	if editions > 0 {
+		return Work{}, ErrInUse
+	}
+
+	update := bson.M{"$set": bson.M{"deleted_at": time.Now()}}
+
+	var document mongoWork
+	err = m.collection().FindOneAndUpdate(ctx, withVersion(workId, version), update,
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&document)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return Work{}, versionError(ctx, m.collection(), workId, version)
+	}
+	if err != nil {
+		return Work{}, translateError(err)
+	}
+	return document.toWork(), nil
+}
@@ -0,0 +1,208 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//Schedule parsed cron expression, the times a job is due
+type Schedule struct {
+	spec   string
+	minute uint64
+	hour   uint64
+	dom    uint64
+	month  uint64
+	dow    uint64
+	// anyDay either day field is *, the other one alone decides the day
+	anyDay bool
+}
+
+// field range and names of one cron field
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors shorthands for common schedules
+var descriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+//Parse read a standard five field cron expression, minute hour
+//day-of-month month day-of-week, or one of the @daily like shorthands.
+//Fields take *, lists, ranges and steps, months and days their english
+//three letter names. A job restricted by both day fields runs on either,
+//as cron does
+func Parse(spec string) (Schedule, error) {
+	expression := strings.TrimSpace(spec)
+	if descriptor, ok := descriptors[strings.ToLower(expression)]; ok {
+		expression = descriptor
+	}
+
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("cron expression %q: expected 5 fields, got %v", spec, len(fields))
+	}
+
+	schedule := Schedule{spec: spec}
+	var err error
+	if schedule.minute, err = minuteField.parse(fields[0]); err != nil {
+		return Schedule{}, fmt.Errorf("cron expression %q: %w", spec, err)
+	}
+	if schedule.hour, err = hourField.parse(fields[1]); err != nil {
+		return Schedule{}, fmt.Errorf("cron expression %q: %w", spec, err)
+	}
+	if schedule.dom, err = domField.parse(fields[2]); err != nil {
+		return Schedule{}, fmt.Errorf("cron expression %q: %w", spec, err)
+	}
+	if schedule.month, err = monthField.parse(fields[3]); err != nil {
+		return Schedule{}, fmt.Errorf("cron expression %q: %w", spec, err)
+	}
+	if schedule.dow, err = dowField.parse(fields[4]); err != nil {
+		return Schedule{}, fmt.Errorf("cron expression %q: %w", spec, err)
+	}
+
+	// sunday is 0 and 7
+	if schedule.dow&(1<<7) != 0 {
+		schedule.dow |= 1
+	}
+	schedule.anyDay = strings.HasPrefix(fields[2], "*") || strings.HasPrefix(fields[4], "*")
+	return schedule, nil
+}
+
+//MustParse see Parse, panics on a bad expression
+func MustParse(spec string) Schedule {
+	schedule, err := Parse(spec)
+	if err != nil {
+		panic(err)
+	}
+	return schedule
+}
+
+func (s Schedule) String() string {
+	return s.spec
+}
+
+// parse bits of the values a field matches
+func (f field) parse(expression string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expression, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("%v: bad step %q", f.name, part[i+1:])
+			}
+			part = part[:i]
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("%v: empty range %q", f.name, part)
+			}
+		default:
+			var err error
+			if low, err = f.value(part); err != nil {
+				return 0, err
+			}
+			// 5/10 runs from 5 to the end of the range
+			if step == 1 {
+				high = low
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+// value one number or name of the field
+func (f field) value(text string) (int, error) {
+	if value, ok := f.names[strings.ToLower(text)]; ok {
+		return value, nil
+	}
+
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%v: bad value %q", f.name, text)
+	}
+	if value < f.min || value > f.max {
+		return 0, fmt.Errorf("%v: %v out of range %v-%v", f.name, value, f.min, f.max)
+	}
+	return value, nil
+}
+
+//Next first time after the given one the schedule is due, in the
+//location of after. The zero time when it never is
+func (s Schedule) Next(after time.Time) time.Time {
+	t := after.Truncate(time.Minute).Add(time.Minute)
+
+	// four years cover every day of month and day of week combination
+	limit := t.AddDate(4, 0, 1)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches the day of t is one the schedule runs on
+func (s Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.anyDay {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,73 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("bad expressions", func(t *testing.T) {
+		for _, spec := range []string{
+			"",
+			"* * * *",
+			"* * * * * *",
+			"60 * * * *",
+			"* 24 * * *",
+			"* * 0 * *",
+			"* * * 13 *",
+			"* * * * 8",
+			"*/0 * * * *",
+			"10-5 * * * *",
+			"* * * foo *",
+			"@fortnightly",
+		} {
+			_, err := Parse(spec)
+			assert.Error(t, err, spec)
+		}
+	})
+
+	t.Run("keeps the expression", func(t *testing.T) {
+		assert.Equal(t, "@daily", MustParse("@daily").String())
+		assert.Panics(t, func() { MustParse("* *") })
+	})
+}
+
+func TestScheduleNext(t *testing.T) {
+	// a wednesday
+	after := time.Date(2021, 3, 31, 10, 7, 30, 0, time.UTC)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2021, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	for _, test := range []struct {
+		spec string
+		next time.Time
+	}{
+		{"* * * * *", at(3, 31, 10, 8)},
+		{"*/15 * * * *", at(3, 31, 10, 15)},
+		{"5/20 * * * *", at(3, 31, 10, 25)},
+		{"0,30 9-17 * * *", at(3, 31, 10, 30)},
+		{"0 2 * * *", at(4, 1, 2, 0)},
+		{"@hourly", at(3, 31, 11, 0)},
+		{"@daily", at(4, 1, 0, 0)},
+		{"@weekly", at(4, 4, 0, 0)},
+		{"@monthly", at(4, 1, 0, 0)},
+		{"@yearly", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", at(4, 4, 0, 0)},
+		{"0 0 * * mon-fri", at(4, 1, 0, 0)},
+		{"0 0 * JUN *", at(6, 1, 0, 0)},
+		// 31 skips the months without one
+		{"0 0 31 * *", at(5, 31, 0, 0)},
+		// either day field matches
+		{"0 0 15 * fri", at(4, 2, 0, 0)},
+		{"0 0 29 2 *", time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	} {
+		assert.Equal(t, test.next, MustParse(test.spec).Next(after), test.spec)
+	}
+
+	t.Run("never due", func(t *testing.T) {
+		assert.True(t, MustParse("0 0 30 2 *").Next(after).IsZero())
+	})
+}
@@ -0,0 +1,175 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"project-api/models"
+	"project-api/search"
+)
+
+//AccrueFines overdue sweep, charges late loans their fines
+func AccrueFines(fines models.FineModel, policy models.FinePolicy) RunFunc {
+	return func(ctx context.Context) (string, error) {
+		accrual, err := fines.AccrueFines(policy, time.Now())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("charged %v loans %v", accrual.Loans, accrual.Amount), nil
+	}
+}
+
+//ExpireHolds end the ready holds nobody collected in time, their copies
+//go to the next in line
+func ExpireHolds(holds models.HoldModel, policy models.LoanPolicy) RunFunc {
+	return func(ctx context.Context) (string, error) {
+		expired, err := holds.ExpireHolds(policy, time.Now())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("expired %v holds", expired), nil
+	}
+}
+
+//PurgeSessions delete the sessions and refresh tokens which expired or
+//were revoked more than retention ago
+func PurgeSessions(sessions models.SessionModel, retention time.Duration) RunFunc {
+	return func(ctx context.Context) (string, error) {
+		purged, err := sessions.PurgeSessions(time.Now().Add(-retention))
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("purged %v sessions", purged), nil
+	}
+}
+
+//RebuildSearchIndex reload the search index of this server from books,
+//picking up the writes made through the other servers. It is a local job,
+//each server has an index of its own
+func RebuildSearchIndex(index *search.Index, books models.BookModel) RunFunc {
+	return func(ctx context.Context) (string, error) {
+		if err := index.Rebuild(books); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("indexed %v books", index.Len()), nil
+	}
+}
+
+//CirculationReport figures of the lending over the period up to the run
+type CirculationReport struct {
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	Loaned         int64     `json:"loaned"`
+	Returned       int64     `json:"returned"`
+	ActiveLoans    int64     `json:"active_loans"`
+	OverdueLoans   int64     `json:"overdue_loans"`
+	WaitingHolds   int64     `json:"waiting_holds"`
+	ReadyHolds     int64     `json:"ready_holds"`
+	FinesCharged   int64     `json:"fines_charged"`
+	FinesCollected int64     `json:"fines_collected"`
+}
+
+//ReportCirculation write a CirculationReport of the last period as the
+//output of the run
+func ReportCirculation(loans models.LoanModel, holds models.HoldModel, fines models.FineModel, period time.Duration) RunFunc {
+	return func(ctx context.Context) (string, error) {
+		to := time.Now()
+		report := CirculationReport{From: to.Add(-period), To: to}
+
+		counts := []struct {
+			count   *int64
+			find    func(models.Query) (models.Page, error)
+			filters []models.Filter
+		}{
+			{&report.Loaned, loanCount(loans), []models.Filter{
+				{Field: "loaned_at", Op: models.FilterFrom, Value: report.From},
+			}},
+			{&report.Returned, loanCount(loans), []models.Filter{
+				{Field: "returned_at", Op: models.FilterFrom, Value: report.From},
+			}},
+			{&report.ActiveLoans, loanCount(loans), []models.Filter{
+				{Field: "status", Op: models.FilterEqual, Value: models.LoanActive},
+			}},
+			{&report.OverdueLoans, loanCount(loans), []models.Filter{
+				{Field: "status", Op: models.FilterEqual, Value: models.LoanActive},
+				{Field: "due_at", Op: models.FilterBefore, Value: to},
+			}},
+			{&report.WaitingHolds, holdCount(holds), []models.Filter{
+				{Field: "status", Op: models.FilterEqual, Value: models.HoldWaiting},
+			}},
+			{&report.ReadyHolds, holdCount(holds), []models.Filter{
+				{Field: "status", Op: models.FilterEqual, Value: models.HoldReady},
+			}},
+		}
+		for _, count := range counts {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			page, err := count.find(models.Query{Limit: 1, Filters: count.filters})
+			if err != nil {
+				return "", err
+			}
+			*count.count = page.Total
+		}
+
+		charged, err := fineSum(ctx, fines, report.From, models.FineCharge)
+		if err != nil {
+			return "", err
+		}
+		collected, err := fineSum(ctx, fines, report.From, models.FinePayment)
+		if err != nil {
+			return "", err
+		}
+		report.FinesCharged, report.FinesCollected = charged, -collected
+
+		output, err := json.Marshal(report)
+		return string(output), err
+	}
+}
+
+// loanCount count loans by the page total of a loan list
+func loanCount(loans models.LoanModel) func(models.Query) (models.Page, error) {
+	return func(query models.Query) (models.Page, error) {
+		_, page, err := loans.GetAllLoan(query)
+		return page, err
+	}
+}
+
+// holdCount count holds by the page total of a hold list
+func holdCount(holds models.HoldModel) func(models.Query) (models.Page, error) {
+	return func(query models.Query) (models.Page, error) {
+		_, page, err := holds.GetAllHold(query)
+		return page, err
+	}
+}
+
+// fineSum total of the ledger entries of a kind booked since from
+func fineSum(ctx context.Context, fines models.FineModel, from time.Time, kind string) (int64, error) {
+	query := models.Query{
+		Limit: models.MaxLimit,
+		Filters: []models.Filter{
+			{Field: "kind", Op: models.FilterEqual, Value: kind},
+			{Field: "created_at", Op: models.FilterFrom, Value: from},
+		},
+	}
+
+	var sum int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		entries, page, err := fines.GetAllFineEntry(query)
+		if err != nil {
+			return 0, err
+		}
+		for _, entry := range entries {
+			sum += entry.Amount
+		}
+		if page.NextCursor == "" {
+			return sum, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
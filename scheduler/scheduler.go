@@ -0,0 +1,342 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	"project-api/models"
+
+	"github.com/labstack/gommon/log"
+)
+
+//ErrUnknownJob no job with the given name is scheduled
+var ErrUnknownJob = fmt.Errorf("job %w", models.ErrNotFound)
+
+// leaseMargin how long a lease outlives the timeout of its run, so a run
+// cut short still gets to record its outcome
+const leaseMargin = time.Minute
+
+// untimedLease lease of a job without timeout
+const untimedLease = time.Hour
+
+//RunFunc work of a job, what it returns is kept in the run history
+type RunFunc func(ctx context.Context) (string, error)
+
+//Job recurring work. Each run starts at a random point up to Jitter after
+//its scheduled time, so replicas do not hit the database at once, and is
+//cancelled after Timeout, zero Timeout never cancels. Jitter is expected
+//to be shorter than the time between two runs. A Local job works on the
+//state of the process rather than the storage, it runs on every server and
+//takes no lease, only a run still going on in this process holds it back
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Jitter   time.Duration
+	Timeout  time.Duration
+	Local    bool
+	Run      RunFunc
+}
+
+//Status a scheduled job and when it is due next
+type Status struct {
+	Job
+	Next time.Time
+}
+
+//Scheduler run jobs on their schedules within the process. Every run is
+//recorded through the job model and claimed with a lease first, of the
+//servers sharing a storage only one runs each scheduled run of a job
+type Scheduler struct {
+	jobs  models.JobModel
+	owner string
+
+	lock    sync.Mutex
+	entries []*Status
+	local   map[string]bool
+	random  *rand.Rand
+	cancel  context.CancelFunc
+	running sync.WaitGroup
+}
+
+//New scheduler recording its runs through jobs, owner names this server
+//in the leases and the run history, DefaultOwner when empty
+func New(jobs models.JobModel, owner string) *Scheduler {
+	if owner == "" {
+		owner = DefaultOwner()
+	}
+	return &Scheduler{
+		jobs:   jobs,
+		owner:  owner,
+		local:  map[string]bool{},
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+//DefaultOwner host name and process id of this server
+func DefaultOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%v-%v", host, os.Getpid())
+}
+
+//Add schedule a job, before Start
+func (s *Scheduler) Add(job Job) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if job.Name == "" || job.Run == nil {
+		return errors.New("scheduler: a job needs a name and a run function")
+	}
+	for _, entry := range s.entries {
+		if entry.Name == job.Name {
+			return fmt.Errorf("scheduler: job %v added twice", job.Name)
+		}
+	}
+
+	s.entries = append(s.entries, &Status{Job: job, Next: job.Schedule.Next(time.Now())})
+	return nil
+}
+
+//Jobs the scheduled jobs in the order they were added
+func (s *Scheduler) Jobs() []Status {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	jobs := make([]Status, 0, len(s.entries))
+	for _, entry := range s.entries {
+		jobs = append(jobs, *entry)
+	}
+	return jobs
+}
+
+//Start run every job on its schedule until Stop
+func (s *Scheduler) Start() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	for _, entry := range s.entries {
+		s.running.Add(1)
+		go s.loop(ctx, entry.Job)
+	}
+}
+
+//Stop cancel the running jobs and wait for them to return
+func (s *Scheduler) Stop() {
+	s.lock.Lock()
+	cancel := s.cancel
+	s.cancel = nil
+	s.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+		s.running.Wait()
+	}
+}
+
+//RunNow run a job right away, outside its schedule. ErrJobRunning when
+//it is running already, here or on another server
+func (s *Scheduler) RunNow(ctx context.Context, name string) (models.JobRun, error) {
+	s.lock.Lock()
+	var job *Job
+	for _, entry := range s.entries {
+		if entry.Name == name {
+			job = &entry.Job
+		}
+	}
+	s.lock.Unlock()
+
+	if job == nil {
+		return models.JobRun{}, ErrUnknownJob
+	}
+	return s.run(ctx, *job, time.Now().Truncate(time.Second))
+}
+
+// loop run the job on its schedule until ctx is done
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.running.Done()
+
+	for {
+		scheduled := job.Schedule.Next(time.Now())
+		if scheduled.IsZero() {
+			log.Warnf("job %v is never due, schedule %q", job.Name, job.Schedule)
+			return
+		}
+		s.setNext(job.Name, scheduled)
+
+		timer := time.NewTimer(time.Until(scheduled) + s.jitter(job.Jitter))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		run, err := s.run(ctx, job, scheduled)
+		switch {
+		case errors.Is(err, models.ErrJobRunning):
+			// another server took this run
+		case err != nil:
+			log.Errorf("job %v: %v", job.Name, err)
+		case run.Status != models.JobSucceeded:
+			log.Errorf("job %v %v: %v", job.Name, run.Status, run.Error)
+		}
+	}
+}
+
+// run claim the run of the job scheduled at the given time and run it,
+// the recorded run. A local job is claimed within this process only
+func (s *Scheduler) run(ctx context.Context, job Job, scheduled time.Time) (models.JobRun, error) {
+	started := time.Now()
+	lease := models.JobLease{
+		Job:         job.Name,
+		Owner:       s.owner,
+		ScheduledAt: scheduled,
+		LockedUntil: started.Add(untimedLease),
+	}
+	if job.Timeout > 0 {
+		lease.LockedUntil = started.Add(job.Timeout + leaseMargin)
+	}
+
+	release := func() {
+		s.jobs.ReleaseJobLease(lease, time.Now())
+	}
+	if job.Local {
+		if !s.claimLocal(job.Name) {
+			return models.JobRun{}, models.ErrJobRunning
+		}
+		release = func() {
+			s.releaseLocal(job.Name)
+		}
+	} else {
+		acquired, err := s.jobs.AcquireJobLease(lease, started)
+		if err != nil {
+			return models.JobRun{}, err
+		}
+		if !acquired {
+			return models.JobRun{}, models.ErrJobRunning
+		}
+	}
+
+	run, err := s.jobs.StartJobRun(models.JobRun{
+		Job:         job.Name,
+		Owner:       s.owner,
+		ScheduledAt: scheduled,
+		StartedAt:   started,
+	})
+	if err != nil {
+		release()
+		return run, err
+	}
+
+	runCtx := ctx
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	output, err := call(runCtx, job.Run)
+	finished := time.Now()
+	run.FinishedAt = &finished
+	run.Output = output
+	run.Status = models.JobSucceeded
+	if err != nil {
+		run.Status = models.JobFailed
+		run.Error = err.Error()
+	}
+	timedOut := errors.Is(err, context.DeadlineExceeded)
+	if timedOut {
+		// the work may still be going on, the lease runs out by itself
+		run.Status = models.JobTimedOut
+	}
+	// a local job is not waited for, it has no lease to run out
+	if !timedOut || job.Local {
+		defer release()
+	}
+
+	return s.jobs.FinishJobRun(run)
+}
+
+// call run the job, returning when it does or ctx is done, whichever is
+// first. A panic fails the run instead of the server
+func call(ctx context.Context, run RunFunc) (string, error) {
+	type result struct {
+		output string
+		err    error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- result{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		output, err := run(ctx)
+		done <- result{output, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.output, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// jitter random delay up to max
+func (s *Scheduler) jitter(max time.Duration) time.Duration {
+	if max <= 0 {
+		return 0
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return time.Duration(s.random.Int63n(int64(max)))
+}
+
+// claimLocal mark a local job running in this process, false when it is
+// already
+func (s *Scheduler) claimLocal(name string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.local[name] {
+		return false
+	}
+	s.local[name] = true
+	return true
+}
+
+// releaseLocal mark a local job done
+func (s *Scheduler) releaseLocal(name string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.local, name)
+}
+
+// setNext remember when the job is due next
+func (s *Scheduler) setNext(name string, next time.Time) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, entry := range s.entries {
+		if entry.Name == name {
+			entry.Next = next
+		}
+	}
+}
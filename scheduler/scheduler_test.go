@@ -0,0 +1,289 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var storage *util.Storage
+
+func TestMain(m *testing.M) {
+	setup()
+	os.Exit(m.Run())
+}
+
+func setup() {
+	// run against the in-memory driver unless DATABASE_DRIVER says otherwise
+	if os.Getenv("DATABASE_DRIVER") == "" {
+		os.Setenv("DATABASE_DRIVER", "memory")
+	}
+
+	// create database connection
+	config := config.GetConfig()
+	storage = util.DatabaseConnection(config)
+
+	// cleaning data before testing
+	if db := storage.DB; db != nil {
+		db.Migrator().DropTable(&models.JobRun{}, &models.JobLease{})
+		db.AutoMigrate(&models.JobRun{}, &models.JobLease{})
+	}
+	if db := storage.Mongo; db != nil {
+		models.DropMongoCollection(db, "job_runs")
+		models.DropMongoCollection(db, "job_leases")
+	}
+}
+
+// job scheduled every minute, named after the test
+func job(t *testing.T, run RunFunc) Job {
+	return Job{Name: t.Name(), Schedule: MustParse("* * * * *"), Run: run}
+}
+
+// history runs of the job recorded so far
+func history(t *testing.T, name string) []models.JobRun {
+	runs, _, err := storage.JobModel.GetAllJobRun(models.Query{
+		Filters: []models.Filter{{Field: "job", Op: models.FilterEqual, Value: name}},
+	})
+	assert.NoError(t, err)
+	return runs
+}
+
+func TestSchedulerRun(t *testing.T) {
+	slot := time.Now().Truncate(time.Minute)
+
+	t.Run("one replica runs a slot", func(t *testing.T) {
+		var calls int32
+		work := job(t, func(ctx context.Context) (string, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(50 * time.Millisecond)
+			return "done", nil
+		})
+
+		replicas := []*Scheduler{New(storage.JobModel, "a"), New(storage.JobModel, "b"), New(storage.JobModel, "c")}
+		errs := make([]error, len(replicas))
+		var wg sync.WaitGroup
+		for i, replica := range replicas {
+			wg.Add(1)
+			go func(i int, replica *Scheduler) {
+				defer wg.Done()
+				_, errs[i] = replica.run(context.Background(), work, slot)
+			}(i, replica)
+		}
+		wg.Wait()
+
+		running := 0
+		for _, err := range errs {
+			if errors.Is(err, models.ErrJobRunning) {
+				running++
+			} else {
+				assert.NoError(t, err)
+			}
+		}
+		assert.Equal(t, len(replicas)-1, running)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		runs := history(t, work.Name)
+		if assert.Len(t, runs, 1) {
+			assert.Equal(t, models.JobSucceeded, runs[0].Status)
+			assert.Equal(t, "done", runs[0].Output)
+			assert.NotNil(t, runs[0].FinishedAt)
+		}
+
+		t.Run("the slot is not run again", func(t *testing.T) {
+			for _, replica := range replicas {
+				_, err := replica.run(context.Background(), work, slot)
+				assert.ErrorIs(t, err, models.ErrJobRunning)
+			}
+		})
+
+		t.Run("the next slot is", func(t *testing.T) {
+			run, err := replicas[1].run(context.Background(), work, slot.Add(time.Minute))
+			assert.NoError(t, err)
+			assert.Equal(t, "b", run.Owner)
+			assert.Len(t, history(t, work.Name), 2)
+		})
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		work := job(t, func(ctx context.Context) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+		work.Timeout = 20 * time.Millisecond
+
+		replica := New(storage.JobModel, "a")
+		run, err := replica.run(context.Background(), work, slot)
+		assert.NoError(t, err)
+		assert.Equal(t, models.JobTimedOut, run.Status)
+
+		// the lease outlives the timeout, another replica waits for it
+		_, err = New(storage.JobModel, "b").run(context.Background(), work, slot.Add(time.Minute))
+		assert.ErrorIs(t, err, models.ErrJobRunning)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		work := job(t, func(ctx context.Context) (string, error) {
+			return "half", errors.New("broken")
+		})
+
+		run, err := New(storage.JobModel, "a").run(context.Background(), work, slot)
+		assert.NoError(t, err)
+		assert.Equal(t, models.JobFailed, run.Status)
+		assert.Equal(t, "half", run.Output)
+		assert.Equal(t, "broken", run.Error)
+
+		// released, another replica takes the next slot
+		_, err = New(storage.JobModel, "b").run(context.Background(), work, slot.Add(time.Minute))
+		assert.NoError(t, err)
+	})
+
+	t.Run("panicked", func(t *testing.T) {
+		work := job(t, func(ctx context.Context) (string, error) {
+			panic("boom")
+		})
+
+		run, err := New(storage.JobModel, "a").run(context.Background(), work, slot)
+		assert.NoError(t, err)
+		assert.Equal(t, models.JobFailed, run.Status)
+		assert.Equal(t, "panic: boom", run.Error)
+	})
+
+	t.Run("local", func(t *testing.T) {
+		started, finish := make(chan struct{}, 2), make(chan struct{})
+		work := job(t, func(ctx context.Context) (string, error) {
+			started <- struct{}{}
+			<-finish
+			return "reloaded", nil
+		})
+		work.Local = true
+
+		replicas := []*Scheduler{New(storage.JobModel, "a"), New(storage.JobModel, "b")}
+		errs := make(chan error, len(replicas))
+		for _, replica := range replicas {
+			go func(replica *Scheduler) {
+				_, err := replica.run(context.Background(), work, slot)
+				errs <- err
+			}(replica)
+		}
+		// every replica runs the slot
+		<-started
+		<-started
+
+		t.Run("not twice in one replica", func(t *testing.T) {
+			_, err := replicas[0].run(context.Background(), work, slot)
+			assert.ErrorIs(t, err, models.ErrJobRunning)
+		})
+
+		close(finish)
+		for range replicas {
+			assert.NoError(t, <-errs)
+		}
+		assert.Len(t, history(t, work.Name), len(replicas))
+	})
+}
+
+func TestSchedulerRunNow(t *testing.T) {
+	scheduler := New(storage.JobModel, "a")
+	assert.NoError(t, scheduler.Add(Job{
+		Name:     "report",
+		Schedule: MustParse("@daily"),
+		Run: func(ctx context.Context) (string, error) {
+			return "reported", nil
+		},
+	}))
+
+	t.Run("added twice", func(t *testing.T) {
+		assert.Error(t, scheduler.Add(Job{Name: "report", Run: func(ctx context.Context) (string, error) { return "", nil }}))
+		assert.Error(t, scheduler.Add(Job{Name: "empty"}))
+	})
+
+	t.Run("jobs", func(t *testing.T) {
+		jobs := scheduler.Jobs()
+		if assert.Len(t, jobs, 1) {
+			assert.Equal(t, "report", jobs[0].Name)
+			assert.True(t, jobs[0].Next.After(time.Now()))
+			assert.Equal(t, 0, jobs[0].Next.Hour()+jobs[0].Next.Minute())
+		}
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := scheduler.RunNow(context.Background(), "missing")
+		assert.ErrorIs(t, err, ErrUnknownJob)
+		assert.ErrorIs(t, err, models.ErrNotFound)
+	})
+
+	t.Run("run", func(t *testing.T) {
+		run, err := scheduler.RunNow(context.Background(), "report")
+		assert.NoError(t, err)
+		assert.Equal(t, models.JobSucceeded, run.Status)
+		assert.Equal(t, "reported", run.Output)
+		assert.Equal(t, "a", run.Owner)
+	})
+
+	t.Run("while a scheduled run is executing", func(t *testing.T) {
+		started, finish := make(chan struct{}), make(chan struct{})
+		var calls int32
+		work := job(t, func(ctx context.Context) (string, error) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+			}
+			<-finish
+			return "done", nil
+		})
+		assert.NoError(t, scheduler.Add(work))
+
+		done := make(chan error, 1)
+		go func() {
+			_, err := scheduler.run(context.Background(), work, time.Now().Truncate(time.Minute).Add(-time.Minute))
+			done <- err
+		}()
+		<-started
+
+		// the scheduled run still holds the lease, same owner or not
+		_, err := scheduler.RunNow(context.Background(), work.Name)
+		assert.ErrorIs(t, err, models.ErrJobRunning)
+
+		close(finish)
+		assert.NoError(t, <-done)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+		run, err := scheduler.RunNow(context.Background(), work.Name)
+		assert.NoError(t, err)
+		assert.Equal(t, models.JobSucceeded, run.Status)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	})
+}
+
+func TestSchedulerStartStop(t *testing.T) {
+	scheduler := New(storage.JobModel, "a")
+	started := make(chan struct{})
+	assert.NoError(t, scheduler.Add(Job{
+		Name:     "never",
+		Schedule: MustParse("0 0 1 1 *"),
+		Run: func(ctx context.Context) (string, error) {
+			close(started)
+			return "", nil
+		},
+	}))
+
+	scheduler.Start()
+	scheduler.Start()
+	scheduler.Stop()
+	scheduler.Stop()
+
+	select {
+	case <-started:
+		t.Fatal("job ran outside its schedule")
+	default:
+	}
+}
@@ -0,0 +1,116 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+//Analyze split text into lower case, stemmed terms
+func Analyze(text string) []string {
+	tokens := Tokenize(text)
+	terms := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		terms = append(terms, Stem(token))
+	}
+	return terms
+}
+
+// possessive drop the possessive 's of a word
+var possessive = strings.NewReplacer("'s ", " ", "’s ", " ")
+
+//Tokenize split text into lower case words, possessive 's is dropped
+func Tokenize(text string) []string {
+	text = possessive.Replace(strings.ToLower(text) + " ")
+
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+//Stem light english stemmer stripping plural and verb suffixes, so
+//"stories" and "story" or "running" and "run" end up as the same term
+func Stem(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "sses"):
+		return strings.TrimSuffix(word, "es")
+	case len(word) > 5 && strings.HasSuffix(word, "ing"):
+		return undouble(strings.TrimSuffix(word, "ing"))
+	case len(word) > 4 && strings.HasSuffix(word, "ed") && !strings.HasSuffix(word, "eed"):
+		return undouble(strings.TrimSuffix(word, "ed"))
+	case len(word) > 4 && strings.HasSuffix(word, "ly"):
+		return strings.TrimSuffix(word, "ly")
+	case len(word) > 3 && strings.HasSuffix(word, "s") &&
+		!strings.HasSuffix(word, "ss") && !strings.HasSuffix(word, "us") && !strings.HasSuffix(word, "is"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// undouble drop the doubled final consonant left by a stripped suffix,
+// "runn" becomes "run" while "fall" stays
+func undouble(word string) string {
+	n := len(word)
+	if n < 3 || word[n-1] != word[n-2] {
+		return word
+	}
+	if strings.ContainsRune("aeiouylsz", rune(word[n-1])) {
+		return word
+	}
+	return word[:n-1]
+}
+
+// distance optimal string alignment distance of a and b, counting an
+// insertion, deletion, substitution or swap of neighbours as one edit.
+// Gives up with max+1 once the distance exceeds max
+func distance(a, b string, max int) int {
+	ra, rb := []rune(a), []rune(b)
+	if diff := len(ra) - len(rb); diff > max || -diff > max {
+		return max + 1
+	}
+
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		best := curr[0]
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = minInt(curr[j], prev2[j-2]+1)
+			}
+			if curr[j] < best {
+				best = curr[j]
+			}
+		}
+		if best > max {
+			return max + 1
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+
+	if prev[len(rb)] > max {
+		return max + 1
+	}
+	return prev[len(rb)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, value := range values[1:] {
+		if value < min {
+			min = value
+		}
+	}
+	return min
+}
@@ -0,0 +1,40 @@
+package search
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnalyze(t *testing.T) {
+	t.Run("tokenize", func(t *testing.T) {
+		assert.Equal(t, []string{"harry", "potter", "and", "the", "philosopher", "stone"},
+			Tokenize("Harry Potter and the Philosopher's Stone"))
+		assert.Equal(t, []string{"j", "k", "rowling"}, Tokenize("J.K. Rowling"))
+	})
+
+	t.Run("stem", func(t *testing.T) {
+		cases := map[string]string{
+			"stories": "story",
+			"books":   "book",
+			"running": "run",
+			"falling": "fall",
+			"classes": "class",
+			"glass":   "glass",
+			"quickly": "quick",
+			"jumped":  "jump",
+			"bus":     "bus",
+		}
+		for word, stem := range cases {
+			assert.Equal(t, stem, Stem(word), word)
+		}
+	})
+
+	t.Run("distance", func(t *testing.T) {
+		assert.Equal(t, 0, distance("potter", "potter", 2))
+		assert.Equal(t, 1, distance("poter", "potter", 2))
+		assert.Equal(t, 1, distance("ptoter", "potter", 2))
+		assert.Equal(t, 2, distance("pottre", "pitter", 2))
+		assert.Equal(t, 2, distance("rowling", "tolkien", 1))
+	})
+}
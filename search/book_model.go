@@ -0,0 +1,41 @@
+package search
+
+import "project-api/models"
+
+//IndexedBookModel BookModel keeping an Index in sync with every
+//successful write, reads go straight to the wrapped model
+type IndexedBookModel struct {
+	models.BookModel
+	index *Index
+}
+
+func NewIndexedBookModel(model models.BookModel, index *Index) *IndexedBookModel {
+	return &IndexedBookModel{model, index}
+}
+
+func (m *IndexedBookModel) InsertBook(newBook models.Book) (models.Book, error) {
+	book, err := m.BookModel.InsertBook(newBook)
+	if err != nil {
+		return book, err
+	}
+	m.index.Add(book)
+	return book, nil
+}
+
+func (m *IndexedBookModel) EditBook(newBook models.Book, bookId int) (models.Book, error) {
+	book, err := m.BookModel.EditBook(newBook, bookId)
+	if err != nil {
+		return book, err
+	}
+	m.index.Add(book)
+	return book, nil
+}
+
+func (m *IndexedBookModel) DeleteBook(bookId int, version uint) (models.Book, error) {
+	book, err := m.BookModel.DeleteBook(bookId, version)
+	if err != nil {
+		return book, err
+	}
+	m.index.Remove(uint(bookId))
+	return book, nil
+}
@@ -0,0 +1,386 @@
+package search
+
+import (
+	"math"
+	"sort"
+	"sync"
+
+	"project-api/models"
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+// field indexed book field
+type field int
+
+const (
+	fieldTitle field = iota
+	fieldAuthor
+	fieldPublisher
+	fieldCount
+)
+
+// fieldBoost weight of a match per field, a title match counts most
+var fieldBoost = [fieldCount]float64{3, 2, 1}
+
+// fieldNames api names of the indexed fields
+var fieldNames = map[string]field{
+	"title":     fieldTitle,
+	"author":    fieldAuthor,
+	"publisher": fieldPublisher,
+}
+
+// document indexed book with the term count of each field
+type document struct {
+	book   models.Book
+	terms  []string
+	length [fieldCount]int
+}
+
+// frequencies count of a term per field of one document
+type frequencies [fieldCount]int
+
+// bucket indexed terms sharing their first letter and length, a typo is
+// only looked for among terms of the same first letter and a length
+// within the edits it tolerates
+type bucket struct {
+	first  rune
+	length int
+}
+
+func bucketOf(term string) bucket {
+	runes := []rune(term)
+	if len(runes) == 0 {
+		return bucket{}
+	}
+	return bucket{first: runes[0], length: len(runes)}
+}
+
+//Hit book matching a search with its relevance score
+type Hit struct {
+	Book  models.Book
+	Score float64
+}
+
+//Index in-process inverted index over the books, ranking matches with
+//BM25F. It is storage agnostic, IndexedBookModel keeps it in sync with
+//the writes of any BookModel made in this process. Writes made by other
+//servers show up after the next Rebuild
+type Index struct {
+	mu          sync.RWMutex
+	docs        map[uint]*document
+	postings    map[string]map[uint]*frequencies
+	vocabulary  map[bucket]map[string]bool
+	totalLength [fieldCount]int
+	completions [fieldCount]*completions
+
+	// rebuild lets one Rebuild run at a time, pending are the writes made
+	// while it reads the books, nil when no Rebuild runs
+	rebuild sync.Mutex
+	pending []func(*Index)
+}
+
+func NewIndex() *Index {
+	index := &Index{
+		docs:       map[uint]*document{},
+		postings:   map[string]map[uint]*frequencies{},
+		vocabulary: map[bucket]map[string]bool{},
+	}
+	for f := range index.completions {
+		index.completions[f] = newCompletions()
+	}
+	return index
+}
+
+//Rebuild replace the index content with every book of model. The index
+//keeps serving while the books are read, Add and Remove calls made
+//meanwhile are replayed onto the rebuilt index before it is swapped in
+func (i *Index) Rebuild(model models.BookModel) error {
+	i.rebuild.Lock()
+	defer i.rebuild.Unlock()
+
+	i.mu.Lock()
+	i.pending = []func(*Index){}
+	i.mu.Unlock()
+
+	fresh, err := read(model)
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	pending := i.pending
+	i.pending = nil
+	if err != nil {
+		return err
+	}
+
+	// a write replayed on a book read after it lands on the same content
+	for _, write := range pending {
+		write(fresh)
+	}
+	i.docs, i.postings, i.vocabulary, i.totalLength = fresh.docs, fresh.postings, fresh.vocabulary, fresh.totalLength
+	i.completions = fresh.completions
+	return nil
+}
+
+// read index every book of model
+func read(model models.BookModel) (*Index, error) {
+	fresh := NewIndex()
+
+	query := models.Query{Limit: models.MaxLimit}
+	for {
+		books, page, err := model.GetAllBook(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, book := range books {
+			fresh.add(book)
+		}
+		if page.NextCursor == "" {
+			return fresh, nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+//Add index book, replacing an older version of it
+func (i *Index) Add(book models.Book) {
+	i.write(func(index *Index) {
+		index.remove(book.ID)
+		index.add(book)
+	})
+}
+
+//Remove drop a book from the index
+func (i *Index) Remove(bookId uint) {
+	i.write(func(index *Index) {
+		index.remove(bookId)
+	})
+}
+
+// write apply a write to the index, and keep it for the rebuilt index
+// when a Rebuild runs
+func (i *Index) write(write func(*Index)) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	write(i)
+	if i.pending != nil {
+		i.pending = append(i.pending, write)
+	}
+}
+
+//Len number of indexed books
+func (i *Index) Len() int {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return len(i.docs)
+}
+
+func (i *Index) add(book models.Book) {
+	doc := &document{book: book}
+
+	texts := [fieldCount]string{book.Title, book.Author, book.Publisher}
+	for f, text := range texts {
+		i.completions[f].add(text)
+
+		terms := Analyze(text)
+		doc.length[f] = len(terms)
+		i.totalLength[f] += len(terms)
+
+		for _, term := range terms {
+			postings, ok := i.postings[term]
+			if !ok {
+				postings = map[uint]*frequencies{}
+				i.postings[term] = postings
+				i.addTerm(term)
+			}
+			tf, ok := postings[book.ID]
+			if !ok {
+				tf = &frequencies{}
+				postings[book.ID] = tf
+				doc.terms = append(doc.terms, term)
+			}
+			tf[f]++
+		}
+	}
+
+	i.docs[book.ID] = doc
+}
+
+func (i *Index) remove(bookId uint) {
+	doc, ok := i.docs[bookId]
+	if !ok {
+		return
+	}
+
+	for _, term := range doc.terms {
+		delete(i.postings[term], bookId)
+		if len(i.postings[term]) == 0 {
+			delete(i.postings, term)
+			i.removeTerm(term)
+		}
+	}
+	texts := [fieldCount]string{doc.book.Title, doc.book.Author, doc.book.Publisher}
+	for f, text := range texts {
+		i.totalLength[f] -= doc.length[f]
+		i.completions[f].remove(text)
+	}
+	delete(i.docs, bookId)
+}
+
+// addTerm file a newly indexed term in its vocabulary bucket
+func (i *Index) addTerm(term string) {
+	key := bucketOf(term)
+	terms, ok := i.vocabulary[key]
+	if !ok {
+		terms = map[string]bool{}
+		i.vocabulary[key] = terms
+	}
+	terms[term] = true
+}
+
+// removeTerm take a term no book uses anymore out of its bucket
+func (i *Index) removeTerm(term string) {
+	key := bucketOf(term)
+	delete(i.vocabulary[key], term)
+	if len(i.vocabulary[key]) == 0 {
+		delete(i.vocabulary, key)
+	}
+}
+
+//Search books matching any term of text, best match first. Returns the
+//hits between offset and offset+limit and the total number of hits
+func (i *Index) Search(text string, limit, offset int) ([]Hit, int) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	scores := map[uint]float64{}
+	seen := map[string]bool{}
+
+	for _, term := range Analyze(text) {
+		if seen[term] {
+			continue
+		}
+		seen[term] = true
+
+		// a document scores with its best spelling of the term
+		best := map[uint]float64{}
+		for candidate, weight := range i.expand(term) {
+			postings := i.postings[candidate]
+			idf := i.idf(len(postings))
+			for id, tf := range postings {
+				score := weight * idf * i.saturate(i.docs[id], tf)
+				if score > best[id] {
+					best[id] = score
+				}
+			}
+		}
+		for id, score := range best {
+			scores[id] += score
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, Hit{Book: i.docs[id].book, Score: score})
+	}
+	sort.Slice(hits, func(x, y int) bool {
+		if hits[x].Score != hits[y].Score {
+			return hits[x].Score > hits[y].Score
+		}
+		return hits[x].Book.ID < hits[y].Book.ID
+	})
+
+	total := len(hits)
+	if offset > total {
+		offset = total
+	}
+	if end := offset + limit; limit > 0 && end < total {
+		return hits[offset:end], total
+	}
+	return hits[offset:], total
+}
+
+// expand indexed terms a query term may stand for with their weight: the
+// term itself and, for longer terms, its misspellings. Misspellings are
+// looked for in the buckets of the first letter of the term, a typo in
+// the first letter is not tolerated
+func (i *Index) expand(term string) map[string]float64 {
+	candidates := map[string]float64{}
+	if _, ok := i.postings[term]; ok {
+		candidates[term] = 1
+	}
+
+	max := maxEdits(term)
+	if max == 0 {
+		return candidates
+	}
+
+	key := bucketOf(term)
+	for length := key.length - max; length <= key.length+max; length++ {
+		for candidate := range i.vocabulary[bucket{first: key.first, length: length}] {
+			if candidate == term {
+				continue
+			}
+			if d := distance(term, candidate, max); d <= max {
+				candidates[candidate] = 1 / float64(1+d)
+			}
+		}
+	}
+	return candidates
+}
+
+// maxEdits typos tolerated in a term, none for short ones
+func maxEdits(term string) int {
+	switch n := len([]rune(term)); {
+	case n < 4:
+		return 0
+	case n < 8:
+		return 1
+	}
+	return 2
+}
+
+// idf inverse document frequency of a term found in df documents
+func (i *Index) idf(df int) float64 {
+	n := float64(len(i.docs))
+	return math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+}
+
+// saturate BM25F term frequency of a document: field frequencies are
+// boosted and length normalized before the k1 saturation
+func (i *Index) saturate(doc *document, tf *frequencies) float64 {
+	var weighted float64
+	for f := field(0); f < fieldCount; f++ {
+		if tf[f] == 0 {
+			continue
+		}
+		avg := float64(i.totalLength[f]) / float64(len(i.docs))
+		norm := 1 - b + b*float64(doc.length[f])/avg
+		weighted += fieldBoost[f] * float64(tf[f]) / norm
+	}
+	return weighted / (k1 + weighted)
+}
+
+//Suggest completions of prefix among the values of a title, author or
+//publisher field, values shared by the most books first
+func (i *Index) Suggest(fieldName, prefix string, limit int) []Suggestion {
+	f, ok := fieldNames[fieldName]
+	if !ok {
+		return []Suggestion{}
+	}
+	if limit <= 0 || limit > suggestTop {
+		limit = suggestTop
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	return i.completions[f].suggest(prefix, limit)
+}
@@ -0,0 +1,130 @@
+package search
+
+import (
+	"testing"
+
+	"project-api/config"
+	"project-api/models"
+	"project-api/util"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func titles(hits []Hit) []string {
+	result := []string{}
+	for _, hit := range hits {
+		result = append(result, hit.Book.Title)
+	}
+	return result
+}
+
+// rebuildingBooks book model making writes to the index while a Rebuild
+// reads the first page
+type rebuildingBooks struct {
+	models.BookModel
+	during func()
+}
+
+func (m *rebuildingBooks) GetAllBook(query models.Query) ([]models.Book, models.Page, error) {
+	books, page, err := m.BookModel.GetAllBook(query)
+	if m.during != nil {
+		m.during()
+		m.during = nil
+	}
+	return books, page, err
+}
+
+func TestIndex(t *testing.T) {
+	var appConfig config.AppConfig
+	appConfig.Database.Driver = "memory"
+	appConfig.Database.Name = "search_test"
+
+	storage, err := util.OpenStorage(&appConfig)
+	assert.NoError(t, err)
+
+	// books written before the index exists are picked up by Rebuild
+	_, err = storage.BookModel.InsertBook(models.Book{Title: "The Hobbit", Author: "J.R.R. Tolkien", Publisher: "Allen & Unwin"})
+	assert.NoError(t, err)
+
+	index := NewIndex()
+	assert.NoError(t, index.Rebuild(storage.BookModel))
+	assert.Equal(t, 1, index.Len())
+
+	bookModel := NewIndexedBookModel(storage.BookModel, index)
+	for _, book := range []models.Book{
+		{Title: "Harry Potter and the Philosopher's Stone", Author: "J. K. Rowling", Publisher: "Bloomsbury"},
+		{Title: "Harry Potter and the Chamber of Secrets", Author: "J. K. Rowling", Publisher: "Bloomsbury"},
+		{Title: "The Cuckoo's Calling", Author: "Robert Galbraith", Publisher: "Sphere"},
+		{Title: "Stories of Your Life", Author: "Ted Chiang", Publisher: "Tor Books"},
+	} {
+		_, err := bookModel.InsertBook(book)
+		assert.NoError(t, err)
+	}
+
+	t.Run("ranks the better match first", func(t *testing.T) {
+		hits, total := index.Search("potter stone", 10, 0)
+		assert.Equal(t, 2, total)
+		assert.Equal(t, "Harry Potter and the Philosopher's Stone", hits[0].Book.Title)
+		assert.True(t, hits[0].Score > hits[1].Score)
+	})
+
+	t.Run("matches stems", func(t *testing.T) {
+		hits, _ := index.Search("story", 10, 0)
+		assert.Equal(t, []string{"Stories of Your Life"}, titles(hits))
+	})
+
+	t.Run("tolerates typos", func(t *testing.T) {
+		hits, _ := index.Search("tolkein hobit", 10, 0)
+		assert.Equal(t, []string{"The Hobbit"}, titles(hits))
+	})
+
+	t.Run("does not tolerate a typo in the first letter", func(t *testing.T) {
+		hits, _ := index.Search("rotter", 10, 0)
+		assert.Equal(t, 0, len(hits))
+	})
+
+	t.Run("pages the hits", func(t *testing.T) {
+		hits, total := index.Search("rowling", 1, 1)
+		assert.Equal(t, 2, total)
+		assert.Equal(t, 1, len(hits))
+
+		hits, _ = index.Search("rowling", 10, 5)
+		assert.Equal(t, 0, len(hits))
+	})
+
+	t.Run("follows edits and deletes", func(t *testing.T) {
+		hits, _ := index.Search("cuckoo", 10, 0)
+		book := hits[0].Book
+
+		_, err := bookModel.EditBook(models.Book{Title: "The Silkworm", Author: book.Author, Publisher: book.Publisher}, int(book.ID))
+		assert.NoError(t, err)
+
+		hits, _ = index.Search("cuckoo", 10, 0)
+		assert.Equal(t, 0, len(hits))
+		hits, _ = index.Search("silkworm", 10, 0)
+		assert.Equal(t, []string{"The Silkworm"}, titles(hits))
+
+		_, err = bookModel.DeleteBook(int(book.ID), 0)
+		assert.NoError(t, err)
+
+		hits, _ = index.Search("silkworm galbraith", 10, 0)
+		assert.Equal(t, 0, len(hits))
+		assert.Nil(t, index.vocabulary[bucketOf("silkworm")])
+	})
+
+	t.Run("keeps the writes made during a Rebuild", func(t *testing.T) {
+		hits, _ := index.Search("chiang", 10, 0)
+		chiang := hits[0].Book
+
+		books := &rebuildingBooks{BookModel: storage.BookModel, during: func() {
+			index.Add(models.Book{Title: "Exhalation", Author: "Ted Chiang", Publisher: "Knopf"})
+			index.Remove(chiang.ID)
+		}}
+		assert.NoError(t, index.Rebuild(books))
+
+		hits, _ = index.Search("exhalation", 10, 0)
+		assert.Equal(t, []string{"Exhalation"}, titles(hits))
+		hits, _ = index.Search("stories", 10, 0)
+		assert.Equal(t, 0, len(hits))
+	})
+}
@@ -0,0 +1,296 @@
+package search
+
+import (
+	"sort"
+	"strings"
+)
+
+// suggestTop completions kept per trie node, the most a suggest request
+// returns
+const suggestTop = 10
+
+// keyDepth runes of a word key kept in the trie, the nodes of a key past
+// it would each hold a single value. A longer prefix is completed from
+// the values stored at that depth
+const keyDepth = 12
+
+//Suggestion completion of a prefix with the number of books carrying it
+type Suggestion struct {
+	Value string
+	Count int
+}
+
+// entry distinct field value, count is the number of books having it
+type entry struct {
+	value string
+	key   string
+	count int
+}
+
+// trieNode node of the prefix tree, top caches the best completions of
+// the whole subtree so a lookup never walks below the prefix
+type trieNode struct {
+	children map[rune]*trieNode
+	entries  []*entry
+	top      []*entry
+}
+
+// completions prefix tree over the values of one book field. A value is
+// reachable from the start of each of its words, "rowl" completes
+// "J. K. Rowling". Keys are cut at keyDepth runes
+type completions struct {
+	root    *trieNode
+	entries map[string]*entry
+}
+
+func newCompletions() *completions {
+	return &completions{
+		root:    &trieNode{children: map[rune]*trieNode{}},
+		entries: map[string]*entry{},
+	}
+}
+
+// normalize lookup key of a value: lower case words split by one space
+func normalize(value string) string {
+	return strings.Join(Tokenize(value), " ")
+}
+
+// wordKeys keys a value is stored under, one per word start
+func wordKeys(key string) []string {
+	words := strings.Split(key, " ")
+	keys := make([]string, 0, len(words))
+	for i := range words {
+		keys = append(keys, strings.Join(words[i:], " "))
+	}
+	return keys
+}
+
+// trieKeys word keys of a value as kept in the trie
+func trieKeys(key string) []string {
+	keys := wordKeys(key)
+	for i, wordKey := range keys {
+		keys[i] = truncate(wordKey)
+	}
+	return keys
+}
+
+// truncate first keyDepth runes of a key
+func truncate(key string) string {
+	runes := []rune(key)
+	if len(runes) > keyDepth {
+		return string(runes[:keyDepth])
+	}
+	return key
+}
+
+func (c *completions) add(value string) {
+	key := normalize(value)
+	if key == "" {
+		return
+	}
+
+	e, ok := c.entries[key]
+	if !ok {
+		e = &entry{value: strings.Join(strings.Fields(value), " "), key: key}
+		c.entries[key] = e
+
+		for _, wordKey := range trieKeys(key) {
+			node := c.root
+			for _, r := range wordKey {
+				child, ok := node.children[r]
+				if !ok {
+					child = &trieNode{children: map[rune]*trieNode{}}
+					node.children[r] = child
+				}
+				node = child
+			}
+			node.entries = append(node.entries, e)
+		}
+	}
+
+	e.count++
+	c.promote(e)
+}
+
+func (c *completions) remove(value string) {
+	key := normalize(value)
+	e, ok := c.entries[key]
+	if !ok {
+		return
+	}
+
+	e.count--
+	if e.count <= 0 {
+		delete(c.entries, key)
+		for _, wordKey := range trieKeys(key) {
+			path := c.path(wordKey)
+			if len(path) == len([]rune(wordKey))+1 {
+				leaf := path[len(path)-1]
+				leaf.entries = removeEntry(leaf.entries, e)
+			}
+		}
+	}
+	c.refresh(e)
+}
+
+// path nodes from the root down to key, shorter when key is missing
+func (c *completions) path(key string) []*trieNode {
+	path := []*trieNode{c.root}
+	node := c.root
+	for _, r := range key {
+		child, ok := node.children[r]
+		if !ok {
+			break
+		}
+		path = append(path, child)
+		node = child
+	}
+	return path
+}
+
+// promote move e up the cached completions above every key of e after its
+// count grew, a growing count never pushes anything but the last one out
+func (c *completions) promote(e *entry) {
+	for _, wordKey := range trieKeys(e.key) {
+		for _, node := range c.path(wordKey) {
+			node.promote(e)
+		}
+	}
+}
+
+func (n *trieNode) promote(e *entry) {
+	i := 0
+	for i < len(n.top) && n.top[i] != e {
+		i++
+	}
+	if i == len(n.top) {
+		if len(n.top) == suggestTop && !before(e, n.top[len(n.top)-1]) {
+			return
+		}
+		if len(n.top) < suggestTop {
+			n.top = append(n.top, e)
+		}
+		n.top[len(n.top)-1] = e
+		i = len(n.top) - 1
+	}
+
+	for ; i > 0 && before(n.top[i], n.top[i-1]); i-- {
+		n.top[i], n.top[i-1] = n.top[i-1], n.top[i]
+	}
+}
+
+// before rank of two completions: more books, then shorter, then
+// alphabetical
+func before(a, b *entry) bool {
+	if a.count != b.count {
+		return a.count > b.count
+	}
+	if len(a.value) != len(b.value) {
+		return len(a.value) < len(b.value)
+	}
+	return a.value < b.value
+}
+
+// refresh recompute the cached completions above every key of e, bottom
+// up, dropping the nodes left without any value
+func (c *completions) refresh(e *entry) {
+	for _, wordKey := range trieKeys(e.key) {
+		runes := []rune(wordKey)
+		path := c.path(wordKey)
+
+		for i := len(path) - 1; i >= 0; i-- {
+			node := path[i]
+			node.recompute()
+			if i > 0 && len(node.top) == 0 {
+				delete(path[i-1].children, runes[i-1])
+			}
+		}
+	}
+}
+
+func (n *trieNode) recompute() {
+	seen := map[*entry]bool{}
+	candidates := make([]*entry, 0, len(n.entries)+len(n.children)*suggestTop)
+
+	collect := func(entries []*entry) {
+		for _, e := range entries {
+			if !seen[e] {
+				seen[e] = true
+				candidates = append(candidates, e)
+			}
+		}
+	}
+	collect(n.entries)
+	for _, child := range n.children {
+		collect(child.top)
+	}
+
+	sort.Slice(candidates, func(x, y int) bool {
+		return before(candidates[x], candidates[y])
+	})
+
+	if len(candidates) > suggestTop {
+		candidates = candidates[:suggestTop]
+	}
+	n.top = candidates
+}
+
+// suggest best completions of prefix, most frequent first
+func (c *completions) suggest(prefix string, limit int) []Suggestion {
+	key := normalize(prefix)
+	suggestions := []Suggestion{}
+	if key == "" {
+		return suggestions
+	}
+
+	trieKey := truncate(key)
+	path := c.path(trieKey)
+	if len(path) != len([]rune(trieKey))+1 {
+		return suggestions
+	}
+	node := path[len(path)-1]
+
+	completions := node.top
+	if trieKey != key {
+		completions = matching(node.entries, key)
+	}
+	for _, e := range completions {
+		if len(suggestions) == limit {
+			break
+		}
+		suggestions = append(suggestions, Suggestion{Value: e.value, Count: e.count})
+	}
+	return suggestions
+}
+
+// matching entries with a word starting with key, best first
+func matching(entries []*entry, key string) []*entry {
+	seen := map[*entry]bool{}
+	var matches []*entry
+	for _, e := range entries {
+		if seen[e] {
+			continue
+		}
+		seen[e] = true
+		for _, wordKey := range wordKeys(e.key) {
+			if strings.HasPrefix(wordKey, key) {
+				matches = append(matches, e)
+				break
+			}
+		}
+	}
+
+	sort.Slice(matches, func(x, y int) bool {
+		return before(matches[x], matches[y])
+	})
+	return matches
+}
+
+func removeEntry(entries []*entry, e *entry) []*entry {
+	for i, candidate := range entries {
+		if candidate == e {
+			return append(entries[:i], entries[i+1:]...)
+		}
+	}
+	return entries
+}
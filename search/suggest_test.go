@@ -0,0 +1,109 @@
+package search
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+
+	"project-api/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func values(suggestions []Suggestion) []string {
+	result := []string{}
+	for _, suggestion := range suggestions {
+		result = append(result, suggestion.Value)
+	}
+	return result
+}
+
+func TestSuggest(t *testing.T) {
+	index := NewIndex()
+	books := []models.Book{
+		{Title: "Harry Potter and the Philosopher's Stone", Author: "J. K. Rowling"},
+		{Title: "Harry Potter and the Chamber of Secrets", Author: "J. K. Rowling"},
+		{Title: "The Casual Vacancy", Author: "J. K. Rowling"},
+		{Title: "The Hobbit", Author: "J. R. R. Tolkien"},
+		{Title: "Ready Player One", Author: "Ernest Cline"},
+	}
+	for id, book := range books {
+		book.ID = uint(id + 1)
+		index.Add(book)
+	}
+
+	t.Run("most frequent value first", func(t *testing.T) {
+		suggestions := index.Suggest("author", "j", 10)
+		assert.Equal(t, []Suggestion{{"J. K. Rowling", 3}, {"J. R. R. Tolkien", 1}}, suggestions)
+	})
+
+	t.Run("matches any word start", func(t *testing.T) {
+		assert.Equal(t, []string{"J. K. Rowling"}, values(index.Suggest("author", "rowl", 10)))
+		assert.Equal(t, []string{"Harry Potter and the Chamber of Secrets", "Harry Potter and the Philosopher's Stone"},
+			values(index.Suggest("title", "POTTER and", 10)))
+	})
+
+	t.Run("prefix longer than the trie keys", func(t *testing.T) {
+		assert.Equal(t, []string{"Harry Potter and the Chamber of Secrets"}, values(index.Suggest("title", "harry potter and the cha", 10)))
+		assert.Equal(t, []string{"Harry Potter and the Philosopher's Stone"}, values(index.Suggest("title", "potter and the philosopher", 10)))
+		assert.Equal(t, 0, len(index.Suggest("title", "harry potter and the goblet", 10)))
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		assert.Equal(t, 1, len(index.Suggest("title", "the", 1)))
+	})
+
+	t.Run("unknown prefix or field", func(t *testing.T) {
+		assert.Equal(t, 0, len(index.Suggest("author", "x", 10)))
+		assert.Equal(t, 0, len(index.Suggest("isbn", "9", 10)))
+	})
+
+	t.Run("follows removals", func(t *testing.T) {
+		index.Remove(4)
+		assert.Equal(t, []string{"J. K. Rowling"}, values(index.Suggest("author", "j", 10)))
+		assert.Equal(t, 0, len(index.Suggest("author", "tolk", 10)))
+
+		index.Remove(1)
+		index.Remove(2)
+		assert.Equal(t, []Suggestion{{"J. K. Rowling", 1}}, index.Suggest("author", "rowling", 10))
+	})
+}
+
+// BenchmarkSuggest suggest over 20k books, reporting the p99 latency of
+// a pair of lookups and the heap the index takes
+func BenchmarkSuggest(b *testing.B) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	index := NewIndex()
+	for id := 1; id <= 20000; id++ {
+		book := models.Book{
+			Title:     fmt.Sprintf("Book %d of the series %d", id, id%500),
+			Author:    fmt.Sprintf("Author %d", id%3000),
+			Publisher: fmt.Sprintf("Publisher %d", id%100),
+		}
+		book.ID = uint(id)
+		index.Add(book)
+	}
+
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+
+	durations := make([]time.Duration, 0, b.N)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		start := time.Now()
+		index.Suggest("author", "auth", 10)
+		index.Suggest("title", "b", 10)
+		durations = append(durations, time.Since(start))
+	}
+	b.StopTimer()
+
+	sort.Slice(durations, func(x, y int) bool { return durations[x] < durations[y] })
+	b.ReportMetric(float64(durations[len(durations)*99/100].Nanoseconds()), "p99-ns/op")
+	// ResetTimer drops the metrics reported before it
+	b.ReportMetric(float64(int64(after.HeapAlloc)-int64(before.HeapAlloc))/(1<<20), "index-MB")
+}
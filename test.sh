@@ -1,2 +1,2 @@
-go test -v -coverprofile=coverage.out ./api/controllers/... ./api/common/...
+DATABASE_DRIVER=${DATABASE_DRIVER:-memory} go test -v -coverprofile=coverage.out ./api/controllers/... ./api/common/...
 go tool cover -func coverage.out
\ No newline at end of file
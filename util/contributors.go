@@ -0,0 +1,48 @@
+package util
+
+import "project-api/models"
+
+//MigrateContributors link every book still carrying only free-text
+//author and publisher names to Author and Publisher records, splitting
+//the by-lines and merging the spellings of the same name. Every book is
+//linked once and marked, so running it again only picks up the books
+//added since without being linked
+func MigrateContributors(storage *Storage) error {
+	query := models.Query{
+		Limit:   models.MaxLimit,
+		Filters: []models.Filter{{Field: "contributors_linked", Op: models.FilterNotEqual, Value: true}},
+	}
+
+	for {
+		books, page, err := storage.BookModel.GetAllBook(query)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]uint, 0, len(books))
+		for _, book := range books {
+			ids = append(ids, book.ID)
+
+			linked, err := models.LinkContributors(storage.AuthorModel, storage.PublisherModel, book)
+			if err != nil {
+				return err
+			}
+			if len(linked.Authors) == len(book.Authors) && linked.PublisherID == book.PublisherID {
+				continue
+			}
+
+			if _, err := storage.BookModel.EditBook(linked, int(book.ID)); err != nil {
+				return err
+			}
+		}
+
+		if err := storage.BookModel.MarkContributorsLinked(ids); err != nil {
+			return err
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
@@ -0,0 +1,80 @@
+package util
+
+import (
+	"testing"
+
+	"project-api/config"
+	"project-api/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateContributors(t *testing.T) {
+	var appConfig config.AppConfig
+	appConfig.Database.Driver = "memory"
+	appConfig.Database.Name = "contributors_test"
+
+	storage, err := OpenStorage(&appConfig)
+	assert.NoError(t, err)
+
+	for _, book := range []models.Book{
+		{Title: "Harry Potter", Author: "J.K. Rowling", Publisher: "Bloomsbury"},
+		{Title: "The Casual Vacancy", Author: "J. K. Rowling", Publisher: "bloomsbury "},
+		{Title: "Good Omens", Author: "Neil Gaiman and Terry Pratchett", Publisher: "Gollancz"},
+		{Title: "Alfabet"},
+		{Title: "The Silmarillion", Author: "Tolkien, J. R. R.; Tolkien, Christopher", Publisher: "Gollancz"},
+	} {
+		_, err := storage.BookModel.InsertBook(book)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, MigrateContributors(storage))
+
+	authors, _, err := storage.AuthorModel.GetAllAuthor(models.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(authors))
+	assert.Equal(t, "J. K. Rowling", authors[0].Name)
+
+	publishers, _, err := storage.PublisherModel.GetAllPublisher(models.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(publishers))
+
+	books, _, err := storage.BookModel.GetAllBook(models.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, books[0].Authors, books[1].Authors)
+	assert.Equal(t, *books[0].PublisherID, *books[1].PublisherID)
+	assert.Equal(t, []models.Contributor{
+		{AuthorID: authors[1].ID, Name: "Neil Gaiman", Role: models.ContributorAuthor},
+		{AuthorID: authors[2].ID, Name: "Terry Pratchett", Role: models.ContributorAuthor},
+	}, books[2].Authors)
+	assert.Nil(t, books[3].Authors)
+	assert.Equal(t, []models.Contributor{
+		{AuthorID: authors[3].ID, Name: "J. R. R. Tolkien", Role: models.ContributorAuthor},
+		{AuthorID: authors[4].ID, Name: "Christopher Tolkien", Role: models.ContributorAuthor},
+	}, books[4].Authors)
+	for _, book := range books {
+		assert.True(t, book.ContributorsLinked, book.Title)
+	}
+
+	t.Run("second run changes nothing", func(t *testing.T) {
+		assert.NoError(t, MigrateContributors(storage))
+
+		again, _, err := storage.BookModel.GetAllBook(models.Query{})
+		assert.NoError(t, err)
+		for i := range books {
+			assert.Equal(t, books[i].Version, again[i].Version)
+		}
+	})
+
+	t.Run("linked books are not linked again", func(t *testing.T) {
+		// a book whose authors were taken off after the migration
+		book, err := storage.BookModel.InsertBook(models.Book{Title: "Unfinished Tales", Author: "Tolkien, J. R. R.", ContributorsLinked: true})
+		assert.NoError(t, err)
+
+		assert.NoError(t, MigrateContributors(storage))
+
+		book, err = storage.BookModel.GetBook(int(book.ID))
+		assert.NoError(t, err)
+		assert.Nil(t, book.Authors)
+	})
+}
@@ -1,39 +1,285 @@
 package util
 
 import (
+	"context"
 	"fmt"
+	stdlog "log"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
 
 	"project-api/config"
 	"project-api/models"
 
 	"github.com/labstack/gommon/log"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
-func MysqlDatabaseConnection(config *config.AppConfig) *gorm.DB {
-	var uri string
+//Storage every model backed by one storage driver
+type Storage struct {
+	// DB underlying gorm connection, nil for drivers not built on gorm
+	DB *gorm.DB
+	// Mongo underlying mongo database, nil for drivers not built on mongo
+	Mongo *mongo.Database
 
-	uri = fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?parseTime=true",
-		config.Database.Username,
-		config.Database.Password,
-		config.Database.Address,
-		config.Database.Port,
-		config.Database.Name)
+	UserModel      models.UserModel
+	BookModel      models.BookModel
+	AuthorModel    models.AuthorModel
+	PublisherModel models.PublisherModel
+	WorkModel      models.WorkModel
+	SeriesModel    models.SeriesModel
+	CategoryModel  models.CategoryModel
+	CopyModel      models.CopyModel
+	LoanModel      models.LoanModel
+	HoldModel      models.HoldModel
+	FineModel      models.FineModel
+	JobModel       models.JobModel
+	SessionModel   models.SessionModel
+}
+
+//DriverFunc open a storage from the given config
+type DriverFunc func(config *config.AppConfig) (*Storage, error)
+
+var driverLock = &sync.RWMutex{}
+var drivers = map[string]DriverFunc{}
+
+func init() {
+	RegisterDriver("mysql", gormDriver(mysqlDialector))
+	RegisterDriver("postgres", gormDriver(postgresDialector))
+	RegisterDriver("sqlite", gormDriver(sqliteDialector))
+	RegisterDriver("memory", gormDriver(memoryDialector, singleConnection))
+	RegisterDriver("mongodb", mongoDriver)
+}
 
-	db, err := gorm.Open(mysql.Open(uri), &gorm.Config{})
+//RegisterDriver make a storage driver available under the given name
+func RegisterDriver(name string, driver DriverFunc) {
+	driverLock.Lock()
+	defer driverLock.Unlock()
+
+	if driver == nil {
+		panic("util: RegisterDriver driver is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("util: RegisterDriver called twice for driver " + name)
+	}
+	drivers[name] = driver
+}
+
+//Drivers sorted list of the registered driver names
+func Drivers() []string {
+	driverLock.RLock()
+	defer driverLock.RUnlock()
+
+	names := make([]string, 0, len(drivers))
+	for name := range drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//OpenStorage open the storage selected by Database.Driver
+func OpenStorage(config *config.AppConfig) (*Storage, error) {
+	driverLock.RLock()
+	driver, ok := drivers[config.Database.Driver]
+	driverLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unknown database driver %q (registered: %v)", config.Database.Driver, Drivers())
+	}
+
+	return driver(config)
+}
+
+//DatabaseConnection open the storage selected by Database.Driver or panic
+func DatabaseConnection(config *config.AppConfig) *Storage {
+	storage, err := OpenStorage(config)
 
 	if err != nil {
 		log.Info("failed to connect database: ", err)
 		panic(err)
 	}
 
-	DatabaseMigration(db)
+	return storage
+}
+
+func MysqlDatabaseConnection(config *config.AppConfig) *gorm.DB {
+	db, err := GormDatabaseConnection(mysqlDialector(config))
+
+	if err != nil {
+		log.Info("failed to connect database: ", err)
+		panic(err)
+	}
 
 	return db
 }
 
+//GormDatabaseConnection open and migrate a gorm database
+func GormDatabaseConnection(dialector gorm.Dialector) (*gorm.DB, error) {
+	// a missing record is reported as models.ErrNotFound, not worth a log line
+	gormLogger := logger.New(stdlog.New(os.Stdout, "\r\n", stdlog.LstdFlags), logger.Config{
+		SlowThreshold:             200 * time.Millisecond,
+		LogLevel:                  logger.Warn,
+		IgnoreRecordNotFoundError: true,
+		Colorful:                  true,
+	})
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: gormLogger})
+
+	if err != nil {
+		return nil, err
+	}
+
+	DatabaseMigration(db)
+
+	return db, nil
+}
+
 func DatabaseMigration(db *gorm.DB) {
 	db.AutoMigrate(models.User{})
 	db.AutoMigrate(models.Book{})
+	db.AutoMigrate(models.Author{})
+	db.AutoMigrate(models.Publisher{})
+	db.AutoMigrate(models.BookAuthor{})
+	db.AutoMigrate(models.Work{})
+	db.AutoMigrate(models.Series{})
+	db.AutoMigrate(models.Category{})
+	db.AutoMigrate(models.BookTag{})
+	db.AutoMigrate(models.BookCategory{})
+	db.AutoMigrate(models.Copy{})
+	db.AutoMigrate(models.Loan{})
+	db.AutoMigrate(models.Hold{})
+	db.AutoMigrate(models.FineEntry{})
+	db.AutoMigrate(models.JobRun{})
+	db.AutoMigrate(models.JobLease{})
+	db.AutoMigrate(models.Session{})
+	db.AutoMigrate(models.RefreshToken{})
+}
+
+// gormDriver storage driver on the gorm dialector, every hook gets the
+// database once it is open
+func gormDriver(dialector func(config *config.AppConfig) gorm.Dialector, hooks ...func(db *gorm.DB) error) DriverFunc {
+	return func(config *config.AppConfig) (*Storage, error) {
+		db, err := GormDatabaseConnection(dialector(config))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, hook := range hooks {
+			if err := hook(db); err != nil {
+				return nil, err
+			}
+		}
+
+		return &Storage{
+			DB:             db,
+			UserModel:      models.NewUserModel(db),
+			BookModel:      models.NewBookModel(db),
+			AuthorModel:    models.NewAuthorModel(db),
+			PublisherModel: models.NewPublisherModel(db),
+			WorkModel:      models.NewWorkModel(db),
+			SeriesModel:    models.NewSeriesModel(db),
+			CategoryModel:  models.NewCategoryModel(db),
+			CopyModel:      models.NewCopyModel(db),
+			LoanModel:      models.NewLoanModel(db),
+			HoldModel:      models.NewHoldModel(db),
+			FineModel:      models.NewFineModel(db),
+			JobModel:       models.NewJobModel(db),
+			SessionModel:   models.NewSessionModel(db),
+		}, nil
+	}
+}
+
+func mysqlDialector(config *config.AppConfig) gorm.Dialector {
+	uri := fmt.Sprintf("%v:%v@tcp(%v:%v)/%v?parseTime=true",
+		config.Database.Username,
+		config.Database.Password,
+		config.Database.Address,
+		config.Database.Port,
+		config.Database.Name)
+
+	return mysql.Open(uri)
+}
+
+func postgresDialector(config *config.AppConfig) gorm.Dialector {
+	dsn := fmt.Sprintf("host=%v port=%v user=%v password=%v dbname=%v sslmode=disable",
+		config.Database.Address,
+		config.Database.Port,
+		config.Database.Username,
+		config.Database.Password,
+		config.Database.Name)
+
+	return postgres.Open(dsn)
+}
+
+func sqliteDialector(config *config.AppConfig) gorm.Dialector {
+	return sqlite.Open(fmt.Sprintf("%v.db", config.Database.Name))
+}
+
+func memoryDialector(config *config.AppConfig) gorm.Dialector {
+	return sqlite.Open(fmt.Sprintf("file:%v?mode=memory&cache=shared", config.Database.Name))
+}
+
+// singleConnection every connection to a shared in-memory database must
+// go through the same handle, or sqlite reports the table as locked
+func singleConnection(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	return nil
+}
+
+func mongoDriver(config *config.AppConfig) (*Storage, error) {
+	uri := url.URL{
+		Scheme: "mongodb",
+		Host:   fmt.Sprintf("%v:%v", config.Database.Address, config.Database.Port),
+	}
+	if config.Database.Username != "" {
+		uri.User = url.UserPassword(config.Database.Username, config.Database.Password)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri.String()))
+	if err != nil {
+		return nil, err
+	}
+
+	// Connect is lazy, ping so a wrong address fails at startup
+	if err := client.Ping(ctx, nil); err != nil {
+		return nil, err
+	}
+
+	db := client.Database(config.Database.Name)
+	if err := models.EnsureMongoIndexes(db); err != nil {
+		return nil, err
+	}
+
+	return &Storage{
+		Mongo:          db,
+		UserModel:      models.NewMongoUserModel(db),
+		BookModel:      models.NewMongoBookModel(db),
+		AuthorModel:    models.NewMongoAuthorModel(db),
+		PublisherModel: models.NewMongoPublisherModel(db),
+		WorkModel:      models.NewMongoWorkModel(db),
+		SeriesModel:    models.NewMongoSeriesModel(db),
+		CategoryModel:  models.NewMongoCategoryModel(db),
+		CopyModel:      models.NewMongoCopyModel(db),
+		LoanModel:      models.NewMongoLoanModel(db),
+		HoldModel:      models.NewMongoHoldModel(db),
+		FineModel:      models.NewMongoFineModel(db),
+		JobModel:       models.NewMongoJobModel(db),
+		SessionModel:   models.NewMongoSessionModel(db),
+	}, nil
 }
@@ -0,0 +1,44 @@
+package util
+
+import (
+	"testing"
+
+	"project-api/config"
+	"project-api/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenStorage(t *testing.T) {
+	t.Run("unknown driver", func(t *testing.T) {
+		var appConfig config.AppConfig
+		appConfig.Database.Driver = "oracle"
+
+		_, err := OpenStorage(&appConfig)
+		assert.Error(t, err)
+	})
+
+	t.Run("memory driver", func(t *testing.T) {
+		var appConfig config.AppConfig
+		appConfig.Database.Driver = "memory"
+		appConfig.Database.Name = "dbdriver_test"
+
+		storage, err := OpenStorage(&appConfig)
+		assert.NoError(t, err)
+
+		book, err := storage.BookModel.InsertBook(models.Book{Title: "Alfabet"})
+		assert.NoError(t, err)
+
+		found, err := storage.BookModel.GetBook(int(book.ID))
+		assert.NoError(t, err)
+		assert.Equal(t, "Alfabet", found.Title)
+
+		sqlDB, err := storage.DB.DB()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sqlDB.Stats().MaxOpenConnections)
+	})
+
+	t.Run("registered drivers", func(t *testing.T) {
+		assert.Equal(t, []string{"memory", "mongodb", "mysql", "postgres", "sqlite"}, Drivers())
+	})
+}
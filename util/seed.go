@@ -0,0 +1,40 @@
+package util
+
+import (
+	"errors"
+
+	"project-api/config"
+	"project-api/models"
+)
+
+//ErrAdminPassword the admin account is to be created without a password
+var ErrAdminPassword = errors.New("admin account needs a password, set auth.admin.password")
+
+//SeedAdmin create the configured admin account unless its email is
+//already registered, so a fresh database has someone to hand out roles.
+//Creating it without a password is an ErrAdminPassword
+func SeedAdmin(storage *Storage, config *config.AppConfig) error {
+	admin := config.Auth.Admin
+	if admin.Email == "" {
+		return nil
+	}
+
+	_, err := storage.UserModel.GetByEmail(admin.Email)
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return err
+	}
+	if admin.Password == "" {
+		return ErrAdminPassword
+	}
+
+	_, err = storage.UserModel.Insert(models.User{
+		Name:     admin.Name,
+		Email:    admin.Email,
+		Password: admin.Password,
+		Role:     models.RoleAdmin,
+	})
+	return err
+}
@@ -0,0 +1,49 @@
+package util
+
+import (
+	"testing"
+
+	"project-api/config"
+	"project-api/models"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedAdmin(t *testing.T) {
+	var appConfig config.AppConfig
+	appConfig.Database.Driver = "memory"
+	appConfig.Database.Name = "seed_test"
+	appConfig.Auth.Admin.Name = "Admin"
+	appConfig.Auth.Admin.Email = "admin@alterra.id"
+	appConfig.Auth.Admin.Password = "admin123"
+
+	storage, err := OpenStorage(&appConfig)
+	assert.NoError(t, err)
+
+	assert.NoError(t, SeedAdmin(storage, &appConfig))
+	assert.NoError(t, SeedAdmin(storage, &appConfig))
+
+	users, _, err := storage.UserModel.GetAll(models.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, models.RoleAdmin, users[0].Role)
+
+	_, err = storage.UserModel.Login("admin@alterra.id", "admin123")
+	assert.NoError(t, err)
+
+	t.Run("registered admin without a password", func(t *testing.T) {
+		registered := appConfig
+		registered.Auth.Admin.Password = ""
+		assert.NoError(t, SeedAdmin(storage, &registered))
+	})
+
+	t.Run("new admin without a password", func(t *testing.T) {
+		missing := appConfig
+		missing.Auth.Admin.Email = "root@alterra.id"
+		missing.Auth.Admin.Password = ""
+		assert.ErrorIs(t, SeedAdmin(storage, &missing), ErrAdminPassword)
+
+		_, err := storage.UserModel.GetByEmail("root@alterra.id")
+		assert.ErrorIs(t, err, models.ErrNotFound)
+	})
+}